@@ -0,0 +1,377 @@
+package admission
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"realy.lol/bech32encoding"
+	"realy.lol/chk"
+	"realy.lol/context"
+	"realy.lol/errorf"
+	"realy.lol/hex"
+	"realy.lol/log"
+	"realy.lol/nwc"
+	"realy.lol/realy/config"
+	"realy.lol/realy/helpers"
+)
+
+// Path is the path of the admission endpoint.
+const Path = "/admission"
+
+// Unit is the unit of the amounts of the fees.
+const Unit = "msats"
+
+// DefaultInvoiceExpiry is how many seconds an invoice for write access may be paid in.
+const DefaultInvoiceExpiry = 3600
+
+const (
+	// MaxPending is how many unpaid invoices a pubkey may have at once.
+	MaxPending = 3
+	// InvoicesPerMinute is how many invoices an address may ask for in a minute.
+	InvoicesPerMinute = 10
+	// LookupConcurrency is how many invoices Check looks up at once.
+	LookupConcurrency = 8
+)
+
+// Member is a pubkey that has paid for write access.
+type Member struct {
+	Pubkey string `json:"pubkey"`
+	// Expires is the unix timestamp of the end of the write access.
+	Expires int64 `json:"expires"`
+}
+
+// Invoice is an invoice for write access that has not been paid yet.
+type Invoice struct {
+	Pubkey      string `json:"pubkey"`
+	Invoice     string `json:"invoice"`
+	PaymentHash string `json:"payment_hash"`
+	Amount      int64  `json:"amount"`
+	Period      int64  `json:"period"`
+	ExpiresAt   int64  `json:"expires_at"`
+}
+
+// Store keeps the members and the unpaid invoices.
+type Store interface {
+	// GetMember returns the membership of a pubkey, or nil if it has none.
+	GetMember(pubkey []byte) (m *Member, err error)
+	// SetMember stores the membership of a pubkey.
+	SetMember(m *Member) (err error)
+	// Invoices returns the unpaid invoices.
+	Invoices() (ii []*Invoice, err error)
+	// SetInvoice stores an unpaid invoice.
+	SetInvoice(i *Invoice) (err error)
+	// DeleteInvoice removes an invoice once it is paid or has expired.
+	DeleteInvoice(paymentHash string) (err error)
+}
+
+// Wallet makes invoices and reports whether they are paid, as an nwc.Client does.
+type Wallet interface {
+	MakeInvoice(c context.T, amount nwc.Msat, description string,
+		expiry int) (tx *nwc.Transaction, err error)
+	LookupInvoice(c context.T, paymentHash string) (tx *nwc.Transaction, err error)
+}
+
+// A sells write access.
+type A struct {
+	Wallet Wallet
+	Store  Store
+	// Fees returns the prices of write access.
+	Fees func() []config.Fee
+	// Description is the description put in the invoices.
+	Description string
+	// mx serializes the changes to the members and invoices.
+	mx      sync.Mutex
+	limiter limiter
+}
+
+var (
+	// ErrNoFee is returned by NewInvoice if there is no fee for the requested period.
+	ErrNoFee = errors.New("no fee for the requested period")
+	// ErrTooManyInvoices is returned by NewInvoice if the pubkey already has MaxPending
+	// unpaid invoices.
+	ErrTooManyInvoices = errors.New("too many unpaid invoices")
+)
+
+// Admitted returns the end of the write access of a pubkey, and whether it is in the future.
+func (a *A) Admitted(pubkey []byte) (expires int64, ok bool, err error) {
+	var m *Member
+	if m, err = a.Store.GetMember(pubkey); err != nil || m == nil {
+		return
+	}
+	return m.Expires, m.Expires > time.Now().Unix(), nil
+}
+
+// NewInvoice makes an invoice for the fee of a period of write access for a pubkey, or returns
+// the unpaid invoice it already has for the period if there is one that has not expired.
+func (a *A) NewInvoice(c context.T, pubkey []byte, period int64) (inv *Invoice, err error) {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	var fee *config.Fee
+	for _, f := range a.Fees() {
+		if f.Period == period {
+			fee = &f
+			break
+		}
+	}
+	if fee == nil {
+		err = ErrNoFee
+		return
+	}
+	var ii []*Invoice
+	if ii, err = a.Store.Invoices(); chk.E(err) {
+		return
+	}
+	pk, now, pending := hex.Enc(pubkey), time.Now().Unix(), 0
+	for _, i := range ii {
+		if i.Pubkey != pk || i.ExpiresAt <= now {
+			continue
+		}
+		if i.Period == fee.Period && i.Amount == fee.Amount {
+			return i, nil
+		}
+		pending++
+	}
+	if pending >= MaxPending {
+		err = ErrTooManyInvoices
+		return
+	}
+	var tx *nwc.Transaction
+	if tx, err = a.Wallet.MakeInvoice(c, nwc.Msat(fee.Amount), a.Description,
+		DefaultInvoiceExpiry); err != nil {
+		return
+	}
+	if tx.PaymentHash == "" || tx.Invoice == "" {
+		err = errorf.E("wallet returned an invoice without a payment hash")
+		return
+	}
+	inv = &Invoice{Pubkey: pk, Invoice: tx.Invoice, PaymentHash: tx.PaymentHash,
+		Amount: fee.Amount, Period: fee.Period, ExpiresAt: tx.ExpiresAt}
+	if inv.ExpiresAt == 0 {
+		inv.ExpiresAt = time.Now().Unix() + DefaultInvoiceExpiry
+	}
+	if err = a.Store.SetInvoice(inv); chk.E(err) {
+		return
+	}
+	log.I.F("made invoice %s of %d msats for %s", inv.PaymentHash, inv.Amount, inv.Pubkey)
+	return
+}
+
+// admit extends the membership of the pubkey of a paid invoice by its period, from now or
+// from the end of the membership if that is later.
+//
+// This must be called with the mutex locked.
+func (a *A) admit(inv *Invoice) (err error) {
+	var pk []byte
+	if pk, err = hex.Dec(inv.Pubkey); chk.E(err) {
+		return
+	}
+	var m *Member
+	if m, err = a.Store.GetMember(pk); chk.E(err) {
+		return
+	}
+	now := time.Now().Unix()
+	if m == nil {
+		m = &Member{Pubkey: inv.Pubkey}
+	}
+	m.Expires = max(m.Expires, now) + inv.Period
+	if err = a.Store.SetMember(m); chk.E(err) {
+		return
+	}
+	log.I.F("admitted %s until %s", m.Pubkey, time.Unix(m.Expires, 0).Format(time.RFC3339))
+	return
+}
+
+// Check looks up the unpaid invoices, LookupConcurrency at a time, admitting the pubkeys of
+// those that have been paid and forgetting those that have expired.
+func (a *A) Check(c context.T) (err error) {
+	var ii []*Invoice
+	if ii, err = a.Store.Invoices(); chk.E(err) {
+		return
+	}
+	now := time.Now().Unix()
+	var g errgroup.Group
+	g.SetLimit(LookupConcurrency)
+	for _, inv := range ii {
+		if c.Err() != nil {
+			break
+		}
+		g.Go(func() error {
+			a.check(c, inv, now)
+			return nil
+		})
+	}
+	return g.Wait()
+}
+
+// check looks up an unpaid invoice, admitting its pubkey if it has been paid and forgetting it
+// if it has been paid or has expired.
+func (a *A) check(c context.T, inv *Invoice, now int64) {
+	tx, err := a.Wallet.LookupInvoice(c, inv.PaymentHash)
+	if err != nil {
+		log.W.F("looking up invoice %s: %v", inv.PaymentHash, err)
+		return
+	}
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	switch {
+	case tx.Settled():
+		if err = a.admit(inv); err != nil {
+			return
+		}
+	case inv.ExpiresAt < now:
+		log.D.F("invoice %s expired unpaid", inv.PaymentHash)
+	default:
+		return
+	}
+	chk.E(a.Store.DeleteInvoice(inv.PaymentHash))
+}
+
+// Run calls Check at an interval until the context is canceled.
+func (a *A) Run(c context.T, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-c.Done():
+			return
+		case <-t.C:
+			chk.E(a.Check(c))
+		}
+	}
+}
+
+// Fee is the price of a period of write access as shown by the admission endpoint.
+type Fee struct {
+	Amount int64  `json:"amount"`
+	Unit   string `json:"unit"`
+	Period int64  `json:"period"`
+}
+
+// Info is the answer of the admission endpoint to a GET request.
+type Info struct {
+	Fees []Fee `json:"fees"`
+	// Expires is the end of the write access of the pubkey in the query, if it has any.
+	Expires int64 `json:"expires,omitempty"`
+}
+
+// Request is the body of a POST request to the admission endpoint for an invoice.
+type Request struct {
+	// Pubkey is the npub or hex pubkey that write access is bought for.
+	Pubkey string `json:"pubkey"`
+	// Period is the period of one of the fees.
+	Period int64 `json:"period"`
+}
+
+// Register adds the admission endpoint to a ServeMux.
+func (a *A) Register(mux *http.ServeMux) { mux.HandleFunc(Path, a.ServeHTTP) }
+
+// ServeHTTP shows the fees and the membership of the pubkey in the query for GET requests,
+// and makes invoices for POST requests, of which each address may make InvoicesPerMinute.
+func (a *A) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		info := Info{Fees: []Fee{}}
+		for _, f := range a.Fees() {
+			info.Fees = append(info.Fees, Fee{Amount: f.Amount, Unit: Unit, Period: f.Period})
+		}
+		if q := r.URL.Query().Get("pubkey"); q != "" {
+			pk, err := decodePubkey(q)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if info.Expires, _, err = a.Admitted(pk); chk.E(err) {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		writeJSON(w, info)
+	case http.MethodPost:
+		if !a.limiter.allow(remoteHost(r)) {
+			http.Error(w, "too many invoice requests", http.StatusTooManyRequests)
+			return
+		}
+		var req Request
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<12)).Decode(&req); err != nil {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+		pk, err := decodePubkey(req.Pubkey)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var inv *Invoice
+		if inv, err = a.NewInvoice(r.Context(), pk, req.Period); err != nil {
+			switch {
+			case errors.Is(err, ErrNoFee):
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			case errors.Is(err, ErrTooManyInvoices):
+				http.Error(w, err.Error(), http.StatusTooManyRequests)
+				return
+			}
+			http.Error(w, "failed to make invoice: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+		writeJSON(w, inv)
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed),
+			http.StatusMethodNotAllowed)
+	}
+}
+
+// limiter counts the requests of each address in the current minute.
+type limiter struct {
+	sync.Mutex
+	minute int64
+	counts map[string]int
+}
+
+// allow returns true if an address has made fewer than InvoicesPerMinute requests in the
+// current minute, counting this one.
+func (l *limiter) allow(remote string) bool {
+	l.Lock()
+	defer l.Unlock()
+	if m := time.Now().Unix() / 60; m != l.minute || l.counts == nil {
+		l.minute, l.counts = m, make(map[string]int)
+	}
+	if l.counts[remote] >= InvoicesPerMinute {
+		return false
+	}
+	l.counts[remote]++
+	return true
+}
+
+// remoteHost returns the address of the client of a request without its port.
+func remoteHost(r *http.Request) (host string) {
+	host = helpers.GetRemoteFromReq(r)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return
+}
+
+// decodePubkey decodes an npub or hex pubkey.
+func decodePubkey(s string) (pk []byte, err error) {
+	if pk, err = bech32encoding.NpubToBytes([]byte(s)); err == nil {
+		return
+	}
+	if pk, err = hex.Dec(s); err != nil || len(pk) != 32 {
+		err = errorf.E("invalid pubkey '%s'", s)
+	}
+	return
+}
+
+// writeJSON writes a JSON response.
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	chk.E(json.NewEncoder(w).Encode(v))
+}
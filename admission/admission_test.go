@@ -0,0 +1,203 @@
+package admission
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"realy.lol/hex"
+	"realy.lol/nwc"
+	"realy.lol/realy/config"
+)
+
+type memStore struct {
+	members  map[string]Member
+	invoices map[string]Invoice
+}
+
+func (m *memStore) GetMember(pubkey []byte) (mm *Member, err error) {
+	if c, ok := m.members[hex.Enc(pubkey)]; ok {
+		mm = &c
+	}
+	return
+}
+
+func (m *memStore) SetMember(mm *Member) (err error) {
+	m.members[mm.Pubkey] = *mm
+	return
+}
+
+func (m *memStore) Invoices() (ii []*Invoice, err error) {
+	for _, i := range m.invoices {
+		c := i
+		ii = append(ii, &c)
+	}
+	return
+}
+
+func (m *memStore) SetInvoice(i *Invoice) (err error) {
+	m.invoices[i.PaymentHash] = *i
+	return
+}
+
+func (m *memStore) DeleteInvoice(paymentHash string) (err error) {
+	delete(m.invoices, paymentHash)
+	return
+}
+
+// mockWallet makes invoices whose payment hashes count up, and reports those in paid as
+// settled.
+type mockWallet struct {
+	made int
+	paid map[string]bool
+}
+
+func (w *mockWallet) MakeInvoice(c context.Context, amount nwc.Msat, description string,
+	expiry int) (tx *nwc.Transaction, err error) {
+	w.made++
+	h := make([]byte, 32)
+	h[31] = byte(w.made)
+	return &nwc.Transaction{Invoice: "lnbc1", PaymentHash: hex.Enc(h), Amount: amount,
+		ExpiresAt: time.Now().Unix() + int64(expiry)}, nil
+}
+
+func (w *mockWallet) LookupInvoice(c context.Context, paymentHash string) (tx *nwc.Transaction,
+	err error) {
+	tx = &nwc.Transaction{PaymentHash: paymentHash}
+	if w.paid[paymentHash] {
+		tx.SettledAt = time.Now().Unix()
+	}
+	return
+}
+
+const pubkey = "e8b487c079b0f67c695ae6c4c2552a47f38adfa6d0fc1f6a1b40b3aa5ec1ed4d"
+
+func TestAdmission(t *testing.T) {
+	st := &memStore{members: map[string]Member{}, invoices: map[string]Invoice{}}
+	w := &mockWallet{paid: map[string]bool{}}
+	a := &A{Wallet: w, Store: st, Fees: func() []config.Fee {
+		return []config.Fee{{Amount: 21000, Period: 3600}, {Amount: 100000, Period: 86400}}
+	}}
+	mux := http.NewServeMux()
+	a.Register(mux)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	pk, _ := hex.Dec(pubkey)
+	if _, err := a.NewInvoice(context.Background(), pk, 60); err != ErrNoFee {
+		t.Fatalf("expected ErrNoFee, got %v", err)
+	}
+	invoice := func(period int64) (inv Invoice) {
+		b, _ := json.Marshal(Request{Pubkey: pubkey, Period: period})
+		res, err := http.Post(srv.URL+Path, "application/json", bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("invoice request failed: %d", res.StatusCode)
+		}
+		if err = json.NewDecoder(res.Body).Decode(&inv); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	first, second := invoice(3600), invoice(86400)
+	if first.Amount != 21000 || second.Amount != 100000 || len(st.invoices) != 2 {
+		t.Fatalf("unexpected invoices %+v %+v", first, second)
+	}
+	if err := a.Check(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := a.Admitted(pk); ok || len(st.invoices) != 2 {
+		t.Fatal("admitted before paying")
+	}
+	w.paid[first.PaymentHash], w.paid[second.PaymentHash] = true, true
+	if err := a.Check(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	expires, ok, _ := a.Admitted(pk)
+	if !ok || len(st.invoices) != 0 {
+		t.Fatal("not admitted after paying")
+	}
+	// the periods of both payments add up.
+	if d := expires - time.Now().Unix() - 3600 - 86400; d > 1 || d < -1 {
+		t.Fatalf("membership expires %d seconds off", d)
+	}
+	res, err := http.Get(srv.URL + Path + "?pubkey=" + pubkey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	var info Info
+	if err = json.NewDecoder(res.Body).Decode(&info); err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Fees) != 2 || info.Fees[0].Unit != Unit || info.Expires != expires {
+		t.Fatalf("unexpected info %+v", info)
+	}
+	// invoices that expire unpaid are forgotten.
+	st.invoices[strings.Repeat("00", 32)] = Invoice{Pubkey: pubkey,
+		PaymentHash: strings.Repeat("00", 32), ExpiresAt: time.Now().Unix() - 1}
+	if err = a.Check(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(st.invoices) != 0 {
+		t.Fatal("expired invoice was kept")
+	}
+}
+
+func TestInvoiceLimits(t *testing.T) {
+	st := &memStore{members: map[string]Member{}, invoices: map[string]Invoice{}}
+	w := &mockWallet{paid: map[string]bool{}}
+	a := &A{Wallet: w, Store: st, Fees: func() []config.Fee {
+		return []config.Fee{{Amount: 1000, Period: 60}, {Amount: 2000, Period: 120},
+			{Amount: 3000, Period: 180}, {Amount: 4000, Period: 240}}
+	}}
+	srv := httptest.NewServer(a)
+	defer srv.Close()
+	pk, _ := hex.Dec(pubkey)
+	c := context.Background()
+	first, err := a.NewInvoice(c, pk, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// an unpaid invoice for the same fee is reused.
+	again, err := a.NewInvoice(c, pk, 60)
+	if err != nil || again.PaymentHash != first.PaymentHash || w.made != 1 {
+		t.Fatalf("unpaid invoice was not reused: %v", err)
+	}
+	for _, period := range []int64{120, 180} {
+		if _, err = a.NewInvoice(c, pk, period); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err = a.NewInvoice(c, pk, 240); err != ErrTooManyInvoices {
+		t.Fatalf("expected ErrTooManyInvoices, got %v", err)
+	}
+	// expired invoices do not count.
+	inv := st.invoices[first.PaymentHash]
+	inv.ExpiresAt = time.Now().Unix() - 1
+	st.invoices[first.PaymentHash] = inv
+	if _, err = a.NewInvoice(c, pk, 240); err != nil {
+		t.Fatal(err)
+	}
+	// each address may only ask for InvoicesPerMinute invoices.
+	b, _ := json.Marshal(Request{Pubkey: pubkey, Period: 60})
+	var limited bool
+	for range InvoicesPerMinute + 1 {
+		res, err := http.Post(srv.URL+Path, "application/json", bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		limited = res.StatusCode == http.StatusTooManyRequests
+	}
+	if !limited {
+		t.Fatal("invoice requests were not rate limited")
+	}
+}
@@ -0,0 +1,12 @@
+// Package admission sells time limited write access to a relay, with invoices made by a
+// Nostr Wallet Connect wallet.
+//
+// A user asks the admission endpoint for an invoice for one of the fees, and once the wallet
+// reports that it is settled the user's pubkey is added to the members, or has its membership
+// extended, for the period the fee pays for. Unpaid invoices are looked up at an interval
+// until they are settled or expire.
+//
+// The endpoint is not authenticated, so a pubkey is given the unpaid invoice it already has
+// for a fee rather than a new one, may have at most MaxPending unpaid invoices, and each
+// address may only ask for InvoicesPerMinute invoices.
+package admission
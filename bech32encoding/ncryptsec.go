@@ -0,0 +1,128 @@
+package bech32encoding
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/text/unicode/norm"
+
+	"realy.lol/chk"
+	"realy.lol/ec/bech32"
+	"realy.lol/ec/secp256k1"
+	"realy.lol/errorf"
+)
+
+// EncryptedSecHRP is the Human Readable Prefix (HRP) for a NIP-49 password encrypted secret
+// key - ncryptsec
+var EncryptedSecHRP = []byte("ncryptsec")
+
+const (
+	// NcryptsecVersion is the version byte of the NIP-49 encrypted secret key format.
+	NcryptsecVersion = 0x02
+	// DefaultLogN is the base 2 logarithm of the scrypt rounds used when encrypting secret
+	// keys, which takes about 64MiB of memory and a fraction of a second.
+	DefaultLogN = 16
+	// ncryptsecLen is the length of the decoded ncryptsec: version, log N, salt, nonce, key
+	// security and the encrypted key with its tag.
+	ncryptsecLen = 1 + 1 + 16 + 24 + 1 + secp256k1.SecKeyBytesLen + chacha20poly1305.Overhead
+)
+
+// KeySecurity records in a NIP-49 encrypted secret key whether the key has ever been
+// handled insecurely, such as unencrypted in a clipboard or an env file.
+type KeySecurity byte
+
+const (
+	// KeyInsecure is a key known to have been handled insecurely.
+	KeyInsecure KeySecurity = iota
+	// KeySecure is a key known not to have been handled insecurely.
+	KeySecure
+	// KeySecurityUnknown is a key it is not known how it was handled.
+	KeySecurityUnknown
+)
+
+// ncryptsecKey derives the symmetric key of a NIP-49 encrypted secret key from a password,
+// which is normalized to NFKC so it can be typed the same on any device.
+func ncryptsecKey(password []byte, salt []byte, logN uint8) (key []byte, err error) {
+	if logN > 30 {
+		err = errorf.E("scrypt log N of %d is too large", logN)
+		return
+	}
+	return scrypt.Key(norm.NFKC.Bytes(password), salt, 1<<logN, 8, 1, 32)
+}
+
+// SecretKeyToNcryptsec encrypts a secret key with a password as a NIP-49 ncryptsec, with 2^logN
+// scrypt rounds.
+func SecretKeyToNcryptsec(sec, password []byte, logN uint8,
+	security KeySecurity) (encoded []byte, err error) {
+	if len(sec) != secp256k1.SecKeyBytesLen {
+		err = errorf.E("secret key is %d bytes, must be %d", len(sec),
+			secp256k1.SecKeyBytesLen)
+		return
+	}
+	b := make([]byte, 2, ncryptsecLen)
+	b[0], b[1] = NcryptsecVersion, logN
+	b = append(b, make([]byte, 16+24)...)
+	salt, nonce := b[2:18], b[18:42]
+	if _, err = rand.Read(b[2:42]); chk.E(err) {
+		return
+	}
+	b = append(b, byte(security))
+	var key []byte
+	if key, err = ncryptsecKey(password, salt, logN); chk.E(err) {
+		return
+	}
+	var aead cipher.AEAD
+	if aead, err = chacha20poly1305.NewX(key); chk.E(err) {
+		return
+	}
+	b = aead.Seal(b, nonce, sec, b[42:43])
+	var b5 []byte
+	if b5, err = ConvertForBech32(b); chk.E(err) {
+		return
+	}
+	return bech32.Encode(EncryptedSecHRP, b5)
+}
+
+// NcryptsecToBytes decrypts a NIP-49 ncryptsec with a password, returning the secret key and
+// what is known of how it has been handled.
+func NcryptsecToBytes(encoded, password []byte) (sec []byte, security KeySecurity,
+	err error) {
+	var b5, hrp []byte
+	if hrp, b5, err = bech32.DecodeNoLimit(encoded); chk.E(err) {
+		return
+	}
+	if !bytes.Equal(hrp, EncryptedSecHRP) {
+		err = errorf.E("wrong human readable part, got '%s' want '%s'", hrp,
+			EncryptedSecHRP)
+		return
+	}
+	var b []byte
+	if b, err = bech32.ConvertBits(b5, 5, 8, false); chk.E(err) {
+		return
+	}
+	if len(b) != ncryptsecLen {
+		err = errorf.E("ncryptsec is %d bytes, must be %d", len(b), ncryptsecLen)
+		return
+	}
+	if b[0] != NcryptsecVersion {
+		err = errorf.E("unknown ncryptsec version %d", b[0])
+		return
+	}
+	var key []byte
+	if key, err = ncryptsecKey(password, b[2:18], b[1]); chk.E(err) {
+		return
+	}
+	var aead cipher.AEAD
+	if aead, err = chacha20poly1305.NewX(key); chk.E(err) {
+		return
+	}
+	if sec, err = aead.Open(nil, b[18:42], b[43:], b[42:43]); err != nil {
+		err = errorf.E("wrong password or corrupted ncryptsec")
+		return
+	}
+	security = KeySecurity(b[42])
+	return
+}
@@ -0,0 +1,49 @@
+package bech32encoding
+
+import (
+	"bytes"
+	"testing"
+
+	"realy.lol/hex"
+)
+
+func TestNcryptsecToBytes(t *testing.T) {
+	// the test vector of NIP-49.
+	sec, security, err := NcryptsecToBytes([]byte("ncryptsec1qgg9947rlpvqu76pj5ecreduf9jxhselq2nae2kghhvd5g7dgjtcxfqtd67p9m0w57lspw8gsq6yphnm8623nsl8xn9j4jdzz84zm3frztj3z7s35vpzmqf6ksu8r89qk5z2zxfmu5gv8th8wclt0h4p"),
+		[]byte("nostr"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.Enc(sec) != "3501454135014541350145413501453fefb02227e449e57cf4d3a3ce05378683" {
+		t.Fatalf("decrypted %0x", sec)
+	}
+	if security != KeyInsecure {
+		t.Fatalf("key security %d", security)
+	}
+}
+
+func TestSecretKeyToNcryptsec(t *testing.T) {
+	sec, _ := hex.Dec("3501454135014541350145413501453fefb02227e449e57cf4d3a3ce05378683")
+	// the password is normalized, so the composed and decomposed forms are the same.
+	enc, err := SecretKeyToNcryptsec(sec, []byte("\u00c5"), 10, KeySecure)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(enc, []byte("ncryptsec1")) {
+		t.Fatalf("encoded as %s", enc)
+	}
+	dec, security, err := NcryptsecToBytes(enc, []byte("A\u030a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dec, sec) || security != KeySecure {
+		t.Fatalf("decrypted %0x %d", dec, security)
+	}
+	if _, _, err = NcryptsecToBytes(enc, []byte("wrong")); err == nil {
+		t.Fatal("decrypted with the wrong password")
+	}
+	enc[20] ^= 1
+	if _, _, err = NcryptsecToBytes(enc, []byte("\u00c5")); err == nil {
+		t.Fatal("decoded a corrupted ncryptsec")
+	}
+}
@@ -0,0 +1,118 @@
+package blossom
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"realy.lol/errorf"
+	"realy.lol/event"
+	"realy.lol/httpauth"
+	"realy.lol/kind"
+)
+
+// The verbs that an authorization event permits, in its t tag.
+const (
+	VerbGet    = "get"
+	VerbUpload = "upload"
+	VerbList   = "list"
+	VerbDelete = "delete"
+)
+
+// ErrMissingAuth is returned by CheckAuth when a request has no authorization.
+var ErrMissingAuth = errors.New("missing authorization")
+
+// Auth is a validated authorization event.
+type Auth struct {
+	Pubkey []byte
+	// Hashes are the hex encoded blob hashes in the x tags the request is limited to.
+	Hashes []string
+}
+
+// Allows returns true if the authorization permits a request for a blob, which is so if it
+// is not limited to particular blobs or the blob is one of them.
+func (a *Auth) Allows(sha string) bool {
+	if len(a.Hashes) == 0 {
+		return true
+	}
+	for _, h := range a.Hashes {
+		if h == sha {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckAuth validates the kind 24242 authorization event of a request for a verb, and
+// returns ErrMissingAuth if there is none.
+func CheckAuth(r *http.Request, verb string) (a *Auth, err error) {
+	val := r.Header.Get(httpauth.HeaderKey)
+	if val == "" {
+		err = ErrMissingAuth
+		return
+	}
+	split := strings.Split(val, " ")
+	if len(split) != 2 || split[0] != httpauth.NIP98Prefix {
+		err = errorf.E("invalid '%s' value: '%s'", httpauth.HeaderKey, val)
+		return
+	}
+	var evb []byte
+	// clients use both the standard and the URL safe base64 alphabets.
+	if evb, err = base64.StdEncoding.DecodeString(split[1]); err != nil {
+		if evb, err = base64.URLEncoding.DecodeString(split[1]); err != nil {
+			err = errorf.E("invalid base64 in authorization: %v", err)
+			return
+		}
+	}
+	ev := event.New()
+	var rem []byte
+	if rem, err = ev.Unmarshal(evb); err != nil {
+		err = errorf.E("invalid authorization event: %v", err)
+		return
+	}
+	if len(rem) > 0 {
+		err = errorf.E("extraneous data after authorization event")
+		return
+	}
+	if !ev.Kind.Equal(kind.BlossomAuth) {
+		err = errorf.E("invalid kind %d in authorization event, require %d", ev.Kind.K,
+			kind.BlossomAuth.K)
+		return
+	}
+	now := time.Now().Unix()
+	if ev.CreatedAt.I64() > now+60 {
+		err = errorf.E("authorization event created_at is in the future")
+		return
+	}
+	var t, expiration string
+	a = &Auth{}
+	for _, tt := range ev.Tags.ToSliceOfTags() {
+		switch tt.S(0) {
+		case "t":
+			t = tt.S(1)
+		case "expiration":
+			expiration = tt.S(1)
+		case "x":
+			a.Hashes = append(a.Hashes, strings.ToLower(tt.S(1)))
+		}
+	}
+	if t != verb {
+		err = errorf.E("authorization event is for %q, not %q", t, verb)
+		return
+	}
+	var exp int64
+	if exp, err = strconv.ParseInt(expiration, 10, 64); err != nil || exp <= now {
+		err = errorf.E("authorization event has expired or has no expiration")
+		return
+	}
+	var valid bool
+	if valid, err = ev.Verify(); err != nil || !valid {
+		err = errorf.E("invalid signature on authorization event")
+		return
+	}
+	a.Pubkey = ev.Pubkey
+	return
+}
@@ -0,0 +1,484 @@
+package blossom
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"realy.lol/chk"
+	"realy.lol/hex"
+	"realy.lol/log"
+	"realy.lol/netaddr"
+	"realy.lol/sha256"
+)
+
+// Descriptor describes a stored blob to clients.
+type Descriptor struct {
+	URL      string `json:"url"`
+	Sha256   string `json:"sha256"`
+	Size     int64  `json:"size"`
+	Type     string `json:"type"`
+	Uploaded int64  `json:"uploaded"`
+}
+
+// Blob is the record of a stored blob.
+type Blob struct {
+	Sha256   string `json:"sha256"`
+	Size     int64  `json:"size"`
+	Type     string `json:"type"`
+	Uploaded int64  `json:"uploaded"`
+	// Owners are the hex encoded pubkeys that uploaded the blob.
+	Owners []string `json:"owners"`
+}
+
+// Index stores the records of blobs.
+type Index interface {
+	// GetBlob returns the record of a blob, or nil if it is not stored.
+	GetBlob(sha []byte) (b *Blob, err error)
+	// PutBlob stores the record of a blob.
+	PutBlob(b *Blob) (err error)
+	// DeleteBlob removes the record of a blob.
+	DeleteBlob(sha []byte) (err error)
+	// BlobsOf returns the records of the blobs a pubkey has uploaded.
+	BlobsOf(pubkey []byte) (bb []*Blob, err error)
+}
+
+// S is a Blossom server.
+type S struct {
+	Index Index
+	// Dir is the directory the blobs are stored in.
+	Dir string
+	// MaxSize is the largest blob that may be uploaded in bytes, 0 is unlimited.
+	MaxSize func() int64
+	// Quota is the total size of the blobs a pubkey may store in bytes, 0 is unlimited.
+	Quota func(pubkey []byte) int64
+	// MayUpload returns true if a pubkey is permitted to upload blobs.
+	MayUpload func(pubkey []byte) bool
+	// mx serializes the changes to the records of blobs, so concurrent uploads and deletions
+	// of a blob, and uploads by a pubkey checked against its quota, do not overwrite each
+	// other.
+	mx sync.Mutex
+}
+
+// Register adds the Blossom endpoints to a ServeMux.
+func (s *S) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/upload", s.handleUpload)
+	mux.HandleFunc("/mirror", s.handleMirror)
+	mux.HandleFunc("/list/{pubkey}", s.handleList)
+	mux.HandleFunc("/{name}", s.handleBlob)
+}
+
+// reject writes an error status with the reason in the X-Reason header.
+func reject(w http.ResponseWriter, status int, reason string) {
+	w.Header().Set("X-Reason", reason)
+	http.Error(w, reason, status)
+}
+
+// path returns the file path of a blob.
+func (s *S) path(sha string) string { return filepath.Join(s.Dir, sha[:2], sha) }
+
+// descriptor returns the descriptor of a blob as served by the host of a request.
+func descriptor(r *http.Request, b *Blob) (d Descriptor) {
+	proto := "http"
+	if r.TLS != nil {
+		proto = "https"
+	}
+	if p := r.Header.Get("X-Forwarded-Proto"); p != "" {
+		proto = p
+	}
+	u := proto + "://" + r.Host + "/" + b.Sha256
+	if exts, _ := mime.ExtensionsByType(b.Type); len(exts) > 0 {
+		u += exts[0]
+	}
+	return Descriptor{URL: u, Sha256: b.Sha256, Size: b.Size, Type: b.Type,
+		Uploaded: b.Uploaded}
+}
+
+// writeJSON writes a JSON response.
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	chk.E(json.NewEncoder(w).Encode(v))
+}
+
+// ParseName returns the hash of a blob from its path, which may have a file extension.
+func ParseName(name string) (sha string, ok bool) {
+	sha = strings.ToLower(strings.SplitN(name, ".", 2)[0])
+	if b, err := hex.Dec(sha); err != nil || len(b) != sha256.Size {
+		return
+	}
+	return sha, true
+}
+
+// handleBlob serves GET, HEAD and DELETE requests for a blob.
+func (s *S) handleBlob(w http.ResponseWriter, r *http.Request) {
+	sha, ok := ParseName(r.PathValue("name"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		s.get(w, r, sha)
+	case http.MethodDelete:
+		s.delete(w, r, sha)
+	default:
+		reject(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// get serves the content of a blob.
+func (s *S) get(w http.ResponseWriter, r *http.Request, sha string) {
+	b, f, err := s.Open(sha)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			reject(w, http.StatusNotFound, err.Error())
+			return
+		}
+		reject(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer f.Close()
+	w.Header().Set("Content-Type", b.Type)
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	http.ServeContent(w, r, "", time.Unix(b.Uploaded, 0), f)
+}
+
+// delete removes a pubkey from the owners of a blob, and the blob if it was the last.
+func (s *S) delete(w http.ResponseWriter, r *http.Request, sha string) {
+	a, err := CheckAuth(r, VerbDelete)
+	if err != nil {
+		reject(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if len(a.Hashes) == 0 || !a.Allows(sha) {
+		reject(w, http.StatusForbidden, ErrNotNamed.Error())
+		return
+	}
+	if err = s.Remove(a.Pubkey, sha); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			reject(w, http.StatusNotFound, err.Error())
+			return
+		}
+		reject(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleList serves the descriptors of the blobs uploaded by a pubkey, optionally limited
+// to those uploaded between the since and until query parameters.
+func (s *S) handleList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		reject(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	pk, err := hex.Dec(r.PathValue("pubkey"))
+	if err != nil || len(pk) != 32 {
+		reject(w, http.StatusBadRequest, "invalid pubkey")
+		return
+	}
+	since, _ := strconv.ParseInt(r.URL.Query().Get("since"), 10, 64)
+	until, _ := strconv.ParseInt(r.URL.Query().Get("until"), 10, 64)
+	var bb []*Blob
+	if bb, err = s.Index.BlobsOf(pk); chk.E(err) {
+		reject(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	dd := []Descriptor{}
+	for _, b := range bb {
+		if (since > 0 && b.Uploaded < since) || (until > 0 && b.Uploaded > until) {
+			continue
+		}
+		dd = append(dd, descriptor(r, b))
+	}
+	slices.SortFunc(dd, func(a, b Descriptor) int { return int(b.Uploaded - a.Uploaded) })
+	writeJSON(w, dd)
+}
+
+// handleUpload stores a blob uploaded with PUT.
+func (s *S) handleUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		reject(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	a, err := CheckAuth(r, VerbUpload)
+	if err != nil {
+		reject(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if s.MayUpload != nil && !s.MayUpload(a.Pubkey) {
+		reject(w, http.StatusForbidden, "pubkey may not upload to this server")
+		return
+	}
+	s.store(w, r, a, r.Body, r.ContentLength, r.Header.Get("Content-Type"))
+}
+
+// MirrorRequest is the body of a request to mirror a blob from another server.
+type MirrorRequest struct {
+	URL string `json:"url"`
+}
+
+// handleMirror stores a blob downloaded from another server, as requested with PUT /mirror.
+func (s *S) handleMirror(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		reject(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	a, err := CheckAuth(r, VerbUpload)
+	if err != nil {
+		reject(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if s.MayUpload != nil && !s.MayUpload(a.Pubkey) {
+		reject(w, http.StatusForbidden, "pubkey may not upload to this server")
+		return
+	}
+	// the hash is known in advance, so the authorization must name it.
+	if len(a.Hashes) == 0 {
+		reject(w, http.StatusForbidden, ErrNotNamed.Error())
+		return
+	}
+	var req MirrorRequest
+	if err = json.NewDecoder(io.LimitReader(r.Body, 1<<12)).Decode(&req); err != nil ||
+		(!strings.HasPrefix(req.URL, "https://") && !strings.HasPrefix(req.URL, "http://")) {
+		reject(w, http.StatusBadRequest, "invalid mirror request")
+		return
+	}
+	var res *http.Response
+	if res, err = MirrorClient.Get(req.URL); err != nil {
+		reject(w, http.StatusBadGateway, "fetching blob: "+err.Error())
+		return
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		reject(w, http.StatusBadGateway, "fetching blob: "+res.Status)
+		return
+	}
+	s.store(w, r, a, res.Body, res.ContentLength, res.Header.Get("Content-Type"))
+}
+
+// MirrorClient is the HTTP client that mirrored blobs are downloaded with, which only
+// connects to public addresses, so a mirror request cannot reach the server's own host or
+// private network.
+var MirrorClient = &http.Client{Timeout: 5 * time.Minute, Transport: &http.Transport{
+	DialContext: (&net.Dialer{Timeout: 30 * time.Second,
+		Control: netaddr.Control}).DialContext,
+	TLSHandshakeTimeout: 10 * time.Second,
+}}
+
+// store receives a blob and adds the authorized pubkey to its owners.
+func (s *S) store(w http.ResponseWriter, r *http.Request, a *Auth, body io.Reader,
+	length int64, declared string) {
+	b, err := s.Put(a.Pubkey, body, length, declared, a.Allows)
+	switch {
+	case errors.Is(err, ErrTooLarge), errors.Is(err, ErrQuotaExceeded):
+		reject(w, http.StatusRequestEntityTooLarge, err.Error())
+		return
+	case errors.Is(err, ErrNotNamed):
+		reject(w, http.StatusForbidden, err.Error())
+		return
+	case err != nil:
+		reject(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, descriptor(r, b))
+}
+
+var (
+	// ErrNotFound is returned when a blob is not stored, or not owned by a pubkey.
+	ErrNotFound = errors.New("blob not found")
+	// ErrTooLarge is returned by Put when a blob is larger than the maximum size.
+	ErrTooLarge = errors.New("blob is too large")
+	// ErrQuotaExceeded is returned by Put when a blob would take a pubkey over its quota.
+	ErrQuotaExceeded = errors.New("storage quota exceeded")
+	// ErrNotNamed is returned by Put when the blob is not one an authorization permits.
+	ErrNotNamed = errors.New("authorization does not name the blob")
+)
+
+// Open returns the record of a blob and its content, which the caller must close.
+func (s *S) Open(sha string) (b *Blob, f *os.File, err error) {
+	if b, err = s.Index.GetBlob(hexBytes(sha)); chk.E(err) {
+		return
+	}
+	if b == nil {
+		err = ErrNotFound
+		return
+	}
+	if f, err = os.Open(s.path(sha)); chk.E(err) {
+		err = ErrNotFound
+	}
+	return
+}
+
+// Put receives a blob and adds a pubkey to its owners, storing it if it is new. If allow is
+// not nil the blob is only stored if allow returns true for its hash. A pubkey that already
+// owns the blob gets its existing record.
+func (s *S) Put(pubkey []byte, body io.Reader, length int64, declared string,
+	allow func(sha string) bool) (b *Blob, err error) {
+
+	var maxSize int64
+	if s.MaxSize != nil {
+		maxSize = s.MaxSize()
+	}
+	if maxSize > 0 && length > maxSize {
+		err = ErrTooLarge
+		return
+	}
+	var tmp string
+	if b, tmp, err = s.receive(body, maxSize, declared); err != nil {
+		return
+	}
+	defer os.Remove(tmp)
+	if allow != nil && !allow(b.Sha256) {
+		err = ErrNotNamed
+		return
+	}
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	var existing *Blob
+	if existing, err = s.Index.GetBlob(hexBytes(b.Sha256)); chk.E(err) {
+		return
+	}
+	pk := hex.Enc(pubkey)
+	if existing != nil {
+		if slices.Contains(existing.Owners, pk) {
+			b = existing
+			return
+		}
+		b = existing
+	}
+	if err = s.checkQuota(pubkey, b.Size); err != nil {
+		return
+	}
+	if existing == nil {
+		p := s.path(b.Sha256)
+		if err = os.MkdirAll(filepath.Dir(p), 0755); chk.E(err) {
+			return
+		}
+		if err = os.Rename(tmp, p); chk.E(err) {
+			return
+		}
+	}
+	b.Owners = append(b.Owners, pk)
+	if err = s.Index.PutBlob(b); chk.E(err) {
+		return
+	}
+	log.I.F("stored blob %s of %d bytes for %s", b.Sha256, b.Size, pk)
+	return
+}
+
+// Remove removes a pubkey from the owners of a blob, and the blob if it was the last.
+func (s *S) Remove(pubkey []byte, sha string) (err error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	var b *Blob
+	if b, err = s.Index.GetBlob(hexBytes(sha)); chk.E(err) {
+		return
+	}
+	pk := hex.Enc(pubkey)
+	if b == nil || !slices.Contains(b.Owners, pk) {
+		err = ErrNotFound
+		return
+	}
+	b.Owners = slices.DeleteFunc(b.Owners, func(o string) bool { return o == pk })
+	if len(b.Owners) > 0 {
+		err = s.Index.PutBlob(b)
+		chk.E(err)
+		return
+	}
+	log.I.F("deleting blob %s", sha)
+	if err = s.Index.DeleteBlob(hexBytes(sha)); chk.E(err) {
+		return
+	}
+	chk.E(os.Remove(s.path(sha)))
+	return
+}
+
+// receive writes an uploaded blob to a temporary file while hashing it, and detects its
+// type from its content, using the declared type only if the content is not recognised.
+func (s *S) receive(body io.Reader, maxSize int64, declared string) (b *Blob, tmp string,
+	err error) {
+	if err = os.MkdirAll(s.Dir, 0755); chk.E(err) {
+		return
+	}
+	var f *os.File
+	if f, err = os.CreateTemp(s.Dir, "upload-*"); chk.E(err) {
+		return
+	}
+	tmp = f.Name()
+	defer func() {
+		chk.E(f.Close())
+		if err != nil {
+			chk.E(os.Remove(tmp))
+		}
+	}()
+	h := sha256.New()
+	if maxSize > 0 {
+		body = io.LimitReader(body, maxSize+1)
+	}
+	sniff := make([]byte, 512)
+	var n int
+	if n, err = io.ReadFull(body, sniff); err != nil && err != io.ErrUnexpectedEOF &&
+		err != io.EOF {
+		return
+	}
+	sniff = sniff[:n]
+	var size int64
+	if size, err = io.Copy(io.MultiWriter(f, h), io.MultiReader(bytes.NewReader(sniff),
+		body)); err != nil {
+		return
+	}
+	if maxSize > 0 && size > maxSize {
+		err = ErrTooLarge
+		return
+	}
+	b = &Blob{Sha256: hex.Enc(h.Sum(nil)), Size: size, Uploaded: time.Now().Unix(),
+		Type: http.DetectContentType(sniff)}
+	if b.Type == "application/octet-stream" || strings.HasPrefix(b.Type, "text/plain") {
+		if mt, _, e := mime.ParseMediaType(declared); e == nil && mt != "" {
+			b.Type = mt
+		}
+	}
+	return
+}
+
+// checkQuota returns an error if storing a blob would take a pubkey over its quota.
+//
+// This must be called with the mutex locked.
+func (s *S) checkQuota(pubkey []byte, size int64) (err error) {
+	if s.Quota == nil {
+		return
+	}
+	quota := s.Quota(pubkey)
+	if quota <= 0 {
+		return
+	}
+	var bb []*Blob
+	if bb, err = s.Index.BlobsOf(pubkey); chk.E(err) {
+		return
+	}
+	used := size
+	for _, b := range bb {
+		used += b.Size
+	}
+	if used > quota {
+		err = ErrQuotaExceeded
+	}
+	return
+}
+
+func hexBytes(sha string) (b []byte) {
+	b, _ = hex.Dec(sha)
+	return
+}
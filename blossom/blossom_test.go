@@ -0,0 +1,225 @@
+package blossom
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"realy.lol/event"
+	"realy.lol/hex"
+	"realy.lol/kind"
+	"realy.lol/p256k"
+	"realy.lol/sha256"
+	"realy.lol/tag"
+	"realy.lol/tags"
+	"realy.lol/timestamp"
+)
+
+type memIndex struct {
+	sync.Mutex
+	blobs map[string]Blob
+}
+
+func (m *memIndex) GetBlob(sha []byte) (b *Blob, err error) {
+	m.Lock()
+	defer m.Unlock()
+	if c, ok := m.blobs[hex.Enc(sha)]; ok {
+		b = &c
+	}
+	return
+}
+
+func (m *memIndex) PutBlob(b *Blob) (err error) {
+	m.Lock()
+	defer m.Unlock()
+	c := *b
+	c.Owners = append([]string{}, b.Owners...)
+	m.blobs[b.Sha256] = c
+	return
+}
+
+func (m *memIndex) DeleteBlob(sha []byte) (err error) {
+	m.Lock()
+	defer m.Unlock()
+	delete(m.blobs, hex.Enc(sha))
+	return
+}
+
+func (m *memIndex) BlobsOf(pubkey []byte) (bb []*Blob, err error) {
+	m.Lock()
+	defer m.Unlock()
+	for _, b := range m.blobs {
+		for _, o := range b.Owners {
+			if o == hex.Enc(pubkey) {
+				c := b
+				bb = append(bb, &c)
+			}
+		}
+	}
+	return
+}
+
+func authHeader(t *testing.T, sign *p256k.Signer, verb string, shas ...string) string {
+	tt := []*tag.T{tag.New("t", verb),
+		tag.New("expiration", strconv.FormatInt(time.Now().Unix()+60, 10))}
+	for _, sha := range shas {
+		tt = append(tt, tag.New("x", sha))
+	}
+	ev := &event.T{CreatedAt: timestamp.Now(), Kind: kind.BlossomAuth, Tags: tags.New(tt...)}
+	if err := ev.Sign(sign); err != nil {
+		t.Fatal(err)
+	}
+	return "Nostr " + base64.StdEncoding.EncodeToString(ev.Serialize())
+}
+
+func do(t *testing.T, method, url, auth string, body []byte) (res *http.Response, b []byte) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	if res, err = http.DefaultClient.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	b, _ = io.ReadAll(res.Body)
+	return
+}
+
+func TestServer(t *testing.T) {
+	alice, bob := &p256k.Signer{}, &p256k.Signer{}
+	for _, s := range []*p256k.Signer{alice, bob} {
+		if err := s.Generate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := &S{Index: &memIndex{blobs: make(map[string]Blob)}, Dir: t.TempDir(),
+		Quota: func([]byte) int64 { return 1000 }}
+	mux := http.NewServeMux()
+	s.Register(mux)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	png := append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 100)...)
+	sum := sha256.Sum256(png)
+	sha := hex.Enc(sum[:])
+	if res, _ := do(t, http.MethodPut, srv.URL+"/upload", "", png); res.StatusCode !=
+		http.StatusUnauthorized {
+		t.Fatalf("upload without authorization: %s", res.Status)
+	}
+	if res, _ := do(t, http.MethodPut, srv.URL+"/upload", authHeader(t, alice, VerbUpload,
+		hex.Enc(make([]byte, 32))), png); res.StatusCode != http.StatusForbidden {
+		t.Fatalf("upload of a blob not named in the authorization: %s", res.Status)
+	}
+	res, b := do(t, http.MethodPut, srv.URL+"/upload", authHeader(t, alice, VerbUpload, sha), png)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("upload: %s %s", res.Status, b)
+	}
+	var d Descriptor
+	if err := json.Unmarshal(b, &d); err != nil {
+		t.Fatal(err)
+	}
+	if d.Sha256 != sha || d.Size != int64(len(png)) || d.Type != "image/png" ||
+		d.URL != srv.URL+"/"+sha+".png" {
+		t.Fatalf("unexpected descriptor %+v", d)
+	}
+	if res, b = do(t, http.MethodGet, d.URL, "", nil); res.StatusCode != http.StatusOK ||
+		!bytes.Equal(b, png) || res.Header.Get("Content-Type") != "image/png" {
+		t.Fatalf("get: %s %d bytes", res.Status, len(b))
+	}
+	if res, _ = do(t, http.MethodHead, srv.URL+"/"+sha, "", nil); res.StatusCode != http.StatusOK ||
+		res.ContentLength != int64(len(png)) {
+		t.Fatalf("head: %s %d", res.Status, res.ContentLength)
+	}
+	// bob's quota does not have room for a second copy of the blob on top of this one.
+	big := bytes.Repeat([]byte{'a'}, 950)
+	bigSum := sha256.Sum256(big)
+	if res, b = do(t, http.MethodPut, srv.URL+"/upload", authHeader(t, bob, VerbUpload),
+		big); res.StatusCode != http.StatusOK {
+		t.Fatalf("upload: %s %s", res.Status, b)
+	}
+	if res, _ = do(t, http.MethodPut, srv.URL+"/upload", authHeader(t, bob, VerbUpload),
+		png); res.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("upload over quota: %s", res.Status)
+	}
+	var list []Descriptor
+	res, b = do(t, http.MethodGet, srv.URL+"/list/"+hex.Enc(bob.Pub()), "", nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("list: %s", res.Status)
+	}
+	if err := json.Unmarshal(b, &list); err != nil || len(list) != 1 ||
+		list[0].Sha256 != hex.Enc(bigSum[:]) || list[0].Type != "text/plain; charset=utf-8" {
+		t.Fatalf("unexpected list %s", b)
+	}
+	// only owners may delete, and the blob is gone when the last owner has.
+	if res, _ = do(t, http.MethodDelete, srv.URL+"/"+sha, authHeader(t, bob, VerbDelete, sha),
+		nil); res.StatusCode != http.StatusNotFound {
+		t.Fatalf("delete by a non owner: %s", res.Status)
+	}
+	if res, _ = do(t, http.MethodDelete, srv.URL+"/"+sha, authHeader(t, alice, VerbDelete, sha),
+		nil); res.StatusCode != http.StatusOK {
+		t.Fatalf("delete: %s", res.Status)
+	}
+	if res, _ = do(t, http.MethodGet, srv.URL+"/"+sha, "", nil); res.StatusCode !=
+		http.StatusNotFound {
+		t.Fatalf("get deleted blob: %s", res.Status)
+	}
+}
+
+func TestConcurrentQuota(t *testing.T) {
+	alice := &p256k.Signer{}
+	if err := alice.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	s := &S{Index: &memIndex{blobs: make(map[string]Blob)}, Dir: t.TempDir(),
+		Quota: func([]byte) int64 { return 1000 }}
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			blob := bytes.Repeat([]byte{byte('a' + i)}, 300)
+			s.Put(alice.Pub(), bytes.NewReader(blob), int64(len(blob)), "", nil)
+		}()
+	}
+	wg.Wait()
+	bb, err := s.Index.BlobsOf(alice.Pub())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bb) != 3 {
+		t.Fatalf("expected 3 blobs of 300 bytes within a quota of 1000, stored %d", len(bb))
+	}
+}
+
+func TestMirrorPrivate(t *testing.T) {
+	alice := &p256k.Signer{}
+	if err := alice.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	s := &S{Index: &memIndex{blobs: make(map[string]Blob)}, Dir: t.TempDir()}
+	mux := http.NewServeMux()
+	s.Register(mux)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	blob := []byte("private")
+	sum := sha256.Sum256(blob)
+	sha := hex.Enc(sum[:])
+	if res, b := do(t, http.MethodPut, srv.URL+"/upload", authHeader(t, alice, VerbUpload,
+		sha), blob); res.StatusCode != http.StatusOK {
+		t.Fatalf("upload: %s %s", res.Status, b)
+	}
+	body, _ := json.Marshal(MirrorRequest{URL: srv.URL + "/" + sha})
+	if res, _ := do(t, http.MethodPut, srv.URL+"/mirror", authHeader(t, alice, VerbUpload,
+		sha), body); res.StatusCode != http.StatusBadGateway {
+		t.Fatalf("mirror from a loopback address: %s", res.Status)
+	}
+}
@@ -0,0 +1,10 @@
+// Package blossom is a Blossom blob server, implementing BUD-01 (retrieving blobs), BUD-02
+// (uploading, listing and deleting blobs) and BUD-04 (mirroring blobs from other servers).
+//
+// Blobs are stored content addressed by their SHA-256 hash in a directory on local disk, and
+// an Index keeps their descriptors and the pubkeys that uploaded them. A blob is removed when
+// the last of its owners deletes it.
+//
+// Requests that change or list blobs are authorized with a kind 24242 event in the
+// Authorization header, encoded in base64 after the Nostr scheme as with NIP-98.
+package blossom
@@ -0,0 +1,451 @@
+package bolt11
+
+import (
+	"bytes"
+	"math/bits"
+	"strconv"
+	"unicode/utf8"
+
+	"realy.lol/chk"
+	"realy.lol/ec"
+	"realy.lol/ec/bech32"
+	"realy.lol/ec/ecdsa"
+	"realy.lol/errorf"
+	"realy.lol/sha256"
+)
+
+const (
+	// DefaultExpiry is how many seconds an invoice is payable for if it does not say.
+	DefaultExpiry = 3600
+	// DefaultMinFinalCLTVExpiry is the min_final_cltv_expiry of an invoice that does not say.
+	DefaultMinFinalCLTVExpiry = 18
+	// Prefix is the start of the human readable part of every invoice.
+	Prefix = "ln"
+	// URIPrefix is the scheme invoices are often given with, which Decode ignores.
+	URIPrefix = "lightning:"
+)
+
+// Networks are the currency prefixes of the human readable part of an invoice.
+var Networks = struct {
+	Bitcoin, Testnet, Signet, Regtest string
+}{"bc", "tb", "tbs", "bcrt"}
+
+// The field types of the tagged fields of an invoice, as their values in the bech32 charset.
+const (
+	fieldPaymentHash     = 1  // p
+	fieldRoutes          = 3  // r
+	fieldFeatures        = 5  // 9
+	fieldExpiry          = 6  // x
+	fieldFallback        = 9  // f
+	fieldDescription     = 13 // d
+	fieldPaymentSecret   = 16 // s
+	fieldPayee           = 19 // n
+	fieldDescriptionHash = 23 // h
+	fieldCLTVExpiry      = 24 // c
+	fieldMetadata        = 27 // m
+)
+
+const (
+	// timestampLen is the number of 5 bit groups of the timestamp at the start of the data.
+	timestampLen = 7
+	// signatureLen is the number of 5 bit groups of the signature and recovery id at the
+	// end of the data.
+	signatureLen = 104
+	// hashLen is the number of 5 bit groups of a 32 byte hash field.
+	hashLen = 52
+	// pubkeyLen is the number of 5 bit groups of a 33 byte compressed pubkey field.
+	pubkeyLen = 53
+	// maxIntLen is the largest number of 5 bit groups of an integer field that fits in 63
+	// bits.
+	maxIntLen = 12
+	// msatPerBitcoin is the number of millisatoshis in one bitcoin, the unit of the amount.
+	msatPerBitcoin = 100_000_000_000
+)
+
+// Invoice is a decoded BOLT11 payment request. Fields that are not used by nostr, such as
+// routing hints and fallback addresses, are not kept.
+type Invoice struct {
+	// Network is the currency prefix, one of Networks.
+	Network string
+	// Amount is the amount requested in millisatoshis, or zero if the payer chooses it.
+	Amount uint64
+	// Timestamp is the unix time the invoice was created.
+	Timestamp int64
+	// PaymentHash is the hash of the preimage revealed when the invoice is paid.
+	PaymentHash []byte
+	// PaymentSecret is the secret the payer sends to the payee to prevent probing.
+	PaymentSecret []byte
+	// Description is the purpose of the payment, if it is short enough to include.
+	Description []byte
+	// DescriptionHash is the sha256 hash of the description, if it is given instead.
+	DescriptionHash []byte
+	// Expiry is how many seconds after Timestamp the invoice may be paid.
+	Expiry int64
+	// MinFinalCLTVExpiry is the number of blocks the last hop of the payment must allow.
+	MinFinalCLTVExpiry int64
+	// Metadata is the payment metadata to send to the payee.
+	Metadata []byte
+	// Payee is the 33 byte compressed pubkey of the node that signed the invoice.
+	Payee []byte
+}
+
+// ExpiresAt returns the unix time after which the invoice may no longer be paid.
+func (inv *Invoice) ExpiresAt() int64 { return inv.Timestamp + inv.Expiry }
+
+// Expired returns true if the invoice may no longer be paid at a unix time.
+func (inv *Invoice) Expired(now int64) bool { return now > inv.ExpiresAt() }
+
+// Decode parses an invoice and verifies its signature. The Payee is the pubkey in the
+// invoice if it has one, which the signature must be made by, otherwise it is recovered
+// from the signature.
+func Decode(invoice []byte) (inv *Invoice, err error) {
+	if len(invoice) > len(URIPrefix) &&
+		bytes.EqualFold(invoice[:len(URIPrefix)], []byte(URIPrefix)) {
+		invoice = invoice[len(URIPrefix):]
+	}
+	// invoices are longer than the 90 characters DecodeGeneric allows, so the checksum is
+	// checked to be bech32 and not bech32m by encoding the decoded invoice again.
+	var hrp, data, enc []byte
+	if hrp, data, err = bech32.DecodeNoLimit(invoice); err != nil {
+		err = errorf.E("invalid invoice: %v", err)
+		return
+	}
+	if enc, err = bech32.Encode(hrp, data); err != nil ||
+		!bytes.EqualFold(enc, invoice) {
+		err = errorf.E("invalid invoice: not a bech32 checksum")
+		return
+	}
+	inv = &Invoice{Expiry: DefaultExpiry, MinFinalCLTVExpiry: DefaultMinFinalCLTVExpiry}
+	if inv.Network, inv.Amount, err = parseHRP(hrp); err != nil {
+		return
+	}
+	if len(data) < timestampLen+signatureLen {
+		err = errorf.E("invalid invoice: data is too short")
+		return
+	}
+	fields, sig := data[:len(data)-signatureLen], data[len(data)-signatureLen:]
+	inv.Timestamp = int64(readInt(fields[:timestampLen]))
+	if err = inv.parseFields(fields[timestampLen:]); err != nil {
+		return
+	}
+	if inv.PaymentHash == nil {
+		err = errorf.E("invalid invoice: no payment hash")
+		return
+	}
+	if inv.Description == nil && inv.DescriptionHash == nil {
+		err = errorf.E("invalid invoice: no description or description hash")
+		return
+	}
+	var payee []byte
+	if payee, err = recoverPayee(hrp, fields, sig); err != nil {
+		return
+	}
+	if inv.Payee != nil && !bytes.Equal(inv.Payee, payee) {
+		err = errorf.E("invalid invoice: not signed by the payee")
+		return
+	}
+	inv.Payee = payee
+	return
+}
+
+// parseHRP returns the network and amount of the human readable part of an invoice.
+func parseHRP(hrp []byte) (network string, amount uint64, err error) {
+	if !bytes.HasPrefix(hrp, []byte(Prefix)) {
+		err = errorf.E("invalid invoice: prefix is not %s", Prefix)
+		return
+	}
+	hrp = hrp[len(Prefix):]
+	i := bytes.IndexAny(hrp, "0123456789")
+	if i < 0 {
+		i = len(hrp)
+	}
+	network = string(hrp[:i])
+	switch network {
+	case Networks.Bitcoin, Networks.Testnet, Networks.Signet, Networks.Regtest:
+	default:
+		err = errorf.E("invalid invoice: unknown network %q", network)
+		return
+	}
+	if amount, err = parseAmount(hrp[i:]); err != nil {
+		return
+	}
+	return
+}
+
+// parseAmount converts the amount and multiplier of the human readable part of an invoice to
+// millisatoshis.
+func parseAmount(a []byte) (msat uint64, err error) {
+	if len(a) == 0 {
+		return
+	}
+	unit := uint64(msatPerBitcoin)
+	var pico bool
+	switch a[len(a)-1] {
+	case 'm':
+		unit = msatPerBitcoin / 1_000
+	case 'u':
+		unit = msatPerBitcoin / 1_000_000
+	case 'n':
+		unit = msatPerBitcoin / 1_000_000_000
+	case 'p':
+		pico = true
+	default:
+		if a[len(a)-1] < '0' || a[len(a)-1] > '9' {
+			err = errorf.E("invalid invoice: unknown multiplier %q", a[len(a)-1])
+			return
+		}
+	}
+	if pico || unit != msatPerBitcoin {
+		a = a[:len(a)-1]
+	}
+	var n uint64
+	if len(a) == 0 || a[0] == '0' {
+		err = errorf.E("invalid invoice: invalid amount %q", a)
+		return
+	}
+	if n, err = strconv.ParseUint(string(a), 10, 64); err != nil {
+		err = errorf.E("invalid invoice: invalid amount %q", a)
+		return
+	}
+	if pico {
+		// a picobitcoin is a tenth of a millisatoshi, which cannot be paid.
+		if n%10 != 0 {
+			err = errorf.E("invalid invoice: amount %dp is not whole millisatoshis", n)
+		}
+		msat = n / 10
+		return
+	}
+	var hi uint64
+	if hi, msat = bits.Mul64(n, unit); hi != 0 {
+		err = errorf.E("invalid invoice: amount is too large")
+	}
+	return
+}
+
+// parseFields reads the tagged fields of an invoice. Unknown fields, and known fields with an
+// unexpected length, are skipped, and only the first of each known field is used.
+func (inv *Invoice) parseFields(d []byte) (err error) {
+	var seen [32]bool
+	for len(d) > 0 {
+		if len(d) < 3 {
+			err = errorf.E("invalid invoice: truncated field")
+			return
+		}
+		typ, l := d[0], int(d[1])<<5|int(d[2])
+		if len(d) < 3+l {
+			err = errorf.E("invalid invoice: field %c is truncated", bech32.Charset[typ])
+			return
+		}
+		v := d[3 : 3+l]
+		d = d[3+l:]
+		if seen[typ] {
+			continue
+		}
+		switch typ {
+		case fieldPaymentHash:
+			if l != hashLen {
+				continue
+			}
+			if inv.PaymentHash, err = fieldBytes(typ, v); err != nil {
+				return
+			}
+		case fieldPaymentSecret:
+			if l != hashLen {
+				continue
+			}
+			if inv.PaymentSecret, err = fieldBytes(typ, v); err != nil {
+				return
+			}
+		case fieldDescriptionHash:
+			if l != hashLen {
+				continue
+			}
+			if inv.DescriptionHash, err = fieldBytes(typ, v); err != nil {
+				return
+			}
+		case fieldPayee:
+			if l != pubkeyLen {
+				continue
+			}
+			if inv.Payee, err = fieldBytes(typ, v); err != nil {
+				return
+			}
+		case fieldDescription:
+			if inv.Description, err = fieldBytes(typ, v); err != nil {
+				return
+			}
+			if !utf8.Valid(inv.Description) {
+				err = errorf.E("invalid invoice: description is not UTF-8")
+				return
+			}
+		case fieldMetadata:
+			if inv.Metadata, err = fieldBytes(typ, v); err != nil {
+				return
+			}
+		case fieldExpiry:
+			if l > maxIntLen {
+				continue
+			}
+			inv.Expiry = int64(readInt(v))
+		case fieldCLTVExpiry:
+			if l > maxIntLen {
+				continue
+			}
+			inv.MinFinalCLTVExpiry = int64(readInt(v))
+		default:
+			continue
+		}
+		seen[typ] = true
+	}
+	return
+}
+
+// recoverPayee verifies the signature of an invoice and returns the compressed pubkey that
+// made it.
+func recoverPayee(hrp, fields, sig5 []byte) (payee []byte, err error) {
+	var sig []byte
+	if sig, err = bech32.ConvertBits(sig5, 5, 8, false); err != nil {
+		err = errorf.E("invalid invoice: invalid signature")
+		return
+	}
+	recovery := sig[64]
+	if recovery > 3 {
+		err = errorf.E("invalid invoice: invalid recovery id %d", recovery)
+		return
+	}
+	// the compact signature format has the recovery code first, offset to say the key is
+	// compressed.
+	compact := make([]byte, 0, 65)
+	compact = append(compact, 27+4+recovery)
+	compact = append(compact, sig[:64]...)
+	var hash []byte
+	if hash, err = sigHash(hrp, fields); err != nil {
+		return
+	}
+	var pub *btcec.PublicKey
+	if pub, _, err = ecdsa.RecoverCompact(compact, hash); err != nil {
+		err = errorf.E("invalid invoice: invalid signature: %v", err)
+		return
+	}
+	payee = pub.SerializeCompressed()
+	return
+}
+
+// sigHash returns the hash an invoice signature is made over, of the human readable part and
+// the data before the signature, padded to whole bytes.
+func sigHash(hrp, fields []byte) (hash []byte, err error) {
+	var b []byte
+	if b, err = bech32.ConvertBits(fields, 5, 8, true); chk.E(err) {
+		return
+	}
+	h := sha256.Sum256(append(append([]byte{}, hrp...), b...))
+	return h[:], nil
+}
+
+// fieldBytes converts the 5 bit groups of a field to bytes.
+func fieldBytes(typ byte, v []byte) (b []byte, err error) {
+	if b, err = bech32.ConvertBits(v, 5, 8, false); err != nil {
+		err = errorf.E("invalid invoice: field %c has invalid padding", bech32.Charset[typ])
+	}
+	return
+}
+
+// readInt reads a big endian integer of 5 bit groups.
+func readInt(d []byte) (n uint64) {
+	for _, b := range d {
+		n = n<<5 | uint64(b)
+	}
+	return
+}
+
+// writeInt appends the 5 bit groups of a big endian integer, with no leading zero groups.
+func writeInt(d []byte, n uint64) []byte {
+	var g []byte
+	for ; n > 0; n >>= 5 {
+		g = append(g, byte(n&31))
+	}
+	for i := len(g) - 1; i >= 0; i-- {
+		d = append(d, g[i])
+	}
+	return d
+}
+
+// Encode creates an invoice signed by a node key. The Payee is added to the invoice if it is
+// set, and it must be the pubkey of the key. Expiry and MinFinalCLTVExpiry are only written if
+// they differ from their defaults.
+func Encode(inv *Invoice, key *btcec.SecretKey) (invoice []byte, err error) {
+	if len(inv.PaymentHash) != 32 {
+		err = errorf.E("payment hash must be 32 bytes")
+		return
+	}
+	if inv.Description == nil && len(inv.DescriptionHash) != 32 {
+		err = errorf.E("invoice requires a description or a 32 byte description hash")
+		return
+	}
+	network := inv.Network
+	if network == "" {
+		network = Networks.Bitcoin
+	}
+	hrp := append([]byte(Prefix+network), formatAmount(inv.Amount)...)
+	d := make([]byte, timestampLen, 512)
+	for i, ts := timestampLen-1, uint64(inv.Timestamp); i >= 0; i, ts = i-1, ts>>5 {
+		d[i] = byte(ts & 31)
+	}
+	field := func(typ byte, v []byte) {
+		d = append(d, typ, byte(len(v)>>5), byte(len(v)&31))
+		d = append(d, v...)
+	}
+	bytesField := func(typ byte, v []byte) {
+		if v == nil {
+			return
+		}
+		g, _ := bech32.ConvertBits(v, 8, 5, true)
+		field(typ, g)
+	}
+	bytesField(fieldPaymentHash, inv.PaymentHash)
+	bytesField(fieldPaymentSecret, inv.PaymentSecret)
+	bytesField(fieldDescription, inv.Description)
+	bytesField(fieldDescriptionHash, inv.DescriptionHash)
+	bytesField(fieldMetadata, inv.Metadata)
+	bytesField(fieldPayee, inv.Payee)
+	if inv.Expiry != 0 && inv.Expiry != DefaultExpiry {
+		field(fieldExpiry, writeInt(nil, uint64(inv.Expiry)))
+	}
+	if inv.MinFinalCLTVExpiry != 0 && inv.MinFinalCLTVExpiry != DefaultMinFinalCLTVExpiry {
+		field(fieldCLTVExpiry, writeInt(nil, uint64(inv.MinFinalCLTVExpiry)))
+	}
+	var hash []byte
+	if hash, err = sigHash(hrp, d); err != nil {
+		return
+	}
+	compact := ecdsa.SignCompact(key, hash, true)
+	// the invoice signature is the compact signature with the recovery id moved to the end.
+	sig := append(append([]byte{}, compact[1:]...), compact[0]-27-4)
+	var sig5 []byte
+	if sig5, err = bech32.ConvertBits(sig, 8, 5, true); chk.E(err) {
+		return
+	}
+	return bech32.Encode(hrp, append(d, sig5...))
+}
+
+// formatAmount returns the amount and multiplier of the human readable part of an invoice for
+// an amount of millisatoshis, using the largest multiplier that expresses it exactly.
+func formatAmount(msat uint64) (a []byte) {
+	if msat == 0 {
+		return
+	}
+	for _, m := range []struct {
+		unit uint64
+		mul  string
+	}{
+		{msatPerBitcoin, ""},
+		{msatPerBitcoin / 1_000, "m"},
+		{msatPerBitcoin / 1_000_000, "u"},
+		{msatPerBitcoin / 1_000_000_000, "n"},
+	} {
+		if msat%m.unit == 0 {
+			return append(strconv.AppendUint(nil, msat/m.unit, 10), m.mul...)
+		}
+	}
+	return append(strconv.AppendUint(nil, msat*10, 10), 'p')
+}
@@ -0,0 +1,114 @@
+package bolt11
+
+import (
+	"bytes"
+	"testing"
+
+	"realy.lol/ec"
+	"realy.lol/ec/bech32"
+	"realy.lol/hex"
+	"realy.lol/sha256"
+)
+
+// the payee of the test vectors of the BOLT11 specification.
+const specPayee = "03e7156ae33b0a208d0744199163177e909e80176e55d97a2f221ede0f934dd9ad"
+
+func TestDecodeSpecVectors(t *testing.T) {
+	hash, _ := hex.Dec("0001020304050607080900010203040506070809000102030405060708090102")
+	for _, c := range []struct {
+		invoice     string
+		amount      uint64
+		description string
+		descHash    string
+		expiry      int64
+	}{
+		{"lnbc1pvjluezsp5zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zygspp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypqdpl2pkx2ctnv5sxxmmwwd5kgetjypeh2ursdae8g6twvus8g6rfwvs8qun0dfjkxaq9qrsgq357wnc5r2ueh7ck6q93dj32dlqnls087fxdwk8qakdyafkq3yap9us6v52vjjsrvywa6rt52cm9r9zqt8r2t7mlcwspyetp5h2tztugp9lfyql",
+			0, "Please consider supporting this project", "", DefaultExpiry},
+		{"lnbc2500u1pvjluezpp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypqdq5xysxxatsyp3k7enxv4jsxqzpuaztrnwngzn3kdzw5hydlzf03qdgm2hdq27cqv3agm2awhz5se903vruatfhq77w3ls4evs3ch9zw97j25emudupq63nyw24cg27h2rspfj9srp",
+			250_000_000, "1 cup coffee", "", 60},
+		{"lnbc20m1pvjluezpp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypqhp58yjmdan79s6qqdhdzgynm4zwqd5d7xmw5fk98klysy043l2ahrqscc6gd6ql3jrc5yzme8v4ntcewwz5cnw92tz0pc8qcuufvq7khhr8wpald05e92xw006sq94mg8v2ndf4sefvf9sygkshp5zfem29trqq2yxxz7",
+			2_000_000_000, "", "3925b6f67e2c340036ed12093dd44e0368df1b6ea26c53dbe4811f58fd5db8c1", DefaultExpiry},
+	} {
+		inv, err := Decode([]byte(c.invoice))
+		if err != nil {
+			t.Fatalf("%s: %v", c.invoice, err)
+		}
+		if hex.Enc(inv.Payee) != specPayee {
+			t.Fatalf("recovered payee %0x", inv.Payee)
+		}
+		if inv.Network != Networks.Bitcoin || inv.Amount != c.amount ||
+			inv.Timestamp != 1496314658 || inv.Expiry != c.expiry ||
+			!bytes.Equal(inv.PaymentHash, hash) || string(inv.Description) != c.description ||
+			hex.Enc(inv.DescriptionHash) != c.descHash {
+			t.Fatalf("decoded %+v", inv)
+		}
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	key, err := btcec.NewSecretKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := sha256.Sum256([]byte("preimage"))
+	desc := sha256.Sum256([]byte("zap request"))
+	for _, amount := range []uint64{0, 1, 10, 1000, 21_000, 100_000_000_000, 123_456_789} {
+		in := &Invoice{Network: Networks.Regtest, Amount: amount, Timestamp: 1700000000,
+			PaymentHash: hash[:], DescriptionHash: desc[:], Expiry: 600,
+			MinFinalCLTVExpiry: 40, Payee: key.PubKey().SerializeCompressed()}
+		invoice, err := Encode(in, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := Decode(invoice)
+		if err != nil {
+			t.Fatalf("%s: %v", invoice, err)
+		}
+		if out.Amount != amount || out.Network != in.Network || out.Timestamp != in.Timestamp ||
+			out.Expiry != 600 || out.MinFinalCLTVExpiry != 40 ||
+			!bytes.Equal(out.DescriptionHash, in.DescriptionHash) ||
+			!bytes.Equal(out.Payee, in.Payee) {
+			t.Fatalf("%s decoded as %+v", invoice, out)
+		}
+		if !out.Expired(1700000601) || out.Expired(1700000600) {
+			t.Fatal("wrong expiry")
+		}
+	}
+	// an invoice naming a payee that did not sign it is rejected.
+	other, _ := btcec.NewSecretKey()
+	in := &Invoice{PaymentHash: hash[:], Description: []byte("x"),
+		Payee: other.PubKey().SerializeCompressed()}
+	invoice, err := Encode(in, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Decode(invoice); err == nil {
+		t.Fatal("invoice signed by another key accepted")
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	for _, invoice := range []string{
+		// wrong checksum
+		"lnbc2500u1pvjluezpp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypqdq5xysxxatsyp3k7enxv4jsxqzpuaztrnwngzn3kdzw5hydlzf03qdgm2hdq27cqv3agm2awhz5se903vruatfhq77w3ls4evs3ch9zw97j25emudupq63nyw24cg27h2rspfj9srq",
+		// not a lightning invoice
+		"pvjluezpp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypqdq5xysxxatsyp3k7enxv4jsxqzpuaztrnwngzn3kdzw5hydlzf03qdgm2hdq27cqv3agm2awhz5se903vruatfhq77w3ls4evs3ch9zw97j25emudupq63nyw24cg27h2rspfj9srp",
+		// mixed case
+		"LNBC2500u1pvjluezpp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypqdq5xysxxatsyp3k7enxv4jsxqzpuaztrnwngzn3kdzw5hydlzf03qdgm2hdq27cqv3agm2awhz5se903vruatfhq77w3ls4evs3ch9zw97j25emudupq63nyw24cg27h2rspfj9srp",
+	} {
+		if _, err := Decode([]byte(invoice)); err == nil {
+			t.Fatalf("invalid invoice %s accepted", invoice)
+		}
+	}
+	// a bech32m checksum
+	hrp, data, _ := bech32.DecodeNoLimit([]byte("lnbc2500u1pvjluezpp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypqdq5xysxxatsyp3k7enxv4jsxqzpuaztrnwngzn3kdzw5hydlzf03qdgm2hdq27cqv3agm2awhz5se903vruatfhq77w3ls4evs3ch9zw97j25emudupq63nyw24cg27h2rspfj9srp"))
+	m, _ := bech32.EncodeM(hrp, data)
+	if _, err := Decode(m); err == nil {
+		t.Fatal("invoice with a bech32m checksum accepted")
+	}
+	for _, a := range []string{"2500x", "0u", "u", "11p", "99999999999999999999"} {
+		if _, err := parseAmount([]byte(a)); err == nil {
+			t.Fatalf("invalid amount %s accepted", a)
+		}
+	}
+}
@@ -0,0 +1,3 @@
+// Package bolt11 decodes and encodes BOLT11 lightning payment requests, verifying the payee
+// signature and recovering the payee pubkey from it when the invoice does not name it.
+package bolt11
@@ -0,0 +1,230 @@
+package broadcast
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"realy.lol/chk"
+	"realy.lol/context"
+	"realy.lol/event"
+	"realy.lol/hex"
+	"realy.lol/log"
+	"realy.lol/normalize"
+	"realy.lol/ws"
+)
+
+const (
+	// DefaultMaxAttempts is the number of times an event is sent to a relay before giving up.
+	DefaultMaxAttempts = 10
+	// DefaultRetention is how long the results of finished deliveries are kept.
+	DefaultRetention = 7 * 24 * time.Hour
+	// MaxBackoff is the longest wait between attempts.
+	MaxBackoff = 6 * time.Hour
+)
+
+// PollInterval is the longest time the queue waits before looking for due deliveries.
+var PollInterval = time.Minute
+
+// Delivery is the state of sending an event to a relay.
+type Delivery struct {
+	// Id is the hex encoded event id.
+	Id string `json:"id"`
+	// Relay is the normalized websocket URL of the relay.
+	Relay string `json:"relay"`
+	// Attempts is the number of times the event has been sent.
+	Attempts int `json:"attempts"`
+	// NextAttempt is the unix time of the next attempt.
+	NextAttempt int64 `json:"next_attempt"`
+	// Done is true when the relay answered or the attempts ran out.
+	Done bool `json:"done"`
+	// Ok is the answer of the relay.
+	Ok bool `json:"ok"`
+	// Message is the reason the relay gave, or the last error sending the event.
+	Message string `json:"message,omitempty"`
+	// Updated is the unix time the delivery last changed.
+	Updated int64 `json:"updated"`
+}
+
+// Queue stores deliveries.
+type Queue interface {
+	// PutDelivery adds or updates a delivery.
+	PutDelivery(d *Delivery) (err error)
+	// DeleteDelivery removes a delivery.
+	DeleteDelivery(d *Delivery) (err error)
+	// Deliveries returns the deliveries of an event, or of all events if id is nil.
+	Deliveries(id []byte) (dd []*Delivery, err error)
+}
+
+// FetchFunc returns a stored event by its id, or nil if it is not stored.
+type FetchFunc func(id []byte) (ev *event.T, err error)
+
+// B sends events to relays.
+type B struct {
+	Pool  *ws.Pool
+	Queue Queue
+	Fetch FetchFunc
+	// MaxAttempts is the number of attempts before giving up, DefaultMaxAttempts if zero.
+	MaxAttempts int
+	// Retention is how long finished deliveries are kept, DefaultRetention if zero.
+	Retention time.Duration
+	wake      chan struct{}
+}
+
+// New creates a broadcaster that sends events through a pool.
+func New(pool *ws.Pool, queue Queue, fetch FetchFunc) (b *B) {
+	return &B{Pool: pool, Queue: queue, Fetch: fetch, wake: make(chan struct{}, 1)}
+}
+
+// Backoff returns the time to wait after a number of failed attempts.
+func Backoff(attempts int) (d time.Duration) {
+	d = 30 * time.Second
+	for i := 1; i < attempts && d < MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, MaxBackoff)
+}
+
+// Broadcast queues an event to be sent to relays.
+func (b *B) Broadcast(ev *event.T, relays []string) (err error) {
+	now := time.Now().Unix()
+	seen := make(map[string]struct{})
+	for _, r := range relays {
+		r = string(normalize.URL(r))
+		if _, ok := seen[r]; ok || r == "" {
+			continue
+		}
+		seen[r] = struct{}{}
+		d := &Delivery{Id: hex.Enc(ev.Id), Relay: r, NextAttempt: now, Updated: now}
+		if err = b.Queue.PutDelivery(d); chk.E(err) {
+			return
+		}
+	}
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+	return
+}
+
+// Run sends the queued deliveries as they fall due until the context is canceled.
+func (b *B) Run(c context.T) {
+	for {
+		wait := b.process(c)
+		timer := time.NewTimer(wait)
+		select {
+		case <-c.Done():
+			timer.Stop()
+			return
+		case <-b.wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// process attempts the due deliveries, removes expired results, and returns the time until
+// the next delivery is due.
+func (b *B) process(c context.T) (wait time.Duration) {
+	wait = PollInterval
+	dd, err := b.Queue.Deliveries(nil)
+	if chk.E(err) {
+		return
+	}
+	retention := b.Retention
+	if retention == 0 {
+		retention = DefaultRetention
+	}
+	now := time.Now().Unix()
+	due := make(map[string][]*Delivery)
+	for _, d := range dd {
+		switch {
+		case d.Done:
+			if now-d.Updated > int64(retention/time.Second) {
+				chk.E(b.Queue.DeleteDelivery(d))
+			}
+		case d.NextAttempt <= now:
+			due[d.Relay] = append(due[d.Relay], d)
+		default:
+			if w := time.Duration(d.NextAttempt-now) * time.Second; w < wait {
+				wait = w
+			}
+		}
+	}
+	// each relay is sent its events in turn, and all relays at once.
+	var wg sync.WaitGroup
+	for relay, rdd := range due {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, d := range rdd {
+				if !b.deliver(c, relay, d) {
+					// the relay is unreachable, the rest are retried later.
+					break
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if len(due) > 0 {
+		// there may be deliveries that were rescheduled to soon after.
+		wait = min(wait, time.Second)
+	}
+	return
+}
+
+// deliver makes an attempt to send an event to a relay and records the result. It returns
+// false if the relay could not be reached.
+func (b *B) deliver(c context.T, relay string, d *Delivery) (reached bool) {
+	reached = true
+	var err error
+	var id []byte
+	if id, err = hex.Dec(d.Id); chk.E(err) {
+		chk.E(b.Queue.DeleteDelivery(d))
+		return
+	}
+	var ev *event.T
+	if ev, err = b.Fetch(id); chk.E(err) {
+		return
+	}
+	if ev == nil {
+		// the event has been deleted, so it will not be sent.
+		log.D.F("not broadcasting deleted event %s", d.Id)
+		chk.E(b.Queue.DeleteDelivery(d))
+		return
+	}
+	d.Attempts++
+	d.Updated = time.Now().Unix()
+	var client *ws.Client
+	if client, err = b.Pool.EnsureRelay(relay); err == nil {
+		err = client.Publish(c, ev)
+	}
+	var rejected *ws.RejectedError
+	switch {
+	case err == nil:
+		d.Done, d.Ok, d.Message = true, true, ""
+		log.D.F("broadcast %s to %s", d.Id, relay)
+	case errors.As(err, &rejected):
+		d.Done, d.Message = true, rejected.Reason
+		// the relay already has it
+		d.Ok = strings.HasPrefix(rejected.Reason, "duplicate:")
+		log.I.F("broadcast %s to %s: %s", d.Id, relay, rejected.Reason)
+	default:
+		reached = false
+		d.Message = err.Error()
+		max := b.MaxAttempts
+		if max == 0 {
+			max = DefaultMaxAttempts
+		}
+		if d.Attempts >= max {
+			d.Done = true
+			log.W.F("giving up broadcasting %s to %s after %d attempts: %v", d.Id, relay,
+				d.Attempts, err)
+		} else {
+			d.NextAttempt = d.Updated + int64(Backoff(d.Attempts)/time.Second)
+		}
+	}
+	chk.E(b.Queue.PutDelivery(d))
+	return
+}
@@ -0,0 +1,87 @@
+package broadcast
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"realy.lol/context"
+	"realy.lol/event"
+	"realy.lol/sha256"
+	"realy.lol/ws"
+)
+
+type memQueue map[string]*Delivery
+
+func (q memQueue) PutDelivery(d *Delivery) (err error) {
+	c := *d
+	q[d.Id+d.Relay] = &c
+	return
+}
+
+func (q memQueue) DeleteDelivery(d *Delivery) (err error) {
+	delete(q, d.Id+d.Relay)
+	return
+}
+
+func (q memQueue) Deliveries(id []byte) (dd []*Delivery, err error) {
+	for _, d := range q {
+		c := *d
+		dd = append(dd, &c)
+	}
+	return
+}
+
+func TestBackoff(t *testing.T) {
+	if Backoff(1) != 30*time.Second || Backoff(2) != time.Minute || Backoff(3) != 2*time.Minute {
+		t.Fatalf("unexpected backoff %v %v %v", Backoff(1), Backoff(2), Backoff(3))
+	}
+	if Backoff(100) != MaxBackoff {
+		t.Fatalf("backoff %v exceeds maximum", Backoff(100))
+	}
+}
+
+func TestRetry(t *testing.T) {
+	c, cancel := context.Cancel(context.Bg())
+	defer cancel()
+	ev := event.New()
+	ev.Id = make([]byte, sha256.Size)
+	deleted := event.New()
+	deleted.Id = bytes.Repeat([]byte{1}, sha256.Size)
+	q := make(memQueue)
+	b := New(ws.NewPool(c), q, func(id []byte) (*event.T, error) {
+		if bytes.Equal(id, ev.Id) {
+			return ev, nil
+		}
+		return nil, nil
+	})
+	b.MaxAttempts = 2
+	// nothing listens on port 1, so the relay is unreachable.
+	relay := "ws://127.0.0.1:1"
+	if err := b.Broadcast(ev, []string{relay, relay}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Broadcast(deleted, []string{"ws://127.0.0.1:2"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(q) != 2 {
+		t.Fatalf("expected 2 deliveries, got %d", len(q))
+	}
+	b.process(c)
+	if len(q) != 1 {
+		t.Fatalf("delivery of deleted event was not removed")
+	}
+	for _, d := range q {
+		if d.Done || d.Attempts != 1 || d.NextAttempt <= time.Now().Unix() || d.Message == "" {
+			t.Fatalf("failed delivery not rescheduled: %+v", d)
+		}
+		// make it due again
+		d.NextAttempt = 0
+	}
+	b.process(c)
+	for _, d := range q {
+		if !d.Done || d.Ok || d.Attempts != 2 {
+			t.Fatalf("delivery not given up after the maximum attempts: %+v", d)
+		}
+	}
+}
@@ -0,0 +1,4 @@
+// Package broadcast sends events to other relays through a persistent retry queue. Each event
+// and relay pair is a Delivery that is retried with exponential backoff until the relay
+// answers with an OK, and the result the relay gave is kept for a while after.
+package broadcast
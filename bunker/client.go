@@ -11,6 +11,7 @@ import (
 	"realy.lol/atomic"
 	"realy.lol/chk"
 	"realy.lol/context"
+	"realy.lol/dns"
 	"realy.lol/encryption"
 	"realy.lol/errorf"
 	"realy.lol/event"
@@ -66,7 +67,7 @@ func ConnectBunker(
 		// could be a NIP-05
 		var pubkey string
 		var relays_ []string
-		if pubkey, relays_, err = queryWellKnownNostrJson(ctx, bunkerURLOrNIP05); chk.E(err) {
+		if pubkey, relays_, err = dns.QueryBunker(ctx, bunkerURLOrNIP05); chk.E(err) {
 			return
 		}
 		targetPublicKey = pubkey
@@ -82,13 +83,10 @@ func ConnectBunker(
 		err = errorf.E("'%s' is not a valid public key hex", targetPublicKey)
 		return
 	}
-	var targetPubkey, sec []byte
+	var targetPubkey []byte
 	if targetPubkey, err = keys.HexPubkeyToBytes(targetPublicKey); chk.E(err) {
 		return
 	}
-	if sec, err = hex.Dec(secret); chk.E(err) {
-		return
-	}
 	if client, err = NewBunker(
 		ctx,
 		clientSecretKey,
@@ -99,7 +97,7 @@ func ConnectBunker(
 	); chk.E(err) {
 		return
 	}
-	_, err = client.RPC(ctx, "connect", [][]byte{targetPubkey, sec})
+	_, err = client.RPC(ctx, "connect", []string{targetPublicKey, secret})
 	return
 }
 
@@ -142,7 +140,7 @@ func NewBunker(
 	go func() {
 		now := timestamp.Now()
 		events := pool.SubMany(ctx, relays, filters.New(&filter.T{
-			Tags:  tags.New(tag.New([]byte("p"), clientPubkey)),
+			Tags:  tags.New(tag.New([]byte("#p"), clientPubkey)),
 			Kinds: kinds.New(kind.NostrConnect),
 			Since: now,
 		}), ws.WithLabel("bunker46client"))
@@ -181,7 +179,7 @@ func NewBunker(
 }
 
 func (client *BunkerClient) RPC(ctx context.T, method string,
-	params [][]byte) (result string, err error) {
+	params []string) (result string, err error) {
 	id := client.idPrefix + "-" + strconv.FormatUint(client.serial.Add(1), 10)
 	var req []byte
 	if req, err = json.Marshal(Request{
@@ -199,7 +197,7 @@ func (client *BunkerClient) RPC(ctx context.T, method string,
 		Content:   content,
 		CreatedAt: timestamp.Now(),
 		Kind:      kind.NostrConnect,
-		Tags:      tags.New(tag.New([]byte("p"), client.target)),
+		Tags:      tags.New(tag.New("p", hex.Enc(client.target))),
 	}
 	if err = ev.Sign(client.clientSecretKey); chk.E(err) {
 		return
@@ -249,7 +247,7 @@ func (client *BunkerClient) RPC(ctx context.T, method string,
 }
 
 func (client *BunkerClient) Ping(ctx context.T) (err error) {
-	if _, err = client.RPC(ctx, "ping", [][]byte{}); chk.E(err) {
+	if _, err = client.RPC(ctx, "ping", []string{}); chk.E(err) {
 		return
 	}
 	return
@@ -260,17 +258,21 @@ func (client *BunkerClient) GetPublicKey(ctx context.T) (resp string, err error)
 		resp = client.getPublicKeyResponse
 		return
 	}
-	resp, err = client.RPC(ctx, "get_public_key", [][]byte{})
+	resp, err = client.RPC(ctx, "get_public_key", []string{})
 	client.getPublicKeyResponse = resp
 	return
 }
 
 func (client *BunkerClient) SignEvent(ctx context.T, evt *event.T) (err error) {
+	var req []byte
+	if req, err = json.Marshal(NewUnsigned(evt)); chk.E(err) {
+		return
+	}
 	var resp string
-	if resp, err = client.RPC(ctx, "sign_event", [][]byte{evt.Serialize()}); chk.E(err) {
+	if resp, err = client.RPC(ctx, "sign_event", []string{string(req)}); chk.E(err) {
 		return
 	}
-	if err = json.Unmarshal([]byte(resp), evt); chk.E(err) {
+	if _, err = evt.Unmarshal([]byte(resp)); chk.E(err) {
 		return
 	}
 	if !client.SkipSignatureCheck {
@@ -288,20 +290,34 @@ func (client *BunkerClient) SignEvent(ctx context.T, evt *event.T) (err error) {
 
 func (client *BunkerClient) NIP44Encrypt(ctx context.T,
 	targetPublicKey, plaintext []byte) (string, error) {
-	return client.RPC(ctx, "nip44_encrypt", [][]byte{targetPublicKey, plaintext})
+	return client.RPC(ctx, "nip44_encrypt", []string{hex.Enc(targetPublicKey), string(plaintext)})
 }
 
 func (client *BunkerClient) NIP44Decrypt(ctx context.T,
 	targetPublicKey, ciphertext []byte) (string, error) {
-	return client.RPC(ctx, "nip44_decrypt", [][]byte{targetPublicKey, ciphertext})
+	return client.RPC(ctx, "nip44_decrypt", []string{hex.Enc(targetPublicKey), string(ciphertext)})
 }
 
 func (client *BunkerClient) NIP04Encrypt(ctx context.T,
 	targetPublicKey, plaintext []byte) (string, error) {
-	return client.RPC(ctx, "nip04_encrypt", [][]byte{targetPublicKey, plaintext})
+	return client.RPC(ctx, "nip04_encrypt", []string{hex.Enc(targetPublicKey), string(plaintext)})
 }
 
 func (client *BunkerClient) NIP04Decrypt(ctx context.T,
 	targetPublicKey, ciphertext []byte) (string, error) {
-	return client.RPC(ctx, "nip04_decrypt", [][]byte{targetPublicKey, ciphertext})
+	return client.RPC(ctx, "nip04_decrypt", []string{hex.Enc(targetPublicKey), string(ciphertext)})
+}
+
+// ECDH asks the bunker for the ECDH shared secret of its key with another pubkey. This is not
+// part of NIP-46 and the bunker only answers clients it has given the ecdh permission.
+func (client *BunkerClient) ECDH(ctx context.T, targetPublicKey []byte) (secret []byte,
+	err error) {
+	var resp string
+	if resp, err = client.RPC(ctx, "ecdh", []string{hex.Enc(targetPublicKey)}); chk.E(err) {
+		return
+	}
+	if secret, err = hex.Dec(resp); chk.E(err) {
+		return
+	}
+	return
 }
@@ -0,0 +1,165 @@
+package bunker
+
+import (
+	"crypto/rand"
+	"slices"
+	"time"
+
+	"realy.lol/chk"
+	"realy.lol/context"
+	"realy.lol/errorf"
+	"realy.lol/event"
+	"realy.lol/filter"
+	"realy.lol/filters"
+	"realy.lol/hex"
+	"realy.lol/kind"
+	"realy.lol/kinds"
+	"realy.lol/log"
+	"realy.lol/normalize"
+	"realy.lol/signer"
+	"realy.lol/tag"
+	"realy.lol/tags"
+	"realy.lol/timestamp"
+	"realy.lol/ws"
+)
+
+// ReloadInterval is how often a Daemon checks whether clients have been paired on new relays
+// by another process.
+var ReloadInterval = 10 * time.Second
+
+// Daemon is a NIP-46 remote signer, which answers the kind 24133 requests addressed to the key
+// of its Signer on its relays and those of the clients paired with nostrconnect:// URIs,
+// allowing its clients what their Permissions say.
+type Daemon struct {
+	Signer *StaticKeySigner
+	Perms  *Permissions
+	// Relays are the relays the daemon listens on and puts in its connection strings.
+	Relays []string
+	pool   *ws.Pool
+}
+
+// NewDaemon creates a Daemon signing with a key, listening on relays.
+func NewDaemon(c context.T, sign signer.I, relays []string, perms *Permissions) (d *Daemon) {
+	d = &Daemon{Signer: NewStaticKeySigner(sign), Perms: perms, Relays: relays,
+		pool: ws.NewPool(c)}
+	d.Signer.AuthorizeRequest = perms.Authorize
+	for _, r := range relays {
+		d.Signer.RelaysToAdvertise[r] = RelayReadWrite{Read: true, Write: true}
+	}
+	return
+}
+
+// NewBunkerURL makes a bunker:// connection string with a new secret, which pairs the first
+// client that connects with it with a permission.
+func (d *Daemon) NewBunkerURL(perm *Permission) (uri string, err error) {
+	secret := make([]byte, 16)
+	if _, err = rand.Read(secret); chk.E(err) {
+		return
+	}
+	if err = d.Perms.AddSecret(hex.Enc(secret), perm); err != nil {
+		return
+	}
+	return BunkerURL(d.Signer.Pubkey(), d.Relays, hex.Enc(secret)), nil
+}
+
+// Pair pairs the client of a nostrconnect:// URI with the permissions it asks for, and sends
+// it the connect response with its secret on its relays.
+func (d *Daemon) Pair(c context.T, nc *NostrConnect) (err error) {
+	if err = d.Perms.Grant(nc.Client, nc.Perms); err != nil {
+		return
+	}
+	var session *Session
+	if session, err = d.Signer.getOrCreateSession(nc.Client); chk.E(err) {
+		return
+	}
+	id := make([]byte, 8)
+	if _, err = rand.Read(id); chk.E(err) {
+		return
+	}
+	var ev *event.T
+	if _, ev, err = session.MakeResponse(hex.Enc(id), hex.Enc(nc.Client), nc.Secret,
+		nil); chk.E(err) {
+		return
+	}
+	if err = ev.Sign(d.Signer.secretKey); chk.E(err) {
+		return
+	}
+	var sent bool
+	for _, u := range nc.Relays {
+		var relay *ws.Client
+		if relay, err = d.pool.EnsureRelay(u); chk.D(err) {
+			continue
+		}
+		if err = relay.Publish(c, ev); chk.D(err) {
+			continue
+		}
+		sent = true
+	}
+	if !sent {
+		return errorf.E("could not send the connect response to any relay of the client: %v",
+			err)
+	}
+	return nil
+}
+
+// relays returns the normalized URLs of the relays of the daemon and its paired clients.
+func (d *Daemon) relays() (relays []string) {
+	for _, r := range append(slices.Clone(d.Relays), d.Perms.Relays()...) {
+		if u := string(normalize.URL(r)); !slices.Contains(relays, u) {
+			relays = append(relays, u)
+		}
+	}
+	slices.Sort(relays)
+	return
+}
+
+// Run answers the requests to the signer until the context is canceled, subscribing again
+// when clients are paired on other relays.
+func (d *Daemon) Run(c context.T) {
+	for c.Err() == nil {
+		relays := d.relays()
+		log.I.F("bunker %0x listening on %v", d.Signer.Pubkey(), relays)
+		sc, cancel := context.Cancel(c)
+		events := d.pool.SubMany(sc, slices.Clone(relays), filters.New(&filter.T{
+			Kinds: kinds.New(kind.NostrConnect),
+			Tags:  tags.New(tag.New([]byte("#p"), d.Signer.Pubkey())),
+			Since: timestamp.Now(),
+		}), ws.WithLabel("bunker"))
+		d.serve(sc, events, relays)
+		cancel()
+		select {
+		case <-c.Done():
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// serve answers the requests on a subscription until the context is canceled, the relays to
+// listen on change or the subscription ends.
+func (d *Daemon) serve(c context.T, events chan ws.IncomingEvent, relays []string) {
+	ticker := time.NewTicker(ReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.Done():
+			return
+		case <-ticker.C:
+			if changed, err := d.Perms.Reload(); !chk.E(err) && changed &&
+				!slices.Equal(d.relays(), relays) {
+				return
+			}
+		case ie, ok := <-events:
+			if !ok {
+				return
+			}
+			req, _, resp, err := d.Signer.HandleRequest(c, ie.Event)
+			if chk.D(err) {
+				continue
+			}
+			log.D.F("bunker request %s from %0x", req.Method, ie.Event.Pubkey)
+			if err = ie.Client.Publish(c, resp); chk.E(err) {
+				continue
+			}
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package bunker
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"realy.lol/context"
+	"realy.lol/encryption"
+	"realy.lol/event"
+	"realy.lol/filter"
+	"realy.lol/filters"
+	"realy.lol/hex"
+	"realy.lol/kind"
+	"realy.lol/kinds"
+	"realy.lol/p256k"
+	"realy.lol/tag"
+	"realy.lol/tags"
+	"realy.lol/timestamp"
+	"realy.lol/ws"
+	"realy.lol/ws/wstest"
+)
+
+// newDaemon runs a Daemon on a fake relay with its permissions in a file.
+func newDaemon(t *testing.T, c context.T) (d *Daemon, relay, path string) {
+	sign := &p256k.Signer{}
+	if err := sign.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	relay, path = wstest.Start(t), filepath.Join(t.TempDir(), "permissions.json")
+	perms, err := OpenPermissions(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d = NewDaemon(c, sign, []string{relay}, perms)
+	go d.Run(c)
+	// give the daemon time to subscribe.
+	time.Sleep(200 * time.Millisecond)
+	return
+}
+
+func TestDaemonBunkerURL(t *testing.T) {
+	c, cancel := context.Timeout(context.Bg(), 10*time.Second)
+	defer cancel()
+	d, _, path := newDaemon(t, c)
+	uri, err := d.NewBunkerURL(ParsePerms("sign_event:1,nip44_encrypt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsValidBunkerURL(uri) {
+		t.Fatalf("invalid bunker url %s", uri)
+	}
+	clientKey := &p256k.Signer{}
+	if err = clientKey.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	// a connection string with a wrong secret does not pair the client.
+	if _, err = ConnectBunker(c, clientKey, uri[:len(uri)-4]+"0000", nil,
+		nil); err == nil {
+		t.Fatal("connected with a wrong secret")
+	}
+	client, err := ConnectBunker(c, clientKey, uri, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk, err := client.GetPublicKey(c)
+	if err != nil || pk != hex.Enc(d.Signer.Pubkey()) {
+		t.Fatalf("public key %s %v", pk, err)
+	}
+	ev := &event.T{CreatedAt: timestamp.Now(), Kind: kind.TextNote, Content: []byte("hi")}
+	if err = client.SignEvent(c, ev); err != nil {
+		t.Fatal(err)
+	}
+	if string(ev.Pubkey) != string(d.Signer.Pubkey()) {
+		t.Fatalf("event signed by %0x", ev.Pubkey)
+	}
+	if err = client.SignEvent(c, &event.T{CreatedAt: timestamp.Now(),
+		Kind: kind.Reaction, Content: []byte("+")}); err == nil {
+		t.Fatal("signed an event of a kind that is not permitted")
+	}
+	ciphertext, err := client.NIP44Encrypt(c, clientKey.Pub(), []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = client.NIP44Decrypt(c, clientKey.Pub(), []byte(ciphertext)); err == nil {
+		t.Fatal("decrypted without permission")
+	}
+	// the secret can only be used once, and the pairing is kept in the permissions file.
+	perms, err := OpenPermissions(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, ok := perms.Client(clientKey.Pub()); !ok || p.String() !=
+		"sign_event:1,nip04_encrypt,nip44_encrypt" || len(perms.Secrets) != 0 {
+		t.Fatalf("stored permissions %+v", perms)
+	}
+	if err = perms.Revoke(clientKey.Pub()); err != nil {
+		t.Fatal(err)
+	}
+	if err = client.Ping(c); err == nil {
+		t.Fatal("revoked client was answered")
+	}
+}
+
+func TestDaemonNostrConnect(t *testing.T) {
+	c, cancel := context.Timeout(context.Bg(), 10*time.Second)
+	defer cancel()
+	d, relay, _ := newDaemon(t, c)
+	clientKey := &p256k.Signer{}
+	if err := clientKey.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	nc, err := ParseNostrConnect("nostrconnect://" + hex.Enc(clientKey.Pub()) + "?relay=" +
+		relay + "&secret=s3cret&perms=sign_event:7,nip44_decrypt&name=app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rl, err := ws.RelayConnect(c, relay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rl.Close()
+	sub, err := rl.Subscribe(c, filters.New(&filter.T{Kinds: kinds.New(kind.NostrConnect),
+		Tags: tags.New(tag.New([]byte("#p"), clientKey.Pub()))}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = d.Pair(c, nc); err != nil {
+		t.Fatal(err)
+	}
+	var ev *event.T
+	select {
+	case ev = <-sub.Events:
+	case <-c.Done():
+		t.Fatal("no connect response")
+	}
+	key, _ := encryption.GenerateConversationKey(d.Signer.Pubkey(), clientKey.Sec())
+	plain, err := encryption.Decrypt(ev.Content, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resp Response
+	if err = json.Unmarshal(plain, &resp); err != nil || resp.Result != "s3cret" {
+		t.Fatalf("connect response %s %v", plain, err)
+	}
+	p, ok := d.Perms.Client(clientKey.Pub())
+	if !ok || p.Name != "app" || !p.Decrypt || p.Encrypt || len(p.Kinds) != 1 ||
+		p.Kinds[0] != 7 {
+		t.Fatalf("paired with %+v", p)
+	}
+	// the paired client may use the bunker without a secret.
+	client, err := NewBunker(c, clientKey, d.Signer.Pubkey(), nc.Relays, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = client.SignEvent(c, &event.T{CreatedAt: timestamp.Now(), Kind: kind.Reaction,
+		Content: []byte("+")}); err != nil {
+		t.Fatal(err)
+	}
+}
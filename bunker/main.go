@@ -14,7 +14,7 @@ import (
 type Request struct {
 	ID     string   `json:"id"`
 	Method string   `json:"method"`
-	Params [][]byte `json:"params"`
+	Params []string `json:"params"`
 }
 
 func (r *Request) String() (s string) {
@@ -67,3 +67,31 @@ func IsValidBunkerURL(input string) bool {
 	}
 	return true
 }
+
+// Unsigned is an event as it is sent to be signed in a sign_event request, without its id,
+// pubkey and signature.
+type Unsigned struct {
+	CreatedAt int64      `json:"created_at"`
+	Kind      uint16     `json:"kind"`
+	Tags      [][]string `json:"tags"`
+	Content   string     `json:"content"`
+}
+
+// NewUnsigned returns the fields of an event that are sent to be signed.
+func NewUnsigned(ev *event.T) *Unsigned {
+	u := &Unsigned{CreatedAt: ev.CreatedAt.I64(), Kind: ev.Kind.K, Tags: [][]string{},
+		Content: string(ev.Content)}
+	if ev.Tags != nil {
+		u.Tags = ev.Tags.ToStringsSlice()
+	}
+	return u
+}
+
+// Event returns the event to sign.
+func (u *Unsigned) Event() (ev *event.T) {
+	ev = &event.T{Content: []byte(u.Content)}
+	ev.CreatedAtFromInt64(u.CreatedAt)
+	ev.KindFromInt32(int32(u.Kind))
+	ev.TagsFromStrings(u.Tags...)
+	return
+}
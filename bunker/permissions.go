@@ -0,0 +1,318 @@
+package bunker
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"realy.lol/chk"
+	"realy.lol/errorf"
+	"realy.lol/hex"
+)
+
+// Permission is what a client of a bunker may ask it to do. Every client may use the harmless
+// methods connect, ping, get_public_key and get_relays.
+type Permission struct {
+	// Name is the name the client gave for itself when it was paired.
+	Name string `json:"name,omitempty"`
+	// Kinds are the kinds of events the client may have signed.
+	Kinds []uint16 `json:"kinds,omitempty"`
+	// AllKinds lets the client have events of any kind signed.
+	AllKinds bool `json:"all_kinds,omitempty"`
+	// Encrypt permits nip04_encrypt and nip44_encrypt.
+	Encrypt bool `json:"encrypt,omitempty"`
+	// Decrypt permits nip04_decrypt and nip44_decrypt.
+	Decrypt bool `json:"decrypt,omitempty"`
+	// ECDH permits ecdh, which reveals the shared secret of the key of the bunker with another
+	// pubkey, letting the client decrypt any messages between them.
+	ECDH bool `json:"ecdh,omitempty"`
+	// Relays are the relays the client sends its requests to, if it was paired with a
+	// nostrconnect:// URI.
+	Relays []string `json:"relays,omitempty"`
+}
+
+// ParsePerms reads a comma separated NIP-46 permission list, such as
+// "sign_event:1,sign_event:7,nip44_encrypt", where sign_event without a kind permits all
+// kinds. Unknown permissions are ignored.
+func ParsePerms(perms string) (p *Permission) {
+	p = &Permission{}
+	for _, perm := range strings.Split(perms, ",") {
+		method, param, _ := strings.Cut(strings.TrimSpace(perm), ":")
+		switch method {
+		case "sign_event":
+			if param == "" {
+				p.AllKinds = true
+				continue
+			}
+			if k, err := strconv.ParseUint(param, 10, 16); err == nil &&
+				!slices.Contains(p.Kinds, uint16(k)) {
+				p.Kinds = append(p.Kinds, uint16(k))
+			}
+		case "nip04_encrypt", "nip44_encrypt":
+			p.Encrypt = true
+		case "nip04_decrypt", "nip44_decrypt":
+			p.Decrypt = true
+		case "ecdh":
+			p.ECDH = true
+		}
+	}
+	return
+}
+
+// String returns the permission as a NIP-46 permission list.
+func (p *Permission) String() string {
+	var perms []string
+	if p.AllKinds {
+		perms = append(perms, "sign_event")
+	} else {
+		for _, k := range p.Kinds {
+			perms = append(perms, "sign_event:"+strconv.Itoa(int(k)))
+		}
+	}
+	if p.Encrypt {
+		perms = append(perms, "nip04_encrypt", "nip44_encrypt")
+	}
+	if p.Decrypt {
+		perms = append(perms, "nip04_decrypt", "nip44_decrypt")
+	}
+	if p.ECDH {
+		perms = append(perms, "ecdh")
+	}
+	return strings.Join(perms, ",")
+}
+
+// Permits returns an error if the permission does not allow a request.
+func (p *Permission) Permits(req *Request) (err error) {
+	switch req.Method {
+	case "connect", "ping", "get_public_key", "get_relays":
+	case "sign_event":
+		if p.AllKinds {
+			return
+		}
+		if len(req.Params) != 1 {
+			return errorf.E("wrong number of arguments to 'sign_event'")
+		}
+		var ev struct {
+			Kind uint16 `json:"kind"`
+		}
+		if err = json.Unmarshal([]byte(req.Params[0]), &ev); err != nil {
+			return errorf.E("invalid event to sign: %v", err)
+		}
+		if !slices.Contains(p.Kinds, ev.Kind) {
+			return errorf.E("not permitted to sign events of kind %d", ev.Kind)
+		}
+	case "nip04_encrypt", "nip44_encrypt":
+		if !p.Encrypt {
+			return errorf.E("not permitted to encrypt")
+		}
+	case "nip04_decrypt", "nip44_decrypt":
+		if !p.Decrypt {
+			return errorf.E("not permitted to decrypt")
+		}
+	case "ecdh":
+		if !p.ECDH {
+			return errorf.E("not permitted to compute shared secrets")
+		}
+	}
+	return
+}
+
+// Permissions are the clients of a bunker and what they may do, and the unused secrets of the
+// bunker:// connection strings it has made with the permissions they grant. They are kept in a
+// JSON file, which is read again when it is changed so that another process, such as a command
+// that pairs a client, can change them.
+type Permissions struct {
+	sync.Mutex
+	path    string
+	modTime time.Time
+	// Clients are the permissions of the paired clients by their hex pubkey.
+	Clients map[string]*Permission `json:"clients"`
+	// Secrets are the permissions granted by the secrets of connection strings that have not
+	// been used.
+	Secrets map[string]*Permission `json:"secrets"`
+}
+
+// OpenPermissions reads the permissions in a file, which is created when they are changed if
+// it does not exist.
+func OpenPermissions(path string) (p *Permissions, err error) {
+	p = &Permissions{path: path}
+	if _, err = p.reload(); err != nil {
+		return
+	}
+	return
+}
+
+// reload reads the permissions file if it has changed since it was last read, returning true
+// if it was read.
+//
+// This must be called with the mutex locked, except while opening.
+func (p *Permissions) reload() (changed bool, err error) {
+	var fi os.FileInfo
+	if fi, err = os.Stat(p.path); os.IsNotExist(err) {
+		err = nil
+		if p.Clients == nil {
+			p.Clients, p.Secrets = make(map[string]*Permission), make(map[string]*Permission)
+		}
+		return
+	} else if chk.E(err) {
+		return
+	}
+	if fi.ModTime().Equal(p.modTime) {
+		return
+	}
+	var b []byte
+	if b, err = os.ReadFile(p.path); chk.E(err) {
+		return
+	}
+	p.Clients, p.Secrets = nil, nil
+	if err = json.Unmarshal(b, p); err != nil {
+		err = errorf.E("reading %s: %v", p.path, err)
+		return
+	}
+	if p.Clients == nil {
+		p.Clients = make(map[string]*Permission)
+	}
+	if p.Secrets == nil {
+		p.Secrets = make(map[string]*Permission)
+	}
+	p.modTime, changed = fi.ModTime(), true
+	return
+}
+
+// save writes the permissions file, readable only by the user as it contains secrets.
+//
+// This must be called with the mutex locked.
+func (p *Permissions) save() (err error) {
+	var b []byte
+	if b, err = json.MarshalIndent(p, "", "  "); chk.E(err) {
+		return
+	}
+	if err = os.MkdirAll(filepath.Dir(p.path), 0700); chk.E(err) {
+		return
+	}
+	tmp := p.path + ".tmp"
+	if err = os.WriteFile(tmp, b, 0600); chk.E(err) {
+		return
+	}
+	if err = os.Rename(tmp, p.path); chk.E(err) {
+		return
+	}
+	var fi os.FileInfo
+	if fi, err = os.Stat(p.path); chk.E(err) {
+		return
+	}
+	p.modTime = fi.ModTime()
+	return
+}
+
+// update reads the permissions file again, applies a change and saves it.
+func (p *Permissions) update(fn func() error) (err error) {
+	p.Lock()
+	defer p.Unlock()
+	if _, err = p.reload(); err != nil {
+		return
+	}
+	if err = fn(); err != nil {
+		return
+	}
+	return p.save()
+}
+
+// Reload reads the permissions file again if it has changed, returning true if it did.
+func (p *Permissions) Reload() (changed bool, err error) {
+	p.Lock()
+	defer p.Unlock()
+	return p.reload()
+}
+
+// Client returns the permission of a client, if it has been paired.
+func (p *Permissions) Client(pubkey []byte) (perm *Permission, ok bool) {
+	p.Lock()
+	defer p.Unlock()
+	if _, err := p.reload(); chk.E(err) {
+		return
+	}
+	perm, ok = p.Clients[hex.Enc(pubkey)]
+	return
+}
+
+// Grant pairs a client with a permission, replacing any it had.
+func (p *Permissions) Grant(pubkey []byte, perm *Permission) (err error) {
+	return p.update(func() error {
+		p.Clients[hex.Enc(pubkey)] = perm
+		return nil
+	})
+}
+
+// Revoke removes a client, which must be paired again to use the bunker.
+func (p *Permissions) Revoke(pubkey []byte) (err error) {
+	return p.update(func() error {
+		if _, ok := p.Clients[hex.Enc(pubkey)]; !ok {
+			return errorf.E("client %0x is not paired", pubkey)
+		}
+		delete(p.Clients, hex.Enc(pubkey))
+		return nil
+	})
+}
+
+// AddSecret stores the secret of a new connection string with the permission it grants.
+func (p *Permissions) AddSecret(secret string, perm *Permission) (err error) {
+	return p.update(func() error {
+		p.Secrets[secret] = perm
+		return nil
+	})
+}
+
+// Relays returns the relays of the clients paired with nostrconnect:// URIs.
+func (p *Permissions) Relays() (relays []string) {
+	p.Lock()
+	defer p.Unlock()
+	for _, c := range p.Clients {
+		for _, r := range c.Relays {
+			if !slices.Contains(relays, r) {
+				relays = append(relays, r)
+			}
+		}
+	}
+	return
+}
+
+// Authorize is the StaticKeySigner.AuthorizeRequest of a bunker. A connect request with the
+// unused secret of a connection string pairs the client with the permission of the secret,
+// which cannot be used again. Other requests are allowed only from paired clients with the
+// permission for them.
+func (p *Permissions) Authorize(from []byte, req *Request) (err error) {
+	if req.Method == "connect" && len(req.Params) >= 2 && req.Params[1] != "" {
+		if used, e := p.useSecret(from, req.Params[1]); e != nil || used {
+			return e
+		}
+	}
+	perm, ok := p.Client(from)
+	if !ok {
+		return errorf.E("unauthorized")
+	}
+	return perm.Permits(req)
+}
+
+// useSecret pairs a client with the permission of an unused secret, returning true if it was
+// one.
+func (p *Permissions) useSecret(pubkey []byte, secret string) (used bool, err error) {
+	p.Lock()
+	defer p.Unlock()
+	if _, err = p.reload(); err != nil {
+		return
+	}
+	var perm *Permission
+	if perm, used = p.Secrets[secret]; !used {
+		return
+	}
+	delete(p.Secrets, secret)
+	p.Clients[hex.Enc(pubkey)] = perm
+	err = p.save()
+	return
+}
@@ -0,0 +1,240 @@
+package bunker
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"realy.lol/chk"
+	"realy.lol/context"
+	"realy.lol/encryption"
+	"realy.lol/errorf"
+	"realy.lol/event"
+	"realy.lol/hex"
+	"realy.lol/keys"
+	"realy.lol/keystore"
+	"realy.lol/p256k"
+	"realy.lol/signer"
+)
+
+// RemoteTimeout is how long a RemoteSigner waits for the bunker to answer a request, which
+// may include the user approving it.
+var RemoteTimeout = time.Minute
+
+// RemoteSigner is a signer.I whose key is kept by a NIP-46 bunker, so that code that signs
+// events with a signer.I can use a bunker. As the bunker only signs whole events, Sign of a
+// bare hash is refused and events must be signed with event.T Sign, which gives them to the
+// bunker. ECDH and the NIP-44 conversation keys derived from it need the bunker to give the
+// client the non-standard ecdh permission.
+type RemoteSigner struct {
+	Client *BunkerClient
+	ctx    context.T
+	cancel context.F
+	pub    []byte
+}
+
+var _ event.EventSigner = (*RemoteSigner)(nil)
+
+// NewRemoteSigner creates a RemoteSigner using a connected BunkerClient, asking it for the
+// pubkey of the bunker.
+func NewRemoteSigner(c context.T, client *BunkerClient) (s *RemoteSigner, err error) {
+	s = &RemoteSigner{Client: client, ctx: c}
+	tc, cancel := context.Timeout(c, RemoteTimeout)
+	defer cancel()
+	var pk string
+	if pk, err = client.GetPublicKey(tc); chk.E(err) {
+		return
+	}
+	if !keys.IsValidPublicKey(pk) {
+		err = errorf.E("bunker returned an invalid pubkey '%s'", pk)
+		return
+	}
+	if s.pub, err = keys.HexPubkeyToBytes(pk); chk.E(err) {
+		return
+	}
+	return
+}
+
+// Generate cannot be done by a RemoteSigner.
+func (s *RemoteSigner) Generate() (err error) {
+	return errorf.E("cannot generate the key of a remote signer")
+}
+
+// InitSec cannot be done by a RemoteSigner.
+func (s *RemoteSigner) InitSec([]byte) (err error) {
+	return errorf.E("cannot set the secret key of a remote signer")
+}
+
+// InitPub cannot be done by a RemoteSigner, whose pubkey is that of the bunker.
+func (s *RemoteSigner) InitPub([]byte) (err error) {
+	return errorf.E("cannot set the public key of a remote signer")
+}
+
+// Sec returns nil, as the secret key is kept by the bunker.
+func (s *RemoteSigner) Sec() []byte { return nil }
+
+// Pub returns the pubkey of the bunker.
+func (s *RemoteSigner) Pub() []byte { return s.pub }
+
+// Sign refuses to sign, as a bunker only signs whole events.
+func (s *RemoteSigner) Sign([]byte) (sig []byte, err error) {
+	return nil, errorf.E("a remote signer can only sign events")
+}
+
+// Verify checks a signature against the pubkey of the bunker.
+func (s *RemoteSigner) Verify(msg, sig []byte) (valid bool, err error) {
+	v := &p256k.Signer{}
+	if err = v.InitPub(s.pub); chk.E(err) {
+		return
+	}
+	return v.Verify(msg, sig)
+}
+
+// Zero does nothing, as there is no secret key to wipe.
+func (s *RemoteSigner) Zero() {}
+
+// Close ends the connection to the bunker, if the RemoteSigner was made by NewSigner.
+func (s *RemoteSigner) Close() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+}
+
+// ECDH asks the bunker for the shared secret of its key with another pubkey.
+func (s *RemoteSigner) ECDH(pub []byte) (secret []byte, err error) {
+	c, cancel := context.Timeout(s.ctx, RemoteTimeout)
+	defer cancel()
+	return s.Client.ECDH(c, pub)
+}
+
+// ConversationKey returns the NIP-44 conversation key of the key of the bunker with another
+// pubkey, derived from the shared secret given by ECDH.
+func (s *RemoteSigner) ConversationKey(pub []byte) (ck []byte, err error) {
+	var shared []byte
+	if shared, err = s.ECDH(pub); err != nil {
+		return
+	}
+	ck = encryption.ConversationKeyFromSharedSecret(shared)
+	return
+}
+
+// NIP44Encrypt has the bunker encrypt a message to another pubkey, which only needs the
+// standard nip44_encrypt permission.
+func (s *RemoteSigner) NIP44Encrypt(pub, plaintext []byte) (ciphertext []byte, err error) {
+	c, cancel := context.Timeout(s.ctx, RemoteTimeout)
+	defer cancel()
+	var res string
+	if res, err = s.Client.NIP44Encrypt(c, pub, plaintext); err != nil {
+		return
+	}
+	return []byte(res), nil
+}
+
+// NIP44Decrypt has the bunker decrypt a message from another pubkey, which only needs the
+// standard nip44_decrypt permission.
+func (s *RemoteSigner) NIP44Decrypt(pub, ciphertext []byte) (plaintext []byte, err error) {
+	c, cancel := context.Timeout(s.ctx, RemoteTimeout)
+	defer cancel()
+	var res string
+	if res, err = s.Client.NIP44Decrypt(c, pub, ciphertext); err != nil {
+		return
+	}
+	return []byte(res), nil
+}
+
+// SignEvent has the bunker sign an event, checking it was signed with the expected key.
+func (s *RemoteSigner) SignEvent(ev *event.T) (err error) {
+	c, cancel := context.Timeout(s.ctx, RemoteTimeout)
+	defer cancel()
+	if err = s.Client.SignEvent(c, ev); err != nil {
+		return
+	}
+	if !bytes.Equal(ev.Pubkey, s.pub) {
+		return errorf.E("bunker signed the event with %0x instead of %0x", ev.Pubkey, s.pub)
+	}
+	return
+}
+
+// ClientKey returns the key a client connects to bunkers with, kept in a file so that it stays
+// paired after the one-time secret of a bunker:// connection string is used. A new key is
+// made and saved if the file does not exist.
+func ClientKey(path string) (sign signer.I, err error) {
+	sign = &p256k.Signer{}
+	var b []byte
+	if b, err = os.ReadFile(path); err == nil {
+		var sec []byte
+		if sec, err = hex.Dec(strings.TrimSpace(string(b))); chk.E(err) {
+			err = errorf.E("invalid client key in %s: %v", path, err)
+			return
+		}
+		if err = sign.InitSec(sec); chk.E(err) {
+			return
+		}
+		return
+	} else if !os.IsNotExist(err) {
+		return
+	}
+	if err = sign.Generate(); chk.E(err) {
+		return
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0700); chk.E(err) {
+		return
+	}
+	if err = os.WriteFile(path, []byte(hex.Enc(sign.Sec())+"\n"), 0600); chk.E(err) {
+		return
+	}
+	return
+}
+
+// NewSigner returns a signer.I for a key given as an nsec, a hex secret key, an ncryptsec or a
+// file containing one of them as read by keystore.Open, or a bunker:// connection string or
+// NIP-05 address of a bunker, which is connected to with the client key kept in
+// clientKeyFile. onAuth is called with the URL of any auth challenge from the bunker.
+func NewSigner(c context.T, key, clientKeyFile string,
+	onAuth func(string)) (sign signer.I, err error) {
+	key = strings.TrimSpace(key)
+	switch {
+	case strings.HasPrefix(key, "bunker://") || strings.Contains(key, "@"):
+		var client signer.I
+		if client, err = ClientKey(clientKeyFile); err != nil {
+			return
+		}
+		// the subscription for the responses of the bunker lasts as long as the context the
+		// client is connected with, so the connect request is timed out here instead.
+		cc, cancel := context.Cancel(c)
+		type connected struct {
+			bc  *BunkerClient
+			err error
+		}
+		done := make(chan connected, 1)
+		go func() {
+			bc, e := ConnectBunker(cc, client, key, nil, onAuth)
+			done <- connected{bc, e}
+		}()
+		var res connected
+		select {
+		case res = <-done:
+		case <-time.After(RemoteTimeout):
+			res.err = errorf.E("bunker did not answer the connect request")
+		}
+		if err = res.err; err != nil {
+			cancel()
+			return
+		}
+		var rs *RemoteSigner
+		if rs, err = NewRemoteSigner(cc, res.bc); err != nil {
+			cancel()
+			return
+		}
+		rs.cancel = cancel
+		return rs, nil
+	default:
+		var ks *p256k.Signer
+		if ks, err = keystore.Open(key, keystore.Prompt("passphrase: ")); err != nil {
+			return
+		}
+		return ks, nil
+	}
+}
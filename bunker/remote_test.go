@@ -0,0 +1,82 @@
+package bunker
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"realy.lol/context"
+	"realy.lol/encryption"
+	"realy.lol/event"
+	"realy.lol/kind"
+	"realy.lol/p256k"
+	"realy.lol/timestamp"
+)
+
+func TestRemoteSigner(t *testing.T) {
+	c, cancel := context.Timeout(context.Bg(), 10*time.Second)
+	defer cancel()
+	d, _, _ := newDaemon(t, c)
+	uri, err := d.NewBunkerURL(ParsePerms("sign_event:1,nip44_encrypt,ecdh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "client.key")
+	sign, err := NewSigner(c, uri, keyFile, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(sign.Pub(), d.Signer.Pubkey()) {
+		t.Fatalf("remote signer has pubkey %0x", sign.Pub())
+	}
+	ev := &event.T{CreatedAt: timestamp.Now(), Kind: kind.TextNote, Content: []byte("hi")}
+	if err = ev.Sign(sign); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := ev.Verify(); err != nil || !ok {
+		t.Fatalf("remote signature valid %v %v", ok, err)
+	}
+	if _, err = sign.Sign(ev.Id); err == nil {
+		t.Fatal("signed a bare hash")
+	}
+	other := &p256k.Signer{}
+	if err = other.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	rs := sign.(*RemoteSigner)
+	ck, err := rs.ConversationKey(other.Pub())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, _ := encryption.GenerateConversationKey(d.Signer.Pubkey(),
+		other.Sec()); !bytes.Equal(ck, expected) {
+		t.Fatal("conversation key does not match")
+	}
+	ciphertext, err := rs.NIP44Encrypt(other.Pub(), []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain, err := encryption.Decrypt(ciphertext, ck); err != nil ||
+		string(plain) != "secret" {
+		t.Fatalf("decrypted %s %v", plain, err)
+	}
+	rs.Close()
+	// the client key is kept, so the used connection string still works.
+	if sign, err = NewSigner(c, uri, keyFile, nil); err != nil {
+		t.Fatal(err)
+	}
+	sign.(*RemoteSigner).Close()
+	// a client without the ecdh permission cannot compute conversation keys.
+	if uri, err = d.NewBunkerURL(ParsePerms("sign_event:1")); err != nil {
+		t.Fatal(err)
+	}
+	if sign, err = NewSigner(c, uri, filepath.Join(t.TempDir(), "client.key"),
+		nil); err != nil {
+		t.Fatal(err)
+	}
+	defer sign.(*RemoteSigner).Close()
+	if _, err = sign.ECDH(other.Pub()); err == nil {
+		t.Fatal("computed a shared secret without permission")
+	}
+}
@@ -31,18 +31,10 @@ func (s *Session) ParseRequest(ev *event.T) (req *Request, err error) {
 
 func (s *Session) MakeResponse(id, requester, result string,
 	rErr error) (resp *Response, ev *event.T, err error) {
+	resp = &Response{ID: id, Result: result}
 	if rErr != nil {
-		resp = &Response{
-			ID:     id,
-			Result: rErr.Error(),
-		}
-	} else if len(result) > 0 {
-		resp = &Response{
-			ID:     id,
-			Result: result,
-		}
+		resp = &Response{ID: id, Error: rErr.Error()}
 	}
-	// todo: what if the response is empty? this shouldn't happen i think?
 	var j []byte
 	if j, err = json.Marshal(resp); chk.E(err) {
 		return
@@ -2,7 +2,6 @@ package bunker
 
 import (
 	"encoding/json"
-	"fmt"
 	"sync"
 
 	"realy.lol/chk"
@@ -24,14 +23,19 @@ type StaticKeySigner struct {
 	secretKey         signer.I
 	sessions          map[string]*Session
 	RelaysToAdvertise map[string]RelayReadWrite
-	AuthorizeRequest  func(harmless bool, from, secret []byte) bool
+	// AuthorizeRequest decides whether a client may make a request, which is refused with
+	// the error it returns. All requests are allowed if it is nil.
+	AuthorizeRequest func(from []byte, req *Request) error
 }
 
 func NewStaticKeySigner(secretKey signer.I) *StaticKeySigner {
-	return &StaticKeySigner{secretKey: secretKey,
+	return &StaticKeySigner{secretKey: secretKey, sessions: make(map[string]*Session),
 		RelaysToAdvertise: make(map[string]RelayReadWrite)}
 }
 
+// Pubkey returns the pubkey of the key the signer signs with.
+func (p *StaticKeySigner) Pubkey() []byte { return p.secretKey.Pub() }
+
 func (p *StaticKeySigner) GetSession(clientPubkey string) (s *Session, exists bool) {
 	p.Lock()
 	defer p.Unlock()
@@ -42,17 +46,17 @@ func (p *StaticKeySigner) GetSession(clientPubkey string) (s *Session, exists bo
 func (p *StaticKeySigner) getOrCreateSession(clientPubkey []byte) (s *Session, err error) {
 	p.Lock()
 	defer p.Unlock()
-	s = new(Session)
 	var exists bool
 	if s, exists = p.sessions[string(clientPubkey)]; exists {
 		return
 	}
+	s = new(Session)
 	if s.SharedKey, err = encryption.ComputeSharedSecret(clientPubkey,
 		p.secretKey.Sec()); chk.E(err) {
 		return
 	}
 	if s.ConversationKey, err = encryption.GenerateConversationKey(clientPubkey,
-		p.secretKey.Pub()); chk.E(err) {
+		p.secretKey.Sec()); chk.E(err) {
 		return
 	}
 	s.Pubkey = p.secretKey.Pub()
@@ -75,101 +79,93 @@ func (p *StaticKeySigner) HandleRequest(_ context.T, ev *event.T) (req *Request,
 	if req, err = session.ParseRequest(ev); chk.E(err) {
 		return
 	}
-	var secret, result []byte
-	var harmless bool
+	var result []byte
 	var rErr error
+	if p.AuthorizeRequest != nil {
+		rErr = p.AuthorizeRequest(ev.Pubkey, req)
+	}
+	if rErr == nil {
+		result, rErr = p.call(session, req)
+	}
+	if res, eventResponse, err = session.MakeResponse(req.ID, hex.Enc(ev.Pubkey),
+		string(result), rErr); chk.E(err) {
+		return
+	}
+	if err = eventResponse.Sign(p.secretKey); chk.E(err) {
+		return
+	}
+	return
+}
+
+// call does the work of a request.
+func (p *StaticKeySigner) call(session *Session, req *Request) (result []byte, rErr error) {
 	switch req.Method {
 	case "connect":
-		if len(req.Params) >= 2 {
-			secret = req.Params[1]
-		}
 		result = []byte("ack")
-		harmless = true
 	case "get_public_key":
-		result = session.Pubkey
-		harmless = true
+		result = []byte(hex.Enc(session.Pubkey))
 	case "sign_event":
 		if len(req.Params) != 1 {
 			rErr = errorf.E("wrong number of arguments to 'sign_event'")
-			break
+			return
 		}
-		evt := &event.T{}
-		if rErr = json.Unmarshal(req.Params[0], evt); chk.E(rErr) {
-			break
+		var u Unsigned
+		if rErr = json.Unmarshal([]byte(req.Params[0]), &u); chk.E(rErr) {
+			return
 		}
+		evt := u.Event()
 		if rErr = evt.Sign(p.secretKey); chk.E(rErr) {
-			break
+			return
 		}
 		result = evt.Serialize()
 	case "get_relays":
 		if result, rErr = json.Marshal(p.RelaysToAdvertise); chk.E(rErr) {
-			break
-		}
-		harmless = true
-	case "nip44_encrypt":
-		var pk, sharedSecret []byte
-		if pk, rErr = CheckParamsAndKey(req); chk.E(err) {
-			break
-		}
-		if sharedSecret, rErr = p.GetConversationKey(pk); chk.E(err) {
-			break
-		}
-		if result, rErr = encryption.Encrypt(req.Params[1], sharedSecret); chk.E(err) {
-			break
-		}
-	case "nip44_decrypt":
-		var pk, sharedSecret []byte
-		if pk, rErr = CheckParamsAndKey(req); chk.E(err) {
-			break
-		}
-		if sharedSecret, rErr = p.GetConversationKey(pk); chk.E(err) {
-			break
-		}
-		if result, err = encryption.Decrypt(req.Params[1], sharedSecret); chk.E(err) {
-			break
-		}
-	case "nip04_encrypt":
-		var pk, sharedSecret []byte
-		if pk, rErr = CheckParamsAndKey(req); chk.E(err) {
-			break
-		}
-		if sharedSecret, rErr = p.ComputeSharedSecret(pk); chk.E(err) {
-			break
-		}
-		if result, rErr = encryption.EncryptNip4(req.Params[1],
-			sharedSecret); chk.E(err) {
-			break
-		}
-	case "nip04_decrypt":
-		var pk, sharedSecret []byte
-		if pk, rErr = CheckParamsAndKey(req); chk.E(err) {
-			break
-		}
-		if sharedSecret, rErr = p.ComputeSharedSecret(pk); chk.E(err) {
-			break
-		}
-		if result, rErr = encryption.DecryptNip4(req.Params[1],
-			sharedSecret); chk.E(err) {
-			break
-		}
+			return
+		}
+	case "nip44_encrypt", "nip44_decrypt", "nip04_encrypt", "nip04_decrypt":
+		var pk, key []byte
+		if pk, rErr = CheckParamsAndKey(req); chk.E(rErr) {
+			return
+		}
+		if req.Method[:5] == "nip44" {
+			key, rErr = p.GetConversationKey(pk)
+		} else {
+			key, rErr = p.ComputeSharedSecret(pk)
+		}
+		if chk.E(rErr) {
+			return
+		}
+		text := []byte(req.Params[1])
+		switch req.Method {
+		case "nip44_encrypt":
+			result, rErr = encryption.Encrypt(text, key)
+		case "nip44_decrypt":
+			result, rErr = encryption.Decrypt(text, key)
+		case "nip04_encrypt":
+			result, rErr = encryption.EncryptNip4(text, key)
+		case "nip04_decrypt":
+			result, rErr = encryption.DecryptNip4(text, key)
+		}
+	case "ecdh":
+		// not part of NIP-46, this lets a client that is trusted with it compute NIP-44
+		// conversation keys itself.
+		if len(req.Params) != 1 || !keys.IsValidPublicKey(req.Params[0]) {
+			rErr = errorf.E("argument to 'ecdh' is not a pubkey string")
+			return
+		}
+		var pk, shared []byte
+		if pk, rErr = keys.HexPubkeyToBytes(req.Params[0]); chk.E(rErr) {
+			return
+		}
+		if shared, rErr = p.ComputeSharedSecret(pk); chk.E(rErr) {
+			return
+		}
+		result = []byte(hex.Enc(shared))
 	case "ping":
 		result = []byte("pong")
-		harmless = true
 	default:
 		rErr = errorf.E("unknown method '%s'", req.Method)
 	}
-	if rErr == nil && p.AuthorizeRequest != nil {
-		if !p.AuthorizeRequest(harmless, ev.Pubkey, secret) {
-			rErr = fmt.Errorf("unauthorized")
-		}
-	}
-	if res, eventResponse, err = session.MakeResponse(req.ID, hex.Enc(ev.Pubkey),
-		string(result), rErr); chk.E(err) {
-		return
-	}
-	if err = eventResponse.Sign(p.secretKey); chk.E(err) {
-		return
-	}
 	return
 }
 
@@ -191,15 +187,15 @@ func (p *StaticKeySigner) ComputeSharedSecret(pk []byte) (sharedSecret []byte, r
 
 func CheckParamsAndKey(req *Request) (pk []byte, rErr error) {
 	if len(req.Params) != 2 {
-		rErr = errorf.E("wrong number of arguments to 'nip04_decrypt'")
+		rErr = errorf.E("wrong number of arguments to '%s'", req.Method)
 		return
 	}
 	if !keys.IsValidPublicKey(req.Params[0]) {
-		rErr = errorf.E("first argument to 'nip04_decrypt' is not a pubkey string")
+		rErr = errorf.E("first argument to '%s' is not a pubkey string", req.Method)
 		return
 	}
 	pk = make([]byte, schnorr.PubKeyBytesLen)
-	if _, rErr = hex.DecBytes(pk, req.Params[0]); chk.E(rErr) {
+	if _, rErr = hex.DecBytes(pk, []byte(req.Params[0])); chk.E(rErr) {
 		return
 	}
 	return
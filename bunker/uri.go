@@ -0,0 +1,64 @@
+package bunker
+
+import (
+	"net/url"
+
+	"realy.lol/chk"
+	"realy.lol/errorf"
+	"realy.lol/hex"
+	"realy.lol/keys"
+)
+
+// BunkerURL returns the bunker:// connection string a client connects to a bunker with.
+func BunkerURL(pubkey []byte, relays []string, secret string) string {
+	q := url.Values{"relay": relays}
+	if secret != "" {
+		q.Set("secret", secret)
+	}
+	return "bunker://" + hex.Enc(pubkey) + "?" + q.Encode()
+}
+
+// NostrConnect is a nostrconnect:// URI, with which a client asks a bunker to pair with it.
+type NostrConnect struct {
+	// Client is the pubkey the client signs its requests with.
+	Client []byte
+	// Relays are the relays the client sends its requests to.
+	Relays []string
+	// Secret is sent back to the client in the connect response, to prove that the bunker
+	// was given the URI.
+	Secret string
+	// Perms are the permissions the client asks for.
+	Perms *Permission
+}
+
+// ParseNostrConnect reads a nostrconnect:// URI.
+func ParseNostrConnect(uri string) (nc *NostrConnect, err error) {
+	var u *url.URL
+	if u, err = url.Parse(uri); chk.D(err) {
+		return
+	}
+	if u.Scheme != "nostrconnect" {
+		err = errorf.E("wrong scheme '%s', must be nostrconnect://", u.Scheme)
+		return
+	}
+	q := u.Query()
+	nc = &NostrConnect{Relays: q["relay"], Secret: q.Get("secret"),
+		Perms: ParsePerms(q.Get("perms"))}
+	nc.Perms.Name, nc.Perms.Relays = q.Get("name"), nc.Relays
+	if !keys.IsValidPublicKey(u.Host) {
+		err = errorf.E("'%s' is not a valid public key hex", u.Host)
+		return
+	}
+	if nc.Client, err = keys.HexPubkeyToBytes(u.Host); chk.E(err) {
+		return
+	}
+	if len(nc.Relays) == 0 {
+		err = errorf.E("nostrconnect:// URI has no relays")
+		return
+	}
+	if nc.Secret == "" {
+		err = errorf.E("nostrconnect:// URI has no secret")
+		return
+	}
+	return
+}
@@ -0,0 +1,149 @@
+package cdc
+
+import (
+	"encoding/json"
+
+	"realy.lol/chk"
+	"realy.lol/context"
+	"realy.lol/event"
+	"realy.lol/eventidserial"
+	"realy.lol/filter"
+	"realy.lol/hex"
+	"realy.lol/tag"
+)
+
+// Op is the kind of change made to the event store.
+type Op string
+
+const (
+	Save   Op = "save"
+	Delete Op = "delete"
+)
+
+// MaxCount is the most changes returned by one Read.
+const MaxCount = 500
+
+// Change is a save or deletion of an event.
+type Change struct {
+	Serial uint64 `json:"serial"`
+	Op     Op     `json:"op"`
+	// Id is the hex encoded id of the event.
+	Id string `json:"id"`
+	// Tombstone is true if a deleted event may not be saved again.
+	Tombstone bool `json:"tombstone,omitempty"`
+	// Event is the saved event, if it is still stored.
+	Event json.RawMessage `json:"event,omitempty"`
+}
+
+// Source is an event store that records its deletions.
+type Source interface {
+	EventIdsBySerial(start uint64, count int) (evs []eventidserial.E, err error)
+	QueryEvents(c context.T, f *filter.T) (evs event.Ts, err error)
+	// Deletions returns the deletions from a serial in ascending order.
+	Deletions(start uint64, count int) (dd []Change, err error)
+	// Changed returns a channel that is closed when an event is next saved or deleted.
+	Changed() <-chan struct{}
+	// Committed returns the serial below which all saves and deletions are committed. Serials
+	// are allocated before the changes they are for are committed, so a change with a higher
+	// serial may be visible before one with a lower serial.
+	Committed() uint64
+}
+
+// Read returns the changes from a serial, in serial order, up to the serial below which all
+// changes are committed, so that a change committed later cannot be skipped.
+func Read(c context.T, src Source, from uint64, count int) (changes []Change, err error) {
+	if count <= 0 || count > MaxCount {
+		count = MaxCount
+	}
+	// the watermark is taken before reading, so every serial below it is in what is read.
+	committed := src.Committed()
+	var saves []eventidserial.E
+	if saves, err = src.EventIdsBySerial(from, count); chk.E(err) {
+		return
+	}
+	var deletes []Change
+	if deletes, err = src.Deletions(from, count); chk.E(err) {
+		return
+	}
+	// merge the two ascending lists, the first count of both are enough for the first count
+	// of the result.
+	var i, j int
+	for len(changes) < count && (i < len(saves) || j < len(deletes)) {
+		if j == len(deletes) || (i < len(saves) && saves[i].Serial < deletes[j].Serial) {
+			if saves[i].Serial >= committed {
+				break
+			}
+			changes = append(changes, Change{Serial: saves[i].Serial, Op: Save,
+				Id: saves[i].EventId})
+			i++
+			continue
+		}
+		if deletes[j].Serial >= committed {
+			break
+		}
+		changes = append(changes, deletes[j])
+		j++
+	}
+	err = fetch(c, src, changes)
+	return
+}
+
+// fetch fills in the events of the saves in a list of changes.
+func fetch(c context.T, src Source, changes []Change) (err error) {
+	ids := tag.NewWithCap(len(changes))
+	for _, ch := range changes {
+		if ch.Op != Save {
+			continue
+		}
+		var b []byte
+		if b, err = hex.Dec(ch.Id); chk.E(err) {
+			return
+		}
+		ids.Append(b)
+	}
+	if ids.Len() == 0 {
+		return
+	}
+	var evs event.Ts
+	limit := uint(ids.Len())
+	if evs, err = src.QueryEvents(c, &filter.T{IDs: ids, Limit: &limit}); chk.E(err) {
+		return
+	}
+	byId := make(map[string]*event.T, len(evs))
+	for _, ev := range evs {
+		byId[hex.Enc(ev.Id)] = ev
+	}
+	for i := range changes {
+		if ev, ok := byId[changes[i].Id]; ok && changes[i].Op == Save {
+			changes[i].Event = ev.Serialize()
+		}
+	}
+	return
+}
+
+// Follow sends the changes from a serial as they are made, until the context is canceled or
+// send returns an error.
+func Follow(c context.T, src Source, from uint64, send func(ch Change) error) (err error) {
+	for {
+		// take the channel before reading so a change made in between is not missed.
+		changed := src.Changed()
+		var changes []Change
+		if changes, err = Read(c, src, from, MaxCount); err != nil {
+			return
+		}
+		for _, ch := range changes {
+			if err = send(ch); err != nil {
+				return
+			}
+			from = ch.Serial + 1
+		}
+		if len(changes) == MaxCount {
+			continue
+		}
+		select {
+		case <-c.Done():
+			return
+		case <-changed:
+		}
+	}
+}
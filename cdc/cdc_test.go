@@ -0,0 +1,197 @@
+package cdc
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"realy.lol/context"
+	"realy.lol/event"
+	"realy.lol/eventidserial"
+	"realy.lol/filter"
+	"realy.lol/hex"
+	"realy.lol/kind"
+	"realy.lol/sha256"
+	"realy.lol/tags"
+	"realy.lol/timestamp"
+)
+
+// memSource is an event store where each serial is either a save or a deletion, or a save
+// that is not yet committed.
+type memSource struct {
+	sync.Mutex
+	changes     []Change
+	evs         map[string]*event.T
+	changed     chan struct{}
+	uncommitted map[uint64]struct{}
+}
+
+func newMemSource() *memSource {
+	return &memSource{evs: make(map[string]*event.T), changed: make(chan struct{}),
+		uncommitted: make(map[uint64]struct{})}
+}
+
+// reserve allocates a serial for a save that is committed later with commit.
+func (m *memSource) reserve() (serial uint64) {
+	m.Lock()
+	defer m.Unlock()
+	serial = uint64(len(m.changes))
+	m.changes = append(m.changes, Change{Serial: serial})
+	m.uncommitted[serial] = struct{}{}
+	return
+}
+
+func (m *memSource) commit(serial uint64, content string) {
+	m.Lock()
+	ev := &event.T{CreatedAt: timestamp.Now(), Kind: kind.TextNote, Tags: tags.New()}
+	id := sha256.Sum256([]byte(content))
+	ev.Id = id[:]
+	ev.Content = []byte(content)
+	m.evs[hex.Enc(ev.Id)] = ev
+	m.changes[serial] = Change{Serial: serial, Op: Save, Id: hex.Enc(ev.Id)}
+	delete(m.uncommitted, serial)
+	close(m.changed)
+	m.changed = make(chan struct{})
+	m.Unlock()
+}
+
+func (m *memSource) save(content string) { m.commit(m.reserve(), content) }
+
+func (m *memSource) delete(content string) {
+	m.Lock()
+	sum := sha256.Sum256([]byte(content))
+	id := hex.Enc(sum[:])
+	delete(m.evs, id)
+	m.changes = append(m.changes, Change{Serial: uint64(len(m.changes)), Op: Delete, Id: id,
+		Tombstone: true})
+	close(m.changed)
+	m.changed = make(chan struct{})
+	m.Unlock()
+}
+
+func (m *memSource) EventIdsBySerial(start uint64, count int) (ids []eventidserial.E,
+	err error) {
+	m.Lock()
+	defer m.Unlock()
+	for _, ch := range m.changes[min(int(start), len(m.changes)):] {
+		if _, ok := m.evs[ch.Id]; ok && ch.Op == Save && len(ids) < count {
+			ids = append(ids, eventidserial.E{Serial: ch.Serial, EventId: ch.Id})
+		}
+	}
+	return
+}
+
+func (m *memSource) QueryEvents(c context.T, f *filter.T) (evs event.Ts, err error) {
+	m.Lock()
+	defer m.Unlock()
+	for _, ev := range m.evs {
+		if f.IDs.Contains(ev.Id) {
+			evs = append(evs, ev)
+		}
+	}
+	return
+}
+
+func (m *memSource) Deletions(start uint64, count int) (dd []Change, err error) {
+	m.Lock()
+	defer m.Unlock()
+	for _, ch := range m.changes[min(int(start), len(m.changes)):] {
+		if ch.Op == Delete && len(dd) < count {
+			dd = append(dd, ch)
+		}
+	}
+	return
+}
+
+func (m *memSource) Committed() (serial uint64) {
+	m.Lock()
+	defer m.Unlock()
+	serial = uint64(len(m.changes))
+	for s := range m.uncommitted {
+		serial = min(serial, s)
+	}
+	return
+}
+
+func (m *memSource) Changed() <-chan struct{} {
+	m.Lock()
+	defer m.Unlock()
+	return m.changed
+}
+
+func TestRead(t *testing.T) {
+	src := newMemSource()
+	src.save("a")
+	src.save("b")
+	src.delete("a")
+	src.save("c")
+	changes, err := Read(context.Bg(), src, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the save of a is no longer in the serial index, but its deletion is.
+	if len(changes) != 3 || changes[0].Serial != 1 || changes[1].Op != Delete ||
+		!changes[1].Tombstone || changes[2].Serial != 3 {
+		t.Fatalf("unexpected changes %+v", changes)
+	}
+	if len(changes[0].Event) == 0 || len(changes[1].Event) != 0 {
+		t.Fatal("events not filled in for saves only")
+	}
+	if changes, err = Read(context.Bg(), src, 2, 1); err != nil || len(changes) != 1 ||
+		changes[0].Op != Delete {
+		t.Fatalf("unexpected changes from serial 2 %+v: %v", changes, err)
+	}
+}
+
+func TestFollow(t *testing.T) {
+	src := newMemSource()
+	src.save("a")
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		src.delete("a")
+	}()
+	c, cancel := context.Timeout(context.Bg(), 5*time.Second)
+	defer cancel()
+	done := errors.New("done")
+	var got []Change
+	err := Follow(c, src, 0, func(ch Change) error {
+		got = append(got, ch)
+		if len(got) == 2 {
+			return done
+		}
+		return nil
+	})
+	if !errors.Is(err, done) || got[0].Op != Save || got[1].Op != Delete {
+		t.Fatalf("unexpected changes %+v: %v", got, err)
+	}
+}
+
+func TestFollowUncommitted(t *testing.T) {
+	src := newMemSource()
+	// the save of a is given its serial first, but b is committed before it.
+	a := src.reserve()
+	src.save("b")
+	if changes, err := Read(context.Bg(), src, 0, 0); err != nil || len(changes) != 0 {
+		t.Fatalf("changes returned before the lowest serial was committed %+v: %v",
+			changes, err)
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		src.commit(a, "a")
+	}()
+	c, cancel := context.Timeout(context.Bg(), 5*time.Second)
+	defer cancel()
+	done := errors.New("done")
+	var got []Change
+	err := Follow(c, src, 0, func(ch Change) error {
+		got = append(got, ch)
+		if len(got) == 2 {
+			return done
+		}
+		return nil
+	})
+	if !errors.Is(err, done) || got[0].Serial != a || got[1].Serial != a+1 {
+		t.Fatalf("unexpected changes %+v: %v", got, err)
+	}
+}
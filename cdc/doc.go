@@ -0,0 +1,11 @@
+// Package cdc reads the changes made to an event store in the order of the database serial
+// sequence, for streaming them to downstream indexers.
+//
+// Every saved event has the serial it was stored under, and every deletion is given the next
+// serial of the same sequence when it is made, so reading from a serial and continuing after
+// the last change returned never misses or repeats a change. As serials are allocated before
+// the writes they are for are committed, changes are only returned up to the lowest serial
+// that is not yet committed. Deletions carry the id of the deleted event, and whether a
+// tombstone was written that prevents it being saved again. A save whose event was deleted
+// later is returned without the event.
+package cdc
@@ -0,0 +1,207 @@
+package cluster
+
+import (
+	"errors"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"realy.lol/cdc"
+	"realy.lol/context"
+	"realy.lol/event"
+	"realy.lol/eventidserial"
+	"realy.lol/filter"
+	"realy.lol/hex"
+	"realy.lol/kind"
+	"realy.lol/p256k"
+	"realy.lol/tags"
+	"realy.lol/timestamp"
+)
+
+// memStore is an event store where each serial is either a save or a deletion.
+type memStore struct {
+	sync.Mutex
+	changes []cdc.Change
+	evs     map[string]*event.T
+	changed chan struct{}
+}
+
+func newMemStore() *memStore {
+	return &memStore{evs: make(map[string]*event.T), changed: make(chan struct{})}
+}
+
+func (m *memStore) change(ch cdc.Change) {
+	ch.Serial = uint64(len(m.changes))
+	m.changes = append(m.changes, ch)
+	close(m.changed)
+	m.changed = make(chan struct{})
+}
+
+func (m *memStore) save(ev *event.T) {
+	m.Lock()
+	defer m.Unlock()
+	m.evs[hex.Enc(ev.Id)] = ev
+	m.change(cdc.Change{Op: cdc.Save, Id: hex.Enc(ev.Id)})
+}
+
+func (m *memStore) delete(ev *event.T) {
+	m.Lock()
+	defer m.Unlock()
+	delete(m.evs, hex.Enc(ev.Id))
+	m.change(cdc.Change{Op: cdc.Delete, Id: hex.Enc(ev.Id), Tombstone: true})
+}
+
+func (m *memStore) EventIdsBySerial(start uint64, count int) (ids []eventidserial.E,
+	err error) {
+	m.Lock()
+	defer m.Unlock()
+	for _, ch := range m.changes[min(int(start), len(m.changes)):] {
+		if _, ok := m.evs[ch.Id]; ok && ch.Op == cdc.Save && len(ids) < count {
+			ids = append(ids, eventidserial.E{Serial: ch.Serial, EventId: ch.Id})
+		}
+	}
+	return
+}
+
+func (m *memStore) QueryEvents(c context.T, f *filter.T) (evs event.Ts, err error) {
+	m.Lock()
+	defer m.Unlock()
+	for _, ev := range m.evs {
+		if f.IDs.Contains(ev.Id) {
+			evs = append(evs, ev)
+		}
+	}
+	return
+}
+
+func (m *memStore) Deletions(start uint64, count int) (dd []cdc.Change, err error) {
+	m.Lock()
+	defer m.Unlock()
+	for _, ch := range m.changes[min(int(start), len(m.changes)):] {
+		if ch.Op == cdc.Delete && len(dd) < count {
+			dd = append(dd, ch)
+		}
+	}
+	return
+}
+
+func (m *memStore) Changed() <-chan struct{} {
+	m.Lock()
+	defer m.Unlock()
+	return m.changed
+}
+
+func (m *memStore) Committed() uint64 {
+	m.Lock()
+	defer m.Unlock()
+	return uint64(len(m.changes))
+}
+
+type memCursors map[string]uint64
+
+func (m memCursors) GetPeerSerial(url string) (uint64, error) { return m[url], nil }
+
+func (m memCursors) SetPeerSerial(url string, serial uint64) error {
+	m[url] = serial
+	return nil
+}
+
+func note(t *testing.T, sign *p256k.Signer, content string) (ev *event.T) {
+	ev = &event.T{CreatedAt: timestamp.Now(), Kind: kind.TextNote, Tags: tags.New(),
+		Content: []byte(content)}
+	if err := ev.Sign(sign); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func TestReplicate(t *testing.T) {
+	Wait = time.Second
+	author, peer, stranger := &p256k.Signer{}, &p256k.Signer{}, &p256k.Signer{}
+	for _, s := range []*p256k.Signer{author, peer, stranger} {
+		if err := s.Generate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	src := newMemStore()
+	a, b, x := note(t, author, "a"), note(t, author, "b"), note(t, author, "x")
+	src.save(a)
+	src.save(x)
+	src.delete(x)
+	src.save(b)
+	l := NewLog(src, func() [][]byte { return [][]byte{peer.Pub()} })
+	srv := httptest.NewServer(l)
+	defer srv.Close()
+	u := srv.URL + Path
+	var added []*event.T
+	var deleted []string
+	var fail error
+	add := func(ev *event.T) error {
+		if fail != nil {
+			return fail
+		}
+		added = append(added, ev)
+		return nil
+	}
+	del := func(id []byte, tombstone bool) error {
+		if !tombstone {
+			t.Errorf("tombstone of %0x was not replicated", id)
+		}
+		deleted = append(deleted, hex.Enc(id))
+		return nil
+	}
+	c := context.Bg()
+	// only peers may read the log
+	if _, err := New(stranger, make(memCursors), add, del).read(c, u); err == nil {
+		t.Fatal("log was served to a stranger")
+	}
+	cursors := make(memCursors)
+	r := New(peer, cursors, add, del)
+	n, err := r.read(c, u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the save of x is gone, its deletion is replicated.
+	if n != 3 || cursors[u] != 4 || len(added) != 2 || len(deleted) != 1 ||
+		deleted[0] != hex.Enc(x.Id) {
+		t.Fatalf("read %d changes to serial %d, expected 3 to serial 4", n, cursors[u])
+	}
+	// a caught up reader waits for the next change.
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		src.save(note(t, author, "c"))
+	}()
+	if n, err = r.read(c, u); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || cursors[u] != 5 || string(added[2].Content) != "c" {
+		t.Fatalf("new event was not replicated, read %d to serial %d", n, cursors[u])
+	}
+	// and returns nothing if there is none.
+	if n, err = r.read(c, u); err != nil || n != 0 || cursors[u] != 5 {
+		t.Fatalf("expected no changes, read %d to serial %d: %v", n, cursors[u], err)
+	}
+	// an event that could not be stored is read again.
+	src.save(note(t, author, "d"))
+	fail = errors.New("disk full")
+	if _, err = r.read(c, u); err == nil || cursors[u] != 5 {
+		t.Fatalf("cursor advanced to %d past an event that was not stored: %v", cursors[u],
+			err)
+	}
+	fail = nil
+	if n, err = r.read(c, u); err != nil || n != 1 || cursors[u] != 6 {
+		t.Fatalf("expected the event to be read again, read %d to serial %d: %v", n,
+			cursors[u], err)
+	}
+	// an event with an invalid signature is skipped, and the changes after it are applied.
+	forged := note(t, author, "e")
+	forged.Content = []byte("forged")
+	src.save(forged)
+	src.save(note(t, author, "f"))
+	if n, err = r.read(c, u); err != nil || n != 1 || cursors[u] != 8 ||
+		string(added[len(added)-1].Content) != "f" {
+		t.Fatalf("expected the forged event to be skipped, read %d to serial %d: %v", n,
+			cursors[u], err)
+	}
+}
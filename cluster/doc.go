@@ -0,0 +1,25 @@
+// Package cluster replicates the events stored by a group of relay nodes to each other, so
+// that any node can fail without losing the events accepted by the others.
+//
+// Each node serves its log, the saves and deletions made to its event store in the order of
+// their database serials as read with package cdc, at Path to the peers it trusts, which
+// authenticate with NIP-98 signed by their relay keys. A request gives the serial to start
+// from and the number of changes wanted:
+//
+//	GET /cluster/log?from=<serial>&limit=<count>
+//
+// The response is one JSON cdc.Change per line. A save without an event marks a serial whose
+// event has since been deleted, so the reader can still advance past it, and the deletion
+// follows with its own serial. If there are no changes from the requested serial the request
+// waits for a new one to be made, for up to Wait, so followers that are caught up receive
+// new events and deletions as they are written.
+//
+// Every node pulls the log of every peer from a cursor that is stored when a batch has been
+// applied, which also serves as the catch up protocol for a node that was down: it continues
+// from where it stopped, and its peers do the same with its log. A change that could not be
+// applied is read again. Events a node received from a peer appear in its own log too, and
+// are skipped as duplicates by the others, and deletions of events that are already gone do
+// nothing.
+//
+// Ephemeral events are not stored and so are not replicated.
+package cluster
@@ -0,0 +1,97 @@
+package cluster
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"realy.lol/cdc"
+	"realy.lol/chk"
+	"realy.lol/httpauth"
+	"realy.lol/log"
+)
+
+// Path is the HTTP path of the log.
+const Path = "/cluster/log"
+
+// MaxLimit is the most changes that are returned in one response.
+const MaxLimit = cdc.MaxCount
+
+// Wait is the longest time a request for changes that are not yet made waits for them.
+var Wait = 25 * time.Second
+
+// Log serves the changes made to the event store of a node to its peers.
+type Log struct {
+	Store cdc.Source
+	// Peers returns the pubkeys that are permitted to read the log.
+	Peers func() [][]byte
+}
+
+// NewLog creates a Log reading from an event store.
+func NewLog(store cdc.Source, peers func() [][]byte) (l *Log) {
+	return &Log{Store: store, Peers: peers}
+}
+
+// authorized returns true if the request is signed by one of the peers.
+func (l *Log) authorized(r *http.Request) (pubkey []byte, ok bool) {
+	valid, pubkey, err := httpauth.CheckAuth(r)
+	if err != nil || !valid {
+		return
+	}
+	ok = slices.ContainsFunc(l.Peers(), func(pk []byte) bool { return string(pk) == string(pubkey) })
+	return
+}
+
+// ServeHTTP serves a part of the log to a peer.
+func (l *Log) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	pubkey, ok := l.authorized(r)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	var err error
+	var from uint64
+	if from, err = strconv.ParseUint(r.URL.Query().Get("from"), 10, 64); err != nil {
+		http.Error(w, "invalid from serial", http.StatusBadRequest)
+		return
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > MaxLimit {
+		limit = MaxLimit
+	}
+	var changes []cdc.Change
+	timer := time.NewTimer(Wait)
+	defer timer.Stop()
+	for {
+		// take the channel before reading so a change made in between is not missed.
+		changed := l.Store.Changed()
+		if changes, err = cdc.Read(r.Context(), l.Store, from, limit); chk.E(err) {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if len(changes) > 0 {
+			break
+		}
+		select {
+		case <-changed:
+			continue
+		case <-timer.C:
+		case <-r.Context().Done():
+		}
+		break
+	}
+	log.T.F("sending %d changes from %d to peer %0x", len(changes), from, pubkey)
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+	for _, ch := range changes {
+		if err = enc.Encode(ch); err != nil {
+			return
+		}
+	}
+}
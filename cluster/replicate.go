@@ -0,0 +1,183 @@
+package cluster
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"realy.lol/cdc"
+	"realy.lol/chk"
+	"realy.lol/context"
+	"realy.lol/errorf"
+	"realy.lol/event"
+	"realy.lol/hex"
+	"realy.lol/httpauth"
+	"realy.lol/log"
+	"realy.lol/signer"
+)
+
+// RetryDelay is how long to wait before reading the log of a peer again after a failure.
+var RetryDelay = 5 * time.Second
+
+// Cursors stores the serial of the next entry to read from the log of each peer.
+type Cursors interface {
+	// GetPeerSerial returns the next serial to read from a peer, 0 if it has not been read.
+	GetPeerSerial(url string) (serial uint64, err error)
+	// SetPeerSerial stores the next serial to read from a peer.
+	SetPeerSerial(url string, serial uint64) (err error)
+}
+
+// AddFunc stores an event received from a peer. It should only return an error if the event
+// could not be stored and should be tried again, and not for an event that is already stored
+// or that is not accepted.
+type AddFunc func(ev *event.T) (err error)
+
+// DeleteFunc deletes an event that a peer deleted, writing a tombstone if the peer did. It
+// should only return an error if the deletion should be tried again.
+type DeleteFunc func(id []byte, tombstone bool) (err error)
+
+// R reads the logs of peers into the local event store.
+type R struct {
+	// Signer is the relay key the node authenticates to its peers with.
+	Signer  signer.I
+	Cursors Cursors
+	Add     AddFunc
+	Delete  DeleteFunc
+	// Client is the HTTP client used for requests, which must allow for the Wait of the
+	// peers.
+	Client *http.Client
+}
+
+// New creates a reader of peer logs.
+func New(sign signer.I, cursors Cursors, add AddFunc, del DeleteFunc) (r *R) {
+	return &R{Signer: sign, Cursors: cursors, Add: add, Delete: del,
+		Client: &http.Client{Timeout: Wait + 30*time.Second}}
+}
+
+// Replicate follows the log of the peer at a base URL, such as https://relay.example.com,
+// until the context is canceled.
+func (r *R) Replicate(c context.T, base string) {
+	u := strings.TrimSuffix(base, "/") + Path
+	log.I.F("replicating from %s", u)
+	for {
+		n, err := r.read(c, u)
+		if c.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.W.F("replicating from %s: %v", u, err)
+			select {
+			case <-c.Done():
+				return
+			case <-time.After(RetryDelay):
+			}
+			continue
+		}
+		if n > 0 {
+			log.D.F("replicated %d changes from %s", n, u)
+		}
+	}
+}
+
+// read requests the changes following the cursor of a peer, applies them and advances the
+// cursor, and returns the number of changes applied.
+func (r *R) read(c context.T, u string) (n int, err error) {
+	var from uint64
+	if from, err = r.Cursors.GetPeerSerial(u); chk.E(err) {
+		return
+	}
+	var ur *url.URL
+	if ur, err = url.Parse(u + "?from=" + strconv.FormatUint(from, 10) +
+		"&limit=" + strconv.Itoa(MaxLimit)); chk.E(err) {
+		return
+	}
+	var req *http.Request
+	if req, err = http.NewRequestWithContext(c, http.MethodGet, ur.String(), nil); chk.E(err) {
+		return
+	}
+	if err = httpauth.AddNIP98Header(req, ur, http.MethodGet, "", r.Signer, 0); chk.E(err) {
+		return
+	}
+	var res *http.Response
+	if res, err = r.Client.Do(req); err != nil {
+		return
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		err = errorf.E("%s: %s", res.Status, strings.TrimSpace(string(b)))
+		return
+	}
+	next := from
+	// store the cursor for the changes that were applied, even if the response is cut short.
+	defer func() {
+		if next != from {
+			chk.E(r.Cursors.SetPeerSerial(u, next))
+		}
+	}()
+	// the changes are read to the end of the response so that the signatures of their events
+	// can be checked together.
+	type pending struct {
+		change cdc.Change
+		ev     *event.T
+	}
+	var changes []pending
+	var evs []*event.T
+	var readErr error
+	dec := json.NewDecoder(res.Body)
+	for {
+		var p pending
+		if readErr = dec.Decode(&p.change); readErr != nil {
+			if readErr == io.EOF {
+				readErr = nil
+			}
+			break
+		}
+		if p.change.Op == cdc.Save && len(p.change.Event) > 0 {
+			p.ev = event.New()
+			if _, readErr = p.ev.Unmarshal(p.change.Event); chk.E(readErr) {
+				break
+			}
+			evs = append(evs, p.ev)
+		}
+		changes = append(changes, p)
+	}
+	invalid := event.VerifyBatch(evs)
+	var i int
+	for _, p := range changes {
+		switch {
+		case p.ev != nil:
+			bad := len(invalid) > 0 && invalid[0] == i
+			i++
+			if bad {
+				// an event that will never be valid is skipped, so that it does not stop
+				// the replication of the changes that follow it.
+				invalid = invalid[1:]
+				log.W.F("skipping event %0x at serial %d from %s with an invalid signature",
+					p.ev.Id, p.change.Serial, u)
+				break
+			}
+			if err = r.Add(p.ev); err != nil {
+				return
+			}
+			n++
+		case p.change.Op == cdc.Delete:
+			var id []byte
+			if id, err = hex.Dec(p.change.Id); chk.E(err) {
+				return
+			}
+			if err = r.Delete(id, p.change.Tombstone); err != nil {
+				return
+			}
+			n++
+		}
+		// a save without an event was deleted since, and its deletion follows.
+		next = p.change.Serial + 1
+	}
+	err = readErr
+	return
+}
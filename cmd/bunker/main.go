@@ -0,0 +1,171 @@
+// Package main is a NIP-46 remote signer daemon, which signs events and encrypts and decrypts
+// messages with a key it keeps, for the clients paired with it. Configuration is via
+// environment variables.
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/adrg/xdg"
+	"go-simpler.org/env"
+
+	"realy.lol/bech32encoding"
+	"realy.lol/bunker"
+	"realy.lol/chk"
+	"realy.lol/context"
+	"realy.lol/hex"
+	"realy.lol/interrupt"
+	"realy.lol/keys"
+	"realy.lol/keystore"
+	"realy.lol/log"
+	"realy.lol/p256k"
+)
+
+type C struct {
+	Nsec   string   `env:"BUNKER_NSEC" usage:"nsec, ncryptsec or key file of the key the bunker signs with; the passphrase of an ncryptsec is read from the file in NCRYPTSEC_PASSPHRASE_FILE or prompted for"`
+	Relays []string `env:"BUNKER_RELAYS" usage:"comma separated websocket URLs of the relays the bunker listens for requests on"`
+	Perms  string   `env:"BUNKER_PERMS" default:"sign_event:1,sign_event:7,nip44_encrypt,nip44_decrypt" usage:"NIP-46 permissions granted by new bunker:// connection strings"`
+	Data   string   `env:"BUNKER_DATA" usage:"file the paired clients and their permissions are kept in, by default in the XDG config directory"`
+}
+
+func fail(format string, a ...any) {
+	_, _ = fmt.Fprintf(os.Stderr, format+"\n", a...)
+	os.Exit(1)
+}
+
+func usage(cfg *C) {
+	fmt.Printf("\nenvironment variables that configure bunker\n\n")
+	env.Usage(cfg, os.Stdout, nil)
+	fmt.Printf(`
+commands:
+
+  - listen for requests from paired clients
+
+      bunker
+
+  - print a bunker:// connection string that pairs the first client using it, with the
+    permissions of BUNKER_PERMS or the ones given
+
+      bunker url [perms]
+
+  - pair the client of a nostrconnect:// URI with the permissions it asks for
+
+      bunker pair <nostrconnect://...>
+
+  - list the paired clients and their permissions
+
+      bunker list
+
+  - remove a paired client
+
+      bunker revoke <client pubkey hex>
+
+`)
+	os.Exit(0)
+}
+
+func main() {
+	cfg := &C{}
+	if err := env.Load(cfg, &env.Options{SliceSep: ","}); chk.T(err) {
+		fail(err.Error())
+	}
+	if len(os.Args) > 1 && os.Args[1] == "help" {
+		usage(cfg)
+	}
+	if cfg.Data == "" {
+		cfg.Data = filepath.Join(xdg.ConfigHome, "bunker", "permissions.json")
+	}
+	perms, err := bunker.OpenPermissions(cfg.Data)
+	if err != nil {
+		fail(err.Error())
+	}
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "list":
+			list(perms)
+			return
+		case "revoke":
+			if len(os.Args) < 3 || !keys.IsValidPublicKey(os.Args[2]) {
+				fail("revoke requires the hex pubkey of a client")
+			}
+			pk, _ := hex.Dec(os.Args[2])
+			if err = perms.Revoke(pk); err != nil {
+				fail(err.Error())
+			}
+			return
+		}
+	}
+	if cfg.Nsec == "" {
+		fail("BUNKER_NSEC is not set")
+	}
+	var sign *p256k.Signer
+	if sign, err = keystore.Open(cfg.Nsec,
+		keystore.Prompt("BUNKER_NSEC passphrase: ")); chk.E(err) {
+		fail("BUNKER_NSEC is invalid: %s", err)
+	}
+	c, cancel := context.Cancel(context.Bg())
+	interrupt.AddHandler(cancel)
+	d := bunker.NewDaemon(c, sign, cfg.Relays, perms)
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "url":
+			if len(cfg.Relays) == 0 {
+				fail("BUNKER_RELAYS is not set")
+			}
+			p := cfg.Perms
+			if len(os.Args) > 2 {
+				p = os.Args[2]
+			}
+			var uri string
+			if uri, err = d.NewBunkerURL(bunker.ParsePerms(p)); err != nil {
+				fail(err.Error())
+			}
+			fmt.Println(uri)
+		case "pair":
+			if len(os.Args) < 3 {
+				fail("pair requires a nostrconnect:// URI")
+			}
+			var nc *bunker.NostrConnect
+			if nc, err = bunker.ParseNostrConnect(os.Args[2]); err != nil {
+				fail(err.Error())
+			}
+			if err = d.Pair(c, nc); err != nil {
+				fail(err.Error())
+			}
+			fmt.Printf("paired %0x with permissions '%s'\n", nc.Client, nc.Perms)
+		default:
+			fail("unknown command '%s', use 'help' for usage information", os.Args[1])
+		}
+		cancel()
+		return
+	}
+	if len(cfg.Relays) == 0 && len(perms.Relays()) == 0 {
+		fail("BUNKER_RELAYS is not set")
+	}
+	npub, _ := bech32encoding.BinToNpub(sign.Pub())
+	log.I.F("bunker %s", npub)
+	d.Run(c)
+}
+
+// list prints the paired clients and their permissions.
+func list(perms *bunker.Permissions) {
+	perms.Lock()
+	defer perms.Unlock()
+	clients := make([]string, 0, len(perms.Clients))
+	for pk := range perms.Clients {
+		clients = append(clients, pk)
+	}
+	sort.Strings(clients)
+	for _, pk := range clients {
+		p := perms.Clients[pk]
+		fmt.Printf("%s %q '%s'", pk, p.Name, p)
+		if len(p.Relays) > 0 {
+			fmt.Printf(" %v", p.Relays)
+		}
+		fmt.Println()
+	}
+	fmt.Printf("%d unused connection strings\n", len(perms.Secrets))
+}
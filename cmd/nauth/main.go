@@ -4,14 +4,17 @@ import (
 	"encoding/base64"
 	"fmt"
 	"os"
+	"path/filepath"
 	"time"
 
-	"realy.lol/bech32encoding"
+	"github.com/adrg/xdg"
+
+	"realy.lol/bunker"
 	"realy.lol/chk"
+	"realy.lol/context"
 	"realy.lol/errorf"
 	"realy.lol/httpauth"
 	"realy.lol/log"
-	"realy.lol/p256k"
 	"realy.lol/signer"
 )
 
@@ -33,6 +36,8 @@ for generating extended expiration NIP-98 tokens:
 
 	* NIP-98 secret will be expected in the environment variable "%s" - if absent, will not be added to the header. Endpoint is assumed to not require it if absent. An error will be returned if it was needed.
 
+	* the secret may be an nsec, a hex secret key, an ncryptsec or the path of a file containing one, whose passphrase is read from the file in NCRYPTSEC_PASSPHRASE_FILE or prompted for, or the bunker:// URL of a NIP-46 remote signer, which must permit signing kind 27235 events.
+
 	output will be rendered to stdout
 
 `, secEnv)
@@ -41,7 +46,7 @@ for generating extended expiration NIP-98 tokens:
 	if len(os.Args) < 3 {
 		fail(`error: nauth requires minimum 2 args: <url> <duration in 0h0m0s format>
 
-    signing nsec (in bech32 format) is expected to be found in %s environment variable.
+    signing nsec (in bech32 format) or bunker:// URL is expected to be found in %s environment variable.
 
     use "help" to get usage information
 `, secEnv)
@@ -64,20 +69,25 @@ for generating extended expiration NIP-98 tokens:
 	fmt.Println("Nostr " + b64)
 }
 
+// GetNIP98Signer returns a signer for the key in the environment variable, which may be an
+// nsec, a hex secret key, an ncryptsec or key file, or the bunker:// URL of a NIP-46 remote
+// signer.
 func GetNIP98Signer() (sign signer.I, err error) {
-	nsex := os.Getenv(secEnv)
-	var sk []byte
-	if len(nsex) == 0 {
-		err = errorf.E("no bech32 secret key found in environment variable %s", secEnv)
-		return
-	} else if sk, err = bech32encoding.NsecToBytes([]byte(nsex)); chk.E(err) {
-		err = errorf.E("failed to decode nsec: '%s'", err.Error())
+	key := os.Getenv(secEnv)
+	if len(key) == 0 {
+		err = errorf.E("no secret key or bunker URL found in environment variable %s", secEnv)
 		return
 	}
-	sign = &p256k.Signer{}
-	if err = sign.InitSec(sk); chk.E(err) {
-		err = errorf.E("failed to init signer: '%s'", err.Error())
+	if sign, err = bunker.NewSigner(context.Bg(), key, clientKeyFile(), func(u string) {
+		_, _ = fmt.Fprintf(os.Stderr, "the bunker asks for authorization at %s\n", u)
+	}); chk.E(err) {
 		return
 	}
 	return
 }
+
+// clientKeyFile is where the key the tools connect to bunkers with is kept, so they stay paired
+// with the bunker after the one-time secret of its bunker:// URL is used.
+func clientKeyFile() string {
+	return filepath.Join(xdg.ConfigHome, "nostr", "bunker-client.key")
+}
@@ -0,0 +1,173 @@
+// Package main is a tool that generates BIP-39 mnemonics and derives nostr keys from them
+// along the NIP-06 path m/44'/1237'/<account>'/0/0, optionally saving them encrypted as NIP-49
+// ncryptsec key files. Configuration is via environment variables.
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"go-simpler.org/env"
+	"golang.org/x/term"
+
+	"realy.lol/bech32encoding"
+	"realy.lol/chk"
+	"realy.lol/ec/bip39"
+	"realy.lol/ec/hdkeychain"
+	"realy.lol/ec/secp256k1"
+	"realy.lol/keys"
+	"realy.lol/keystore"
+)
+
+type C struct {
+	Mnemonic   string `env:"NOSTR_MNEMONIC" usage:"BIP-39 mnemonic the keys are derived from, which is prompted for if it is not set"`
+	Passphrase string `env:"NOSTR_MNEMONIC_PASSPHRASE" usage:"optional BIP-39 passphrase of the mnemonic"`
+}
+
+func fail(format string, a ...any) {
+	_, _ = fmt.Fprintf(os.Stderr, format+"\n", a...)
+	os.Exit(1)
+}
+
+func usage(cfg *C) {
+	fmt.Printf("\nenvironment variables that configure nkey\n\n")
+	env.Usage(cfg, os.Stdout, nil)
+	fmt.Printf(`
+commands:
+
+  - generate a new mnemonic of 12 or 24 words and print the key of its first account
+
+      nkey generate [12|24]
+
+  - print the keys of accounts of the mnemonic, by default the first
+
+      nkey derive [account...]
+
+  - save the key of an account of the mnemonic encrypted with a passphrase as an ncryptsec
+
+      nkey encrypt <file> [account]
+
+`)
+	os.Exit(0)
+}
+
+func main() {
+	cfg := &C{}
+	if err := env.Load(cfg, nil); chk.T(err) {
+		fail(err.Error())
+	}
+	if len(os.Args) < 2 || os.Args[1] == "help" {
+		usage(cfg)
+	}
+	switch os.Args[1] {
+	case "generate":
+		bits := 128
+		if len(os.Args) > 2 {
+			switch os.Args[2] {
+			case "12":
+			case "24":
+				bits = 256
+			default:
+				fail("a mnemonic can have 12 or 24 words")
+			}
+		}
+		entropy, err := bip39.NewEntropy(bits)
+		if err != nil {
+			fail(err.Error())
+		}
+		if cfg.Mnemonic, err = bip39.NewMnemonic(entropy); err != nil {
+			fail(err.Error())
+		}
+		fmt.Printf("%s\n\n", cfg.Mnemonic)
+		printKey(root(cfg), 0)
+	case "derive":
+		r := root(cfg)
+		accounts := os.Args[2:]
+		if len(accounts) == 0 {
+			accounts = []string{"0"}
+		}
+		for _, a := range accounts {
+			printKey(r, account(a))
+		}
+	case "encrypt":
+		if len(os.Args) < 3 {
+			fail("encrypt requires the file to save the key in")
+		}
+		var acct uint32
+		if len(os.Args) > 3 {
+			acct = account(os.Args[3])
+		}
+		sec, err := keys.AccountKey(root(cfg), acct)
+		if err != nil {
+			fail(err.Error())
+		}
+		var pass []byte
+		if pass, err = keystore.Prompt("passphrase: ")(); err != nil {
+			fail(err.Error())
+		}
+		if len(pass) == 0 {
+			fail("the passphrase is empty")
+		}
+		if err = keystore.Save(os.Args[2], sec, pass, bech32encoding.DefaultLogN,
+			bech32encoding.KeySecure); err != nil {
+			fail(err.Error())
+		}
+		pub, _ := keys.SecretBytesToPubKeyHex(sec)
+		fmt.Printf("saved the key of %s in %s\n", pub, os.Args[2])
+	default:
+		fail("unknown command '%s', use 'help' for usage information", os.Args[1])
+	}
+}
+
+// root returns the NIP-06 root key of the mnemonic, prompting for it if it is not set.
+func root(cfg *C) (r *hdkeychain.ExtendedKey) {
+	if cfg.Mnemonic == "" {
+		cfg.Mnemonic = readSecret("mnemonic: ")
+	}
+	var err error
+	if r, err = keys.MnemonicRoot(cfg.Mnemonic, cfg.Passphrase); err != nil {
+		fail(err.Error())
+	}
+	return
+}
+
+// account parses an account index.
+func account(s string) uint32 {
+	a, err := strconv.ParseUint(s, 10, 31)
+	if err != nil {
+		fail("invalid account '%s'", s)
+	}
+	return uint32(a)
+}
+
+// printKey prints the derivation path and keys of an account.
+func printKey(r *hdkeychain.ExtendedKey, acct uint32) {
+	sec, err := keys.AccountKey(r, acct)
+	if err != nil {
+		fail(err.Error())
+	}
+	sk := secp256k1.SecKeyFromBytes(sec)
+	nsec, _ := bech32encoding.SecretKeyToNsec(sk)
+	npub, _ := bech32encoding.PublicKeyToNpub(sk.PubKey())
+	fmt.Printf("%s\nNSEC = %s\nNPUB = %s\n\n", keys.NIP06Path(acct), nsec, npub)
+}
+
+// readSecret reads a line typed at a prompt without echo if stdin is a terminal, or the first
+// line of stdin.
+func readSecret(prompt string) string {
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		_, _ = fmt.Fprint(os.Stderr, prompt)
+		b, err := term.ReadPassword(fd)
+		_, _ = fmt.Fprintln(os.Stderr)
+		if err != nil {
+			fail(err.Error())
+		}
+		return string(b)
+	}
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	return strings.TrimSpace(line)
+}
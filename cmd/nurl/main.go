@@ -9,15 +9,18 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
+
+	"github.com/adrg/xdg"
 
 	realy_lol "realy.lol"
-	"realy.lol/bech32encoding"
+	"realy.lol/bunker"
 	"realy.lol/chk"
+	"realy.lol/context"
 	"realy.lol/errorf"
 	"realy.lol/hex"
 	"realy.lol/httpauth"
 	"realy.lol/log"
-	"realy.lol/p256k"
 	"realy.lol/sha256"
 	"realy.lol/signer"
 )
@@ -44,6 +47,8 @@ for nostr http using NIP-98 HTTP authentication:
 
 	* NIP-98 secret will be expected in the environment variable "%s" - if absent, will not be added to the header. Endpoint is assumed to not require it if absent. An error will be returned if it was needed.
 
+	* the secret may be an nsec, a hex secret key, an ncryptsec or the path of a file containing one, whose passphrase is read from the file in NCRYPTSEC_PASSPHRASE_FILE or prompted for, or the bunker:// URL of a NIP-46 remote signer, which must permit signing kind 27235 events.
+
 	output will be rendered to stdout
 
 `, secEnv)
@@ -52,7 +57,7 @@ for nostr http using NIP-98 HTTP authentication:
 	if len(os.Args) < 2 {
 		fail(`error: nurl requires minimum 1 arg:  <url> 
 
-    signing nsec (in bech32 format) is expected to be found in %s environment variable.
+    signing nsec (in bech32 format) or bunker:// URL is expected to be found in %s environment variable.
 
     use "help" to get usage information
 `, secEnv)
@@ -77,24 +82,29 @@ for nostr http using NIP-98 HTTP authentication:
 	}
 }
 
+// GetNIP98Signer returns a signer for the key in the environment variable, which may be an
+// nsec, a hex secret key, an ncryptsec or key file, or the bunker:// URL of a NIP-46 remote
+// signer.
 func GetNIP98Signer() (sign signer.I, err error) {
-	nsex := os.Getenv(secEnv)
-	var sk []byte
-	if len(nsex) == 0 {
-		err = errorf.E("no bech32 secret key found in environment variable %s", secEnv)
-		return
-	} else if sk, err = bech32encoding.NsecToBytes([]byte(nsex)); chk.E(err) {
-		err = errorf.E("failed to decode nsec: '%s'", err.Error())
+	key := os.Getenv(secEnv)
+	if len(key) == 0 {
+		err = errorf.E("no secret key or bunker URL found in environment variable %s", secEnv)
 		return
 	}
-	sign = &p256k.Signer{}
-	if err = sign.InitSec(sk); chk.E(err) {
-		err = errorf.E("failed to init signer: '%s'", err.Error())
+	if sign, err = bunker.NewSigner(context.Bg(), key, clientKeyFile(), func(u string) {
+		_, _ = fmt.Fprintf(os.Stderr, "the bunker asks for authorization at %s\n", u)
+	}); chk.E(err) {
 		return
 	}
 	return
 }
 
+// clientKeyFile is where the key the tools connect to bunkers with is kept, so they stay paired
+// with the bunker after the one-time secret of its bunker:// URL is used.
+func clientKeyFile() string {
+	return filepath.Join(xdg.ConfigHome, "nostr", "bunker-client.key")
+}
+
 func Get(ur *url.URL, sign signer.I) (err error) {
 	log.T.F("GET")
 	var r *http.Request
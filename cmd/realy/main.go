@@ -11,6 +11,7 @@ import (
 	"runtime"
 	"runtime/debug"
 	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/adrg/xdg"
@@ -22,10 +23,13 @@ import (
 	"realy.lol/context"
 	"realy.lol/hex"
 	"realy.lol/interrupt"
+	"realy.lol/keystore"
 	"realy.lol/log"
 	"realy.lol/lol"
+	"realy.lol/nwc"
 	"realy.lol/openapi"
 	"realy.lol/p256k"
+	"realy.lol/policy"
 	"realy.lol/ratel"
 	"realy.lol/realy"
 	"realy.lol/servemux"
@@ -45,16 +49,26 @@ func main() {
 	debug.SetMemoryLimit(2500000)
 	var err error
 	a := cfg.Superuser
-	var dst []byte
-	if dst, err = bech32encoding.NpubToBytes([]byte(a)); chk.E(err) {
-		if _, err = hex.DecBytes(dst, []byte(a)); chk.E(err) {
-			log.F.F("SUPERUSER is invalid: %s", a)
+	super := &p256k.Signer{}
+	if strings.HasPrefix(a, "nsec") || strings.HasPrefix(a, "ncryptsec") ||
+		filepath.IsAbs(a) {
+		// with the secret key the relay can sign events, such as NIP-29 group state.
+		if super, err = keystore.Open(a,
+			keystore.Prompt("SUPERUSER passphrase: ")); chk.E(err) {
+			log.F.F("SUPERUSER is invalid: %s", err)
 			os.Exit(1)
 		}
-	}
-	super := &p256k.Signer{}
-	if err = super.InitPub(dst); chk.E(err) {
-		return
+	} else {
+		var dst []byte
+		if dst, err = bech32encoding.NpubToBytes([]byte(a)); chk.E(err) {
+			if _, err = hex.DecBytes(dst, []byte(a)); chk.E(err) {
+				log.F.F("SUPERUSER is invalid: %s", a)
+				os.Exit(1)
+			}
+		}
+		if err = super.InitPub(dst); chk.E(err) {
+			return
+		}
 	}
 	lol.ShortLoc.Store(false)
 	log.I.F("starting %s %s", cfg.AppName, realy_lol.Version)
@@ -86,6 +100,20 @@ func main() {
 		MaxLimit:  ratel.DefaultMaxLimit,
 		Superuser: super,
 	}
+	if s.BlobDir = cfg.BlossomDir; s.BlobDir == "" {
+		s.BlobDir = filepath.Join(xdg.DataHome, cfg.AppName+"-blossom")
+	}
+	if cfg.Policy != "" {
+		s.Policy = policy.New(c, cfg.Policy, cfg.PolicyTimeout, cfg.PolicyFailOpen)
+	}
+	if cfg.NWC != "" {
+		var wallet *nwc.Client
+		if wallet, err = nwc.NewClient(cfg.NWC); chk.E(err) {
+			log.F.F("NWC_URI is invalid: %s", err)
+			os.Exit(1)
+		}
+		s.Wallet = wallet
+	}
 	openapi.New(s, cfg.AppName, realy_lol.Version, realy_lol.Description, "/api", serveMux)
 	socketapi.New(s, "/{$}", serveMux)
 	interrupt.AddHandler(func() { s.Shutdown() })
@@ -1,8 +1,10 @@
 // Package main is a simple nostr key miner that uses the fast bitcoin secp256k1
-// C library to derive npubs with specified prefix/infix/suffix strings present.
+// C library to derive npubs with specified prefix/infix/suffix strings present,
+// from random keys or the NIP-06 accounts of a BIP-39 mnemonic.
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/hex"
 	"fmt"
@@ -13,14 +15,17 @@ import (
 	"time"
 
 	"github.com/alexflint/go-arg"
+	"golang.org/x/term"
 
 	"realy.lol/atomic"
 	"realy.lol/bech32encoding"
 	"realy.lol/chk"
 	"realy.lol/ec/bech32"
+	"realy.lol/ec/hdkeychain"
 	"realy.lol/ec/schnorr"
 	"realy.lol/ec/secp256k1"
 	"realy.lol/interrupt"
+	"realy.lol/keys"
 	"realy.lol/log"
 )
 
@@ -36,12 +41,20 @@ type Result struct {
 	sec  *secp256k1.SecretKey
 	npub []byte
 	pub  *secp256k1.PublicKey
+	// account is the NIP-06 account of the mnemonic the key was derived from, or -1 if it
+	// is random.
+	account int64
 }
 
+// Generator makes the keys that are searched.
+type Generator func() (r Result, err error)
+
 var args struct {
 	String   string `arg:"positional" help:"the string you want to appear in the npub"`
 	Position string `arg:"positional" default:"end" help:"[begin|contain|end] default: end"`
 	Threads  int    `help:"number of threads to mine with - defaults to using all CPU threads available"`
+	Mnemonic bool   `help:"search the NIP-06 accounts of the BIP-39 mnemonic in NOSTR_MNEMONIC, or typed at a prompt, instead of random keys"`
+	Start    uint32 `help:"account of the mnemonic to start searching from"`
 }
 
 func main() {
@@ -56,6 +69,9 @@ Positional arguments:
 
 Options:
   --threads THREADS      number of threads to mine with - defaults to using all CPU threads available
+  --mnemonic             search the NIP-06 accounts of the BIP-39 mnemonic in NOSTR_MNEMONIC, or typed at a prompt, instead of random keys
+                         (with the optional passphrase in NOSTR_MNEMONIC_PASSPHRASE)
+  --start START          account of the mnemonic to start searching from
   --help, -h             display this help and exit`)
 		os.Exit(0)
 	}
@@ -72,12 +88,38 @@ Options:
 	if args.Threads == 0 {
 		args.Threads = runtime.NumCPU()
 	}
-	if err := Vanity(args.String, where, args.Threads); chk.T(err) {
+	gen := GenKeyPairResult
+	if args.Mnemonic {
+		root, err := keys.MnemonicRoot(readMnemonic(), os.Getenv("NOSTR_MNEMONIC_PASSPHRASE"))
+		if chk.T(err) {
+			log.F.F("error: %s", err)
+			os.Exit(1)
+		}
+		gen = MnemonicAccounts(root, args.Start)
+	}
+	if err := Vanity(args.String, where, args.Threads, gen); chk.T(err) {
 		log.F.F("error: %s", err)
 	}
 }
 
-func Vanity(str string, where int, threads int) (e error) {
+// readMnemonic returns the mnemonic in NOSTR_MNEMONIC, or typed without echo at a prompt if
+// stdin is a terminal, or else the first line of stdin.
+func readMnemonic() string {
+	if m := os.Getenv("NOSTR_MNEMONIC"); m != "" {
+		return m
+	}
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		_, _ = fmt.Fprint(os.Stderr, "mnemonic: ")
+		b, _ := term.ReadPassword(fd)
+		_, _ = fmt.Fprintln(os.Stderr)
+		return string(b)
+	}
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	return strings.TrimSpace(line)
+}
+
+func Vanity(str string, where int, threads int, gen Generator) (e error) {
 
 	// check the string has valid bech32 ciphers
 	for i := range str {
@@ -104,7 +146,7 @@ func Vanity(str string, where int, threads int) (e error) {
 	counter := atomic.NewInt64(0)
 	for i := 0; i < threads; i++ {
 		log.D.F("starting up worker %d", i)
-		go mine(str, where, quit, resC, &wg, counter)
+		go mine(str, where, quit, resC, &wg, counter, gen)
 	}
 	tick := time.NewTicker(time.Second * 5)
 	var res Result
@@ -143,12 +185,15 @@ out:
 		hex.EncodeToString(schnorr.SerializePubKey(res.pub)),
 	)
 	nsec, _ := bech32encoding.SecretKeyToNsec(res.sec)
+	if res.account >= 0 {
+		fmt.Printf("\nPATH = %s", keys.NIP06Path(uint32(res.account)))
+	}
 	fmt.Printf("\nNSEC = %s\nNPUB = %s\n\n", nsec, res.npub)
 	return
 }
 
 func mine(str string, where int, quit chan struct{}, resC chan Result, wg *sync.WaitGroup,
-	counter *atomic.Int64) {
+	counter *atomic.Int64, gen Generator) {
 
 	wg.Add(1)
 	var r Result
@@ -171,7 +216,7 @@ out:
 		default:
 		}
 		counter.Inc()
-		r.sec, r.pub, e = GenKeyPair()
+		r, e = gen()
 		if e != nil {
 			log.E.Ln("error generating key: '%v' worker stopping", e)
 			break out
@@ -214,3 +259,30 @@ func GenKeyPair() (sec *secp256k1.SecretKey,
 	pub = sec.PubKey()
 	return
 }
+
+// GenKeyPairResult is the Generator of random keys.
+func GenKeyPairResult() (r Result, err error) {
+	r.sec, r.pub, err = GenKeyPair()
+	r.account = -1
+	return
+}
+
+// MnemonicAccounts returns a Generator of the keys of the accounts of a mnemonic in turn,
+// from its root key and the first account.
+func MnemonicAccounts(root *hdkeychain.ExtendedKey, start uint32) Generator {
+	next := atomic.NewInt64(int64(start))
+	return func() (r Result, err error) {
+		r.account = next.Inc() - 1
+		if r.account >= int64(hdkeychain.HardenedKeyStart) {
+			err = fmt.Errorf("searched all accounts of the mnemonic")
+			return
+		}
+		var sec []byte
+		if sec, err = keys.AccountKey(root, uint32(r.account)); err != nil {
+			return
+		}
+		r.sec = secp256k1.SecKeyFromBytes(sec)
+		r.pub = r.sec.PubKey()
+		return
+	}
+}
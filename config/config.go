@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/pkg/profile"
 	"go-simpler.org/env"
@@ -17,12 +18,17 @@ import (
 // configurations should generally be stored in the database, where APIs make them easy to
 // modify.
 type C struct {
-	AppName   string `env:"APP_NAME" default:"realy"`
-	Listen    string `env:"LISTEN" default:"0.0.0.0" usage:"network listen address"`
-	Port      int    `env:"PORT" default:"3334" usage:"network listen port"`
-	Pprof     bool   `env:"PPROF" default:"false" usage:"enable pprof on 127.0.0.1:6060"`
-	Superuser string `env:"SUPERUSER" usage:"superuser npub/hex public key"`
-	Binary    bool   `env:"BINARY" usage:"use binary encoder for database" default:"false"`
+	AppName        string        `env:"APP_NAME" default:"realy"`
+	Listen         string        `env:"LISTEN" default:"0.0.0.0" usage:"network listen address"`
+	Port           int           `env:"PORT" default:"3334" usage:"network listen port"`
+	Pprof          bool          `env:"PPROF" default:"false" usage:"enable pprof on 127.0.0.1:6060"`
+	Superuser      string        `env:"SUPERUSER" usage:"superuser npub/hex public key, or nsec, ncryptsec or absolute path of a key file to let the relay sign events such as NIP-29 group state; the passphrase of an ncryptsec is read from the file in NCRYPTSEC_PASSPHRASE_FILE or prompted for"`
+	Binary         bool          `env:"BINARY" usage:"use binary encoder for database" default:"false"`
+	Policy         string        `env:"POLICY" usage:"command line of a write policy plugin that decides whether to accept events and subscription requests"`
+	PolicyTimeout  time.Duration `env:"POLICY_TIMEOUT" default:"2s" usage:"time the write policy plugin has to answer a request"`
+	PolicyFailOpen bool          `env:"POLICY_FAIL_OPEN" default:"false" usage:"accept requests when the write policy plugin does not answer, instead of rejecting them"`
+	BlossomDir     string        `env:"BLOSSOM_DIR" usage:"directory Blossom and NIP-96 blobs are stored in, by default beside the event database"`
+	NWC            string        `env:"NWC_URI" usage:"nostr+walletconnect:// URI of the wallet that makes the invoices for paid admission"`
 }
 
 func New() (c *C) {
@@ -0,0 +1,21 @@
+// Package cosign lets the members of a shared nostr identity co-sign events with MuSig2, so
+// that a team can run an account none of them controls alone. The pubkey of the identity is
+// the MuSig2 aggregate of the keys of its members, and every event it publishes carries an
+// ordinary BIP-340 signature, which needs a partial signature from every member.
+//
+// The members exchange the messages of a signing session over nostr, as kind 24135 ephemeral
+// events signed by their own keys, p tagged with the member they are for and NIP-44 encrypted
+// to them. The content of each is a JSON Message:
+//
+//   - a member proposes an event, with the pubkey of the identity and its id, to all the
+//     others in a "propose" message with the public nonce of the proposer,
+//
+//   - each member that approves of the event sends its public nonce to all the others in a
+//     "nonce" message, or a "reject" message if it does not,
+//
+//   - once a member has the nonces of all the members it signs the id, and sends its partial
+//     signature to all the others in a "partial_sig" message,
+//
+//   - once a member has all the partial signatures, which are each checked against the nonce
+//     and key of their member, it combines them into the signature of the event.
+package cosign
@@ -0,0 +1,71 @@
+package cosign
+
+import (
+	"bytes"
+	"slices"
+
+	btcec "realy.lol/ec"
+	"realy.lol/ec/musig2"
+	"realy.lol/ec/schnorr"
+	"realy.lol/errorf"
+)
+
+// Group is the members of a shared identity, whose pubkey is the MuSig2 aggregate of their
+// keys.
+type Group struct {
+	members [][]byte
+	keys    []*btcec.PublicKey
+	pubkey  []byte
+}
+
+// NewGroup makes the Group of the x-only pubkeys of two or more members, in any order.
+func NewGroup(members ...[]byte) (g *Group, err error) {
+	if len(members) < 2 {
+		err = errorf.E("a group must have at least two members")
+		return
+	}
+	g = &Group{}
+	// the keys are sorted as MuSig2 key aggregation does, so the index of the second unique
+	// key it finds is the same in the keys the members are signed with.
+	members = slices.Clone(members)
+	slices.SortFunc(members, bytes.Compare)
+	for _, m := range members {
+		if slices.ContainsFunc(g.members, func(o []byte) bool { return bytes.Equal(o, m) }) {
+			err = errorf.E("%0x is a member more than once", m)
+			return
+		}
+		var pk *btcec.PublicKey
+		if pk, err = schnorr.ParsePubKey(m); err != nil {
+			err = errorf.E("invalid member pubkey %0x: %v", m, err)
+			return
+		}
+		g.members, g.keys = append(g.members, m), append(g.keys, pk)
+	}
+	var agg *musig2.AggregateKey
+	if agg, _, _, err = musig2.AggregateKeys(g.keys, true); err != nil {
+		return
+	}
+	g.pubkey = schnorr.SerializePubKey(agg.FinalKey)
+	return
+}
+
+// Pubkey returns the pubkey of the shared identity.
+func (g *Group) Pubkey() []byte { return g.pubkey }
+
+// Members returns the pubkeys of the members, in order.
+func (g *Group) Members() [][]byte { return g.members }
+
+// IsMember returns true if a pubkey is that of a member.
+func (g *Group) IsMember(pubkey []byte) bool {
+	return slices.ContainsFunc(g.members, func(m []byte) bool { return bytes.Equal(m, pubkey) })
+}
+
+// key returns the key of a member, with the even y coordinate its x-only pubkey stands for.
+func (g *Group) key(pubkey []byte) *btcec.PublicKey {
+	for i, m := range g.members {
+		if bytes.Equal(m, pubkey) {
+			return g.keys[i]
+		}
+	}
+	return nil
+}
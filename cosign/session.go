@@ -0,0 +1,209 @@
+package cosign
+
+import (
+	"bytes"
+
+	btcec "realy.lol/ec"
+	"realy.lol/ec/musig2"
+	"realy.lol/ec/schnorr"
+	"realy.lol/ec/secp256k1"
+	"realy.lol/errorf"
+	"realy.lol/event"
+	"realy.lol/hex"
+)
+
+// The types of the messages of a signing session.
+const (
+	Propose    = "propose"
+	Nonce      = "nonce"
+	PartialSig = "partial_sig"
+	Reject     = "reject"
+)
+
+// Message is the content of the events members send each other during a signing session.
+type Message struct {
+	// Session identifies the signing session, and is chosen by the proposer.
+	Session string `json:"session"`
+	// Type is Propose, Nonce, PartialSig or Reject.
+	Type string `json:"type"`
+	// Event is the proposed event, with the pubkey of the identity, its id and an empty
+	// signature.
+	Event string `json:"event,omitempty"`
+	// Nonce is the hex public nonce of the sender, which is sent with the proposal.
+	Nonce string `json:"nonce,omitempty"`
+	// PartialSig is the hex partial signature of the sender.
+	PartialSig string `json:"partial_sig,omitempty"`
+	// Reason is why the sender rejected the proposal.
+	Reason string `json:"reason,omitempty"`
+}
+
+// Session is the state of a member in the signing of an event. It does not send or receive
+// anything, which is done by a Signer, so that it can be used over other transports.
+type Session struct {
+	ID string
+	// Event is the event being signed, which has its Sig once all the partial signatures
+	// are combined.
+	Event    *event.T
+	group    *Group
+	key      *btcec.SecretKey
+	pub      []byte
+	msg      [32]byte
+	nonces   *musig2.Nonces
+	pubNonce map[string][musig2.PubNonceSize]byte
+	partial  map[string]*musig2.PartialSignature
+	pending  map[string][]byte
+	combined *[musig2.PubNonceSize]byte
+	ourSig   *musig2.PartialSignature
+}
+
+// signingKey returns the secret key of a member negated if needed so that its public key has
+// an even y coordinate, as the x-only pubkey of the member stands for in the group.
+func signingKey(sec []byte) (sk *btcec.SecretKey) {
+	sk = secp256k1.SecKeyFromBytes(sec)
+	if sk.PubKey().SerializeCompressed()[0] == secp256k1.PubKeyFormatCompressedOdd {
+		sk.Key.Negate()
+	}
+	return
+}
+
+// NewSession starts the signing of an event by a member with a secret key. The event must have
+// the pubkey of the group and its id, which is what is signed.
+func (g *Group) NewSession(id string, ev *event.T, sec []byte) (s *Session, err error) {
+	if !bytes.Equal(ev.Pubkey, g.pubkey) {
+		err = errorf.E("event pubkey %0x is not that of the group", ev.Pubkey)
+		return
+	}
+	if !bytes.Equal(ev.Id, ev.GetIDBytes()) {
+		err = errorf.E("event id does not match its content")
+		return
+	}
+	key := signingKey(sec)
+	pub := schnorr.SerializePubKey(key.PubKey())
+	if !g.IsMember(pub) {
+		err = errorf.E("%0x is not a member of the group", pub)
+		return
+	}
+	s = &Session{ID: id, Event: ev, group: g, key: key, pub: pub,
+		pubNonce: make(map[string][musig2.PubNonceSize]byte),
+		partial:  make(map[string]*musig2.PartialSignature),
+		pending:  make(map[string][]byte)}
+	copy(s.msg[:], ev.Id)
+	if s.nonces, err = musig2.GenNonces(musig2.WithPublicKey(key.PubKey()),
+		musig2.WithNonceSecretKeyAux(key), musig2.WithNonceMessageAux(s.msg)); err != nil {
+		return
+	}
+	s.pubNonce[string(pub)] = s.nonces.PubNonce
+	return
+}
+
+// PublicNonce returns the hex public nonce of the member, to send to the others.
+func (s *Session) PublicNonce() string { return hex.Enc(s.nonces.PubNonce[:]) }
+
+// AddNonce records the public nonce of another member. Once the nonces of all the members are
+// known the member signs, and its partial signature is returned to send to the others.
+func (s *Session) AddNonce(from []byte, nonce string) (partialSig string, err error) {
+	if !s.group.IsMember(from) {
+		err = errorf.E("%0x is not a member of the group", from)
+		return
+	}
+	if _, ok := s.pubNonce[string(from)]; ok {
+		err = errorf.E("already have the nonce of %0x", from)
+		return
+	}
+	var b []byte
+	if b, err = hex.Dec(nonce); err != nil || len(b) != musig2.PubNonceSize {
+		err = errorf.E("invalid nonce from %0x", from)
+		return
+	}
+	s.pubNonce[string(from)] = [musig2.PubNonceSize]byte(b)
+	if len(s.pubNonce) < len(s.group.members) {
+		return
+	}
+	nonces := make([][musig2.PubNonceSize]byte, 0, len(s.pubNonce))
+	for _, n := range s.pubNonce {
+		nonces = append(nonces, n)
+	}
+	var combined [musig2.PubNonceSize]byte
+	if combined, err = musig2.AggregateNonces(nonces); err != nil {
+		return
+	}
+	s.combined = &combined
+	if s.ourSig, err = musig2.Sign(s.nonces.SecNonce, s.key, combined, s.group.keys, s.msg,
+		musig2.WithSortedKeys()); err != nil {
+		return
+	}
+	s.nonces.SecNonce = [musig2.SecNonceSize]byte{}
+	s.partial[string(s.pub)] = s.ourSig
+	var buf bytes.Buffer
+	if err = s.ourSig.Encode(&buf); err != nil {
+		return
+	}
+	partialSig = hex.Enc(buf.Bytes())
+	// partial signatures that arrived before the last nonce can now be checked.
+	for m, p := range s.pending {
+		delete(s.pending, m)
+		if err = s.addPartialSig([]byte(m), p); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// AddPartialSig records the partial signature of another member, returning true once the
+// partial signatures of all the members have been combined into the signature of the event.
+func (s *Session) AddPartialSig(from []byte, partialSig string) (done bool, err error) {
+	if !s.group.IsMember(from) {
+		err = errorf.E("%0x is not a member of the group", from)
+		return
+	}
+	var b []byte
+	if b, err = hex.Dec(partialSig); err != nil || len(b) != 32 {
+		err = errorf.E("invalid partial signature from %0x", from)
+		return
+	}
+	if s.combined == nil {
+		// the partial signature can only be checked once all the nonces are known.
+		s.pending[string(from)] = b
+		return
+	}
+	if err = s.addPartialSig(from, b); err != nil {
+		return
+	}
+	return s.Done(), nil
+}
+
+// addPartialSig checks and records a partial signature, and combines them once they are all
+// known.
+func (s *Session) addPartialSig(from, b []byte) (err error) {
+	if _, ok := s.partial[string(from)]; ok {
+		return errorf.E("already have the partial signature of %0x", from)
+	}
+	p := &musig2.PartialSignature{}
+	if err = p.Decode(bytes.NewReader(b)); err != nil {
+		return
+	}
+	if !p.Verify(s.pubNonce[string(from)], *s.combined, s.group.keys, s.group.key(from),
+		s.msg, musig2.WithSortedKeys()) {
+		return errorf.E("invalid partial signature from %0x", from)
+	}
+	s.partial[string(from)] = p
+	if len(s.partial) < len(s.group.members) {
+		return
+	}
+	sigs := make([]*musig2.PartialSignature, 0, len(s.partial))
+	for _, p := range s.partial {
+		sigs = append(sigs, p)
+	}
+	sig := musig2.CombineSigs(s.ourSig.R, sigs)
+	ev := *s.Event
+	ev.Sig = sig.Serialize()
+	var valid bool
+	if valid, err = ev.Verify(); err != nil || !valid {
+		return errorf.E("combined signature is invalid")
+	}
+	s.Event.Sig = ev.Sig
+	return
+}
+
+// Done returns true once the event has been signed.
+func (s *Session) Done() bool { return len(s.partial) == len(s.group.members) }
@@ -0,0 +1,93 @@
+package cosign
+
+import (
+	"testing"
+
+	"realy.lol/event"
+	"realy.lol/kind"
+	"realy.lol/p256k"
+	"realy.lol/timestamp"
+)
+
+// newMembers makes the keys of the members of a group.
+func newMembers(t *testing.T, n int) (keys []*p256k.Signer, g *Group) {
+	var pubs [][]byte
+	for range n {
+		k := &p256k.Signer{}
+		if err := k.Generate(); err != nil {
+			t.Fatal(err)
+		}
+		keys, pubs = append(keys, k), append(pubs, k.Pub())
+	}
+	var err error
+	if g, err = NewGroup(pubs...); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+// newEvent makes an event of the group to sign.
+func newEvent(g *Group) (ev *event.T) {
+	ev = &event.T{Pubkey: g.Pubkey(), CreatedAt: timestamp.Now(), Kind: kind.TextNote,
+		Content: []byte("from all of us")}
+	ev.Id = ev.GetIDBytes()
+	return
+}
+
+func TestSession(t *testing.T) {
+	keys, g := newMembers(t, 3)
+	ev := newEvent(g)
+	sessions := make([]*Session, len(keys))
+	for i, k := range keys {
+		var err error
+		if sessions[i], err = g.NewSession("s", ev, k.Sec()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// every member gets the nonces of the others, but the last member only gets the last
+	// nonce after the partial signatures of the others, which it must hold until then.
+	partials := make([]string, len(keys))
+	for i, s := range sessions {
+		for j, o := range sessions {
+			if i == j || (i == 2 && j == 1) {
+				continue
+			}
+			p, err := s.AddNonce(keys[j].Pub(), o.PublicNonce())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p != "" {
+				partials[i] = p
+			}
+		}
+	}
+	for j := range 2 {
+		if _, err := sessions[2].AddPartialSig(keys[j].Pub(), partials[j]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var err error
+	if partials[2], err = sessions[2].AddNonce(keys[1].Pub(),
+		sessions[1].PublicNonce()); err != nil || partials[2] == "" {
+		t.Fatalf("last nonce %v", err)
+	}
+	if !sessions[2].Done() {
+		t.Fatal("held partial signatures were not combined")
+	}
+	// a forged partial signature is refused.
+	if _, err = sessions[0].AddPartialSig(keys[1].Pub(), partials[2]); err == nil {
+		t.Fatal("accepted the partial signature of another member")
+	}
+	for j := 1; j < 3; j++ {
+		if _, err = sessions[1].AddPartialSig(keys[(j+1)%3].Pub(),
+			partials[(j+1)%3]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !sessions[1].Done() {
+		t.Fatal("not done")
+	}
+	if valid, err := ev.Verify(); err != nil || !valid {
+		t.Fatalf("signature valid %v %v", valid, err)
+	}
+}
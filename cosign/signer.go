@@ -0,0 +1,301 @@
+package cosign
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"realy.lol/chk"
+	"realy.lol/context"
+	"realy.lol/encryption"
+	"realy.lol/errorf"
+	"realy.lol/event"
+	"realy.lol/filter"
+	"realy.lol/filters"
+	"realy.lol/hex"
+	"realy.lol/kind"
+	"realy.lol/kinds"
+	"realy.lol/log"
+	"realy.lol/signer"
+	"realy.lol/tag"
+	"realy.lol/tags"
+	"realy.lol/timestamp"
+	"realy.lol/ws"
+)
+
+// SessionTimeout is how long a member keeps a signing session that has not finished, and the
+// messages of sessions it has not yet been proposed.
+var SessionTimeout = 10 * time.Minute
+
+// Signer is a member of a Group, which signs events proposed by itself and the other members
+// by exchanging messages with them on relays.
+type Signer struct {
+	Group *Group
+	// Approve decides whether to co-sign an event proposed by another member, returning why
+	// not if it does not. All proposals are rejected if it is nil.
+	Approve func(proposer []byte, ev *event.T) error
+	// Relays are the relays the members send each other messages on.
+	Relays []string
+	sign   signer.I
+	pool   *ws.Pool
+	sync.Mutex
+	sessions map[string]*signing
+	early    map[string][]received
+}
+
+// signing is a session of a Signer.
+type signing struct {
+	*Session
+	started time.Time
+	// done receives the result of the sessions the Signer proposed.
+	done chan error
+}
+
+// received is a message from a member.
+type received struct {
+	from []byte
+	msg  *Message
+	at   time.Time
+}
+
+// NewSigner creates the Signer of a member of a group with its key, which listens for the
+// messages of the other members on relays until the context is canceled. The key must be
+// local, as MuSig2 signing needs the secret key.
+func NewSigner(c context.T, sign signer.I, g *Group, relays []string) (s *Signer,
+	err error) {
+	if len(sign.Sec()) == 0 {
+		err = errorf.E("co-signing needs a local secret key")
+		return
+	}
+	if !g.IsMember(sign.Pub()) {
+		err = errorf.E("%0x is not a member of the group", sign.Pub())
+		return
+	}
+	s = &Signer{Group: g, Relays: relays, sign: sign, pool: ws.NewPool(c),
+		sessions: make(map[string]*signing), early: make(map[string][]received)}
+	events := s.pool.SubMany(c, relays, filters.New(&filter.T{
+		Kinds: kinds.New(kind.CoSign),
+		Tags:  tags.New(tag.New([]byte("#p"), sign.Pub())),
+		Since: timestamp.Now(),
+	}), ws.WithLabel("cosign"))
+	go func() {
+		for ie := range events {
+			s.receive(c, ie.Event)
+		}
+	}()
+	return
+}
+
+// Sign has the event signed by all the members of the group, setting its Pubkey, Id and Sig.
+// The CreatedAt timestamp must be set by the caller.
+func (s *Signer) Sign(c context.T, ev *event.T) (err error) {
+	ev.Pubkey = s.Group.Pubkey()
+	ev.Id = ev.GetIDBytes()
+	id := make([]byte, 16)
+	if _, err = rand.Read(id); chk.E(err) {
+		return
+	}
+	var session *Session
+	if session, err = s.Group.NewSession(hex.Enc(id), ev, s.sign.Sec()); err != nil {
+		return
+	}
+	sg := &signing{Session: session, started: time.Now(), done: make(chan error, 1)}
+	s.Lock()
+	s.sessions[session.ID] = sg
+	s.Unlock()
+	defer func() {
+		s.Lock()
+		delete(s.sessions, session.ID)
+		s.Unlock()
+	}()
+	proposal := *ev
+	proposal.Sig = make([]byte, 64)
+	if err = s.broadcast(c, &Message{Session: session.ID, Type: Propose,
+		Event: string(proposal.Serialize()), Nonce: session.PublicNonce()}); err != nil {
+		return
+	}
+	select {
+	case err = <-sg.done:
+		return
+	case <-c.Done():
+		return errorf.E("the event was not signed by all the members: %v", c.Err())
+	}
+}
+
+// receive handles a message from another member.
+func (s *Signer) receive(c context.T, ev *event.T) {
+	if !ev.Kind.Equal(kind.CoSign) || !s.Group.IsMember(ev.Pubkey) ||
+		string(ev.Pubkey) == string(s.sign.Pub()) {
+		return
+	}
+	if valid, err := ev.Verify(); err != nil || !valid {
+		return
+	}
+	ck, err := encryption.GenerateConversationKey(ev.Pubkey, s.sign.Sec())
+	if chk.E(err) {
+		return
+	}
+	var plain []byte
+	if plain, err = encryption.Decrypt(ev.Content, ck); chk.D(err) {
+		return
+	}
+	msg := &Message{}
+	if err = json.Unmarshal(plain, msg); chk.D(err) {
+		return
+	}
+	for _, out := range s.handle(ev.Pubkey, msg) {
+		if err = s.broadcast(c, out); chk.E(err) {
+			continue
+		}
+	}
+}
+
+// handle applies a message to its session, returning the messages to send to the other
+// members.
+func (s *Signer) handle(from []byte, msg *Message) (out []*Message) {
+	s.Lock()
+	defer s.Unlock()
+	s.prune()
+	sg, ok := s.sessions[msg.Session]
+	if msg.Type == Propose {
+		if ok {
+			return
+		}
+		var err error
+		if sg, err = s.propose(from, msg); err != nil {
+			log.D.F("rejecting co-signing session %s: %v", msg.Session, err)
+			return []*Message{{Session: msg.Session, Type: Reject, Reason: err.Error()}}
+		}
+		s.sessions[msg.Session] = sg
+		out = append(out, &Message{Session: msg.Session, Type: Nonce,
+			Nonce: sg.PublicNonce()})
+		// the proposal was sent with the nonce of the proposer.
+		early := append([]received{{from: from, msg: &Message{Session: msg.Session,
+			Type: Nonce, Nonce: msg.Nonce}}}, s.early[msg.Session]...)
+		delete(s.early, msg.Session)
+		for _, r := range early {
+			out = append(out, s.apply(sg, r.from, r.msg)...)
+		}
+		return
+	}
+	if !ok {
+		// the proposal may arrive after the messages of members that have approved it.
+		s.early[msg.Session] = append(s.early[msg.Session],
+			received{from: from, msg: msg, at: time.Now()})
+		return
+	}
+	return s.apply(sg, from, msg)
+}
+
+// propose starts a session for an event proposed by another member, if it is approved.
+func (s *Signer) propose(from []byte, msg *Message) (sg *signing, err error) {
+	ev := &event.T{}
+	if _, err = ev.Unmarshal([]byte(msg.Event)); err != nil {
+		return
+	}
+	if s.Approve == nil {
+		err = errorf.E("not accepting proposals")
+		return
+	}
+	if err = s.Approve(from, ev); err != nil {
+		return
+	}
+	var session *Session
+	if session, err = s.Group.NewSession(msg.Session, ev, s.sign.Sec()); err != nil {
+		return
+	}
+	return &signing{Session: session, started: time.Now()}, nil
+}
+
+// apply applies a nonce, partial signature or rejection to a session, ending it if the event
+// has been signed or rejected.
+//
+// This must be called with the mutex locked.
+func (s *Signer) apply(sg *signing, from []byte, msg *Message) (out []*Message) {
+	var err error
+	switch msg.Type {
+	case Nonce:
+		var partialSig string
+		if partialSig, err = sg.AddNonce(from, msg.Nonce); err != nil {
+			break
+		}
+		if partialSig != "" {
+			out = append(out, &Message{Session: sg.ID, Type: PartialSig,
+				PartialSig: partialSig})
+		}
+	case PartialSig:
+		_, err = sg.AddPartialSig(from, msg.PartialSig)
+	case Reject:
+		err = errorf.E("%0x rejected the event: %s", from, msg.Reason)
+	default:
+		return
+	}
+	if err == nil && !sg.Done() {
+		return
+	}
+	if err != nil {
+		log.D.F("co-signing session %s failed: %v", sg.ID, err)
+	}
+	if sg.done != nil {
+		sg.done <- err
+	}
+	delete(s.sessions, sg.ID)
+	return
+}
+
+// prune removes the sessions and early messages older than the SessionTimeout.
+//
+// This must be called with the mutex locked.
+func (s *Signer) prune() {
+	for id, sg := range s.sessions {
+		if sg.done == nil && time.Since(sg.started) > SessionTimeout {
+			delete(s.sessions, id)
+		}
+	}
+	for id, rr := range s.early {
+		if time.Since(rr[0].at) > SessionTimeout {
+			delete(s.early, id)
+		}
+	}
+}
+
+// broadcast sends a message to all the other members.
+func (s *Signer) broadcast(c context.T, msg *Message) (err error) {
+	var b []byte
+	if b, err = json.Marshal(msg); chk.E(err) {
+		return
+	}
+	for _, m := range s.Group.Members() {
+		if string(m) == string(s.sign.Pub()) {
+			continue
+		}
+		var ck, content []byte
+		if ck, err = encryption.GenerateConversationKey(m, s.sign.Sec()); chk.E(err) {
+			return
+		}
+		if content, err = encryption.Encrypt(b, ck); chk.E(err) {
+			return
+		}
+		ev := &event.T{CreatedAt: timestamp.Now(), Kind: kind.CoSign, Content: content,
+			Tags: tags.New(tag.New("p", hex.Enc(m)))}
+		if err = ev.Sign(s.sign); chk.E(err) {
+			return
+		}
+		var sent bool
+		for _, u := range s.Relays {
+			var relay *ws.Client
+			if relay, err = s.pool.EnsureRelay(u); chk.D(err) {
+				continue
+			}
+			if err = relay.Publish(c, ev); chk.D(err) {
+				continue
+			}
+			sent = true
+		}
+		if !sent {
+			return errorf.E("could not send to %0x on any relay: %v", m, err)
+		}
+	}
+	return nil
+}
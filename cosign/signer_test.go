@@ -0,0 +1,51 @@
+package cosign
+
+import (
+	"testing"
+	"time"
+
+	"realy.lol/context"
+	"realy.lol/errorf"
+	"realy.lol/event"
+	"realy.lol/kind"
+	"realy.lol/timestamp"
+	"realy.lol/ws/wstest"
+)
+
+func TestSigner(t *testing.T) {
+	c, cancel := context.Timeout(context.Bg(), 10*time.Second)
+	defer cancel()
+	relays := []string{wstest.Start(t)}
+	keys, g := newMembers(t, 3)
+	signers := make([]*Signer, len(keys))
+	for i, k := range keys {
+		var err error
+		if signers[i], err = NewSigner(c, k, g, relays); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// give the signers time to subscribe.
+	time.Sleep(200 * time.Millisecond)
+	approve := func(_ []byte, ev *event.T) error {
+		if !ev.Kind.Equal(kind.TextNote) {
+			return errorf.E("only text notes")
+		}
+		return nil
+	}
+	for _, s := range signers[1:] {
+		s.Approve = approve
+	}
+	ev := &event.T{CreatedAt: timestamp.Now(), Kind: kind.TextNote,
+		Content: []byte("from all of us")}
+	if err := signers[0].Sign(c, ev); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := ev.Verify(); err != nil || !ok {
+		t.Fatalf("co-signed event valid %v %v", ok, err)
+	}
+	// a member that does not approve the event stops it being signed.
+	ev = &event.T{CreatedAt: timestamp.Now(), Kind: kind.Reaction, Content: []byte("+")}
+	if err := signers[0].Sign(c, ev); err == nil {
+		t.Fatal("signed an event that was rejected")
+	}
+}
@@ -5,7 +5,9 @@ package dns
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"regexp"
 	"strings"
 
@@ -98,12 +100,20 @@ func Fetch(c context.T, account string) (resp *WellKnownResponse,
 		err = errorf.E("failed to parse '%s': %w", account, err)
 		return
 	}
+	resp, err = FetchFrom(c, "https://"+domain, name)
+	return
+}
+
+// FetchFrom queries the NIP-05 verification document for a name from a base URL, which is
+// https:// and the domain of the identifier for a real query, and may be a local server in
+// tests.
+func FetchFrom(c context.T, base, name string) (resp *WellKnownResponse, err error) {
 	var req *http.Request
 	if req, err = http.NewRequestWithContext(c, "GET",
-		fmt.Sprintf("https://%s/.well-known/nostr.json?name=%s", domain, name),
+		fmt.Sprintf("%s%s?name=%s", base, WellKnownPath, url.QueryEscape(name)),
 		nil); chk.E(err) {
 
-		return resp, name, errorf.E("failed to create a request: %w", err)
+		return resp, errorf.E("failed to create a request: %w", err)
 	}
 	client := &http.Client{
 		CheckRedirect: func(req *http.Request,
@@ -117,13 +127,15 @@ func Fetch(c context.T, account string) (resp *WellKnownResponse,
 		return
 	}
 	defer func() { _ = res.Body.Close() }()
+	if res.StatusCode != http.StatusOK {
+		err = errorf.E("request failed: %s", res.Status)
+		return
+	}
 	resp = NewWellKnownResponse()
-	b := make([]byte, 65535)
-	var n int
-	if n, err = res.Body.Read(b); chk.E(err) {
+	var b []byte
+	if b, err = io.ReadAll(io.LimitReader(res.Body, 65535)); chk.E(err) {
 		return
 	}
-	b = b[:n]
 	if err = json.Unmarshal(b, resp); chk.E(err) {
 		err = errorf.E("failed to decode json response: %w", err)
 	}
@@ -147,3 +159,23 @@ func StringSliceToByteSlice(ss []string) (bs [][]byte) {
 	}
 	return
 }
+
+// QueryBunker queries the NIP-05 identity of a remote signer, returning its pubkey and the
+// relays in the nip46 field that it listens for requests on.
+func QueryBunker(c context.T, account string) (pubkey string, relays []string, err error) {
+	var result *WellKnownResponse
+	var name string
+	if result, name, err = Fetch(c, account); chk.E(err) {
+		return
+	}
+	var ok bool
+	if pubkey, ok = result.Names[name]; !ok {
+		err = errorf.E("no entry found for the '%s' name", name)
+		return
+	}
+	if relays, ok = result.NIP46[pubkey]; !ok {
+		err = errorf.E("no bunker relays found for the '%s' name", name)
+		return
+	}
+	return
+}
@@ -0,0 +1,179 @@
+package dns
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"realy.lol/chk"
+	"realy.lol/errorf"
+	"realy.lol/keys"
+)
+
+// WellKnownPath is the path that NIP-05 identities are served at.
+const WellKnownPath = "/.well-known/nostr.json"
+
+// NameRegex matches the local part of the NIP-05 names that may be registered, which are
+// restricted to the characters the specification recommends.
+var NameRegex = regexp.MustCompile(`^[a-z0-9._-]{1,64}$`)
+
+// Name is an entry in a registry of NIP-05 names.
+type Name struct {
+	// Name is the local part of the identifier, _ for the domain itself.
+	Name string `json:"name"`
+	// Pubkey is the hex encoded public key the name resolves to.
+	Pubkey string `json:"pubkey"`
+	// Registered is the unix timestamp of when the name was registered.
+	Registered int64 `json:"registered"`
+}
+
+// Registry stores the NIP-05 names served by a domain.
+type Registry interface {
+	// GetName returns the entry of a name, or nil if it is not registered.
+	GetName(name string) (n *Name, err error)
+	// SetName stores the entry of a name, replacing any previous one.
+	SetName(n *Name) (err error)
+	// DeleteName removes the entry of a name.
+	DeleteName(name string) (err error)
+	// Names returns all the registered names.
+	Names() (nn []*Name, err error)
+}
+
+// NormalizeName returns the lowercase form of a name, or an error if it may not be registered.
+func NormalizeName(name string) (n string, err error) {
+	n = strings.ToLower(name)
+	if !NameRegex.MatchString(n) {
+		err = errorf.E("invalid name '%s'", name)
+	}
+	return
+}
+
+// Validate checks a registry entry has a valid name and pubkey, normalizing the name.
+func (n *Name) Validate() (err error) {
+	if n.Name, err = NormalizeName(n.Name); err != nil {
+		return
+	}
+	n.Pubkey = strings.ToLower(n.Pubkey)
+	if !keys.IsValidPublicKey(n.Pubkey) {
+		err = errorf.E("invalid pubkey '%s'", n.Pubkey)
+	}
+	return
+}
+
+// WellKnown serves the NIP-05 document of the names in a Registry.
+type WellKnown struct {
+	Registry Registry
+	// Relays returns the relays recommended for the users of a request, if it is not nil.
+	Relays func(r *http.Request) []string
+}
+
+// ServeHTTP answers a request for the NIP-05 document, with only the entry of the name in the
+// query if there is one, and otherwise every registered name.
+func (wk *WellKnown) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed),
+			http.StatusMethodNotAllowed)
+		return
+	}
+	var err error
+	var nn []*Name
+	if q := r.URL.Query().Get("name"); q != "" {
+		var n *Name
+		if q, err = NormalizeName(q); err == nil {
+			if n, err = wk.Registry.GetName(q); chk.E(err) {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		if n != nil {
+			nn = append(nn, n)
+		}
+	} else if nn, err = wk.Registry.Names(); chk.E(err) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp := &WellKnownResponse{Names: make(map[string]string)}
+	var relays []string
+	if wk.Relays != nil {
+		relays = wk.Relays(r)
+	}
+	for _, n := range nn {
+		resp.Names[n.Name] = n.Pubkey
+		if len(relays) > 0 {
+			if resp.Relays == nil {
+				resp.Relays = make(map[string][]string)
+			}
+			resp.Relays[n.Pubkey] = relays
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	chk.E(json.NewEncoder(w).Encode(resp))
+}
+
+var (
+	// ErrNameTaken is returned by Claim when a name is registered to another pubkey.
+	ErrNameTaken = errors.New("name is registered to another pubkey")
+	// ErrNameReserved is returned by Claim for a reserved name, which only an admin may
+	// register.
+	ErrNameReserved = errors.New("name is reserved")
+)
+
+// ReservedNames are the names that users may not claim for themselves: _ is the identity of
+// the domain itself, and the others are commonly taken to speak for it.
+var ReservedNames = []string{"_", "admin", "administrator", "root", "postmaster", "hostmaster",
+	"webmaster", "abuse", "security", "support"}
+
+// IsReserved returns true if a normalized name is one of the ReservedNames or starts with _.
+func IsReserved(name string) bool {
+	return strings.HasPrefix(name, "_") || slices.Contains(ReservedNames, name)
+}
+
+// claimMx serializes the claims of names, so that a name that is free is not claimed by two
+// pubkeys at once.
+var claimMx sync.Mutex
+
+// Claim registers a name to a pubkey for the pubkey itself, so that a pubkey holds at most one
+// name: any other name it holds is removed. A name that is registered to another pubkey, or
+// that is reserved, cannot be claimed.
+func Claim(reg Registry, name, pubkey string) (n *Name, err error) {
+	n = &Name{Name: name, Pubkey: pubkey, Registered: time.Now().Unix()}
+	if err = n.Validate(); err != nil {
+		return
+	}
+	if IsReserved(n.Name) {
+		err = ErrNameReserved
+		return
+	}
+	claimMx.Lock()
+	defer claimMx.Unlock()
+	var prev *Name
+	if prev, err = reg.GetName(n.Name); chk.E(err) {
+		return
+	}
+	if prev != nil {
+		if prev.Pubkey != n.Pubkey {
+			err = ErrNameTaken
+			return
+		}
+		n = prev
+		return
+	}
+	var nn []*Name
+	if nn, err = reg.Names(); chk.E(err) {
+		return
+	}
+	for _, o := range nn {
+		if o.Pubkey == n.Pubkey {
+			if err = reg.DeleteName(o.Name); chk.E(err) {
+				return
+			}
+		}
+	}
+	err = reg.SetName(n)
+	return
+}
@@ -0,0 +1,157 @@
+package dns
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memRegistry map[string]Name
+
+func (m memRegistry) GetName(name string) (n *Name, err error) {
+	if c, ok := m[name]; ok {
+		n = &c
+	}
+	return
+}
+
+func (m memRegistry) SetName(n *Name) (err error) {
+	m[n.Name] = *n
+	return
+}
+
+func (m memRegistry) DeleteName(name string) (err error) {
+	delete(m, name)
+	return
+}
+
+func (m memRegistry) Names() (nn []*Name, err error) {
+	for _, n := range m {
+		c := n
+		nn = append(nn, &c)
+	}
+	return
+}
+
+const (
+	alice = "79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"
+	bob   = "c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5"
+)
+
+func TestClaim(t *testing.T) {
+	reg := memRegistry{}
+	if _, err := Claim(reg, "Alice", alice); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Claim(reg, "alice", bob); !errors.Is(err, ErrNameTaken) {
+		t.Fatalf("claimed a name registered to another pubkey: %v", err)
+	}
+	if _, err := Claim(reg, "not valid!", bob); err == nil {
+		t.Fatal("claimed an invalid name")
+	}
+	// a pubkey holds one name, so claiming another releases the first.
+	if _, err := Claim(reg, "al", alice); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := reg.GetName("alice"); n != nil || len(reg) != 1 {
+		t.Fatalf("previous name was not released: %v", reg)
+	}
+	for _, name := range []string{"_", "_relay", "Admin", "postmaster"} {
+		if _, err := Claim(reg, name, bob); !errors.Is(err, ErrNameReserved) {
+			t.Fatalf("claimed reserved name %s: %v", name, err)
+		}
+	}
+}
+
+// slowRegistry is a memRegistry that may be used concurrently and is slow to look up names.
+type slowRegistry struct {
+	sync.Mutex
+	memRegistry
+}
+
+func (s *slowRegistry) GetName(name string) (n *Name, err error) {
+	s.Lock()
+	n, err = s.memRegistry.GetName(name)
+	s.Unlock()
+	time.Sleep(time.Millisecond)
+	return
+}
+
+func (s *slowRegistry) SetName(n *Name) (err error) {
+	s.Lock()
+	defer s.Unlock()
+	return s.memRegistry.SetName(n)
+}
+
+func (s *slowRegistry) DeleteName(name string) (err error) {
+	s.Lock()
+	defer s.Unlock()
+	return s.memRegistry.DeleteName(name)
+}
+
+func (s *slowRegistry) Names() (nn []*Name, err error) {
+	s.Lock()
+	defer s.Unlock()
+	return s.memRegistry.Names()
+}
+
+func TestClaimConcurrent(t *testing.T) {
+	reg := &slowRegistry{memRegistry: memRegistry{}}
+	var wg sync.WaitGroup
+	var mx sync.Mutex
+	var claimed int
+	for _, pk := range []string{alice, bob, alice, bob} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n, err := Claim(reg, "carol", pk)
+			if err == nil && n.Pubkey == pk {
+				mx.Lock()
+				claimed++
+				mx.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	// the first claim wins, and the other pubkey is refused, though the same one may claim
+	// the name again.
+	if n, _ := reg.GetName("carol"); n == nil || claimed != 2 {
+		t.Fatalf("name claimed %d times: %v", claimed, n)
+
+	}
+}
+
+func TestWellKnown(t *testing.T) {
+	reg := memRegistry{"alice": {Name: "alice", Pubkey: alice},
+		"bob": {Name: "bob", Pubkey: bob}}
+	srv := httptest.NewServer(&WellKnown{Registry: reg,
+		Relays: func(*http.Request) []string { return []string{"wss://relay.example.com"} }})
+	defer srv.Close()
+	get := func(query string) (resp *WellKnownResponse) {
+		res, err := http.Get(srv.URL + WellKnownPath + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		resp = &WellKnownResponse{}
+		if err = json.NewDecoder(res.Body).Decode(resp); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	resp := get("?name=Alice")
+	if len(resp.Names) != 1 || resp.Names["alice"] != alice ||
+		len(resp.Relays[alice]) != 1 || resp.Relays[alice][0] != "wss://relay.example.com" {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	if resp = get("?name=carol"); len(resp.Names) != 0 {
+		t.Fatalf("unexpected response for an unregistered name %+v", resp)
+	}
+	if resp = get(""); len(resp.Names) != 2 {
+		t.Fatalf("unexpected response for all names %+v", resp)
+	}
+}
@@ -0,0 +1,220 @@
+package dns
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"realy.lol/chk"
+	"realy.lol/context"
+	"realy.lol/hex"
+	"realy.lol/log"
+)
+
+// Timeout is how long a Verifier waits for the domain of an identifier to answer.
+var Timeout = 5 * time.Second
+
+// RetryAge is how long a result that is not valid is used before the identifier is verified
+// again, so that a name that was just registered, or a domain that could not be reached, is
+// soon checked again.
+var RetryAge = 5 * time.Minute
+
+// Verification is the result of verifying the NIP-05 identifier of a pubkey.
+type Verification struct {
+	// Pubkey is the hex encoded pubkey that was verified.
+	Pubkey string `json:"pubkey"`
+	// Identifier is the name@domain identifier in the pubkey's profile.
+	Identifier string `json:"identifier"`
+	// Valid is true if the domain of the identifier resolved the name to the pubkey.
+	Valid bool `json:"valid"`
+	// Checked is the unix timestamp of when the identifier was last verified.
+	Checked int64 `json:"checked"`
+}
+
+// Verifications stores the results of verifying NIP-05 identifiers.
+type Verifications interface {
+	// GetVerification returns the last result of verifying a pubkey, or nil if there is none.
+	GetVerification(pubkey []byte) (v *Verification, err error)
+	// SetVerification stores the result of verifying a pubkey.
+	SetVerification(v *Verification) (err error)
+	// Verifications returns all the stored results.
+	Verifications() (vv []*Verification, err error)
+}
+
+// ProfileIdentifier returns the nip05 field of the content of a kind 0 profile event.
+func ProfileIdentifier(content []byte) (identifier string) {
+	var p struct {
+		NIP05 string `json:"nip05"`
+	}
+	if err := json.Unmarshal(content, &p); err != nil {
+		return
+	}
+	return strings.TrimSpace(p.NIP05)
+}
+
+// Verifier checks that pubkeys have a NIP-05 identifier on one of a set of domains, keeping
+// the results so that the domains are only queried again when a result is older than MaxAge,
+// or RetryAge if it is not valid.
+type Verifier struct {
+	Results Verifications
+	// Domains returns the domains that identifiers must be on.
+	Domains func() []string
+	// MaxAge is how long a valid result is used before the identifier is verified again.
+	MaxAge time.Duration
+	// Identifier returns the identifier in the latest profile of a pubkey, or an empty string
+	// if it has none.
+	Identifier func(c context.T, pubkey []byte) (identifier string, err error)
+	// Base returns the URL that the NIP-05 document of a domain is fetched from, if it is not
+	// nil, so tests can use a local server. The default is https:// and the domain.
+	Base func(domain string) string
+}
+
+// fresh returns true if a stored result is recent enough to be used, which is MaxAge for a
+// valid result and at most RetryAge for one that is not.
+func (v *Verifier) fresh(prev *Verification) bool {
+	age := v.MaxAge
+	if !prev.Valid {
+		age = min(age, RetryAge)
+	}
+	return time.Since(time.Unix(prev.Checked, 0)) < age
+}
+
+// allowed returns the domain of an identifier if it is one of the allowed domains.
+func (v *Verifier) allowed(identifier string) (name, domain string, ok bool) {
+	var err error
+	if name, domain, err = ParseIdentifier(identifier); err != nil {
+		return
+	}
+	domain = strings.ToLower(domain)
+	for _, d := range v.Domains() {
+		if strings.EqualFold(d, domain) {
+			return name, domain, true
+		}
+	}
+	return
+}
+
+// Verified returns true if a pubkey has a valid identifier on one of the allowed domains.
+//
+// If identifier is empty the one in the pubkey's stored profile is verified, and the result is
+// stored. A stored result for the same identifier is used if it is not older than MaxAge, or
+// RetryAge if it is not valid.
+//
+// If identifier is not empty it is verified in place of the stored one, as is needed to accept
+// a new profile. The result is not stored, as the profile may not be, except that a stored
+// valid result for the same identifier is used.
+func (v *Verifier) Verified(c context.T, pubkey []byte, identifier string) (ok bool,
+	err error) {
+
+	profile := identifier != ""
+	if !profile {
+		if identifier, err = v.Identifier(c, pubkey); chk.E(err) {
+			return
+		}
+	}
+	if _, _, allowed := v.allowed(identifier); !allowed {
+		return
+	}
+	var prev *Verification
+	if prev, err = v.Results.GetVerification(pubkey); chk.E(err) {
+		return
+	}
+	if prev != nil && prev.Identifier == identifier && v.fresh(prev) &&
+		(prev.Valid || !profile) {
+		return prev.Valid, nil
+	}
+	var res *Verification
+	if res, err = v.verify(c, pubkey, identifier, prev, !profile); err != nil {
+		return
+	}
+	return res.Valid, nil
+}
+
+// verify queries the domain of an identifier, and stores the result if store is true. A
+// result that was valid is kept if the domain cannot be reached, so that an outage does not
+// lock out its users until the next attempt.
+func (v *Verifier) verify(c context.T, pubkey []byte, identifier string,
+	prev *Verification, store bool) (res *Verification, err error) {
+
+	res = &Verification{Pubkey: hex.Enc(pubkey), Identifier: identifier,
+		Checked: time.Now().Unix()}
+	name, domain, _ := v.allowed(identifier)
+	base := "https://" + domain
+	if v.Base != nil {
+		base = v.Base(domain)
+	}
+	c, cancel := context.Timeout(c, Timeout)
+	defer cancel()
+	var resp *WellKnownResponse
+	if resp, err = FetchFrom(c, base, name); err != nil {
+		err = nil
+		if prev != nil && prev.Valid && prev.Identifier == identifier {
+			res.Valid = true
+		}
+	} else {
+		res.Valid = strings.EqualFold(resp.Names[name], res.Pubkey)
+	}
+	log.D.F("nip-05 identifier %s of %s valid: %v", identifier, res.Pubkey, res.Valid)
+	if !store {
+		return
+	}
+	if err = v.Results.SetVerification(res); chk.E(err) {
+		return
+	}
+	return
+}
+
+// Reverify verifies again the stored results that are no longer fresh, using the
+// identifiers in the current profiles of their pubkeys.
+func (v *Verifier) Reverify(c context.T) (err error) {
+	var vv []*Verification
+	if vv, err = v.Results.Verifications(); chk.E(err) {
+		return
+	}
+	for _, prev := range vv {
+		if c.Err() != nil {
+			return
+		}
+		if v.fresh(prev) {
+			continue
+		}
+		var pk []byte
+		if pk, err = hex.Dec(prev.Pubkey); chk.E(err) {
+			continue
+		}
+		var identifier string
+		if identifier, err = v.Identifier(c, pk); chk.E(err) {
+			continue
+		}
+		if _, _, allowed := v.allowed(identifier); !allowed {
+			// the profile no longer has an identifier on an allowed domain.
+			if prev.Valid {
+				chk.E(v.Results.SetVerification(&Verification{Pubkey: prev.Pubkey,
+					Identifier: identifier, Checked: time.Now().Unix()}))
+			}
+			continue
+		}
+		if _, err = v.verify(c, pk, identifier, prev, true); chk.E(err) {
+			continue
+		}
+	}
+	return
+}
+
+// Run calls Reverify at an interval until the context is canceled.
+func (v *Verifier) Run(c context.T, interval time.Duration) {
+	if interval <= 0 {
+		log.E.F("invalid nip-05 reverification interval %v", interval)
+		return
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-c.Done():
+			return
+		case <-t.C:
+			chk.E(v.Reverify(c))
+		}
+	}
+}
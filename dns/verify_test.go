@@ -0,0 +1,181 @@
+package dns
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"realy.lol/hex"
+)
+
+type memVerifications map[string]Verification
+
+func (m memVerifications) GetVerification(pubkey []byte) (v *Verification, err error) {
+	if c, ok := m[hex.Enc(pubkey)]; ok {
+		v = &c
+	}
+	return
+}
+
+func (m memVerifications) SetVerification(v *Verification) (err error) {
+	m[v.Pubkey] = *v
+	return
+}
+
+func (m memVerifications) Verifications() (vv []*Verification, err error) {
+	for _, v := range m {
+		c := v
+		vv = append(vv, &c)
+	}
+	return
+}
+
+func TestVerifier(t *testing.T) {
+	reg := memRegistry{"alice": {Name: "alice", Pubkey: alice}}
+	srv := httptest.NewServer(&WellKnown{Registry: reg})
+	defer srv.Close()
+	results := memVerifications{}
+	profiles := map[string]string{alice: "alice@example.com", bob: "bob@example.com"}
+	var queried []string
+	v := &Verifier{
+		Results: results,
+		Domains: func() []string { return []string{"Example.com"} },
+		MaxAge:  time.Hour,
+		Identifier: func(c context.Context, pubkey []byte) (string, error) {
+			return profiles[hex.Enc(pubkey)], nil
+		},
+		Base: func(domain string) string {
+			queried = append(queried, domain)
+			return srv.URL
+		},
+	}
+	c := context.Background()
+	pkA, _ := hex.Dec(alice)
+	pkB, _ := hex.Dec(bob)
+	if ok, err := v.Verified(c, pkA, ""); err != nil || !ok {
+		t.Fatalf("alice not verified: %v", err)
+	}
+	if ok, err := v.Verified(c, pkB, ""); err != nil || ok {
+		t.Fatalf("bob verified without a registered name: %v", err)
+	}
+	if ok, _ := v.Verified(c, pkA, "alice@elsewhere.com"); ok {
+		t.Fatal("verified an identifier on a domain that is not allowed")
+	}
+	// the stored results are used until they are older than MaxAge.
+	n := len(queried)
+	if ok, _ := v.Verified(c, pkA, ""); !ok || len(queried) != n {
+		t.Fatalf("stored result was not used, %d queries", len(queried))
+	}
+	// when alice's name is removed she is no longer verified after reverification, and bob
+	// is once his name is registered.
+	delete(reg, "alice")
+	reg["bob"] = Name{Name: "bob", Pubkey: bob}
+	for pk, r := range results {
+		r.Checked -= int64(2 * time.Hour / time.Second)
+		results[pk] = r
+	}
+	if err := v.Reverify(c); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := v.Verified(c, pkA, ""); ok {
+		t.Fatal("alice still verified after her name was removed")
+	}
+	if ok, _ := v.Verified(c, pkB, ""); !ok {
+		t.Fatal("bob not verified after his name was registered")
+	}
+	// an unreachable domain does not revoke a verification.
+	srv.Close()
+	for pk, r := range results {
+		r.Checked = 0
+		results[pk] = r
+	}
+	if ok, _ := v.Verified(c, pkB, ""); !ok {
+		t.Fatal("bob's verification was revoked when the domain could not be reached")
+	}
+}
+
+func TestVerifierRetry(t *testing.T) {
+	reg := memRegistry{}
+	srv := httptest.NewServer(&WellKnown{Registry: reg})
+	defer srv.Close()
+	results := memVerifications{}
+	var queried int
+	v := &Verifier{
+		Results: results,
+		Domains: func() []string { return []string{"example.com"} },
+		MaxAge:  time.Hour,
+		Identifier: func(c context.Context, pubkey []byte) (string, error) {
+			return "alice@example.com", nil
+		},
+		Base: func(domain string) string {
+			queried++
+			return srv.URL
+		},
+	}
+	c := context.Background()
+	pk, _ := hex.Dec(alice)
+	if ok, _ := v.Verified(c, pk, ""); ok {
+		t.Fatal("alice verified without a registered name")
+	}
+	// a new profile is verified again when the stored result is not valid.
+	reg["alice"] = Name{Name: "alice", Pubkey: alice}
+	if ok, _ := v.Verified(c, pk, "alice@example.com"); !ok || queried != 2 {
+		t.Fatalf("new profile was not verified again, %d queries", queried)
+	}
+	// a result that is not valid is only used for the RetryAge.
+	delete(reg, "alice")
+	r := results[alice]
+	r.Checked -= int64(2 * time.Hour / time.Second)
+	results[alice] = r
+	if ok, _ := v.Verified(c, pk, ""); ok {
+		t.Fatal("alice still verified after her name was removed")
+	}
+	reg["alice"] = Name{Name: "alice", Pubkey: alice}
+	if ok, _ := v.Verified(c, pk, ""); ok || queried != 3 {
+		t.Fatalf("result that is not valid was not used, %d queries", queried)
+	}
+	r = results[alice]
+	r.Checked -= int64(RetryAge/time.Second) + 1
+	results[alice] = r
+	if ok, _ := v.Verified(c, pk, ""); !ok || queried != 4 {
+		t.Fatalf("result that is not valid was used after the RetryAge, %d queries", queried)
+	}
+}
+
+func TestVerifierProfile(t *testing.T) {
+	reg := memRegistry{"alice": {Name: "alice", Pubkey: alice}}
+	srv := httptest.NewServer(&WellKnown{Registry: reg})
+	defer srv.Close()
+	results := memVerifications{}
+	identifier := "alice@example.com"
+	v := &Verifier{
+		Results: results,
+		Domains: func() []string { return []string{"example.com"} },
+		MaxAge:  time.Hour,
+		Identifier: func(c context.Context, pubkey []byte) (string, error) {
+			return identifier, nil
+		},
+		Base: func(domain string) string { return srv.URL },
+	}
+	c := context.Background()
+	pk, _ := hex.Dec(alice)
+	if ok, _ := v.Verified(c, pk, ""); !ok {
+		t.Fatal("alice not verified")
+	}
+	// a profile that is not stored does not change the stored result.
+	if ok, _ := v.Verified(c, pk, "mallory@example.com"); ok {
+		t.Fatal("unregistered identifier verified")
+	}
+	if r := results[alice]; !r.Valid || r.Identifier != "alice@example.com" {
+		t.Fatalf("stored result was replaced by %+v", r)
+	}
+	if ok, _ := v.Verified(c, pk, ""); !ok {
+		t.Fatal("alice not verified after an unstored profile")
+	}
+	// the identifier is taken from the stored profile, not from the stored result.
+	identifier = "bob@example.com"
+	if ok, _ := v.Verified(c, pk, ""); ok {
+		t.Fatal("verified with the identifier of a profile that was replaced")
+	}
+}
@@ -0,0 +1,112 @@
+package bip39
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+
+	"realy.lol/sha256"
+)
+
+var (
+	// ErrEntropyLength indicates that entropy is not 128 to 256 bits in a multiple of 32 bits.
+	ErrEntropyLength = errors.New("entropy must be 128 to 256 bits, in a multiple of 32")
+	// ErrMnemonicLength indicates that a mnemonic does not have 12, 15, 18, 21 or 24 words.
+	ErrMnemonicLength = errors.New("mnemonic must have 12, 15, 18, 21 or 24 words")
+	// ErrChecksum indicates that the checksum of a mnemonic does not match its entropy.
+	ErrChecksum = errors.New("mnemonic checksum is invalid")
+)
+
+// wordIndex is the position of each word in the word list.
+var wordIndex = func() (m map[string]int) {
+	m = make(map[string]int, len(English))
+	for i, w := range English {
+		m[w] = i
+	}
+	return
+}()
+
+// NewEntropy returns a number of bits of entropy from the system random source, which must be
+// from 128 to 256 in a multiple of 32.
+func NewEntropy(bits int) (entropy []byte, err error) {
+	if bits < 128 || bits > 256 || bits%32 != 0 {
+		return nil, ErrEntropyLength
+	}
+	entropy = make([]byte, bits/8)
+	if _, err = rand.Read(entropy); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// NewMnemonic encodes entropy as a mnemonic, with one word for each 11 bits of the entropy
+// followed by the first bits of its hash as a checksum.
+func NewMnemonic(entropy []byte) (mnemonic string, err error) {
+	bits := len(entropy) * 8
+	if bits < 128 || bits > 256 || bits%32 != 0 {
+		return "", ErrEntropyLength
+	}
+	hash := sha256.Sum256(entropy)
+	data := append(append([]byte{}, entropy...), hash[0])
+	words := make([]string, (bits+bits/32)/11)
+	for i := range words {
+		var idx int
+		for j := 0; j < 11; j++ {
+			bit := i*11 + j
+			idx = idx<<1 | int(data[bit/8]>>(7-bit%8)&1)
+		}
+		words[i] = English[idx]
+	}
+	return strings.Join(words, " "), nil
+}
+
+// MnemonicToEntropy decodes a mnemonic, checking its words and checksum.
+func MnemonicToEntropy(mnemonic string) (entropy []byte, err error) {
+	words := strings.Fields(norm.NFKD.String(mnemonic))
+	switch len(words) {
+	case 12, 15, 18, 21, 24:
+	default:
+		return nil, ErrMnemonicLength
+	}
+	bits := len(words) * 11
+	entBits := bits * 32 / 33
+	data := make([]byte, (bits+7)/8)
+	for i, w := range words {
+		idx, ok := wordIndex[strings.ToLower(w)]
+		if !ok {
+			return nil, fmt.Errorf("'%s' is not a mnemonic word", w)
+		}
+		for j := 0; j < 11; j++ {
+			if idx>>(10-j)&1 == 1 {
+				bit := i*11 + j
+				data[bit/8] |= 1 << (7 - bit%8)
+			}
+		}
+	}
+	entropy = data[:entBits/8]
+	hash := sha256.Sum256(entropy)
+	csBits := uint(bits - entBits)
+	if data[entBits/8]>>(8-csBits) != hash[0]>>(8-csBits) {
+		return nil, ErrChecksum
+	}
+	return
+}
+
+// IsValid returns true if a mnemonic has valid words and checksum.
+func IsValid(mnemonic string) bool {
+	_, err := MnemonicToEntropy(mnemonic)
+	return err == nil
+}
+
+// Seed derives the 64 byte BIP-32 seed of a mnemonic and passphrase, which may be empty. The
+// mnemonic is not checked, use MnemonicToEntropy or IsValid for that.
+func Seed(mnemonic, passphrase string) (seed []byte, err error) {
+	m := strings.Join(strings.Fields(norm.NFKD.String(mnemonic)), " ")
+	return pbkdf2.Key(sha512.New, m, []byte("mnemonic"+norm.NFKD.String(passphrase)),
+		2048, 64)
+}
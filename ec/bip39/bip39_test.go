@@ -0,0 +1,56 @@
+package bip39
+
+import (
+	"bytes"
+	"testing"
+
+	"realy.lol/hex"
+)
+
+func TestMnemonic(t *testing.T) {
+	// test vectors of BIP-39, with the passphrase "TREZOR".
+	for _, v := range []struct{ entropy, mnemonic, seed string }{
+		{"00000000000000000000000000000000",
+			"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+			"c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04"},
+	} {
+		entropy, _ := hex.Dec(v.entropy)
+		mnemonic, err := NewMnemonic(entropy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mnemonic != v.mnemonic {
+			t.Fatalf("mnemonic %s", mnemonic)
+		}
+		seed, err := Seed(mnemonic, "TREZOR")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hex.Enc(seed) != v.seed {
+			t.Fatalf("seed %0x", seed)
+		}
+	}
+	for _, bits := range []int{128, 160, 192, 224, 256} {
+		entropy, err := NewEntropy(bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mnemonic, err := NewMnemonic(entropy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := MnemonicToEntropy(mnemonic)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(decoded, entropy) {
+			t.Fatalf("decoded %0x from %s", decoded, mnemonic)
+		}
+	}
+	if IsValid("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon") {
+		t.Fatal("mnemonic with a wrong checksum is valid")
+	}
+	if IsValid("abandon abandon abandon") {
+		t.Fatal("short mnemonic is valid")
+	}
+}
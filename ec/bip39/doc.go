@@ -0,0 +1,4 @@
+// Package bip39 implements BIP-39 mnemonic phrases, which encode the entropy a wallet is
+// derived from as a list of words that is easy to write down, and the derivation of the BIP-32
+// seed from a mnemonic and an optional passphrase.
+package bip39
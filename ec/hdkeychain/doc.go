@@ -0,0 +1,3 @@
+// Package hdkeychain implements the derivation of BIP-32 hierarchical deterministic private keys
+// from a seed, such as one derived from a BIP-39 mnemonic, along a path of child indexes.
+package hdkeychain
@@ -0,0 +1,166 @@
+package hdkeychain
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/ripemd160"
+
+	"realy.lol/ec/base58"
+	"realy.lol/ec/chaincfg"
+	"realy.lol/ec/secp256k1"
+	"realy.lol/sha256"
+)
+
+// HardenedKeyStart is the index of the first hardened child key, which is derived from the
+// private key of its parent so that it cannot be derived from the public key.
+const HardenedKeyStart = uint32(0x80000000)
+
+var (
+	// ErrInvalidSeedLen indicates that a seed is not from 16 to 64 bytes long.
+	ErrInvalidSeedLen = errors.New("seed must be from 16 to 64 bytes")
+	// ErrUnusableSeed indicates that a seed derives an invalid key, which happens with a
+	// probability of less than 1 in 2^127.
+	ErrUnusableSeed = errors.New("unusable seed")
+	// ErrInvalidChild indicates that a child index derives an invalid key, which happens
+	// with a probability of less than 1 in 2^127, and the next index should be used.
+	ErrInvalidChild = errors.New("the child index derives an invalid key")
+	// ErrInvalidPath indicates that a derivation path cannot be parsed.
+	ErrInvalidPath = errors.New("invalid derivation path")
+)
+
+// ExtendedKey is a BIP-32 extended private key, which is a secret key and the chain code its
+// children are derived with.
+type ExtendedKey struct {
+	key       *secp256k1.SecretKey
+	chainCode []byte
+	depth     uint8
+	parentFP  []byte
+	childNum  uint32
+}
+
+// NewMaster derives the master key of a seed.
+func NewMaster(seed []byte) (k *ExtendedKey, err error) {
+	if len(seed) < 16 || len(seed) > 64 {
+		return nil, ErrInvalidSeedLen
+	}
+	h := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	h.Write(seed)
+	lr := h.Sum(nil)
+	var s secp256k1.ModNScalar
+	if overflow := s.SetByteSlice(lr[:32]); overflow || s.IsZero() {
+		return nil, ErrUnusableSeed
+	}
+	return &ExtendedKey{key: secp256k1.NewSecretKey(&s), chainCode: lr[32:],
+		parentFP: []byte{0, 0, 0, 0}}, nil
+}
+
+// SecretKey returns the secret key of the extended key.
+func (k *ExtendedKey) SecretKey() *secp256k1.SecretKey { return k.key }
+
+// Depth returns the number of derivations from the master key to the extended key.
+func (k *ExtendedKey) Depth() uint8 { return k.depth }
+
+// ChildIndex returns the index the extended key was derived from its parent with.
+func (k *ExtendedKey) ChildIndex() uint32 { return k.childNum }
+
+// Fingerprint returns the first four bytes of the hash160 of the public key, which identifies
+// the parent of a key.
+func (k *ExtendedKey) Fingerprint() []byte {
+	sum := sha256.Sum256(k.key.PubKey().SerializeCompressed())
+	h := ripemd160.New()
+	h.Write(sum[:])
+	return h.Sum(nil)[:4]
+}
+
+// Derive returns the child of the extended key with an index, which is hardened if it is at
+// least HardenedKeyStart.
+func (k *ExtendedKey) Derive(i uint32) (child *ExtendedKey, err error) {
+	if k.depth == 255 {
+		return nil, errors.New("cannot derive beyond a depth of 255")
+	}
+	data := make([]byte, 0, 37)
+	if i >= HardenedKeyStart {
+		data = append(data, 0)
+		b := k.key.Key.Bytes()
+		data = append(data, b[:]...)
+	} else {
+		data = append(data, k.key.PubKey().SerializeCompressed()...)
+	}
+	data = binary.BigEndian.AppendUint32(data, i)
+	h := hmac.New(sha512.New, k.chainCode)
+	h.Write(data)
+	lr := h.Sum(nil)
+	var s secp256k1.ModNScalar
+	if overflow := s.SetByteSlice(lr[:32]); overflow {
+		return nil, ErrInvalidChild
+	}
+	s.Add(&k.key.Key)
+	if s.IsZero() {
+		return nil, ErrInvalidChild
+	}
+	return &ExtendedKey{key: secp256k1.NewSecretKey(&s), chainCode: lr[32:],
+		depth: k.depth + 1, parentFP: k.Fingerprint(), childNum: i}, nil
+}
+
+// ParsePath reads a derivation path such as "m/44'/1237'/0'/0/0", where indexes ending with '
+// or h are hardened.
+func ParsePath(path string) (indexes []uint32, err error) {
+	parts := strings.Split(path, "/")
+	if parts[0] != "m" {
+		return nil, ErrInvalidPath
+	}
+	for _, p := range parts[1:] {
+		var hardened bool
+		if strings.HasSuffix(p, "'") || strings.HasSuffix(p, "h") {
+			p, hardened = p[:len(p)-1], true
+		}
+		var n uint64
+		if n, err = strconv.ParseUint(p, 10, 31); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidPath, path)
+		}
+		if hardened {
+			n += uint64(HardenedKeyStart)
+		}
+		indexes = append(indexes, uint32(n))
+	}
+	return
+}
+
+// DerivePath returns the descendant of the extended key along a derivation path such as
+// "m/44'/1237'/0'/0/0".
+func (k *ExtendedKey) DerivePath(path string) (child *ExtendedKey, err error) {
+	var indexes []uint32
+	if indexes, err = ParsePath(path); err != nil {
+		return
+	}
+	child = k
+	for _, i := range indexes {
+		if child, err = child.Derive(i); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// String returns the extended key serialized for a network, such as an xprv for the
+// chaincfg.MainNetParams.
+func (k *ExtendedKey) String(net *chaincfg.Params) string {
+	b := make([]byte, 0, 82)
+	b = append(b, net.HDPrivateKeyID[:]...)
+	b = append(b, k.depth)
+	b = append(b, k.parentFP...)
+	b = binary.BigEndian.AppendUint32(b, k.childNum)
+	b = append(b, k.chainCode...)
+	b = append(b, 0)
+	key := k.key.Key.Bytes()
+	b = append(b, key[:]...)
+	first := sha256.Sum256(b)
+	second := sha256.Sum256(first[:])
+	return base58.Encode(append(b, second[:4]...))
+}
@@ -0,0 +1,34 @@
+package hdkeychain
+
+import (
+	"testing"
+
+	"realy.lol/ec/chaincfg"
+	"realy.lol/hex"
+)
+
+func TestDerivePath(t *testing.T) {
+	// test vector 1 of BIP-32.
+	seed, _ := hex.Dec("000102030405060708090a0b0c0d0e0f")
+	m, err := NewMaster(seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []struct{ path, xprv string }{
+		{"m", "xprv9s21ZrQH143K3QTDL4LXw2F7HEK3wJUD2nW2nRk4stbPy6cq3jPPqjiChkVvvNKmPGJxWUtg6LnF5kejMRNNU3TGtRBeJgk33yuGBxrMPHi"},
+		{"m/0'/1/2'/2/1000000000", "xprvA41z7zogVVwxVSgdKUHDy1SKmdb533PjDz7J6N6mV6uS3ze1ai8FHa8kmHScGpWmj4WggLyQjgPie1rFSruoUihUZREPSL39UNdE3BBDu76"},
+	} {
+		k, err := m.DerivePath(v.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s := k.String(&chaincfg.MainNetParams); s != v.xprv {
+			t.Errorf("%s derived %s", v.path, s)
+		}
+	}
+	for _, path := range []string{"", "0/1", "m/x", "m/2147483648"} {
+		if _, err = m.DerivePath(path); err == nil {
+			t.Errorf("derived invalid path '%s'", path)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package schnorr
+
+import (
+	"crypto/rand"
+	"sort"
+
+	"realy.lol/ec"
+	"realy.lol/ec/chainhash"
+)
+
+// BatchItem is a signature to check with BatchVerify, with the hash it signs and the BIP-340
+// x-only public key it is signed with.
+type BatchItem struct {
+	Hash   []byte
+	Sig    []byte
+	PubKey []byte
+}
+
+// batchTerm is a parsed BatchItem.
+type batchTerm struct {
+	// index is the position of the item in the batch.
+	index int
+	s     btcec.ModNScalar
+	e     btcec.ModNScalar
+	r     btcec.JacobianPoint
+	p     btcec.JacobianPoint
+	// pub is the serialized public key, to merge the terms of the same key.
+	pub string
+	sig *Signature
+	raw BatchItem
+}
+
+// BatchVerify checks many BIP-340 signatures at once, which is much faster than checking them
+// one at a time, returning the indexes of the invalid signatures in ascending order, or nil if
+// they are all valid.
+//
+// The batch is checked as a random linear combination of the verification equations of its
+// signatures, as described in BIP-340:
+//
+//	(a_1*s_1 + ... + a_u*s_u)*G = a_1*R_1 + ... + a_u*R_u + a_1*e_1*P_1 + ... + a_u*e_u*P_u
+//
+// where a_1 is 1 and the other a_i are random, so that invalid signatures cannot be crafted to
+// cancel each other out. The right hand side is computed with a single multi-scalar
+// multiplication. If the batch fails, it is split in halves which are checked again, until the
+// invalid signatures are found, so a batch with few invalid signatures costs little more than
+// one with none.
+func BatchVerify(items []BatchItem) (invalid []int) {
+	terms := make([]*batchTerm, 0, len(items))
+	for i, item := range items {
+		t, err := parseBatchItem(i, item)
+		if err != nil {
+			invalid = append(invalid, i)
+			continue
+		}
+		terms = append(terms, t)
+	}
+	invalid = append(invalid, findInvalid(terms)...)
+	if len(invalid) == 0 {
+		return nil
+	}
+	sort.Ints(invalid)
+	return
+}
+
+// parseBatchItem parses the signature and public key of an item and computes its challenge e
+// and the point R of its signature.
+func parseBatchItem(i int, item BatchItem) (t *batchTerm, err error) {
+	t = &batchTerm{index: i, raw: item}
+	if t.sig, err = ParseSignature(item.Sig); err != nil {
+		return
+	}
+	var pub, r *btcec.PublicKey
+	if pub, err = ParsePubKey(item.PubKey); err != nil {
+		return
+	}
+	// R = lift_x(r), the point with the x coordinate r and an even y, which fails if r is not
+	// the x coordinate of a point on the curve.
+	if r, err = ParsePubKey(item.Sig[:32]); err != nil {
+		return
+	}
+	pub.AsJacobian(&t.p)
+	r.AsJacobian(&t.r)
+	t.s.Set(&t.sig.s)
+	t.pub = string(item.PubKey)
+	// e = int(tagged_hash("BIP0340/challenge", bytes(r) || bytes(P) || M)) mod n.
+	commitment := chainhash.TaggedHash(chainhash.TagBIP0340Challenge, item.Sig[:32],
+		item.PubKey, item.Hash)
+	t.e.SetBytes((*[32]byte)(commitment))
+	return
+}
+
+// findInvalid returns the indexes of the items of the terms whose signatures are invalid,
+// bisecting the batch until they are found.
+func findInvalid(terms []*batchTerm) (invalid []int) {
+	switch {
+	case len(terms) == 0:
+		return
+	case len(terms) == 1:
+		if schnorrVerify(terms[0].sig, terms[0].raw.Hash, terms[0].raw.PubKey) != nil {
+			invalid = append(invalid, terms[0].index)
+		}
+		return
+	case batchValid(terms):
+		return
+	}
+	half := len(terms) / 2
+	return append(findInvalid(terms[:half]), findInvalid(terms[half:])...)
+}
+
+// batchValid returns true if the random linear combination of the verification equations of
+// the terms holds, which means they are all valid.
+func batchValid(terms []*batchTerm) bool {
+	coeffs := make([]byte, 32*len(terms))
+	if _, err := rand.Read(coeffs); err != nil {
+		return false
+	}
+	var sumS btcec.ModNScalar
+	points := make([]btcec.JacobianPoint, 0, 2*len(terms))
+	scalars := make([]btcec.ModNScalar, 0, 2*len(terms))
+	// the terms of the same public key are merged, as events are often by few authors.
+	pubs := make(map[string]int)
+	for i, t := range terms {
+		var a btcec.ModNScalar
+		if i == 0 {
+			a.SetInt(1)
+		} else {
+			a.SetByteSlice(coeffs[32*i : 32*i+32])
+			if a.IsZero() {
+				a.SetInt(1)
+			}
+		}
+		// a_i*s_i is summed on the left, and the terms on the right are negated so that the
+		// whole equation must sum to the point at infinity.
+		var as btcec.ModNScalar
+		as.Mul2(&a, &t.s)
+		sumS.Add(&as)
+		a.Negate()
+		points, scalars = append(points, t.r), append(scalars, a)
+		var ae btcec.ModNScalar
+		ae.Mul2(&a, &t.e)
+		if j, ok := pubs[t.pub]; ok {
+			scalars[j].Add(&ae)
+			continue
+		}
+		pubs[t.pub] = len(points)
+		points, scalars = append(points, t.p), append(scalars, ae)
+	}
+	var sG, rhs, sum btcec.JacobianPoint
+	btcec.ScalarBaseMultNonConst(&sumS, &sG)
+	multiScalarMult(scalars, points, &rhs)
+	btcec.AddNonConst(&sG, &rhs, &sum)
+	return (sum.X.IsZero() && sum.Y.IsZero()) || sum.Z.IsZero()
+}
+
+// windowBits returns the window size for a multi-scalar multiplication of n points that needs
+// the fewest point additions.
+func windowBits(n int) (c int) {
+	c, best := 1, -1
+	for w := 1; w <= 16; w++ {
+		cost := (256 + w - 1) / w * (n + 1<<w)
+		if best < 0 || cost < best {
+			c, best = w, cost
+		}
+	}
+	return
+}
+
+// digit returns the c bits of a big endian 256 bit scalar starting at a bit.
+func digit(b *[32]byte, bit, c int) (d int) {
+	for i := 0; i < c && bit+i < 256; i++ {
+		pos := bit + i
+		if b[31-pos/8]>>(pos%8)&1 == 1 {
+			d |= 1 << i
+		}
+	}
+	return
+}
+
+// multiScalarMult computes the sum of the points multiplied by their scalars with Pippenger's
+// bucket method, which takes far fewer point additions than multiplying each point.
+//
+// The points must be normalized, and are fastest to add if they are in affine coordinates.
+func multiScalarMult(scalars []btcec.ModNScalar, points []btcec.JacobianPoint,
+	result *btcec.JacobianPoint) {
+	c := windowBits(len(points))
+	bs := make([][32]byte, len(scalars))
+	for i := range scalars {
+		bs[i] = scalars[i].Bytes()
+	}
+	buckets := make([]btcec.JacobianPoint, 1<<c)
+	var acc, tmp btcec.JacobianPoint
+	for w := 255 / c * c; w >= 0; w -= c {
+		for i := 0; i < c; i++ {
+			btcec.DoubleNonConst(&acc, &tmp)
+			acc.Set(&tmp)
+		}
+		for i := range buckets {
+			buckets[i] = btcec.JacobianPoint{}
+		}
+		for i := range points {
+			if d := digit(&bs[i], w, c); d != 0 {
+				btcec.AddNonConst(&buckets[d], &points[i], &tmp)
+				buckets[d].Set(&tmp)
+			}
+		}
+		// the sum of d*buckets[d] is the sum of the running sums of the buckets from the top.
+		var running, sum btcec.JacobianPoint
+		for d := len(buckets) - 1; d > 0; d-- {
+			btcec.AddNonConst(&running, &buckets[d], &tmp)
+			running.Set(&tmp)
+			btcec.AddNonConst(&sum, &running, &tmp)
+			sum.Set(&tmp)
+		}
+		btcec.AddNonConst(&acc, &sum, &tmp)
+		acc.Set(&tmp)
+	}
+	result.Set(&acc)
+}
@@ -0,0 +1,73 @@
+package schnorr
+
+import (
+	"crypto/rand"
+	"reflect"
+	"testing"
+
+	"realy.lol/ec/secp256k1"
+	"realy.lol/sha256"
+)
+
+func TestBatchVerifyVectors(t *testing.T) {
+	var items []BatchItem
+	var expected []int
+	for i, test := range bip340TestVectors {
+		items = append(items, BatchItem{Hash: decodeHex(test.message),
+			Sig: decodeHex(test.signature), PubKey: decodeHex(test.publicKey)})
+		if !test.verifyResult {
+			expected = append(expected, i)
+		}
+	}
+	if invalid := BatchVerify(items); !reflect.DeepEqual(invalid, expected) {
+		t.Fatalf("invalid signatures %v, expected %v", invalid, expected)
+	}
+}
+
+// newBatch signs n random messages with a few keys.
+func newBatch(t testing.TB, n int) (items []BatchItem) {
+	keys := make([]*secp256k1.SecretKey, 3)
+	for i := range keys {
+		var err error
+		if keys[i], err = secp256k1.GenerateSecretKey(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := range n {
+		msg := make([]byte, 32)
+		if _, err := rand.Read(msg); err != nil {
+			t.Fatal(err)
+		}
+		hash := sha256.Sum256(msg)
+		k := keys[i%len(keys)]
+		sig, err := Sign(k, hash[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		items = append(items, BatchItem{Hash: hash[:], Sig: sig.Serialize(),
+			PubKey: SerializePubKey(k.PubKey())})
+	}
+	return
+}
+
+func TestBatchVerify(t *testing.T) {
+	items := newBatch(t, 100)
+	if invalid := BatchVerify(items); invalid != nil {
+		t.Fatalf("valid batch has invalid signatures %v", invalid)
+	}
+	// a changed s, a signature of another message, and a swapped pubkey.
+	items[3].Sig = append([]byte{}, items[3].Sig...)
+	items[3].Sig[63] ^= 1
+	items[40].Hash = items[41].Hash
+	items[77].PubKey = items[78].PubKey
+	// a signature whose r is not on the curve.
+	items[90].Sig = append(make([]byte, 32), items[90].Sig[32:]...)
+	items[90].Sig[31] = 5
+	expected := []int{3, 40, 77, 90}
+	if invalid := BatchVerify(items); !reflect.DeepEqual(invalid, expected) {
+		t.Fatalf("invalid signatures %v, expected %v", invalid, expected)
+	}
+	if BatchVerify(nil) != nil {
+		t.Fatal("empty batch is invalid")
+	}
+}
@@ -169,3 +169,16 @@ func BenchmarkSigSerialize(b *testing.B) {
 		sig.Serialize()
 	}
 }
+
+// BenchmarkBatchVerify benchmarks how long it takes to verify a batch of
+// signatures, per signature.
+func BenchmarkBatchVerify(b *testing.B) {
+	items := newBatch(b, 256)
+	var invalid []int
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i += len(items) {
+		invalid = BatchVerify(items)
+	}
+	testOk = invalid == nil
+}
@@ -71,13 +71,12 @@ func DecryptNip4(content, key []byte) (msg []byte, err error) {
 		return nil, errorf.E(
 			"error parsing encrypted message: no initialization vector")
 	}
-	ciphertext := make([]byte, base64.StdEncoding.EncodedLen(len(parts[0])))
-	iv := make([]byte, base64.StdEncoding.EncodedLen(len(parts[1])))
-	if _, err = base64.StdEncoding.Decode(ciphertext, parts[0]); chk.E(err) {
+	var ciphertext, iv []byte
+	if ciphertext, err = base64.StdEncoding.DecodeString(string(parts[0])); chk.E(err) {
 		err = errorf.E("error decoding ciphertext from base64: %w", err)
 		return
 	}
-	if _, err = base64.StdEncoding.Decode(iv, parts[1]); chk.E(err) {
+	if iv, err = base64.StdEncoding.DecodeString(string(parts[1])); chk.E(err) {
 		err = errorf.E("error decoding iv from base64: %w", err)
 		return
 	}
@@ -86,6 +85,10 @@ func DecryptNip4(content, key []byte) (msg []byte, err error) {
 		err = errorf.E("error creating block cipher: %w", err)
 		return
 	}
+	if len(iv) != block.BlockSize() || len(ciphertext)%block.BlockSize() != 0 {
+		err = errorf.E("invalid ciphertext or initialization vector length")
+		return
+	}
 	mode := cipher.NewCBCDecrypter(block, iv)
 	msg = make([]byte, len(ciphertext))
 	mode.CryptBlocks(msg, ciphertext)
@@ -96,7 +99,7 @@ func DecryptNip4(content, key []byte) (msg []byte, err error) {
 	if plaintextLen > 0 {
 		// the padding amount is encoded in the padding bytes themselves
 		padding := int(msg[plaintextLen-1])
-		if padding > plaintextLen {
+		if padding == 0 || padding > plaintextLen {
 			err = errorf.E("invalid padding amount: %d", padding)
 			return
 		}
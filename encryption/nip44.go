@@ -157,10 +157,16 @@ func GenerateConversationKey(pk, sk []byte) (ck []byte, err error) {
 	if shared, err = ComputeSharedSecret(pk, sk); chk.E(err) {
 		return
 	}
-	ck = hkdf.Extract(sha256.New, shared, []byte("nip44-v2"))
+	ck = ConversationKeyFromSharedSecret(shared)
 	return
 }
 
+// ConversationKeyFromSharedSecret hashes an ECDH shared secret into a nip-44-v2 conversation
+// key, for signers that compute the shared secret without revealing their secret key.
+func ConversationKeyFromSharedSecret(shared []byte) (ck []byte) {
+	return hkdf.Extract(sha256.New, shared, []byte("nip44-v2"))
+}
+
 func encrypt(key, nonce, message []byte) (dst []byte, err error) {
 	var cipher *chacha20.Cipher
 	if cipher, err = chacha20.NewUnauthenticatedCipher(key, nonce); chk.E(err) {
@@ -86,6 +86,33 @@ func TestT_SignWithSecKey(t *testing.T) {
 	}
 }
 
+func TestVerifyBatch(t *testing.T) {
+	var evs []*T
+	for range 3 {
+		signer := new(p256k.Signer)
+		if err := signer.Generate(); chk.E(err) {
+			t.Fatal(err)
+		}
+		for range 30 {
+			ev, err := GenerateRandomTextNoteEvent(signer, 100)
+			if chk.E(err) {
+				t.Fatal(err)
+			}
+			evs = append(evs, ev)
+		}
+	}
+	if invalid := VerifyBatch(evs); invalid != nil {
+		t.Fatalf("valid events have invalid signatures %v", invalid)
+	}
+	// an event whose content was changed, and one whose signature was.
+	evs[10].Content = append(evs[10].Content, '!')
+	evs[20].Sig[40] ^= 1
+	if invalid := VerifyBatch(evs); len(invalid) != 2 || invalid[0] != 10 ||
+		invalid[1] != 20 {
+		t.Fatalf("invalid events %v, expected [10 20]", invalid)
+	}
+}
+
 func BenchmarkMarshal(bb *testing.B) {
 	bb.StopTimer()
 	var i int
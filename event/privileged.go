@@ -0,0 +1,57 @@
+package event
+
+import (
+	"bytes"
+
+	"realy.lol/hex"
+	"realy.lol/tag"
+)
+
+// Recipients returns the decoded pubkeys found in the `p` tags of an event.
+func (ev *T) Recipients() (pks [][]byte) {
+	if ev.Tags == nil {
+		return
+	}
+	for _, t := range ev.Tags.ToSliceOfTags() {
+		if t.Len() < 2 || !bytes.Equal(t.Key(), []byte("p")) {
+			continue
+		}
+		var pk []byte
+		var err error
+		if pk, err = hex.Dec(string(t.Value())); err != nil {
+			continue
+		}
+		pks = append(pks, pk)
+	}
+	return
+}
+
+// IsRecipient returns true if the given pubkey is found in one of the `p` tags of the event.
+func (ev *T) IsRecipient(pubkey []byte) (is bool) {
+	if len(pubkey) == 0 || ev.Tags == nil {
+		return
+	}
+	return ev.Tags.ContainsAny([]byte("p"), tag.New(hex.Enc(pubkey)))
+}
+
+// ReadableBy returns true if the event may be served to a client authenticated to the given
+// pubkey.
+//
+// Events that are not of a privileged kind are always readable. Privileged events such as
+// NIP-04 DMs and application specific data are readable by their author and by the pubkeys
+// in their `p` tags.
+//
+// NIP-59 gift wraps are signed by a random, single use key, so authorship grants nothing, and
+// they are only readable by the recipient named in the `p` tag.
+func (ev *T) ReadableBy(pubkey []byte) (ok bool) {
+	if !ev.Kind.IsPrivileged() {
+		return true
+	}
+	if len(pubkey) == 0 {
+		return false
+	}
+	if ev.Kind.IsGiftWrap() {
+		return ev.IsRecipient(pubkey)
+	}
+	return bytes.Equal(ev.Pubkey, pubkey) || ev.IsRecipient(pubkey)
+}
@@ -0,0 +1,40 @@
+package event
+
+import (
+	"testing"
+
+	"lukechampine.com/frand"
+
+	"realy.lol/hex"
+	"realy.lol/kind"
+	"realy.lol/tag"
+	"realy.lol/tags"
+)
+
+func TestT_ReadableBy(t *testing.T) {
+	author, recipient, other := frand.Bytes(32), frand.Bytes(32), frand.Bytes(32)
+	mk := func(k *kind.T) *T {
+		return &T{Pubkey: author, Kind: k,
+			Tags: tags.New(tag.New([]byte("p"), []byte(hex.Enc(recipient))))}
+	}
+	cases := []struct {
+		name   string
+		ev     *T
+		reader []byte
+		ok     bool
+	}{
+		{"text note unauthed", mk(kind.TextNote), nil, true},
+		{"dm unauthed", mk(kind.EncryptedDirectMessage), nil, false},
+		{"dm author", mk(kind.EncryptedDirectMessage), author, true},
+		{"dm recipient", mk(kind.EncryptedDirectMessage), recipient, true},
+		{"dm other", mk(kind.EncryptedDirectMessage), other, false},
+		{"gift wrap author", mk(kind.GiftWrap), author, false},
+		{"gift wrap recipient", mk(kind.GiftWrap), recipient, true},
+		{"gift wrap other", mk(kind.GiftWrap), other, false},
+	}
+	for _, c := range cases {
+		if ok := c.ev.ReadableBy(c.reader); ok != c.ok {
+			t.Errorf("%s: got %v expected %v", c.name, ok, c.ok)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package event
 
 import (
 	"bytes"
+	"slices"
 
 	"realy.lol/chk"
 	sch "realy.lol/ec/schnorr"
@@ -12,12 +13,24 @@ import (
 	"realy.lol/signer"
 )
 
+// EventSigner is a signer.I that signs whole events rather than their Id, such as a
+// NIP-46 remote signer, which is not given the secret key and only signs events.
+type EventSigner interface {
+	signer.I
+	// SignEvent populates the Pubkey, Id and Sig of an event.
+	SignEvent(ev *T) (err error)
+}
+
 // Sign the event using the signer.I. Uses github.com/bitcoin-core/secp256k1 if
-// available for much faster signatures.
+// available for much faster signatures. If the signer.I is an EventSigner the
+// event is given to it to sign.
 //
 // Note that this only populates the Pubkey, Id and Sig. The caller must
 // set the CreatedAt timestamp as intended.
 func (ev *T) Sign(keys signer.I) (err error) {
+	if es, ok := keys.(EventSigner); ok {
+		return es.SignEvent(ev)
+	}
 	ev.Pubkey = keys.Pub()
 	ev.Id = ev.GetIDBytes()
 	if ev.Sig, err = keys.Sign(ev.Id); chk.E(err) {
@@ -50,6 +63,32 @@ func (ev *T) Verify() (valid bool, err error) {
 	return
 }
 
+// VerifyBatch checks the Id and signature of many events at once, which is much faster than
+// calling Verify on each of them, returning the indexes of the invalid events in ascending
+// order, or nil if they are all valid. Unlike Verify, an event with an incorrect Id is invalid
+// even if it is signed.
+func VerifyBatch(evs []*T) (invalid []int) {
+	items := make([]p256k.BatchItem, 0, len(evs))
+	// index is the index in evs of each of the items.
+	index := make([]int, 0, len(evs))
+	for i, ev := range evs {
+		if !bytes.Equal(ev.GetIDBytes(), ev.Id) {
+			invalid = append(invalid, i)
+			continue
+		}
+		items = append(items, p256k.BatchItem{Hash: ev.Id, Sig: ev.Sig, PubKey: ev.Pubkey})
+		index = append(index, i)
+	}
+	for _, i := range p256k.BatchVerify(items) {
+		invalid = append(invalid, index[i])
+	}
+	if len(invalid) == 0 {
+		return nil
+	}
+	slices.Sort(invalid)
+	return
+}
+
 // SignWithSecKey signs an event with a given *secp256xk1.SecretKey.
 //
 // Deprecated: use Sign method of event.T and signer.I instead.
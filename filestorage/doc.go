@@ -0,0 +1,8 @@
+// Package filestorage is a NIP-96 HTTP file storage server, with the server information
+// document at /.well-known/nostr/nip96.json and endpoints to upload, download, delete and list
+// files authorized with NIP-98 events.
+//
+// Files are kept by a blossom.S, so the same blobs, owners and per-pubkey quota are shared with
+// the Blossom server, and the metadata of each file is returned as a kind 1063 NIP-94 event
+// without a signature.
+package filestorage
@@ -0,0 +1,316 @@
+package filestorage
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"realy.lol/blossom"
+	"realy.lol/chk"
+	"realy.lol/httpauth"
+)
+
+// Path is the path of the NIP-96 API, to which files are uploaded and listed, and below which
+// they are downloaded and deleted.
+const Path = "/n96"
+
+// WellKnown is the path of the NIP-96 server information document.
+const WellKnown = "/.well-known/nostr/nip96.json"
+
+// DefaultCount is the number of files listed in a page if the request does not say.
+const DefaultCount = 10
+
+// MaxCount is the most files listed in a page.
+const MaxCount = 500
+
+// Plan is a storage plan in the server information document.
+type Plan struct {
+	Name            string  `json:"name"`
+	IsNIP98Required bool    `json:"is_nip98_required"`
+	MaxByteSize     int64   `json:"max_byte_size"`
+	FileExpiration  []int64 `json:"file_expiration"`
+}
+
+// Info is the NIP-96 server information document.
+type Info struct {
+	APIURL        string          `json:"api_url"`
+	DownloadURL   string          `json:"download_url"`
+	SupportedNIPs []int           `json:"supported_nips"`
+	Plans         map[string]Plan `json:"plans"`
+}
+
+// Event is the unsigned kind 1063 NIP-94 event that describes a stored file.
+type Event struct {
+	Tags      [][]string `json:"tags"`
+	Content   string     `json:"content"`
+	CreatedAt int64      `json:"created_at,omitempty"`
+}
+
+// Response is the reply to uploads and deletions, and to requests that fail.
+type Response struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	Event   *Event `json:"nip94_event,omitempty"`
+}
+
+// List is the reply to a request to list the files of a pubkey.
+type List struct {
+	Count int      `json:"count"`
+	Total int      `json:"total"`
+	Page  int      `json:"page"`
+	Files []*Event `json:"files"`
+}
+
+// S is a NIP-96 file storage server.
+type S struct {
+	// Store keeps the files, and is shared with the Blossom server if it is enabled.
+	Store *blossom.S
+}
+
+// Register adds the NIP-96 endpoints to a ServeMux.
+func (s *S) Register(mux *http.ServeMux) {
+	mux.HandleFunc(WellKnown, s.handleInfo)
+	mux.HandleFunc(Path, s.handleAPI)
+	mux.HandleFunc(Path+"/{name}", s.handleFile)
+}
+
+// reply writes a JSON response with a status code.
+func reply(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	chk.E(json.NewEncoder(w).Encode(v))
+}
+
+// fail writes an error response.
+func fail(w http.ResponseWriter, status int, message string) {
+	reply(w, status, Response{Status: "error", Message: message})
+}
+
+// baseURL returns the URL of the host of a request.
+func baseURL(r *http.Request) string {
+	proto := "http"
+	if r.TLS != nil {
+		proto = "https"
+	}
+	if p := r.Header.Get("X-Forwarded-Proto"); p != "" {
+		proto = p
+	}
+	return proto + "://" + r.Host
+}
+
+// auth returns the pubkey of the valid NIP-98 authorization of a request.
+func auth(r *http.Request) (pubkey []byte, err error) {
+	var valid bool
+	if valid, pubkey, err = httpauth.CheckAuth(r); err != nil {
+		return
+	}
+	if !valid || len(pubkey) == 0 {
+		err = errors.New("invalid authorization")
+	}
+	return
+}
+
+// NewEvent returns the NIP-94 metadata of a blob as served by the host of a request.
+func NewEvent(r *http.Request, b *blossom.Blob) (ev *Event) {
+	u := baseURL(r) + Path + "/" + b.Sha256
+	if exts, _ := mime.ExtensionsByType(b.Type); len(exts) > 0 {
+		u += exts[0]
+	}
+	return &Event{Tags: [][]string{
+		{"url", u},
+		{"ox", b.Sha256},
+		{"x", b.Sha256},
+		{"m", b.Type},
+		{"size", strconv.FormatInt(b.Size, 10)},
+	}}
+}
+
+// handleInfo serves the server information document.
+func (s *S) handleInfo(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		fail(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	var maxSize int64
+	if s.Store.MaxSize != nil {
+		maxSize = s.Store.MaxSize()
+	}
+	u := baseURL(r) + Path
+	reply(w, http.StatusOK, Info{
+		APIURL:        u,
+		DownloadURL:   u,
+		SupportedNIPs: []int{94, 96, 98},
+		Plans: map[string]Plan{"free": {Name: "Free", IsNIP98Required: true,
+			MaxByteSize: maxSize, FileExpiration: []int64{0, 0}}},
+	})
+}
+
+// handleAPI serves uploads with POST and listings with GET.
+func (s *S) handleAPI(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		s.upload(w, r)
+	case http.MethodGet:
+		s.list(w, r)
+	default:
+		fail(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleFile serves downloads with GET and HEAD and deletions with DELETE.
+func (s *S) handleFile(w http.ResponseWriter, r *http.Request) {
+	sha, ok := blossom.ParseName(r.PathValue("name"))
+	if !ok {
+		fail(w, http.StatusNotFound, blossom.ErrNotFound.Error())
+		return
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		s.download(w, r, sha)
+	case http.MethodDelete:
+		s.delete(w, r, sha)
+	default:
+		fail(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// upload stores the file in the file field of a multipart form.
+func (s *S) upload(w http.ResponseWriter, r *http.Request) {
+	pk, err := auth(r)
+	if err != nil {
+		fail(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if s.Store.MayUpload != nil && !s.Store.MayUpload(pk) {
+		fail(w, http.StatusForbidden, "pubkey may not upload to this server")
+		return
+	}
+	if s.Store.MaxSize != nil {
+		if maxSize := s.Store.MaxSize(); maxSize > 0 {
+			// allow for the other fields and the multipart framing.
+			r.Body = http.MaxBytesReader(w, r.Body, maxSize+1<<16)
+		}
+	}
+	if err = r.ParseMultipartForm(1 << 20); err != nil {
+		var mbe *http.MaxBytesError
+		if errors.As(err, &mbe) {
+			fail(w, http.StatusRequestEntityTooLarge, blossom.ErrTooLarge.Error())
+			return
+		}
+		fail(w, http.StatusBadRequest, "invalid multipart form: "+err.Error())
+		return
+	}
+	defer func() { chk.E(r.MultipartForm.RemoveAll()) }()
+	var f multipart.File
+	var fh *multipart.FileHeader
+	if f, fh, err = r.FormFile("file"); err != nil {
+		fail(w, http.StatusBadRequest, "missing file field")
+		return
+	}
+	defer f.Close()
+	declared := r.FormValue("content_type")
+	if declared == "" {
+		declared = fh.Header.Get("Content-Type")
+	}
+	var b *blossom.Blob
+	if b, err = s.Store.Put(pk, f, fh.Size, declared, nil); err != nil {
+		switch {
+		case errors.Is(err, blossom.ErrTooLarge), errors.Is(err, blossom.ErrQuotaExceeded):
+			fail(w, http.StatusRequestEntityTooLarge, err.Error())
+		default:
+			fail(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	ev := NewEvent(r, b)
+	ev.Content = r.FormValue("caption")
+	if alt := r.FormValue("alt"); alt != "" {
+		ev.Tags = append(ev.Tags, []string{"alt", alt})
+	}
+	reply(w, http.StatusOK, Response{Status: "success", Message: "Upload successful.",
+		Event: ev})
+}
+
+// download serves the content of a file.
+func (s *S) download(w http.ResponseWriter, r *http.Request, sha string) {
+	b, f, err := s.Store.Open(sha)
+	if err != nil {
+		if errors.Is(err, blossom.ErrNotFound) {
+			fail(w, http.StatusNotFound, err.Error())
+			return
+		}
+		fail(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer f.Close()
+	w.Header().Set("Content-Type", b.Type)
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	http.ServeContent(w, r, "", time.Unix(b.Uploaded, 0), f)
+}
+
+// delete removes the authorized pubkey from the owners of a file.
+func (s *S) delete(w http.ResponseWriter, r *http.Request, sha string) {
+	pk, err := auth(r)
+	if err != nil {
+		fail(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if err = s.Store.Remove(pk, sha); err != nil {
+		if errors.Is(err, blossom.ErrNotFound) {
+			fail(w, http.StatusNotFound, err.Error())
+			return
+		}
+		fail(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	reply(w, http.StatusOK, Response{Status: "success", Message: "File deleted."})
+}
+
+// list serves a page of the files of the authorized pubkey, newest first, as selected by the
+// page and count query parameters.
+func (s *S) list(w http.ResponseWriter, r *http.Request) {
+	pk, err := auth(r)
+	if err != nil {
+		fail(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	count, _ := strconv.Atoi(r.URL.Query().Get("count"))
+	if page < 0 {
+		page = 0
+	}
+	if count <= 0 {
+		count = DefaultCount
+	}
+	count = min(count, MaxCount)
+	var bb []*blossom.Blob
+	if bb, err = s.Store.Index.BlobsOf(pk); chk.E(err) {
+		fail(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	slices.SortFunc(bb, func(a, b *blossom.Blob) int {
+		if a.Uploaded != b.Uploaded {
+			return int(b.Uploaded - a.Uploaded)
+		}
+		return strings.Compare(a.Sha256, b.Sha256)
+	})
+	// the page is compared by division so a huge page cannot overflow the offset.
+	if page > len(bb)/count {
+		fail(w, http.StatusBadRequest, "page is past the end of the list")
+		return
+	}
+	l := List{Total: len(bb), Page: page, Files: []*Event{}}
+	for _, b := range bb[page*count : min((page+1)*count, len(bb))] {
+		ev := NewEvent(r, b)
+		ev.CreatedAt = b.Uploaded
+		l.Files = append(l.Files, ev)
+	}
+	l.Count = len(l.Files)
+	reply(w, http.StatusOK, l)
+}
@@ -0,0 +1,202 @@
+package filestorage
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"realy.lol/blossom"
+	"realy.lol/hex"
+	"realy.lol/httpauth"
+	"realy.lol/p256k"
+	"realy.lol/sha256"
+)
+
+type memIndex struct {
+	sync.Mutex
+	blobs map[string]blossom.Blob
+}
+
+func (m *memIndex) GetBlob(sha []byte) (b *blossom.Blob, err error) {
+	m.Lock()
+	defer m.Unlock()
+	if c, ok := m.blobs[hex.Enc(sha)]; ok {
+		b = &c
+	}
+	return
+}
+
+func (m *memIndex) PutBlob(b *blossom.Blob) (err error) {
+	m.Lock()
+	defer m.Unlock()
+	c := *b
+	c.Owners = append([]string{}, b.Owners...)
+	m.blobs[b.Sha256] = c
+	return
+}
+
+func (m *memIndex) DeleteBlob(sha []byte) (err error) {
+	m.Lock()
+	defer m.Unlock()
+	delete(m.blobs, hex.Enc(sha))
+	return
+}
+
+func (m *memIndex) BlobsOf(pubkey []byte) (bb []*blossom.Blob, err error) {
+	m.Lock()
+	defer m.Unlock()
+	for _, b := range m.blobs {
+		for _, o := range b.Owners {
+			if o == hex.Enc(pubkey) {
+				c := b
+				bb = append(bb, &c)
+			}
+		}
+	}
+	return
+}
+
+func do(t *testing.T, sign *p256k.Signer, method, u, contentType string,
+	body []byte) (res *http.Response, b []byte) {
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if sign != nil {
+		var ur *url.URL
+		if ur, err = url.Parse(u); err != nil {
+			t.Fatal(err)
+		}
+		if err = httpauth.AddNIP98Header(req, ur, method, "", sign, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if res, err = http.DefaultClient.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	b, _ = io.ReadAll(res.Body)
+	return
+}
+
+func form(t *testing.T, file []byte, caption string) (contentType string, body []byte) {
+	buf := new(bytes.Buffer)
+	mw := multipart.NewWriter(buf)
+	if err := mw.WriteField("caption", caption); err != nil {
+		t.Fatal(err)
+	}
+	fw, err := mw.CreateFormFile("file", "file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = fw.Write(file); err != nil {
+		t.Fatal(err)
+	}
+	if err = mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return mw.FormDataContentType(), buf.Bytes()
+}
+
+func tag(ev *Event, key string) string {
+	for _, t := range ev.Tags {
+		if len(t) > 1 && t[0] == key {
+			return t[1]
+		}
+	}
+	return ""
+}
+
+func TestServer(t *testing.T) {
+	alice, bob := &p256k.Signer{}, &p256k.Signer{}
+	for _, s := range []*p256k.Signer{alice, bob} {
+		if err := s.Generate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store := &blossom.S{Index: &memIndex{blobs: make(map[string]blossom.Blob)},
+		Dir: t.TempDir(), MaxSize: func() int64 { return 2000 },
+		Quota: func([]byte) int64 { return 1000 }}
+	mux := http.NewServeMux()
+	(&S{Store: store}).Register(mux)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	res, b := do(t, nil, http.MethodGet, srv.URL+WellKnown, "", nil)
+	var info Info
+	if err := json.Unmarshal(b, &info); err != nil || res.StatusCode != http.StatusOK ||
+		info.APIURL != srv.URL+Path || info.Plans["free"].MaxByteSize != 2000 {
+		t.Fatalf("unexpected server information %s %s", res.Status, b)
+	}
+	png := append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 100)...)
+	sum := sha256.Sum256(png)
+	sha := hex.Enc(sum[:])
+	ct, body := form(t, png, "a picture")
+	if res, _ = do(t, nil, http.MethodPost, info.APIURL, ct, body); res.StatusCode !=
+		http.StatusUnauthorized {
+		t.Fatalf("upload without authorization: %s", res.Status)
+	}
+	if res, b = do(t, alice, http.MethodPost, info.APIURL, ct, body); res.StatusCode !=
+		http.StatusOK {
+		t.Fatalf("upload: %s %s", res.Status, b)
+	}
+	var r Response
+	if err := json.Unmarshal(b, &r); err != nil || r.Status != "success" || r.Event == nil {
+		t.Fatalf("unexpected upload response %s", b)
+	}
+	if tag(r.Event, "x") != sha || tag(r.Event, "ox") != sha ||
+		tag(r.Event, "m") != "image/png" || tag(r.Event, "size") != "108" ||
+		tag(r.Event, "url") != info.DownloadURL+"/"+sha+".png" || r.Event.Content != "a picture" {
+		t.Fatalf("unexpected nip94 event %s", b)
+	}
+	if res, b = do(t, nil, http.MethodGet, tag(r.Event, "url"), "", nil); res.StatusCode !=
+		http.StatusOK || !bytes.Equal(b, png) {
+		t.Fatalf("download: %s %d bytes", res.Status, len(b))
+	}
+	// the quota is shared with the blossom server, and bob has no room for the picture on
+	// top of this blob.
+	if _, err := store.Put(bob.Pub(), bytes.NewReader(bytes.Repeat([]byte{'a'}, 950)), 950,
+		"", nil); err != nil {
+		t.Fatal(err)
+	}
+	if res, _ = do(t, bob, http.MethodPost, info.APIURL, ct, body); res.StatusCode !=
+		http.StatusRequestEntityTooLarge {
+		t.Fatalf("upload over quota: %s", res.Status)
+	}
+	ct, body = form(t, bytes.Repeat([]byte{'b'}, 2100), "")
+	if res, _ = do(t, alice, http.MethodPost, info.APIURL, ct, body); res.StatusCode !=
+		http.StatusRequestEntityTooLarge {
+		t.Fatalf("upload over the maximum size: %s", res.Status)
+	}
+	var l List
+	res, b = do(t, bob, http.MethodGet, info.APIURL+"?page=0&count=5", "", nil)
+	if err := json.Unmarshal(b, &l); err != nil || res.StatusCode != http.StatusOK ||
+		l.Total != 1 || l.Count != 1 || tag(l.Files[0], "m") != "text/plain; charset=utf-8" ||
+		l.Files[0].CreatedAt == 0 {
+		t.Fatalf("unexpected list %s %s", res.Status, b)
+	}
+	if res, _ = do(t, bob, http.MethodGet, info.APIURL+"?page=9223372036854775807&count=5", "",
+		nil); res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("list of a page past the end: %s", res.Status)
+	}
+	if res, _ = do(t, bob, http.MethodDelete, info.APIURL+"/"+sha, "", nil); res.StatusCode !=
+		http.StatusNotFound {
+		t.Fatalf("delete by a non owner: %s", res.Status)
+	}
+	if res, b = do(t, alice, http.MethodDelete, info.APIURL+"/"+sha+".png", "",
+		nil); res.StatusCode != http.StatusOK {
+		t.Fatalf("delete: %s %s", res.Status, b)
+	}
+	if res, _ = do(t, nil, http.MethodGet, tag(r.Event, "url"), "", nil); res.StatusCode !=
+		http.StatusNotFound {
+		t.Fatalf("download deleted file: %s", res.Status)
+	}
+}
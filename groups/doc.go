@@ -0,0 +1,7 @@
+// Package groups implements the state machine of NIP-29 relay based groups.
+//
+// A group is identified by the `h` tag of the events posted to it. Group admins change the
+// group state with moderation events (kinds 9000-9020), users ask to join or leave with kinds
+// 9021 and 9022, and the relay publishes the resulting state as the addressable events 39000
+// to 39003, signed with its own key.
+package groups
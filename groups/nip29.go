@@ -0,0 +1,321 @@
+package groups
+
+import (
+	"bytes"
+	"sort"
+
+	"realy.lol/chk"
+	"realy.lol/errorf"
+	"realy.lol/event"
+	"realy.lol/hex"
+	"realy.lol/kind"
+	"realy.lol/reason"
+	"realy.lol/signer"
+	"realy.lol/tag"
+	"realy.lol/tags"
+	"realy.lol/timestamp"
+)
+
+// RoleAdmin is the role that allows a member to publish all moderation events for a group.
+const RoleAdmin = "admin"
+
+// Role is a name and description of a role a group member can have.
+type Role struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Roles are the roles supported by the relay, advertised in the kind 39003 event.
+var Roles = []Role{
+	{Name: RoleAdmin, Description: "may publish all moderation events of the group"},
+}
+
+// Group is the state of a NIP-29 group, as derived from the moderation events applied to it.
+type Group struct {
+	Id      string `json:"id"`
+	Name    string `json:"name,omitempty"`
+	About   string `json:"about,omitempty"`
+	Picture string `json:"picture,omitempty"`
+	// Private groups can only be read by their members.
+	Private bool `json:"private"`
+	// Closed groups ignore join requests that do not carry a valid invite code.
+	Closed bool `json:"closed"`
+	// Members maps the hex encoded pubkeys of the group members to their roles.
+	Members map[string][]string `json:"members"`
+	// Invites is the set of invite codes that admit a user to a closed group.
+	Invites map[string]struct{} `json:"invites,omitempty"`
+	// LastModified is the created_at of the last event that changed the group.
+	LastModified int64 `json:"last_modified"`
+}
+
+// New creates a new, public and open group with the creator as its admin.
+func New(id string, creator []byte) (g *Group) {
+	return &Group{
+		Id:      id,
+		Members: map[string][]string{hex.Enc(creator): {RoleAdmin}},
+		Invites: make(map[string]struct{}),
+	}
+}
+
+// Id returns the group identifier found in the `h` tag of an event, or an empty string if the
+// event is not a group event.
+func Id(ev *event.T) (id string) {
+	if tt := withKey(ev, "h"); len(tt) > 0 {
+		id = string(tt[0].Value())
+	}
+	return
+}
+
+// InGroup returns the events that were posted to the group with the identifier, which are the
+// only events a delete-event moderation event of the group may remove.
+func InGroup(id string, evs event.Ts) (in event.Ts) {
+	for _, ev := range evs {
+		if id != "" && Id(ev) == id {
+			in = append(in, ev)
+		}
+	}
+	return
+}
+
+// withKey returns the tags of an event with exactly the given key and a value.
+func withKey(ev *event.T, key string) (tt []*tag.T) {
+	if ev.Tags == nil {
+		return
+	}
+	for _, t := range ev.Tags.ToSliceOfTags() {
+		if t.Len() >= 2 && string(t.Key()) == key {
+			tt = append(tt, t)
+		}
+	}
+	return
+}
+
+// IsMember returns true if the pubkey is a member of the group.
+func (g *Group) IsMember(pk []byte) (is bool) {
+	_, is = g.Members[hex.Enc(pk)]
+	return
+}
+
+// IsAdmin returns true if the pubkey is a member of the group with the admin role.
+func (g *Group) IsAdmin(pk []byte) (is bool) {
+	for _, r := range g.Members[hex.Enc(pk)] {
+		if r == RoleAdmin {
+			return true
+		}
+	}
+	return
+}
+
+// Check returns an error if the event may not be applied to, or posted in, the group. The
+// group is nil if it does not exist. The superuser may moderate every group.
+func Check(g *Group, ev *event.T, superuser []byte) (err error) {
+	id := Id(ev)
+	if id == "" {
+		err = errorf.D(string(reason.Invalid.F("group event without h tag")))
+		return
+	}
+	if ev.Kind.Equal(kind.GroupCreateGroup) {
+		if g != nil {
+			err = errorf.D(string(reason.Duplicate.F("group %s already exists", id)))
+		}
+		return
+	}
+	if g == nil {
+		err = errorf.D(string(reason.Invalid.F("group %s does not exist", id)))
+		return
+	}
+	switch {
+	case ev.Kind.IsGroupModeration():
+		if !g.IsAdmin(ev.Pubkey) && !bytes.Equal(ev.Pubkey, superuser) {
+			err = errorf.D(string(reason.Restricted.F("%0x is not an admin of group %s",
+				ev.Pubkey, id)))
+		}
+	case ev.Kind.Equal(kind.GroupJoinRequest):
+		if g.IsMember(ev.Pubkey) {
+			err = errorf.D(string(reason.Duplicate.F("%0x is already a member of group %s",
+				ev.Pubkey, id)))
+		}
+	default:
+		// leave requests and everything else posted to the group
+		if !g.IsMember(ev.Pubkey) {
+			err = errorf.D(string(reason.Restricted.F("%0x is not a member of group %s",
+				ev.Pubkey, id)))
+		}
+	}
+	return
+}
+
+// Apply changes the state of the group according to an event that has passed Check.
+//
+// The returned group is nil if the group was deleted, and the ids are the events that a
+// delete-event moderation event requests to be removed from the group, which must be checked
+// with InGroup to be events of the group before they are removed.
+func Apply(g *Group, ev *event.T) (ng *Group, ids [][]byte, err error) {
+	ng = g
+	switch {
+	case ev.Kind.Equal(kind.GroupCreateGroup):
+		ng = New(Id(ev), ev.Pubkey)
+	case ev.Kind.Equal(kind.GroupDeleteGroup):
+		ng = nil
+		return
+	case ev.Kind.Equal(kind.GroupPutUser):
+		for _, t := range withKey(ev, "p") {
+			var pk []byte
+			if pk, err = pubkey(t); chk.E(err) {
+				return
+			}
+			var roles []string
+			for _, r := range t.ToStringSlice()[2:] {
+				if r != "" {
+					roles = append(roles, r)
+				}
+			}
+			ng.Members[hex.Enc(pk)] = roles
+		}
+	case ev.Kind.Equal(kind.GroupRemoveUser):
+		for _, t := range withKey(ev, "p") {
+			var pk []byte
+			if pk, err = pubkey(t); chk.E(err) {
+				return
+			}
+			delete(ng.Members, hex.Enc(pk))
+		}
+	case ev.Kind.Equal(kind.GroupEditMetadata):
+		for _, t := range ev.Tags.ToSliceOfTags() {
+			switch string(t.Key()) {
+			case "name":
+				ng.Name = string(t.Value())
+			case "about":
+				ng.About = string(t.Value())
+			case "picture":
+				ng.Picture = string(t.Value())
+			case "public":
+				ng.Private = false
+			case "private":
+				ng.Private = true
+			case "open":
+				ng.Closed = false
+			case "closed":
+				ng.Closed = true
+			}
+		}
+	case ev.Kind.Equal(kind.GroupDeleteEvent):
+		for _, t := range withKey(ev, "e") {
+			var id []byte
+			if id, err = hex.Dec(string(t.Value())); chk.E(err) {
+				return
+			}
+			ids = append(ids, id)
+		}
+	case ev.Kind.Equal(kind.GroupCreateInvite):
+		for _, t := range withKey(ev, "code") {
+			if ng.Invites == nil {
+				ng.Invites = make(map[string]struct{})
+			}
+			ng.Invites[string(t.Value())] = struct{}{}
+		}
+	case ev.Kind.Equal(kind.GroupJoinRequest):
+		admit := !ng.Closed
+		for _, t := range withKey(ev, "code") {
+			if _, ok := ng.Invites[string(t.Value())]; ok {
+				admit = true
+			}
+		}
+		if admit {
+			ng.Members[hex.Enc(ev.Pubkey)] = nil
+		}
+	case ev.Kind.Equal(kind.GroupLeaveRequest):
+		delete(ng.Members, hex.Enc(ev.Pubkey))
+	default:
+		// ordinary group events do not change the group state
+		return
+	}
+	if ng.LastModified < ev.CreatedAt.I64() {
+		ng.LastModified = ev.CreatedAt.I64()
+	}
+	return
+}
+
+// pubkey decodes the hex pubkey in the value field of a `p` tag.
+func pubkey(t *tag.T) (pk []byte, err error) {
+	if pk, err = hex.Dec(string(t.Value())); chk.E(err) {
+		return
+	}
+	return
+}
+
+// members returns the sorted hex pubkeys of the members of the group, optionally only those
+// with the admin role.
+func (g *Group) members(admins bool) (pks []string) {
+	for pk, roles := range g.Members {
+		if admins {
+			var isAdmin bool
+			for _, r := range roles {
+				if r == RoleAdmin {
+					isAdmin = true
+				}
+			}
+			if !isAdmin {
+				continue
+			}
+		}
+		pks = append(pks, pk)
+	}
+	sort.Strings(pks)
+	return
+}
+
+// Events generates the relay signed group metadata (kind 39000), admins (kind 39001), members
+// (kind 39002) and roles (kind 39003) events for the group.
+func (g *Group) Events(sign signer.I) (evs event.Ts, err error) {
+	d := tag.New("d", g.Id)
+	meta := tags.New(d)
+	if g.Name != "" {
+		meta.AppendTags(tag.New("name", g.Name))
+	}
+	if g.Picture != "" {
+		meta.AppendTags(tag.New("picture", g.Picture))
+	}
+	if g.About != "" {
+		meta.AppendTags(tag.New("about", g.About))
+	}
+	if g.Private {
+		meta.AppendTags(tag.New("private"))
+	} else {
+		meta.AppendTags(tag.New("public"))
+	}
+	if g.Closed {
+		meta.AppendTags(tag.New("closed"))
+	} else {
+		meta.AppendTags(tag.New("open"))
+	}
+	admins := tags.New(d)
+	for _, pk := range g.members(true) {
+		admins.AppendTags(tag.New(append([]string{"p", pk}, g.Members[pk]...)...))
+	}
+	members := tags.New(d)
+	for _, pk := range g.members(false) {
+		members.AppendTags(tag.New("p", pk))
+	}
+	roles := tags.New(d)
+	for _, r := range Roles {
+		roles.AppendTags(tag.New("role", r.Name, r.Description))
+	}
+	now := timestamp.Now()
+	for _, e := range []struct {
+		k *kind.T
+		t *tags.T
+	}{
+		{kind.GroupMetadata, meta},
+		{kind.GroupAdmins, admins},
+		{kind.GroupMembers, members},
+		{kind.GroupRoles, roles},
+	} {
+		ev := &event.T{CreatedAt: now, Kind: e.k, Tags: e.t, Content: []byte{}}
+		if err = ev.Sign(sign); chk.E(err) {
+			return
+		}
+		evs = append(evs, ev)
+	}
+	return
+}
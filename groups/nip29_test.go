@@ -0,0 +1,137 @@
+package groups
+
+import (
+	"bytes"
+	"testing"
+
+	"realy.lol/event"
+	"realy.lol/hex"
+	"realy.lol/kind"
+	"realy.lol/p256k"
+	"realy.lol/tag"
+	"realy.lol/tags"
+	"realy.lol/timestamp"
+)
+
+func newSigner(t *testing.T) (s *p256k.Signer) {
+	s = &p256k.Signer{}
+	if err := s.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func apply(t *testing.T, g *Group, ev *event.T, superuser []byte, allowed bool) (ng *Group) {
+	err := Check(g, ev, superuser)
+	if allowed && err != nil {
+		t.Fatalf("kind %d from %0x rejected: %v", ev.Kind.K, ev.Pubkey, err)
+	}
+	if !allowed {
+		if err == nil {
+			t.Fatalf("kind %d from %0x was not rejected", ev.Kind.K, ev.Pubkey)
+		}
+		return g
+	}
+	if ng, _, err = Apply(g, ev); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func TestGroupLifecycle(t *testing.T) {
+	relay, admin, user, stranger := newSigner(t), newSigner(t), newSigner(t), newSigner(t)
+	mk := func(k *kind.T, author *p256k.Signer, tt ...*tag.T) *event.T {
+		return &event.T{Pubkey: author.Pub(), Kind: k, CreatedAt: timestamp.Now(),
+			Tags: tags.New(append([]*tag.T{tag.New("h", "test")}, tt...)...)}
+	}
+	var g *Group
+	g = apply(t, g, mk(kind.TextNote, user), relay.Pub(), false)
+	g = apply(t, g, mk(kind.GroupCreateGroup, admin), relay.Pub(), true)
+	if !g.IsAdmin(admin.Pub()) {
+		t.Fatal("creator is not admin")
+	}
+	g = apply(t, g, mk(kind.GroupCreateGroup, stranger), relay.Pub(), false)
+	g = apply(t, g, mk(kind.GroupEditMetadata, admin, tag.New("name", "test group"),
+		tag.New("private"), tag.New("closed")), relay.Pub(), true)
+	if g.Name != "test group" || !g.Private || !g.Closed {
+		t.Fatalf("metadata not applied: %+v", g)
+	}
+	// closed group ignores join requests without an invite
+	g = apply(t, g, mk(kind.GroupJoinRequest, user), relay.Pub(), true)
+	if g.IsMember(user.Pub()) {
+		t.Fatal("user joined closed group without invite")
+	}
+	g = apply(t, g, mk(kind.GroupCreateInvite, stranger, tag.New("code", "abc")),
+		relay.Pub(), false)
+	g = apply(t, g, mk(kind.GroupCreateInvite, admin, tag.New("code", "abc")),
+		relay.Pub(), true)
+	g = apply(t, g, mk(kind.GroupJoinRequest, user, tag.New("code", "abc")), relay.Pub(), true)
+	if !g.IsMember(user.Pub()) {
+		t.Fatal("user with invite was not admitted")
+	}
+	g = apply(t, g, mk(kind.TextNote, user), relay.Pub(), true)
+	g = apply(t, g, mk(kind.TextNote, stranger), relay.Pub(), false)
+	// the relay superuser may moderate any group
+	g = apply(t, g, mk(kind.GroupRemoveUser, relay, tag.New("p", hex.Enc(user.Pub()))),
+		relay.Pub(), true)
+	if g.IsMember(user.Pub()) {
+		t.Fatal("user was not removed")
+	}
+	g = apply(t, g, mk(kind.GroupPutUser, admin, tag.New("p", hex.Enc(user.Pub()), "admin")),
+		relay.Pub(), true)
+	if !g.IsAdmin(user.Pub()) {
+		t.Fatal("user was not made admin")
+	}
+	evs, err := g.Events(relay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evs) != 4 {
+		t.Fatalf("expected 4 group state events, got %d", len(evs))
+	}
+	for _, ev := range evs {
+		var valid bool
+		if valid, err = ev.Verify(); err != nil || !valid {
+			t.Fatalf("invalid relay signature on kind %d: %v", ev.Kind.K, err)
+		}
+	}
+	if n := evs[1].Tags.GetAll(tag.New("p")).Len(); n != 2 {
+		t.Fatalf("expected 2 admins, got %d", n)
+	}
+	if g = apply(t, g, mk(kind.GroupDeleteGroup, admin), relay.Pub(), true); g != nil {
+		t.Fatal("group was not deleted")
+	}
+}
+
+func TestDeleteOutsideGroup(t *testing.T) {
+	relay, admin, user := newSigner(t), newSigner(t), newSigner(t)
+	mk := func(group string, k *kind.T, author *p256k.Signer, tt ...*tag.T) (ev *event.T) {
+		ev = &event.T{Pubkey: author.Pub(), Kind: k, CreatedAt: timestamp.Now(),
+			Tags: tags.New(tt...)}
+		if group != "" {
+			ev.Tags.AppendTags(tag.New("h", group))
+		}
+		ev.Id = ev.GetIDBytes()
+		return
+	}
+	g := apply(t, nil, mk("a", kind.GroupCreateGroup, admin), relay.Pub(), true)
+	inA := mk("a", kind.TextNote, admin)
+	inB := mk("b", kind.TextNote, user)
+	outside := mk("", kind.TextNote, user)
+	del := mk("a", kind.GroupDeleteEvent, admin, tag.New("e", hex.Enc(inA.Id)),
+		tag.New("e", hex.Enc(inB.Id)), tag.New("e", hex.Enc(outside.Id)))
+	if err := Check(g, del, relay.Pub()); err != nil {
+		t.Fatal(err)
+	}
+	_, ids, err := Apply(g, del)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 3 {
+		t.Fatalf("expected 3 ids to delete, got %d", len(ids))
+	}
+	in := InGroup(Id(del), event.Ts{inA, inB, outside})
+	if len(in) != 1 || !bytes.Equal(in[0].Id, inA.Id) {
+		t.Fatalf("deletion in group a may only remove %0x, got %d events", inA.Id, len(in))
+	}
+}
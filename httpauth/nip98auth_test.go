@@ -1,7 +1,13 @@
 package httpauth
 
 import (
+	"crypto/sha256"
+	"net/http"
+	"net/url"
 	"testing"
+
+	"realy.lol/hex"
+	"realy.lol/p256k"
 )
 
 func TestMakeNIP98Request_ValidateNIP98Request(t *testing.T) {
@@ -32,3 +38,34 @@ func TestMakeNIP98Request_ValidateNIP98Request(t *testing.T) {
 	// 		pk, sign.Pub())
 	// }
 }
+
+func TestCheckPayload(t *testing.T) {
+	sign := &p256k.Signer{}
+	if err := sign.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	ur, err := url.Parse("https://example.com/management")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := []byte(`{"method":"banpubkey","params":[]}`)
+	hash := sha256.Sum256(body)
+	for _, c := range []struct {
+		hash  string
+		body  []byte
+		valid bool
+	}{
+		{hex.Enc(hash[:]), body, true},
+		{hex.Enc(hash[:]), []byte(`{"method":"allowpubkey","params":[]}`), false},
+		{"", body, false},
+	} {
+		r := &http.Request{Method: http.MethodPost, URL: ur, Header: make(http.Header)}
+		if err = AddNIP98Header(r, ur, r.Method, c.hash, sign, 0); err != nil {
+			t.Fatal(err)
+		}
+		if err = CheckPayload(r, c.body); (err == nil) != c.valid {
+			t.Fatalf("payload %q with body %s: expected valid %v, got %v", c.hash, c.body,
+				c.valid, err)
+		}
+	}
+}
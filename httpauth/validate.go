@@ -1,6 +1,7 @@
 package httpauth
 
 import (
+	"crypto/sha256"
 	"encoding/base64"
 	"fmt"
 	"net/http"
@@ -10,6 +11,7 @@ import (
 	"realy.lol/chk"
 	"realy.lol/errorf"
 	"realy.lol/event"
+	"realy.lol/hex"
 	"realy.lol/ints"
 	"realy.lol/kind"
 	"realy.lol/tag"
@@ -170,3 +172,34 @@ func CheckAuth(r *http.Request, tolerance ...time.Duration) (valid bool,
 
 	return
 }
+
+// CheckPayload verifies that the NIP-98 authentication event of a request, which must already
+// have been validated with CheckAuth, has a `payload` tag with the SHA256 hash of the request
+// body, so the authorization cannot be used for another request to the same URL.
+func CheckPayload(r *http.Request, body []byte) (err error) {
+	val := r.Header.Get(HeaderKey)
+	split := strings.Split(val, " ")
+	if len(split) != 2 || split[0] != NIP98Prefix {
+		err = errorf.E("invalid '%s' value: '%s'", HeaderKey, val)
+		return
+	}
+	var evb []byte
+	if evb, err = base64.URLEncoding.DecodeString(split[1]); chk.E(err) {
+		return
+	}
+	ev := event.New()
+	if _, err = ev.Unmarshal(evb); chk.E(err) {
+		return
+	}
+	pt := ev.Tags.GetAll(tag.New("payload"))
+	if pt.Len() != 1 {
+		err = errorf.E("nip-98 event must have one \"payload\" tag, found %d", pt.Len())
+		return
+	}
+	hash := sha256.Sum256(body)
+	if string(pt.ToSliceOfTags()[0].Value()) != hex.Enc(hash[:]) {
+		err = errorf.E("nip-98 event payload hash does not match the request body")
+		return
+	}
+	return
+}
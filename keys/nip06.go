@@ -0,0 +1,60 @@
+package keys
+
+import (
+	"fmt"
+
+	"realy.lol/chk"
+	"realy.lol/ec/bip39"
+	"realy.lol/ec/hdkeychain"
+	"realy.lol/errorf"
+)
+
+// NIP06CoinType is the BIP-44 coin type of nostr keys.
+const NIP06CoinType = 1237
+
+// NIP06Path returns the NIP-06 derivation path of the key of an account.
+func NIP06Path(account uint32) string {
+	return fmt.Sprintf("m/44'/%d'/%d'/0/0", NIP06CoinType, account)
+}
+
+// MnemonicRoot checks a BIP-39 mnemonic and derives its m/44'/1237' key, which the keys of
+// all its accounts are derived from. The passphrase may be empty.
+func MnemonicRoot(mnemonic, passphrase string) (root *hdkeychain.ExtendedKey, err error) {
+	if _, err = bip39.MnemonicToEntropy(mnemonic); err != nil {
+		err = errorf.E("invalid mnemonic: %v", err)
+		return
+	}
+	var seed []byte
+	if seed, err = bip39.Seed(mnemonic, passphrase); chk.E(err) {
+		return
+	}
+	var master *hdkeychain.ExtendedKey
+	if master, err = hdkeychain.NewMaster(seed); chk.E(err) {
+		return
+	}
+	return master.DerivePath(fmt.Sprintf("m/44'/%d'", NIP06CoinType))
+}
+
+// AccountKey derives the secret key of an account from the root key of a mnemonic.
+func AccountKey(root *hdkeychain.ExtendedKey, account uint32) (sec []byte, err error) {
+	if account >= hdkeychain.HardenedKeyStart {
+		err = errorf.E("account %d is too large", account)
+		return
+	}
+	var k *hdkeychain.ExtendedKey
+	if k, err = root.DerivePath(fmt.Sprintf("m/%d'/0/0", account)); chk.E(err) {
+		return
+	}
+	b := k.SecretKey().Key.Bytes()
+	return b[:], nil
+}
+
+// SecretFromMnemonic derives the secret key of an account of a BIP-39 mnemonic, along the
+// NIP-06 path m/44'/1237'/<account>'/0/0. The passphrase may be empty.
+func SecretFromMnemonic(mnemonic, passphrase string, account uint32) (sec []byte, err error) {
+	var root *hdkeychain.ExtendedKey
+	if root, err = MnemonicRoot(mnemonic, passphrase); err != nil {
+		return
+	}
+	return AccountKey(root, account)
+}
@@ -0,0 +1,34 @@
+package keys
+
+import (
+	"testing"
+
+	"realy.lol/hex"
+)
+
+func TestSecretFromMnemonic(t *testing.T) {
+	// the test vectors of NIP-06.
+	for _, v := range []struct{ mnemonic, sec, pub string }{
+		{"leader monkey parrot ring guide accident before fence cannon height naive bean",
+			"7f7ff03d123792d6ac594bfa67bf6d0c0ab55b6b1fdb6249303fe861f1ccba9a",
+			"17162c921dc4d2518f9a101db33695df1afb56ab82f5ff3e5da6eec3ca5cd917"},
+		{"what bleak badge arrange retreat wolf trade produce cricket blur garlic valid proud rude strong choose busy staff weather area salt hollow arm fade",
+			"c15d739894c81a2fcfd3a2df85a0d2c0dbc47a280d092799f144d73d7ae78add",
+			"d41b22899549e1f3d335a31002cfd382174006e166d3e658e3a5eecdb6463573"},
+	} {
+		sec, err := SecretFromMnemonic(v.mnemonic, "", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hex.Enc(sec) != v.sec {
+			t.Fatalf("derived %0x", sec)
+		}
+		if pub, _ := SecretBytesToPubKeyHex(sec); pub != v.pub {
+			t.Fatalf("public key %s", pub)
+		}
+	}
+	if _, err := SecretFromMnemonic("leader monkey parrot ring guide accident before fence cannon height naive naive",
+		"", 0); err == nil {
+		t.Fatal("derived from an invalid mnemonic")
+	}
+}
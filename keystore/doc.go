@@ -0,0 +1,4 @@
+// Package keystore loads secret keys kept encrypted as NIP-49 ncryptsecs, so that the keys of
+// relays, bunkers and tools need not be stored in plaintext, decrypting them with a passphrase
+// read from a file or typed at a prompt.
+package keystore
@@ -0,0 +1,137 @@
+package keystore
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/term"
+
+	"realy.lol/bech32encoding"
+	"realy.lol/chk"
+	"realy.lol/errorf"
+	"realy.lol/hex"
+	"realy.lol/log"
+	"realy.lol/p256k"
+)
+
+// PassphraseFileEnv is the environment variable naming a file the passphrase of encrypted keys
+// is read from, for services that cannot be given it at a prompt.
+const PassphraseFileEnv = "NCRYPTSEC_PASSPHRASE_FILE"
+
+// Passphrase returns a passphrase for an encrypted key.
+type Passphrase func() (passphrase []byte, err error)
+
+// Prompt returns a Passphrase read from the file in PassphraseFileEnv if it is set, otherwise
+// typed at a prompt without echo if stdin is a terminal, or the first line of stdin.
+func Prompt(prompt string) Passphrase {
+	return func() (passphrase []byte, err error) {
+		if path := os.Getenv(PassphraseFileEnv); path != "" {
+			return FromFile(path)()
+		}
+		fd := int(os.Stdin.Fd())
+		if term.IsTerminal(fd) {
+			_, _ = fmt.Fprint(os.Stderr, prompt)
+			passphrase, err = term.ReadPassword(fd)
+			_, _ = fmt.Fprintln(os.Stderr)
+			return
+		}
+		if passphrase, err = bufio.NewReader(os.Stdin).ReadBytes('\n'); len(passphrase) > 0 {
+			err = nil
+		}
+		passphrase = bytes.TrimRight(passphrase, "\r\n")
+		return
+	}
+}
+
+// FromFile returns a Passphrase that is the first line of a file.
+func FromFile(path string) Passphrase {
+	return func() (passphrase []byte, err error) {
+		if passphrase, err = os.ReadFile(path); chk.E(err) {
+			return
+		}
+		passphrase, _, _ = bytes.Cut(passphrase, []byte("\n"))
+		passphrase = bytes.TrimRight(passphrase, "\r")
+		return
+	}
+}
+
+// Open returns a signer for a secret key given as an ncryptsec, which is decrypted with the
+// passphrase, an nsec or hex secret key, or the path of a file containing one of them.
+func Open(key string, passphrase Passphrase) (sign *p256k.Signer, err error) {
+	key = strings.TrimSpace(key)
+	if _, e := os.Stat(key); e == nil {
+		return Load(key, passphrase)
+	}
+	var sec []byte
+	switch {
+	case strings.HasPrefix(key, string(bech32encoding.EncryptedSecHRP)):
+		var pass []byte
+		if pass, err = passphrase(); err != nil {
+			return
+		}
+		var security bech32encoding.KeySecurity
+		if sec, security, err = bech32encoding.NcryptsecToBytes([]byte(key),
+			pass); err != nil {
+			return
+		}
+		if security == bech32encoding.KeyInsecure {
+			log.W.F("the encrypted key has been handled insecurely")
+		}
+	case strings.HasPrefix(key, string(bech32encoding.SecHRP)):
+		if sec, err = bech32encoding.NsecToBytes([]byte(key)); chk.E(err) {
+			err = errorf.E("failed to decode nsec: '%s'", err.Error())
+			return
+		}
+	default:
+		if sec, err = hex.Dec(key); err != nil || len(sec) != 32 {
+			err = errorf.E("key is not an ncryptsec, nsec, hex secret key or key file")
+			return
+		}
+	}
+	sign = &p256k.Signer{}
+	if err = sign.InitSec(sec); chk.E(err) {
+		return
+	}
+	return
+}
+
+// Load returns a signer for the secret key in a file, which may be an ncryptsec that is
+// decrypted with the passphrase, or an nsec or hex secret key.
+func Load(path string, passphrase Passphrase) (sign *p256k.Signer, err error) {
+	var b []byte
+	if b, err = os.ReadFile(path); chk.E(err) {
+		return
+	}
+	key := strings.TrimSpace(string(b))
+	if _, e := os.Stat(key); e == nil {
+		err = errorf.E("%s does not contain a key", path)
+		return
+	}
+	if sign, err = Open(key, passphrase); err != nil {
+		err = errorf.E("%s: %v", path, err)
+		return
+	}
+	return
+}
+
+// Save encrypts a secret key with a passphrase into an ncryptsec file, readable only by the
+// user, with 2^logN scrypt rounds.
+func Save(path string, sec, passphrase []byte, logN uint8,
+	security bech32encoding.KeySecurity) (err error) {
+	var enc []byte
+	if enc, err = bech32encoding.SecretKeyToNcryptsec(sec, passphrase, logN,
+		security); chk.E(err) {
+		return
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0700); chk.E(err) {
+		return
+	}
+	if err = os.WriteFile(path, append(enc, '\n'), 0600); chk.E(err) {
+		return
+	}
+	return
+}
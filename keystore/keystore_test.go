@@ -0,0 +1,44 @@
+package keystore
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"realy.lol/bech32encoding"
+	"realy.lol/ec/secp256k1"
+	"realy.lol/p256k"
+)
+
+func TestSaveLoad(t *testing.T) {
+	sign := &p256k.Signer{}
+	if err := sign.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "key")
+	if err := Save(path, sign.Sec(), []byte("hunter2"), 10,
+		bech32encoding.KeySecure); err != nil {
+		t.Fatal(err)
+	}
+	passFile := filepath.Join(dir, "passphrase")
+	if err := os.WriteFile(passFile, []byte("hunter2\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Open(path, FromFile(passFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(loaded.Pub(), sign.Pub()) {
+		t.Fatal("loaded the wrong key")
+	}
+	if _, err = Load(path, func() ([]byte, error) { return []byte("hunter3"), nil }); err == nil {
+		t.Fatal("loaded with the wrong passphrase")
+	}
+	nsec, _ := bech32encoding.SecretKeyToNsec(secp256k1.SecKeyFromBytes(sign.Sec()))
+	if loaded, err = Open(string(nsec), nil); err != nil ||
+		!bytes.Equal(loaded.Pub(), sign.Pub()) {
+		t.Fatalf("opening nsec %v", err)
+	}
+}
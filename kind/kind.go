@@ -108,6 +108,24 @@ func (k *T) IsPrivileged() (is bool) {
 	return
 }
 
+// IsGiftWrap returns true if the kind is a NIP-59 gift wrap, which is signed by a random key
+// and is only addressed to the pubkey in its p tag.
+func (k *T) IsGiftWrap() (is bool) {
+	if k == nil {
+		return false
+	}
+	return k.Equal(GiftWrap) || k.Equal(GiftWrapWithKind4)
+}
+
+// IsGroupModeration returns true if the kind is one of the NIP-29 moderation events that may
+// only be published by group admins.
+func (k *T) IsGroupModeration() (is bool) {
+	if k == nil {
+		return false
+	}
+	return k.K >= GroupModerationStart.K && k.K <= GroupModerationEnd.K
+}
+
 // Marshal renders the kind.T into bytes containing the ASCII string form of the kind number.
 func (k *T) Marshal(dst []byte) (b []byte) { return ints.New(k.ToU64()).Marshal(dst) }
 
@@ -334,7 +352,32 @@ var (
 	JobResultStart        = &T{6000}
 	JobResultEnd          = &T{6999}
 	JobFeedback           = &T{7000}
-	ZapGoal               = &T{9041}
+	// GroupPutUser is a NIP-29 moderation event that adds a user to a group, or sets their
+	// roles.
+	GroupPutUser = &T{9000}
+	// GroupRemoveUser is a NIP-29 moderation event that removes a user from a group.
+	GroupRemoveUser = &T{9001}
+	// GroupEditMetadata is a NIP-29 moderation event that changes the name, picture, about
+	// and the public/private and open/closed status of a group.
+	GroupEditMetadata = &T{9002}
+	// GroupDeleteEvent is a NIP-29 moderation event that removes an event from a group.
+	GroupDeleteEvent = &T{9005}
+	// GroupCreateGroup is a NIP-29 moderation event that creates a new group.
+	GroupCreateGroup = &T{9007}
+	// GroupDeleteGroup is a NIP-29 moderation event that deletes a group.
+	GroupDeleteGroup = &T{9008}
+	// GroupCreateInvite is a NIP-29 moderation event that creates an invite code that can be
+	// used in a join request to become a member of a closed group.
+	GroupCreateInvite = &T{9009}
+	// GroupModerationStart is the first of the NIP-29 moderation event kinds.
+	GroupModerationStart = &T{9000}
+	// GroupModerationEnd is the last of the NIP-29 moderation event kinds.
+	GroupModerationEnd = &T{9020}
+	// GroupJoinRequest is a NIP-29 request by a user to join a group.
+	GroupJoinRequest = &T{9021}
+	// GroupLeaveRequest is a NIP-29 request by a user to leave a group.
+	GroupLeaveRequest = &T{9022}
+	ZapGoal           = &T{9041}
 	// ZapRequest is an event type that...
 	ZapRequest = &T{9734}
 	// Zap is an event type that...
@@ -378,7 +421,12 @@ var (
 	WalletNotification = NWCNotification
 	// NostrConnect is an event type that...
 	NostrConnect = &T{24133}
-	HTTPAuth     = &T{27235}
+	// CoSign is an event type that carries the encrypted messages of a MuSig2 session in
+	// which the members of a shared identity co-sign an event.
+	CoSign = &T{24135}
+	// BlossomAuth is an event type that authorizes requests to a Blossom blob server.
+	BlossomAuth = &T{24242}
+	HTTPAuth    = &T{27235}
 	// EphemeralEnd is an event type that...
 	EphemeralEnd = &T{30000}
 	// ParameterizedReplaceableStart is an event type that...
@@ -427,7 +475,16 @@ var (
 	// WaveLakeTrack which has no spec and uses malformed tags
 	WaveLakeTrack       = &T{32123}
 	CommunityDefinition = &T{34550}
-	ACLEvent            = &T{39998}
+	// GroupMetadata is a relay signed NIP-29 event that describes a group.
+	GroupMetadata = &T{39000}
+	// GroupAdmins is a relay signed NIP-29 event that lists the admins of a group and their
+	// roles.
+	GroupAdmins = &T{39001}
+	// GroupMembers is a relay signed NIP-29 event that lists the members of a group.
+	GroupMembers = &T{39002}
+	// GroupRoles is a relay signed NIP-29 event that lists the roles supported by the relay.
+	GroupRoles = &T{39003}
+	ACLEvent   = &T{39998}
 	// ParameterizedReplaceableEnd is an event type that...
 	ParameterizedReplaceableEnd = &T{40000}
 )
@@ -464,6 +521,15 @@ var Map = map[uint16]string{
 	JobResultStart.K:              "JobResultStart",
 	JobResultEnd.K:                "JobResultEnd",
 	JobFeedback.K:                 "JobFeedback",
+	GroupPutUser.K:                "GroupPutUser",
+	GroupRemoveUser.K:             "GroupRemoveUser",
+	GroupEditMetadata.K:           "GroupEditMetadata",
+	GroupDeleteEvent.K:            "GroupDeleteEvent",
+	GroupCreateGroup.K:            "GroupCreateGroup",
+	GroupDeleteGroup.K:            "GroupDeleteGroup",
+	GroupCreateInvite.K:           "GroupCreateInvite",
+	GroupJoinRequest.K:            "GroupJoinRequest",
+	GroupLeaveRequest.K:           "GroupLeaveRequest",
 	ZapGoal.K:                     "ZapGoal",
 	ZapRequest.K:                  "ZapRequest",
 	Zap.K:                         "Zap",
@@ -486,6 +552,8 @@ var Map = map[uint16]string{
 	WalletResponse.K:              "WalletResponse",
 	WalletNotification.K:          "WalletNotification",
 	NostrConnect.K:                "NostrConnect",
+	CoSign.K:                      "CoSign",
+	BlossomAuth.K:                 "BlossomAuth",
 	HTTPAuth.K:                    "HTTPAuth",
 	FollowSets.K:                  "FollowSets",
 	GenericLists.K:                "GenericLists",
@@ -515,4 +583,8 @@ var Map = map[uint16]string{
 	HandlerRecommendation.K:       "HandlerRecommendation",
 	HandlerInformation.K:          "HandlerInformation",
 	CommunityDefinition.K:         "CommunityDefinition",
+	GroupMetadata.K:               "GroupMetadata",
+	GroupAdmins.K:                 "GroupAdmins",
+	GroupMembers.K:                "GroupMembers",
+	GroupRoles.K:                  "GroupRoles",
 }
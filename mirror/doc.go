@@ -0,0 +1,7 @@
+// Package mirror pulls events from upstream relays into the local store. For each upstream
+// it backfills the history matching a set of filters by paging backwards with until, while a
+// live subscription receives new events, and it records its progress in a Cursor so that
+// syncing resumes where it left off after a restart. The live subscription is opened again
+// from the cursor when the connection is lost, and the mirror restarts when its filters
+// change.
+package mirror
@@ -0,0 +1,345 @@
+package mirror
+
+import (
+	"encoding/binary"
+	"strings"
+	"sync"
+	"time"
+
+	"realy.lol/chk"
+	"realy.lol/context"
+	"realy.lol/errorf"
+	"realy.lol/event"
+	"realy.lol/filters"
+	"realy.lol/hex"
+	"realy.lol/log"
+	"realy.lol/sha256"
+	"realy.lol/signer"
+	"realy.lol/timestamp"
+	"realy.lol/ws"
+)
+
+// DefaultPageSize is the limit of each backfill request.
+const DefaultPageSize = 500
+
+var (
+	// SaveInterval is the minimum time between saving the cursor for live events.
+	SaveInterval = 5 * time.Second
+	// RefreshInterval is how often the filters of a mirror are checked for changes.
+	RefreshInterval = time.Minute
+	// RetryInterval is how long to wait before subscribing again after the live subscription
+	// to an upstream fails, which grows with each failure up to MaxRetryInterval.
+	RetryInterval = 3 * time.Second
+	// MaxRetryInterval is the longest wait before subscribing again to an upstream.
+	MaxRetryInterval = 5 * time.Minute
+)
+
+// Cursor records how far the mirroring of an upstream relay has progressed.
+type Cursor struct {
+	// Fingerprint identifies the filters the cursor was made with, the mirroring starts over
+	// if they change.
+	Fingerprint string `json:"fingerprint"`
+	// Until is the created_at that backfilling has fetched all events newer than.
+	Until int64 `json:"until"`
+	// Done is true when backfilling has reached the oldest event on the upstream.
+	Done bool `json:"done"`
+	// Since is the created_at of the newest event received from the live subscription.
+	Since int64 `json:"since"`
+}
+
+// Cursors stores the cursors of the upstream relays. GetCursor returns nil if there is no
+// cursor for the url.
+type Cursors interface {
+	GetCursor(url string) (c *Cursor, err error)
+	SetCursor(url string, c *Cursor) (err error)
+}
+
+// AddFunc saves an event received from an upstream.
+type AddFunc func(ev *event.T) (err error)
+
+// M mirrors events from upstream relays. It checks the Id and signature of the events it
+// receives, those of each backfilled page together, so its Pool can skip checking them with a
+// SignatureChecker that accepts all events.
+type M struct {
+	Pool    *ws.Pool
+	Cursors Cursors
+	Add     AddFunc
+	// PageSize is the limit of each backfill request, DefaultPageSize if zero.
+	PageSize uint
+	// Auth returns the key to authenticate to upstreams that require it, if it is not nil.
+	Auth func() signer.I
+}
+
+// Fingerprint returns a digest of filters without their since, until and limit fields.
+func Fingerprint(ff *filters.T) (fp string) {
+	var b []byte
+	for _, f := range ff.F {
+		// a shallow copy, the paging fields are replaced, not modified.
+		c := *f
+		c.Since, c.Until, c.Limit = nil, nil, nil
+		var p uint64
+		var err error
+		if p, err = c.Fingerprint(); chk.E(err) {
+			continue
+		}
+		b = binary.LittleEndian.AppendUint64(b, p)
+	}
+	h := sha256.Sum256(b)
+	return hex.Enc(h[:8])
+}
+
+// upstream is the state of mirroring one relay.
+type upstream struct {
+	*M
+	url    string
+	mx     sync.Mutex
+	cursor *Cursor
+	saved  time.Time
+}
+
+// Mirror pulls the events matching the filters returned by ff from the upstream relay at url
+// until the context is canceled. The filters are got again every RefreshInterval, and if they
+// have changed the mirroring starts over with them. Nothing is pulled while there are none.
+func (m *M) Mirror(c context.T, url string, ff func() *filters.T) {
+	t := time.NewTicker(RefreshInterval)
+	defer t.Stop()
+	for c.Err() == nil {
+		current := ff()
+		fp := Fingerprint(current)
+		mc, cancel := context.Cancel(c)
+		var wg sync.WaitGroup
+		if current.Len() > 0 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				m.mirror(mc, url, current)
+			}()
+		}
+	wait:
+		for {
+			select {
+			case <-c.Done():
+				break wait
+			case <-t.C:
+				if Fingerprint(ff()) != fp {
+					log.I.F("mirror %s: filters changed", url)
+					break wait
+				}
+			}
+		}
+		cancel()
+		wg.Wait()
+	}
+}
+
+// mirror pulls the events matching a set of filters from the upstream relay at url until the
+// context is canceled.
+func (m *M) mirror(c context.T, url string, ff *filters.T) {
+	u := &upstream{M: m, url: url}
+	var err error
+	if u.cursor, err = m.Cursors.GetCursor(url); chk.E(err) {
+		return
+	}
+	fp := Fingerprint(ff)
+	if u.cursor == nil || u.cursor.Fingerprint != fp {
+		now := timestamp.Now().I64()
+		u.cursor = &Cursor{Fingerprint: fp, Until: now, Since: now}
+		u.save(true)
+	}
+	log.I.F("mirroring %s from %d, backfilled until %d", url, u.cursor.Since,
+		u.cursor.Until)
+	var wg sync.WaitGroup
+	if !u.cursor.Done {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			u.backfill(c, ff)
+		}()
+	}
+	u.live(c, ff)
+	wg.Wait()
+}
+
+// save stores the cursor if it was last saved longer than SaveInterval ago, or if forced.
+// It must be called with the mutex locked, except before the mirroring goroutines start.
+func (u *upstream) save(force bool) {
+	if !force && time.Since(u.saved) < SaveInterval {
+		return
+	}
+	cur := *u.cursor
+	if err := u.Cursors.SetCursor(u.url, &cur); chk.E(err) {
+		return
+	}
+	u.saved = time.Now()
+}
+
+// add checks the signatures of events received from the upstream together and saves those
+// that are valid.
+func (u *upstream) add(evs ...*event.T) {
+	invalid := event.VerifyBatch(evs)
+	for i, ev := range evs {
+		if len(invalid) > 0 && invalid[0] == i {
+			invalid = invalid[1:]
+			log.D.F("mirror %s: not adding %0x with an invalid signature", u.url, ev.Id)
+			continue
+		}
+		if err := u.Add(ev); err != nil {
+			log.T.F("mirror %s: not adding %0x: %v", u.url, ev.Id, err)
+		}
+	}
+}
+
+// with returns a copy of the filters with since, until and limit set.
+func with(ff *filters.T, since, until int64, limit uint) (nf *filters.T) {
+	nf = filters.New()
+	for _, f := range ff.F {
+		c := *f
+		c.Since, c.Until, c.Limit = nil, nil, nil
+		if since > 0 {
+			c.Since = timestamp.FromUnix(since)
+		}
+		if until > 0 {
+			c.Until = timestamp.FromUnix(until)
+		}
+		if limit > 0 {
+			l := limit
+			c.Limit = &l
+		}
+		nf.F = append(nf.F, &c)
+	}
+	return
+}
+
+// backfill pages backwards through the history of the upstream until it returns no more
+// events.
+func (u *upstream) backfill(c context.T, ff *filters.T) {
+	page := u.PageSize
+	if page == 0 {
+		page = DefaultPageSize
+	}
+	for {
+		u.mx.Lock()
+		until := u.cursor.Until
+		u.mx.Unlock()
+		var evs []*event.T
+		oldest := until
+		for ie := range u.Pool.SubManyEose(c, []string{u.url}, with(ff, 0, until, page)) {
+			evs = append(evs, ie.Event)
+			if ts := ie.Event.CreatedAt.I64(); ts < oldest {
+				oldest = ts
+			}
+		}
+		n := len(evs)
+		u.add(evs...)
+		select {
+		case <-c.Done():
+			return
+		default:
+		}
+		u.mx.Lock()
+		if n == 0 {
+			u.cursor.Done = true
+			log.I.F("mirror %s: backfill complete", u.url)
+		} else {
+			// events at the oldest second may have been cut off by the limit, so ask for it
+			// again unless the whole page was that second.
+			u.cursor.Until = oldest
+			if oldest == until {
+				u.cursor.Until = oldest - 1
+			}
+		}
+		u.save(true)
+		done := u.cursor.Done
+		u.mx.Unlock()
+		if done {
+			return
+		}
+	}
+}
+
+// live subscribes to new events from the upstream until the context is canceled. When the
+// subscription ends it subscribes again from the created_at of the newest event received, so
+// that no events are missed while it was disconnected.
+func (u *upstream) live(c context.T, ff *filters.T) {
+	interval := RetryInterval
+	for {
+		u.mx.Lock()
+		since := u.cursor.Since
+		u.mx.Unlock()
+		n, err := u.subscribe(c, with(ff, since, 0, 0))
+		if c.Err() != nil {
+			break
+		}
+		if err != nil {
+			log.D.F("mirror %s: %v", u.url, err)
+		}
+		if n > 0 {
+			interval = RetryInterval
+		}
+		select {
+		case <-c.Done():
+		case <-time.After(interval):
+		}
+		interval = min(interval*17/10, MaxRetryInterval)
+	}
+	u.mx.Lock()
+	u.save(true)
+	u.mx.Unlock()
+}
+
+// subscribe receives the events matching the filters from the upstream until the
+// subscription ends, returning the number of events received.
+func (u *upstream) subscribe(c context.T, ff *filters.T) (n int, err error) {
+	var relay *ws.Client
+	if relay, err = u.Pool.EnsureRelay(u.url); err != nil {
+		return
+	}
+	var authed bool
+	for {
+		var sub *ws.Subscription
+		if sub, err = relay.Subscribe(c, ff, ws.WithLabel("mirror")); err != nil {
+			return
+		}
+		var reason string
+		reason, err = u.receive(c, sub, &n)
+		sub.Unsub()
+		if err != nil || reason == "" {
+			return
+		}
+		if !strings.HasPrefix(reason, "auth-required:") || u.Auth == nil || authed {
+			err = errorf.E("subscription closed: %s", reason)
+			return
+		}
+		if err = relay.Auth(c, u.Auth()); err != nil {
+			return
+		}
+		authed = true
+	}
+}
+
+// receive adds the events of a subscription until it ends, returning the reason if the
+// upstream closed it.
+func (u *upstream) receive(c context.T, sub *ws.Subscription, n *int) (reason string,
+	err error) {
+	for {
+		select {
+		case ev, more := <-sub.Events:
+			if !more {
+				err = errorf.E("connection closed")
+				return
+			}
+			u.add(ev)
+			*n++
+			u.mx.Lock()
+			if ts := ev.CreatedAt.I64(); ts > u.cursor.Since {
+				u.cursor.Since = ts
+			}
+			u.save(false)
+			u.mx.Unlock()
+		case reason = <-sub.ClosedReason:
+			return
+		case <-c.Done():
+			return
+		}
+	}
+}
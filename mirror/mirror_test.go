@@ -0,0 +1,33 @@
+package mirror
+
+import (
+	"testing"
+
+	"realy.lol/filter"
+	"realy.lol/filters"
+	"realy.lol/kind"
+	"realy.lol/kinds"
+	"realy.lol/timestamp"
+)
+
+func TestFingerprint(t *testing.T) {
+	ff := filters.New(&filter.T{Kinds: kinds.New(kind.TextNote)})
+	paged := with(ff, 0, 1700000000, 100)
+	if *paged.F[0].Limit != 100 || paged.F[0].Until.I64() != 1700000000 ||
+		paged.F[0].Since != nil {
+		t.Fatalf("paging fields not set: %s", paged.F[0].Serialize())
+	}
+	if ff.F[0].Until != nil {
+		t.Fatal("original filter was modified")
+	}
+	if Fingerprint(ff) != Fingerprint(paged) {
+		t.Fatal("fingerprint depends on since, until or limit")
+	}
+	ff.F[0].Since = timestamp.Now()
+	if Fingerprint(ff) != Fingerprint(with(ff, 1, 0, 0)) {
+		t.Fatal("fingerprint depends on since")
+	}
+	if Fingerprint(ff) == Fingerprint(filters.New(&filter.T{Kinds: kinds.New(kind.Reaction)})) {
+		t.Fatal("different filters have the same fingerprint")
+	}
+}
@@ -0,0 +1,3 @@
+// Package netaddr checks that the hosts of URLs given by users are on the public internet, so
+// they cannot be used to make the relay connect to its own host or its private network.
+package netaddr
@@ -0,0 +1,56 @@
+package netaddr
+
+import (
+	"net"
+	"net/netip"
+	"syscall"
+
+	"realy.lol/context"
+	"realy.lol/errorf"
+)
+
+// sharedAddressSpace is the carrier grade NAT range of RFC 6598, which is not routable on the
+// public internet.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// IsPublic returns true if an address is routable on the public internet, and is not a
+// loopback, private, link local, multicast or unspecified address.
+func IsPublic(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsValid() && addr.IsGlobalUnicast() && !addr.IsPrivate() &&
+		!sharedAddressSpace.Contains(addr)
+}
+
+// CheckHost resolves a host name, or parses an IP address, and returns an error if it has no
+// addresses or any of them is not public.
+func CheckHost(c context.T, host string) (err error) {
+	var addrs []netip.Addr
+	if addr, perr := netip.ParseAddr(host); perr == nil {
+		addrs = append(addrs, addr)
+	} else if addrs, err = net.DefaultResolver.LookupNetIP(c, "ip", host); err != nil {
+		return
+	}
+	if len(addrs) == 0 {
+		return errorf.E("%s has no addresses", host)
+	}
+	for _, addr := range addrs {
+		if !IsPublic(addr) {
+			return errorf.E("%s resolves to %s, which is not a public address", host, addr)
+		}
+	}
+	return
+}
+
+// Control is a net.Dialer Control function that refuses to connect to addresses that are not
+// public. As it checks the address that is dialled, it also covers host names that resolve to
+// private addresses and redirects to them.
+func Control(network, address string, _ syscall.RawConn) (err error) {
+	var ap netip.AddrPort
+	if ap, err = netip.ParseAddrPort(address); err != nil {
+		return
+	}
+	if !IsPublic(ap.Addr()) {
+		return errorf.E("%s is not a public address", ap.Addr())
+	}
+	return
+}
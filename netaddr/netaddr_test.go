@@ -0,0 +1,45 @@
+package netaddr
+
+import (
+	"net/netip"
+	"testing"
+
+	"realy.lol/context"
+)
+
+func TestIsPublic(t *testing.T) {
+	for addr, public := range map[string]bool{
+		"1.1.1.1":            true,
+		"2606:4700::1111":    true,
+		"127.0.0.1":          false,
+		"::1":                false,
+		"10.1.2.3":           false,
+		"172.16.0.1":         false,
+		"192.168.1.1":        false,
+		"100.64.0.1":         false,
+		"169.254.169.254":    false,
+		"0.0.0.0":            false,
+		"::":                 false,
+		"fe80::1":            false,
+		"fd00::1":            false,
+		"224.0.0.1":          false,
+		"::ffff:127.0.0.1":   false,
+		"::ffff:203.0.113.7": true,
+	} {
+		if IsPublic(netip.MustParseAddr(addr)) != public {
+			t.Errorf("%s: expected public %v", addr, public)
+		}
+	}
+}
+
+func TestCheckHost(t *testing.T) {
+	c := context.Bg()
+	if err := CheckHost(c, "1.1.1.1"); err != nil {
+		t.Fatal(err)
+	}
+	for _, host := range []string{"127.0.0.1", "::1", "localhost"} {
+		if err := CheckHost(c, host); err == nil {
+			t.Errorf("%s was accepted as a public host", host)
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package nwc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"realy.lol/chk"
+	"realy.lol/context"
+	"realy.lol/encryption"
+	"realy.lol/errorf"
+	"realy.lol/event"
+	"realy.lol/filter"
+	"realy.lol/filters"
+	"realy.lol/hex"
+	"realy.lol/kind"
+	"realy.lol/kinds"
+	"realy.lol/p256k"
+	"realy.lol/tag"
+	"realy.lol/tags"
+	"realy.lol/timestamp"
+	"realy.lol/ws"
+)
+
+// DefaultTimeout is how long a Client waits for the answer to a request.
+const DefaultTimeout = 30 * time.Second
+
+// The encryption schemes a wallet service can advertise in its info event.
+const (
+	NIP04 = "nip04"
+	NIP44 = "nip44_v2"
+)
+
+// Capabilities are what a wallet service advertises in its kind 13194 info event.
+type Capabilities struct {
+	// Methods are the request methods the wallet service supports.
+	Methods []string
+	// Encryption are the encryption schemes the wallet service supports, NIP04 if the info
+	// event does not say.
+	Encryption []string
+	// Notifications are the notification types the wallet service sends.
+	Notifications []string
+}
+
+// Supports returns true if the wallet service advertises a request method.
+func (c *Capabilities) Supports(method []byte) bool {
+	for _, m := range c.Methods {
+		if m == string(method) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseCapabilities reads the capabilities of a wallet service from its info event.
+func ParseCapabilities(ev *event.T) (c *Capabilities) {
+	c = &Capabilities{Methods: strings.Fields(string(ev.Content))}
+	if t := ev.Tags.GetFirst(tag.New("encryption")); t != nil && t.Len() > 1 {
+		c.Encryption = strings.Fields(t.S(1))
+	}
+	if len(c.Encryption) == 0 {
+		c.Encryption = []string{NIP04}
+	}
+	if t := ev.Tags.GetFirst(tag.New("notifications")); t != nil && t.Len() > 1 {
+		c.Notifications = strings.Fields(t.S(1))
+	}
+	return
+}
+
+// Client sends requests to a wallet service over a wallet connection.
+type Client struct {
+	URI *URI
+	// Timeout is how long to wait for the answer to a request.
+	Timeout time.Duration
+	sign    *p256k.Signer
+	// nip04 is the shared secret and nip44 the conversation key with the wallet service.
+	nip04, nip44 []byte
+	mx           sync.Mutex
+	relay        *ws.Client
+	capabilities *Capabilities
+}
+
+// NewClient creates a Client for a nostr+walletconnect:// URI. The relay of the wallet is
+// connected to on the first request.
+func NewClient(uri string) (cl *Client, err error) {
+	cl = &Client{Timeout: DefaultTimeout, sign: &p256k.Signer{}}
+	if cl.URI, err = ParseURI(uri); err != nil {
+		return
+	}
+	if err = cl.sign.InitSec(cl.URI.Secret); chk.E(err) {
+		return
+	}
+	if cl.nip04, err = encryption.ComputeSharedSecret(cl.URI.Wallet,
+		cl.URI.Secret); chk.E(err) {
+		return
+	}
+	if cl.nip44, err = encryption.GenerateConversationKey(cl.URI.Wallet,
+		cl.URI.Secret); chk.E(err) {
+		return
+	}
+	return
+}
+
+// connect returns the connection to the relay of the wallet, connecting if it is not.
+func (cl *Client) connect(c context.T) (relay *ws.Client, err error) {
+	cl.mx.Lock()
+	defer cl.mx.Unlock()
+	if cl.relay != nil && cl.relay.IsConnected() {
+		return cl.relay, nil
+	}
+	if cl.relay, err = ws.RelayConnect(c, cl.URI.Relays[0]); err != nil {
+		cl.relay = nil
+		err = errorf.E("connecting to wallet relay %s: %v", cl.URI.Relays[0], err)
+		return
+	}
+	return cl.relay, nil
+}
+
+// Close closes the connection to the relay of the wallet.
+func (cl *Client) Close() {
+	cl.mx.Lock()
+	defer cl.mx.Unlock()
+	if cl.relay != nil {
+		chk.E(cl.relay.Close())
+		cl.relay = nil
+	}
+}
+
+// Capabilities returns what the wallet service advertises in its info event, which is fetched
+// from its relay the first time and kept. A wallet service without an info event is taken to
+// support every method with NIP-04 encryption.
+func (cl *Client) Capabilities(c context.T) (caps *Capabilities, err error) {
+	cl.mx.Lock()
+	caps = cl.capabilities
+	cl.mx.Unlock()
+	if caps != nil {
+		return
+	}
+	c, cancel := context.Timeout(c, cl.Timeout)
+	defer cancel()
+	var relay *ws.Client
+	if relay, err = cl.connect(c); err != nil {
+		return
+	}
+	var evs []*event.T
+	if evs, err = relay.QuerySync(c, &filter.T{
+		Kinds:   kinds.New(kind.WalletInfo),
+		Authors: tag.New(cl.URI.Wallet),
+	}, ws.WithLabel("nwc-info")); err != nil {
+		return
+	}
+	var latest *event.T
+	for _, ev := range evs {
+		if latest == nil || ev.CreatedAt.I64() > latest.CreatedAt.I64() {
+			latest = ev
+		}
+	}
+	if latest == nil {
+		caps = &Capabilities{Encryption: []string{NIP04}}
+	} else {
+		caps = ParseCapabilities(latest)
+	}
+	cl.mx.Lock()
+	cl.capabilities = caps
+	cl.mx.Unlock()
+	return
+}
+
+// encrypt encrypts a request with NIP-44 if the wallet service supports it, and otherwise
+// with NIP-04, returning the encryption tag the request event must carry.
+func (cl *Client) encrypt(c context.T, req []byte) (content []byte, t *tag.T, err error) {
+	var caps *Capabilities
+	if caps, err = cl.Capabilities(c); err != nil {
+		return
+	}
+	for _, e := range caps.Encryption {
+		if e == NIP44 {
+			if content, err = encryption.Encrypt(req, cl.nip44); chk.E(err) {
+				return
+			}
+			t = tag.New("encryption", NIP44)
+			return
+		}
+	}
+	if content, err = encryption.EncryptNip4(req, cl.nip04); chk.E(err) {
+		return
+	}
+	return
+}
+
+// decrypt decrypts a response, which is NIP-04 if it has the NIP-04 initialization vector
+// suffix and NIP-44 otherwise.
+func (cl *Client) decrypt(content []byte) (plain []byte, err error) {
+	if bytes.Contains(content, []byte("?iv=")) {
+		return encryption.DecryptNip4(content, cl.nip04)
+	}
+	return encryption.Decrypt(content, cl.nip44)
+}
+
+// wireError is the error of a response as it is encoded in JSON.
+type wireError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// response is a decrypted kind 23195 wallet response.
+type response struct {
+	ResultType string          `json:"result_type"`
+	Error      *wireError      `json:"error"`
+	Result     json.RawMessage `json:"result"`
+}
+
+// err returns the error of a response as an *Error, or nil if it has none.
+func (r *response) err() error {
+	if r.Error == nil {
+		return nil
+	}
+	return NewError([]byte(r.Error.Code), r.Error.Message)
+}
+
+// request sends a request to the wallet and passes its answers, with the value of their d
+// tag, to handle until it returns true. Every request but the multi payments is answered once.
+func (cl *Client) request(c context.T, method []byte, params any,
+	handle func(d string, resp *response) (done bool)) (err error) {
+	c, cancel := context.Timeout(c, cl.Timeout)
+	defer cancel()
+	var req []byte
+	if req, err = json.Marshal(struct {
+		Method string `json:"method"`
+		Params any    `json:"params"`
+	}{string(method), params}); chk.E(err) {
+		return
+	}
+	var content []byte
+	var enc *tag.T
+	if content, enc, err = cl.encrypt(c, req); err != nil {
+		return
+	}
+	ev := &event.T{
+		Content:   content,
+		CreatedAt: timestamp.Now(),
+		Kind:      kind.WalletRequest,
+		Tags:      tags.New(tag.New("p", hex.Enc(cl.URI.Wallet))),
+	}
+	if enc != nil {
+		ev.Tags.AppendTags(enc)
+	}
+	if err = ev.Sign(cl.sign); chk.E(err) {
+		return
+	}
+	var relay *ws.Client
+	if relay, err = cl.connect(c); err != nil {
+		return
+	}
+	var sub *ws.Subscription
+	if sub, err = relay.Subscribe(c, filters.New(&filter.T{
+		Kinds:   kinds.New(kind.WalletResponse),
+		Authors: tag.New(cl.URI.Wallet),
+		Tags:    tags.New(tag.New([]byte("#e"), ev.Id)),
+	}), ws.WithLabel("nwc")); err != nil {
+		return
+	}
+	defer sub.Unsub()
+	if err = relay.Publish(c, ev); err != nil {
+		err = errorf.E("sending %s request: %v", method, err)
+		return
+	}
+	for {
+		select {
+		case <-c.Done():
+			err = fmt.Errorf("%s request: %w", method, ErrTimeout)
+			return
+		case res := <-sub.Events:
+			if res == nil {
+				err = errorf.E("wallet relay closed the subscription")
+				return
+			}
+			var plain []byte
+			if plain, err = cl.decrypt(res.Content); chk.E(err) {
+				continue
+			}
+			resp := &response{}
+			if err = json.Unmarshal(plain, resp); chk.E(err) {
+				continue
+			}
+			err = nil
+			var d string
+			if t := res.Tags.GetFirst(tag.New("d")); t != nil && t.Len() > 1 {
+				d = t.S(1)
+			}
+			if handle(d, resp) {
+				return
+			}
+		}
+	}
+}
+
+// RPC sends a request to the wallet and decodes the result of its answer into result. An
+// error answer is returned as an *Error.
+func (cl *Client) RPC(c context.T, method []byte, params, result any) (err error) {
+	var rerr error
+	if err = cl.request(c, method, params, func(_ string, resp *response) bool {
+		if rerr = resp.err(); rerr == nil && result != nil {
+			rerr = json.Unmarshal(resp.Result, result)
+		}
+		return true
+	}); err != nil {
+		return
+	}
+	return rerr
+}
@@ -0,0 +1,190 @@
+package nwc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"realy.lol/encryption"
+	"realy.lol/event"
+	"realy.lol/hex"
+	"realy.lol/kind"
+	"realy.lol/p256k"
+	"realy.lol/tag"
+	"realy.lol/tags"
+	"realy.lol/timestamp"
+	"realy.lol/ws/wstest"
+)
+
+// reply is one response of the fake wallet to a request, with the d tag of the multi
+// payments.
+type reply struct {
+	d      string
+	result any
+	err    *Error
+}
+
+// fakeWallet is a wallet service which answers the requests sent to it with the replies of
+// handle.
+type fakeWallet struct {
+	t    *testing.T
+	sign *p256k.Signer
+	// encryption is advertised in the info event, which is not published if it is empty.
+	encryption string
+	handle     func(method string, params json.RawMessage) []reply
+	// seen are the encryption schemes of the requests.
+	seen []string
+}
+
+// respond decrypts a request and returns the encrypted and signed answers of handle to it.
+func (w *fakeWallet) respond(req *event.T) (evs []*event.T) {
+	nip04, _ := encryption.ComputeSharedSecret(req.Pubkey, w.sign.Sec())
+	nip44, _ := encryption.GenerateConversationKey(req.Pubkey, w.sign.Sec())
+	scheme := NIP04
+	if t := req.Tags.GetFirst(tag.New("encryption")); t != nil && t.Len() > 1 {
+		scheme = t.S(1)
+	}
+	w.seen = append(w.seen, scheme)
+	var plain []byte
+	var err error
+	if scheme == NIP44 {
+		plain, err = encryption.Decrypt(req.Content, nip44)
+	} else {
+		plain, err = encryption.DecryptNip4(req.Content, nip04)
+	}
+	if err != nil {
+		w.t.Error(err)
+		return
+	}
+	var r struct {
+		Method string          `json:"method"`
+		Params json.RawMessage `json:"params"`
+	}
+	if err = json.Unmarshal(plain, &r); err != nil {
+		w.t.Error(err)
+		return
+	}
+	for _, a := range w.handle(r.Method, r.Params) {
+		resp := map[string]any{"result_type": r.Method}
+		if a.err != nil {
+			resp["error"] = map[string]string{"code": string(a.err.Code),
+				"message": string(a.err.Message)}
+		} else {
+			resp["result"] = a.result
+		}
+		b, _ := json.Marshal(resp)
+		var content []byte
+		if scheme == NIP44 {
+			content, _ = encryption.Encrypt(b, nip44)
+		} else {
+			content, _ = encryption.EncryptNip4(b, nip04)
+		}
+		ev := &event.T{Kind: kind.WalletResponse, CreatedAt: timestamp.Now(), Content: content,
+			Tags: tags.New(tag.New("p", hex.Enc(req.Pubkey)), tag.New("e", hex.Enc(req.Id)))}
+		if a.d != "" {
+			ev.Tags.AppendTags(tag.New("d", a.d))
+		}
+		if err = ev.Sign(w.sign); err != nil {
+			w.t.Error(err)
+			return
+		}
+		evs = append(evs, ev)
+	}
+	return
+}
+
+// newClient starts a fake wallet and returns a Client connected to it.
+func newClient(t *testing.T, w *fakeWallet) (cl *Client) {
+	w.t, w.sign = t, &p256k.Signer{}
+	if err := w.sign.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	r := &wstest.Relay{Respond: w.respond}
+	if w.encryption != "" {
+		info := &event.T{Kind: kind.WalletInfo, CreatedAt: timestamp.Now(),
+			Content: []byte("pay_invoice make_invoice multi_pay_invoice get_balance"),
+			Tags:    tags.New(tag.New("encryption", w.encryption))}
+		if err := info.Sign(w.sign); err != nil {
+			t.Fatal(err)
+		}
+		r.Stored = append(r.Stored, info)
+	}
+	sec := &p256k.Signer{}
+	if err := sec.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	uri := Scheme + "://" + hex.Enc(w.sign.Pub()) + "?relay=" + r.Start(t) + "&secret=" +
+		hex.Enc(sec.Sec())
+	var err error
+	if cl, err = NewClient(uri); err != nil {
+		t.Fatal(err)
+	}
+	cl.Timeout = 2 * time.Second
+	t.Cleanup(cl.Close)
+	return
+}
+
+func TestClient(t *testing.T) {
+	for _, enc := range []string{"", NIP04, NIP44 + " " + NIP04} {
+		w := &fakeWallet{encryption: enc,
+			handle: func(method string, params json.RawMessage) []reply {
+				switch method {
+				case string(Methods.GetBalance):
+					return []reply{{result: map[string]any{"balance": 21000}}}
+				case string(Methods.MakeInvoice):
+					var p MakeInvoiceParams
+					json.Unmarshal(params, &p)
+					return []reply{{result: Transaction{Type: "incoming", Invoice: "lnbc1",
+						Description: p.Description, PaymentHash: "00", Amount: p.Amount}}}
+				case string(Methods.MultiPayInvoice):
+					return []reply{{d: "b", result: Payment{Preimage: "02"}},
+						{d: "a", err: NewError(Errors.InsufficientBalance, "no funds")}}
+				default:
+					return []reply{{err: NewError(Errors.NotImplemented, method)}}
+				}
+			}}
+		cl := newClient(t, w)
+		c := context.Background()
+		balance, err := cl.GetBalance(c)
+		if err != nil || balance != 21000 {
+			t.Fatalf("balance %d %v", balance, err)
+		}
+		tx, err := cl.MakeInvoice(c, 1000, "coffee", 0)
+		if err != nil || tx.Invoice != "lnbc1" || tx.Amount != 1000 || tx.Description != "coffee" {
+			t.Fatalf("invoice %+v %v", tx, err)
+		}
+		rr, err := cl.MultiPayInvoice(c, []PayInvoiceParams{{Id: "a", Invoice: "lnbc2"},
+			{Id: "b", Invoice: "lnbc3"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !errors.Is(rr[0].Err, NewError(Errors.InsufficientBalance, "")) ||
+			rr[1].Err != nil || rr[1].Preimage != "02" {
+			t.Fatalf("multi pay results %+v", rr)
+		}
+		if _, err = cl.GetInfo(c); !errors.Is(err, NewError(Errors.NotImplemented, "")) {
+			t.Fatalf("expected NOT_IMPLEMENTED, got %v", err)
+		}
+		want := NIP04
+		if strings.HasPrefix(enc, NIP44) {
+			want = NIP44
+		}
+		for _, s := range w.seen {
+			if s != want {
+				t.Fatalf("wallet advertising '%s' got a %s request", enc, s)
+			}
+		}
+	}
+}
+
+func TestClientTimeout(t *testing.T) {
+	cl := newClient(t, &fakeWallet{encryption: NIP04,
+		handle: func(string, json.RawMessage) []reply { return nil }})
+	cl.Timeout = 200 * time.Millisecond
+	if _, err := cl.GetBalance(context.Background()); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+}
@@ -1,6 +1,31 @@
 package nwc
 
+import (
+	"bytes"
+	"errors"
+)
+
+// Error is an error returned by a wallet service, with one of the codes in Errors.
 type Error struct {
 	Code    []byte
 	Message []byte
 }
+
+// Error returns the code and message of the error.
+func (e *Error) Error() string { return string(e.Code) + ": " + string(e.Message) }
+
+// Is returns true if the target is an Error with the same code, so that errors.Is can check
+// the code of an error returned by a Client.
+func (e *Error) Is(target error) bool {
+	var t *Error
+	return errors.As(target, &t) && bytes.Equal(e.Code, t.Code)
+}
+
+// NewError creates an Error with one of the codes in Errors.
+func NewError[V string | []byte](code []byte, message V) *Error {
+	return &Error{Code: code, Message: []byte(message)}
+}
+
+// ErrTimeout is returned by a Client when the wallet service does not answer a request in
+// time.
+var ErrTimeout = errors.New("no answer from wallet service")
@@ -10,9 +10,3 @@ var Kinds = []*kind.T{
 	kind.WalletResponse,
 	kind.WalletNotification,
 }
-
-type Server struct {
-}
-
-type Client struct {
-}
@@ -0,0 +1,235 @@
+package nwc
+
+import (
+	"encoding/json"
+
+	"realy.lol/context"
+)
+
+// Transaction is an invoice or payment as it is returned by make_invoice, lookup_invoice and
+// list_transactions.
+type Transaction struct {
+	Type            string `json:"type,omitempty"`
+	Invoice         string `json:"invoice,omitempty"`
+	Description     string `json:"description,omitempty"`
+	DescriptionHash string `json:"description_hash,omitempty"`
+	Preimage        string `json:"preimage,omitempty"`
+	PaymentHash     string `json:"payment_hash"`
+	Amount          Msat   `json:"amount"`
+	FeesPaid        Msat   `json:"fees_paid,omitempty"`
+	CreatedAt       int64  `json:"created_at,omitempty"`
+	ExpiresAt       int64  `json:"expires_at,omitempty"`
+	SettledAt       int64  `json:"settled_at,omitempty"`
+	Metadata        any    `json:"metadata,omitempty"`
+}
+
+// Settled returns true if the invoice of a transaction has been paid.
+func (t *Transaction) Settled() bool { return t.SettledAt > 0 }
+
+// Payment is the result of paying an invoice or sending a keysend payment.
+type Payment struct {
+	Preimage string `json:"preimage"`
+	FeesPaid Msat   `json:"fees_paid,omitempty"`
+}
+
+// Info is the result of get_info.
+type Info struct {
+	Alias         string   `json:"alias,omitempty"`
+	Color         string   `json:"color,omitempty"`
+	Pubkey        string   `json:"pubkey,omitempty"`
+	Network       string   `json:"network,omitempty"`
+	BlockHeight   uint64   `json:"block_height,omitempty"`
+	BlockHash     string   `json:"block_hash,omitempty"`
+	Methods       []string `json:"methods"`
+	Notifications []string `json:"notifications,omitempty"`
+}
+
+// PayInvoiceParams is an invoice to pay, with the amount to pay if the invoice has none.
+// The Id is only used in multi_pay_invoice, to tell the results apart.
+type PayInvoiceParams struct {
+	Id      string `json:"id,omitempty"`
+	Invoice string `json:"invoice"`
+	Amount  Msat   `json:"amount,omitempty"`
+}
+
+// TLVRecord is a custom record of a keysend payment, with a hex encoded value.
+type TLVRecord struct {
+	Type  uint64 `json:"type"`
+	Value string `json:"value"`
+}
+
+// PayKeysendParams is a keysend payment to a node pubkey. The Id is only used in
+// multi_pay_keysend, to tell the results apart.
+type PayKeysendParams struct {
+	Id         string      `json:"id,omitempty"`
+	Amount     Msat        `json:"amount"`
+	Pubkey     string      `json:"pubkey"`
+	Preimage   string      `json:"preimage,omitempty"`
+	TLVRecords []TLVRecord `json:"tlv_records,omitempty"`
+}
+
+// MakeInvoiceParams are the parameters of make_invoice. The expiry is in seconds.
+type MakeInvoiceParams struct {
+	Amount          Msat   `json:"amount"`
+	Description     string `json:"description,omitempty"`
+	DescriptionHash string `json:"description_hash,omitempty"`
+	Expiry          int    `json:"expiry,omitempty"`
+}
+
+// LookupInvoiceParams identifies an invoice by its payment hash or by the invoice itself.
+type LookupInvoiceParams struct {
+	PaymentHash string `json:"payment_hash,omitempty"`
+	Invoice     string `json:"invoice,omitempty"`
+}
+
+// ListTransactionsParams selects the transactions returned by list_transactions. Type is
+// "incoming", "outgoing" or empty for both.
+type ListTransactionsParams struct {
+	From   int64  `json:"from,omitempty"`
+	Until  int64  `json:"until,omitempty"`
+	Limit  int    `json:"limit,omitempty"`
+	Offset int    `json:"offset,omitempty"`
+	Unpaid bool   `json:"unpaid,omitempty"`
+	Type   string `json:"type,omitempty"`
+}
+
+// MultiResult is the result of one of the payments of a multi_pay_invoice or
+// multi_pay_keysend request.
+type MultiResult struct {
+	Id string
+	Payment
+	// Err is the *Error the wallet answered the payment with, or ErrTimeout if it did not.
+	Err error
+}
+
+// PayInvoice pays an invoice.
+func (cl *Client) PayInvoice(c context.T, p PayInvoiceParams) (pm *Payment, err error) {
+	pm = &Payment{}
+	err = cl.RPC(c, Methods.PayInvoice, p, pm)
+	return
+}
+
+// PayKeysend sends a keysend payment.
+func (cl *Client) PayKeysend(c context.T, p PayKeysendParams) (pm *Payment, err error) {
+	pm = &Payment{}
+	err = cl.RPC(c, Methods.PayKeysend, p, pm)
+	return
+}
+
+// MultiPayInvoice pays several invoices, which must each have a distinct Id, returning their
+// results in the same order.
+func (cl *Client) MultiPayInvoice(c context.T, invoices []PayInvoiceParams) (rr []MultiResult,
+	err error) {
+	ids := make([]string, len(invoices))
+	for i, inv := range invoices {
+		ids[i] = inv.Id
+	}
+	return cl.multi(c, Methods.MultiPayInvoice, struct {
+		Invoices []PayInvoiceParams `json:"invoices"`
+	}{invoices}, ids)
+}
+
+// MultiPayKeysend sends several keysend payments, which must each have a distinct Id,
+// returning their results in the same order.
+func (cl *Client) MultiPayKeysend(c context.T, keysends []PayKeysendParams) (rr []MultiResult,
+	err error) {
+	ids := make([]string, len(keysends))
+	for i, k := range keysends {
+		ids[i] = k.Id
+	}
+	return cl.multi(c, Methods.MultiPayKeysend, struct {
+		Keysends []PayKeysendParams `json:"keysends"`
+	}{keysends}, ids)
+}
+
+// multi sends a multi payment request and collects the answers for each of the ids, which
+// come as separate events with the id in their d tag. The payments that are not answered in
+// time have ErrTimeout as their error.
+func (cl *Client) multi(c context.T, method []byte, params any, ids []string) (rr []MultiResult,
+	err error) {
+	rr = make([]MultiResult, len(ids))
+	index := make(map[string]int, len(ids))
+	for i, id := range ids {
+		rr[i] = MultiResult{Id: id, Err: ErrTimeout}
+		index[id] = i
+	}
+	remaining := len(ids)
+	if err = cl.request(c, method, params, func(d string, resp *response) bool {
+		i, ok := index[d]
+		if !ok {
+			return false
+		}
+		delete(index, d)
+		if rr[i].Err = resp.err(); rr[i].Err == nil {
+			rr[i].Err = json.Unmarshal(resp.Result, &rr[i].Payment)
+		}
+		remaining--
+		return remaining == 0
+	}); err != nil && remaining < len(ids) {
+		// some of the payments were answered, the rest have ErrTimeout as their error.
+		err = nil
+	}
+	return
+}
+
+// MakeInvoice asks the wallet for an invoice for an amount, with a description and an expiry
+// in seconds, which are omitted if they are empty or zero.
+func (cl *Client) MakeInvoice(c context.T, amount Msat, description string,
+	expiry int) (tx *Transaction, err error) {
+	return cl.MakeInvoiceWith(c, MakeInvoiceParams{Amount: amount,
+		Description: description, Expiry: expiry})
+}
+
+// MakeInvoiceWith asks the wallet for an invoice with all the parameters of make_invoice.
+func (cl *Client) MakeInvoiceWith(c context.T, p MakeInvoiceParams) (tx *Transaction,
+	err error) {
+	tx = &Transaction{}
+	err = cl.RPC(c, Methods.MakeInvoice, p, tx)
+	return
+}
+
+// LookupInvoice asks the wallet for the state of the invoice with a payment hash.
+func (cl *Client) LookupInvoice(c context.T, paymentHash string) (tx *Transaction,
+	err error) {
+	return cl.LookupInvoiceWith(c, LookupInvoiceParams{PaymentHash: paymentHash})
+}
+
+// LookupInvoiceWith asks the wallet for the state of an invoice identified by its payment
+// hash or the invoice itself.
+func (cl *Client) LookupInvoiceWith(c context.T, p LookupInvoiceParams) (tx *Transaction,
+	err error) {
+	tx = &Transaction{}
+	err = cl.RPC(c, Methods.LookupInvoice, p, tx)
+	return
+}
+
+// ListTransactions returns the invoices and payments of the wallet selected by the
+// parameters.
+func (cl *Client) ListTransactions(c context.T, p ListTransactionsParams) (txs []Transaction,
+	err error) {
+	var res struct {
+		Transactions []Transaction `json:"transactions"`
+	}
+	if err = cl.RPC(c, Methods.ListTransactions, p, &res); err != nil {
+		return
+	}
+	return res.Transactions, nil
+}
+
+// GetBalance returns the balance of the wallet.
+func (cl *Client) GetBalance(c context.T) (balance Msat, err error) {
+	var res struct {
+		Balance Msat `json:"balance"`
+	}
+	if err = cl.RPC(c, Methods.GetBalance, struct{}{}, &res); err != nil {
+		return
+	}
+	return res.Balance, nil
+}
+
+// GetInfo returns the information about the wallet node and the methods the connection may use.
+func (cl *Client) GetInfo(c context.T) (info *Info, err error) {
+	info = &Info{}
+	err = cl.RPC(c, Methods.GetInfo, struct{}{}, info)
+	return
+}
@@ -0,0 +1,271 @@
+package nwc
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"realy.lol/context"
+	"realy.lol/hex"
+)
+
+// MockPrefix is the prefix of the invoices made by a MockWallet, which are followed by the
+// amount in millisatoshis, a 1 and the hex payment hash.
+const MockPrefix = "lnmock"
+
+// MockWallet is a deterministic in-memory Wallet, for testing NWC flows without a Lightning
+// node. The preimages and payment hashes of its invoices and payments are derived from a
+// counter, and invoices it made are settled when they are paid through it or with Settle.
+type MockWallet struct {
+	// Now returns the unix time used for timestamps, the system clock if it is nil.
+	Now     func() int64
+	mx      sync.Mutex
+	balance Msat
+	n       uint64
+	// txs are the transactions in the order they were made.
+	txs []*Transaction
+}
+
+// NewMockWallet creates a MockWallet with a balance.
+func NewMockWallet(balance Msat) *MockWallet { return &MockWallet{balance: balance} }
+
+var _ Wallet = (*MockWallet)(nil)
+
+func (m *MockWallet) now() int64 {
+	if m.Now != nil {
+		return m.Now()
+	}
+	return time.Now().Unix()
+}
+
+// next returns the next preimage and its payment hash.
+func (m *MockWallet) next() (preimage, hash string) {
+	m.n++
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, m.n)
+	p := sha256.Sum256(append([]byte("mock preimage"), b...))
+	h := sha256.Sum256(p[:])
+	return hex.Enc(p[:]), hex.Enc(h[:])
+}
+
+// find returns the transaction of a direction with a payment hash or invoice.
+func (m *MockWallet) find(typ, paymentHash, invoice string) *Transaction {
+	for _, tx := range m.txs {
+		if tx.Type == typ && ((paymentHash != "" && tx.PaymentHash == paymentHash) ||
+			(invoice != "" && tx.Invoice == invoice)) {
+			return tx
+		}
+	}
+	return nil
+}
+
+// InvoiceAmount returns the amount of an invoice made by a MockWallet.
+func (m *MockWallet) InvoiceAmount(invoice string) (amount Msat, err error) {
+	if !strings.HasPrefix(invoice, MockPrefix) {
+		err = NewError(Errors.Other, "not a mock invoice")
+		return
+	}
+	a, _, ok := splitMock(invoice)
+	var u uint64
+	if u, err = strconv.ParseUint(a, 10, 64); !ok || err != nil {
+		err = NewError(Errors.Other, "invalid mock invoice")
+		return
+	}
+	return Msat(u), nil
+}
+
+// splitMock returns the amount and payment hash of a mock invoice.
+func splitMock(invoice string) (amount, hash string, ok bool) {
+	s := strings.TrimPrefix(invoice, MockPrefix)
+	// the payment hash is the last 64 characters, after the 1.
+	if len(s) < 66 || s[len(s)-65] != '1' {
+		return
+	}
+	return s[:len(s)-65], s[len(s)-64:], true
+}
+
+// Settle marks an invoice made by the wallet as paid from outside, adding its amount to the
+// balance.
+func (m *MockWallet) Settle(paymentHash string) (err error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	return m.settle(m.find("incoming", paymentHash, ""))
+}
+
+func (m *MockWallet) settle(tx *Transaction) (err error) {
+	if tx == nil {
+		return NewError(Errors.NotFound, "invoice not found")
+	}
+	if tx.Settled() {
+		return NewError(Errors.Other, "invoice already paid")
+	}
+	tx.SettledAt = m.now()
+	m.balance += tx.Amount
+	return
+}
+
+// pay takes a payment from the balance and records it.
+func (m *MockWallet) pay(amount Msat, invoice, preimage, hash string) (pm *Payment, err error) {
+	if amount == 0 {
+		err = NewError(Errors.Other, "no amount")
+		return
+	}
+	if amount > m.balance {
+		err = NewError(Errors.InsufficientBalance, "balance is "+strconv.FormatUint(
+			uint64(m.balance), 10)+" msats")
+		return
+	}
+	m.balance -= amount
+	now := m.now()
+	m.txs = append(m.txs, &Transaction{Type: "outgoing", Invoice: invoice, Preimage: preimage,
+		PaymentHash: hash, Amount: amount, CreatedAt: now, SettledAt: now})
+	return &Payment{Preimage: preimage}, nil
+}
+
+// PayInvoice pays a mock invoice, settling it if the wallet made it.
+func (m *MockWallet) PayInvoice(_ context.T, p PayInvoiceParams) (pm *Payment, err error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	amount := p.Amount
+	if amount == 0 {
+		if amount, err = m.InvoiceAmount(p.Invoice); err != nil {
+			return
+		}
+	}
+	if m.find("outgoing", "", p.Invoice) != nil {
+		err = NewError(Errors.PaymentFailed, "invoice already paid")
+		return
+	}
+	_, hash, _ := splitMock(p.Invoice)
+	incoming := m.find("incoming", "", p.Invoice)
+	if incoming != nil && incoming.Settled() {
+		err = NewError(Errors.PaymentFailed, "invoice already paid")
+		return
+	}
+	preimage := ""
+	if incoming != nil {
+		preimage = incoming.Preimage
+	}
+	if pm, err = m.pay(amount, p.Invoice, preimage, hash); err != nil {
+		return
+	}
+	if incoming != nil {
+		err = m.settle(incoming)
+	}
+	return
+}
+
+// PayKeysend sends a keysend payment, with a preimage derived from the counter if it has none.
+func (m *MockWallet) PayKeysend(_ context.T, p PayKeysendParams) (pm *Payment, err error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	preimage, hash := p.Preimage, ""
+	if preimage == "" {
+		preimage, hash = m.next()
+	} else {
+		var b []byte
+		if b, err = hex.Dec(preimage); err != nil {
+			err = NewError(Errors.Other, "invalid preimage")
+			return
+		}
+		h := sha256.Sum256(b)
+		hash = hex.Enc(h[:])
+	}
+	return m.pay(p.Amount, "", preimage, hash)
+}
+
+// MakeInvoice makes a mock invoice.
+func (m *MockWallet) MakeInvoice(_ context.T, p MakeInvoiceParams) (tx *Transaction,
+	err error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	if p.Amount == 0 {
+		err = NewError(Errors.Other, "no amount")
+		return
+	}
+	preimage, hash := m.next()
+	now := m.now()
+	expiry := int64(p.Expiry)
+	if expiry == 0 {
+		expiry = 86400
+	}
+	tx = &Transaction{Type: "incoming",
+		Invoice:     fmt.Sprintf("%s%d1%s", MockPrefix, p.Amount, hash),
+		Description: p.Description, DescriptionHash: p.DescriptionHash, Preimage: preimage,
+		PaymentHash: hash, Amount: p.Amount, CreatedAt: now, ExpiresAt: now + expiry}
+	m.txs = append(m.txs, tx)
+	c := *tx
+	c.Preimage = ""
+	return &c, nil
+}
+
+// LookupInvoice returns the state of an invoice the wallet made or paid, with its preimage
+// once it is settled.
+func (m *MockWallet) LookupInvoice(_ context.T, p LookupInvoiceParams) (tx *Transaction,
+	err error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	found := m.find("incoming", p.PaymentHash, p.Invoice)
+	if found == nil {
+		found = m.find("outgoing", p.PaymentHash, p.Invoice)
+	}
+	if found == nil {
+		err = NewError(Errors.NotFound, "invoice not found")
+		return
+	}
+	c := *found
+	if !c.Settled() {
+		c.Preimage = ""
+	}
+	return &c, nil
+}
+
+// ListTransactions returns the transactions selected by the parameters, newest first.
+func (m *MockWallet) ListTransactions(_ context.T, p ListTransactionsParams) (txs []Transaction,
+	err error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	skipped := 0
+	for i := len(m.txs) - 1; i >= 0; i-- {
+		tx := *m.txs[i]
+		switch {
+		case p.From > 0 && tx.CreatedAt < p.From, p.Until > 0 && tx.CreatedAt > p.Until,
+			p.Type != "" && tx.Type != p.Type, !p.Unpaid && !tx.Settled():
+			continue
+		}
+		if skipped < p.Offset {
+			skipped++
+			continue
+		}
+		if p.Limit > 0 && len(txs) >= p.Limit {
+			break
+		}
+		if !tx.Settled() {
+			tx.Preimage = ""
+		}
+		txs = append(txs, tx)
+	}
+	return
+}
+
+// GetBalance returns the balance of the wallet.
+func (m *MockWallet) GetBalance(context.T) (balance Msat, err error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	return m.balance, nil
+}
+
+// GetInfo describes the mock node.
+func (m *MockWallet) GetInfo(context.T) (info *Info, err error) {
+	methods := make([]string, len(AllMethods))
+	for i, mt := range AllMethods {
+		methods[i] = string(mt)
+	}
+	h := sha256.Sum256([]byte("mock node"))
+	return &Info{Alias: "mock", Color: "#000000", Pubkey: "02" + hex.Enc(h[:]),
+		Network: "regtest", Methods: methods}, nil
+}
@@ -114,6 +114,10 @@ var Errors = struct {
 	Restricted,
 	// Unauthorized - This public key has no wallet connected.
 	Unauthorized,
+	// NotFound - The invoice could not be found by the given parameters.
+	NotFound,
+	// PaymentFailed - The payment failed, which may be because of a timeout or no route.
+	PaymentFailed,
 	// Internal - An internal error.
 	Internal,
 	// Other - Other error.
@@ -125,6 +129,8 @@ var Errors = struct {
 	[]byte("QUOTA_EXCEEDED"),
 	[]byte("RESTRICTED"),
 	[]byte("UNAUTHORIZED"),
+	[]byte("NOT_FOUND"),
+	[]byte("PAYMENT_FAILED"),
 	[]byte("INTERNAL"),
 	[]byte("OTHER"),
 }
@@ -0,0 +1,493 @@
+package nwc
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"realy.lol/chk"
+	"realy.lol/context"
+	"realy.lol/encryption"
+	"realy.lol/errorf"
+	"realy.lol/event"
+	"realy.lol/filter"
+	"realy.lol/filters"
+	"realy.lol/hex"
+	"realy.lol/kind"
+	"realy.lol/kinds"
+	"realy.lol/log"
+	"realy.lol/p256k"
+	"realy.lol/tag"
+	"realy.lol/tags"
+	"realy.lol/timestamp"
+	"realy.lol/ws"
+)
+
+// Wallet is the backend of a Service, which does the work of the requests. The errors it
+// returns are passed on to the client with their code if they are an *Error, and as INTERNAL
+// errors otherwise.
+type Wallet interface {
+	PayInvoice(c context.T, p PayInvoiceParams) (pm *Payment, err error)
+	PayKeysend(c context.T, p PayKeysendParams) (pm *Payment, err error)
+	MakeInvoice(c context.T, p MakeInvoiceParams) (tx *Transaction, err error)
+	LookupInvoice(c context.T, p LookupInvoiceParams) (tx *Transaction, err error)
+	ListTransactions(c context.T, p ListTransactionsParams) (txs []Transaction, err error)
+	GetBalance(c context.T) (balance Msat, err error)
+	GetInfo(c context.T) (info *Info, err error)
+	// InvoiceAmount returns the amount of an invoice, or zero if it does not have one, so that
+	// the payment can be checked against the budget of the connection before it is made.
+	InvoiceAmount(invoice string) (amount Msat, err error)
+}
+
+// AllMethods are the methods a Service supports, which a Connection may use if it does not
+// list the methods it is permitted.
+var AllMethods = [][]byte{
+	Methods.PayInvoice,
+	Methods.MultiPayInvoice,
+	Methods.PayKeysend,
+	Methods.MultiPayKeysend,
+	Methods.MakeInvoice,
+	Methods.LookupInvoice,
+	Methods.ListTransactions,
+	Methods.GetBalance,
+	Methods.GetInfo,
+}
+
+// Connection is a client that may send requests to a Service, with what it is permitted to do.
+type Connection struct {
+	// Pubkey is the pubkey the client signs its requests with.
+	Pubkey []byte
+	// Methods are the methods the client may use, all of them if it is empty.
+	Methods []string
+	// Budget is how much the client may spend in a budget period, unlimited if it is zero.
+	Budget Msat
+	// BudgetPeriod is how often the spending is reset, never if it is zero.
+	BudgetPeriod time.Duration
+	// ExpiresAt is the unix time after which the connection may no longer be used, never if it
+	// is zero.
+	ExpiresAt int64
+	spent     Msat
+	periodEnd time.Time
+}
+
+// permits returns true if the connection may use a method.
+func (cn *Connection) permits(method string) bool {
+	return len(cn.Methods) == 0 || slices.Contains(cn.Methods, method)
+}
+
+// spend adds an amount to the spending of the current budget period if it is within the
+// budget, resetting the spending first if the period has ended.
+func (cn *Connection) spend(amount Msat, now time.Time) (ok bool) {
+	if cn.BudgetPeriod > 0 && !now.Before(cn.periodEnd) {
+		cn.spent, cn.periodEnd = 0, now.Add(cn.BudgetPeriod)
+	}
+	if cn.Budget > 0 && cn.spent+amount > cn.Budget {
+		return false
+	}
+	cn.spent += amount
+	return true
+}
+
+// Service is a NIP-47 wallet service, which answers the kind 23194 requests addressed to its
+// key from its connections with kind 23195 responses, doing their work with a Wallet.
+type Service struct {
+	Wallet Wallet
+	// Relay is the relay the service listens for requests on.
+	Relay string
+	// Encryption are the encryption schemes the service advertises, NIP44 and NIP04 by default.
+	Encryption []string
+	sign       *p256k.Signer
+	mx         sync.Mutex
+	conns      map[string]*Connection
+}
+
+// NewService creates a Service with a secret key, listening on a relay.
+func NewService(sec []byte, relay string, w Wallet) (s *Service, err error) {
+	s = &Service{Wallet: w, Relay: relay, Encryption: []string{NIP44, NIP04},
+		sign: &p256k.Signer{}, conns: make(map[string]*Connection)}
+	if err = s.sign.InitSec(sec); chk.E(err) {
+		return
+	}
+	return
+}
+
+// Pubkey returns the pubkey of the service, which its connections send requests to.
+func (s *Service) Pubkey() []byte { return s.sign.Pub() }
+
+// AddConnection permits a client to send requests to the service.
+func (s *Service) AddConnection(cn *Connection) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.conns[string(cn.Pubkey)] = cn
+}
+
+// RemoveConnection revokes a connection.
+func (s *Service) RemoveConnection(pubkey []byte) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	delete(s.conns, string(pubkey))
+}
+
+// NewConnection creates a client key, adds a connection for it with the permissions of cn and
+// returns the nostr+walletconnect:// URI the client connects with.
+func (s *Service) NewConnection(cn *Connection) (uri string, err error) {
+	client := &p256k.Signer{}
+	if err = client.Generate(); chk.E(err) {
+		return
+	}
+	cn.Pubkey = client.Pub()
+	s.AddConnection(cn)
+	uri = Scheme + "://" + hex.Enc(s.Pubkey()) + "?relay=" + s.Relay + "&secret=" +
+		hex.Enc(client.Sec())
+	return
+}
+
+// InfoEvent returns the kind 13194 info event of the service, advertising its methods and
+// encryption schemes.
+func (s *Service) InfoEvent() (ev *event.T, err error) {
+	methods := make([]string, len(AllMethods))
+	for i, m := range AllMethods {
+		methods[i] = string(m)
+	}
+	ev = &event.T{
+		Content:   []byte(strings.Join(methods, " ")),
+		CreatedAt: timestamp.Now(),
+		Kind:      kind.WalletInfo,
+		Tags:      tags.New(tag.New("encryption", strings.Join(s.Encryption, " "))),
+	}
+	if err = ev.Sign(s.sign); chk.E(err) {
+		return
+	}
+	return
+}
+
+// request is a decrypted kind 23194 request.
+type request struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+// result is the content of a response, before it is encrypted.
+type result struct {
+	ResultType string     `json:"result_type"`
+	Error      *wireError `json:"error,omitempty"`
+	Result     any        `json:"result,omitempty"`
+}
+
+// answer is a result with the id of the payment of a multi payment request it is about.
+type answer struct {
+	d string
+	result
+}
+
+// newAnswer makes the answer to a request with the outcome of the work it asked for.
+func newAnswer(method, d string, res any, err error) (a answer) {
+	a = answer{d: d, result: result{ResultType: method}}
+	if err == nil {
+		a.Result = res
+		return
+	}
+	var e *Error
+	if !errors.As(err, &e) {
+		e = NewError(Errors.Internal, err.Error())
+	}
+	a.Error = &wireError{string(e.Code), string(e.Message)}
+	return
+}
+
+// Handle answers a request event, returning the signed response events, which is one for each
+// payment of multi payment requests and one for every other request. Events that are not
+// requests to the service, have expired or cannot be decrypted are not answered.
+func (s *Service) Handle(c context.T, ev *event.T) (resps []*event.T) {
+	if !ev.Kind.Equal(kind.WalletRequest) {
+		return
+	}
+	if p := ev.Tags.GetFirst(tag.New("p")); p == nil || p.Len() < 2 ||
+		p.S(1) != hex.Enc(s.Pubkey()) {
+		return
+	}
+	if exp := ev.Tags.GetFirst(tag.New("expiration")); exp != nil && exp.Len() > 1 {
+		if ts, err := strconv.ParseInt(exp.S(1), 10, 64); err == nil && ts < time.Now().Unix() {
+			return
+		}
+	}
+	scheme := NIP04
+	if t := ev.Tags.GetFirst(tag.New("encryption")); t != nil && t.Len() > 1 {
+		scheme = t.S(1)
+	}
+	var key []byte
+	var err error
+	switch scheme {
+	case NIP44:
+		key, err = encryption.GenerateConversationKey(ev.Pubkey, s.sign.Sec())
+	case NIP04:
+		key, err = encryption.ComputeSharedSecret(ev.Pubkey, s.sign.Sec())
+	default:
+		log.D.F("nwc request %0x with unsupported encryption %s", ev.Id, scheme)
+		return
+	}
+	if chk.E(err) {
+		return
+	}
+	var plain []byte
+	if scheme == NIP44 {
+		plain, err = encryption.Decrypt(ev.Content, key)
+	} else {
+		plain, err = encryption.DecryptNip4(ev.Content, key)
+	}
+	if chk.D(err) {
+		return
+	}
+	var req request
+	if err = json.Unmarshal(plain, &req); chk.D(err) {
+		return
+	}
+	for _, a := range s.dispatch(c, ev.Pubkey, &req) {
+		var b, content []byte
+		if b, err = json.Marshal(a.result); chk.E(err) {
+			continue
+		}
+		if scheme == NIP44 {
+			content, err = encryption.Encrypt(b, key)
+		} else {
+			content, err = encryption.EncryptNip4(b, key)
+		}
+		if chk.E(err) {
+			continue
+		}
+		resp := &event.T{
+			Content:   content,
+			CreatedAt: timestamp.Now(),
+			Kind:      kind.WalletResponse,
+			Tags: tags.New(tag.New("p", hex.Enc(ev.Pubkey)),
+				tag.New("e", hex.Enc(ev.Id))),
+		}
+		if a.d != "" {
+			resp.Tags.AppendTags(tag.New("d", a.d))
+		}
+		if err = resp.Sign(s.sign); chk.E(err) {
+			continue
+		}
+		resps = append(resps, resp)
+	}
+	return
+}
+
+// dispatch checks that the client may make a request and does its work with the Wallet.
+func (s *Service) dispatch(c context.T, pubkey []byte, req *request) (aa []answer) {
+	s.mx.Lock()
+	cn, ok := s.conns[string(pubkey)]
+	s.mx.Unlock()
+	fail := func(code []byte, msg string) []answer {
+		return []answer{newAnswer(req.Method, "", nil, NewError(code, msg))}
+	}
+	switch {
+	case !ok:
+		return fail(Errors.Unauthorized, "no wallet connected to this key")
+	case cn.ExpiresAt > 0 && cn.ExpiresAt < time.Now().Unix():
+		return fail(Errors.Unauthorized, "the connection has expired")
+	case !slices.ContainsFunc(AllMethods, func(m []byte) bool { return string(m) == req.Method }):
+		return fail(Errors.NotImplemented, "unknown method "+req.Method)
+	case !cn.permits(req.Method):
+		return fail(Errors.Restricted, "the connection may not use "+req.Method)
+	}
+	w := s.Wallet
+	switch req.Method {
+	case string(Methods.PayInvoice):
+		var p PayInvoiceParams
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			return fail(Errors.Other, "invalid params")
+		}
+		pm, err := s.payInvoice(c, cn, p)
+		return []answer{newAnswer(req.Method, "", pm, err)}
+	case string(Methods.MultiPayInvoice):
+		var p struct {
+			Invoices []PayInvoiceParams `json:"invoices"`
+		}
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			return fail(Errors.Other, "invalid params")
+		}
+		for _, inv := range p.Invoices {
+			pm, err := s.payInvoice(c, cn, inv)
+			aa = append(aa, newAnswer(req.Method, inv.Id, pm, err))
+		}
+		return
+	case string(Methods.PayKeysend):
+		var p PayKeysendParams
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			return fail(Errors.Other, "invalid params")
+		}
+		pm, err := s.payKeysend(c, cn, p)
+		return []answer{newAnswer(req.Method, "", pm, err)}
+	case string(Methods.MultiPayKeysend):
+		var p struct {
+			Keysends []PayKeysendParams `json:"keysends"`
+		}
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			return fail(Errors.Other, "invalid params")
+		}
+		for _, k := range p.Keysends {
+			pm, err := s.payKeysend(c, cn, k)
+			aa = append(aa, newAnswer(req.Method, k.Id, pm, err))
+		}
+		return
+	case string(Methods.MakeInvoice):
+		var p MakeInvoiceParams
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			return fail(Errors.Other, "invalid params")
+		}
+		tx, err := w.MakeInvoice(c, p)
+		return []answer{newAnswer(req.Method, "", tx, err)}
+	case string(Methods.LookupInvoice):
+		var p LookupInvoiceParams
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			return fail(Errors.Other, "invalid params")
+		}
+		tx, err := w.LookupInvoice(c, p)
+		return []answer{newAnswer(req.Method, "", tx, err)}
+	case string(Methods.ListTransactions):
+		var p ListTransactionsParams
+		if len(req.Params) > 0 {
+			if err := json.Unmarshal(req.Params, &p); err != nil {
+				return fail(Errors.Other, "invalid params")
+			}
+		}
+		txs, err := w.ListTransactions(c, p)
+		if txs == nil {
+			txs = []Transaction{}
+		}
+		return []answer{newAnswer(req.Method, "",
+			map[string][]Transaction{"transactions": txs}, err)}
+	case string(Methods.GetBalance):
+		balance, err := w.GetBalance(c)
+		return []answer{newAnswer(req.Method, "", map[string]Msat{"balance": balance}, err)}
+	default:
+		info, err := w.GetInfo(c)
+		if err == nil {
+			// the methods are those the connection may use.
+			info.Methods = nil
+			for _, m := range AllMethods {
+				if cn.permits(string(m)) {
+					info.Methods = append(info.Methods, string(m))
+				}
+			}
+		}
+		return []answer{newAnswer(req.Method, "", info, err)}
+	}
+}
+
+// payInvoice pays an invoice if its amount is within the budget of the connection. The amount
+// is that of the invoice, and the amount in the request is only used for invoices that do not
+// have one, so that a client cannot pay more than it is charged. The amount is given back to
+// the budget if the payment fails.
+func (s *Service) payInvoice(c context.T, cn *Connection, p PayInvoiceParams) (pm *Payment,
+	err error) {
+	var amount Msat
+	if amount, err = s.Wallet.InvoiceAmount(p.Invoice); err != nil {
+		return
+	}
+	switch {
+	case amount == 0:
+		amount = p.Amount
+	case p.Amount != 0 && p.Amount != amount:
+		err = NewError(Errors.Other, "the amount differs from the amount of the invoice")
+		return
+	}
+	if err = s.spend(cn, amount); err != nil {
+		return
+	}
+	if pm, err = s.Wallet.PayInvoice(c, p); err != nil {
+		s.refund(cn, amount)
+	}
+	return
+}
+
+// payKeysend sends a keysend payment if its amount is within the budget of the connection.
+func (s *Service) payKeysend(c context.T, cn *Connection, p PayKeysendParams) (pm *Payment,
+	err error) {
+	if err = s.spend(cn, p.Amount); err != nil {
+		return
+	}
+	if pm, err = s.Wallet.PayKeysend(c, p); err != nil {
+		s.refund(cn, p.Amount)
+	}
+	return
+}
+
+// spend takes an amount from the budget of a connection, returning a QUOTA_EXCEEDED error if
+// it is not within it.
+func (s *Service) spend(cn *Connection, amount Msat) (err error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if !cn.spend(amount, time.Now()) {
+		err = NewError(Errors.QuotaExceeded, "the payment exceeds the budget of the connection")
+	}
+	return
+}
+
+// refund gives back an amount taken from the budget of a connection for a failed payment.
+func (s *Service) refund(cn *Connection, amount Msat) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	cn.spent -= min(amount, cn.spent)
+}
+
+// Run publishes the info event of the service to its relay and answers the requests sent to
+// it there until the context is canceled, reconnecting if the connection to the relay is lost.
+func (s *Service) Run(c context.T) {
+	for c.Err() == nil {
+		if err := s.serve(c); err != nil {
+			log.E.F("nwc service on %s: %v", s.Relay, err)
+		}
+		select {
+		case <-c.Done():
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+// serve answers the requests to the service on its relay until the connection is lost.
+func (s *Service) serve(c context.T) (err error) {
+	var relay *ws.Client
+	if relay, err = ws.RelayConnect(c, s.Relay); err != nil {
+		return
+	}
+	defer relay.Close()
+	var info *event.T
+	if info, err = s.InfoEvent(); err != nil {
+		return
+	}
+	if err = relay.Publish(c, info); err != nil {
+		return errorf.E("publishing info event: %v", err)
+	}
+	var sub *ws.Subscription
+	if sub, err = relay.Subscribe(c, filters.New(&filter.T{
+		Kinds: kinds.New(kind.WalletRequest),
+		Tags:  tags.New(tag.New([]byte("#p"), s.Pubkey())),
+		Since: timestamp.Now(),
+	}), ws.WithLabel("nwc-service")); err != nil {
+		return
+	}
+	defer sub.Unsub()
+	for {
+		select {
+		case <-c.Done():
+			return
+		case <-relay.Context().Done():
+			return errorf.E("connection lost")
+		case ev := <-sub.Events:
+			if ev == nil {
+				return errorf.E("subscription closed")
+			}
+			for _, resp := range s.Handle(c, ev) {
+				if err = relay.Publish(c, resp); err != nil {
+					log.E.F("publishing nwc response: %v", err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package nwc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"realy.lol/event"
+	"realy.lol/p256k"
+	"realy.lol/ws/wstest"
+)
+
+// newService starts a Service with a MockWallet behind a fake relay.
+func newService(t *testing.T, balance Msat) (s *Service, mock *MockWallet) {
+	sign := &p256k.Signer{}
+	if err := sign.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	mock = NewMockWallet(balance)
+	r := &wstest.Relay{}
+	var err error
+	if s, err = NewService(sign.Sec(), "", mock); err != nil {
+		t.Fatal(err)
+	}
+	r.Respond = func(ev *event.T) []*event.T { return s.Handle(context.Background(), ev) }
+	info, err := s.InfoEvent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Stored = append(r.Stored, info)
+	s.Relay = r.Start(t)
+	return
+}
+
+// connect adds a connection to a Service and returns a Client using it.
+func connect(t *testing.T, s *Service, cn *Connection) (cl *Client) {
+	uri, err := s.NewConnection(cn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cl, err = NewClient(uri); err != nil {
+		t.Fatal(err)
+	}
+	cl.Timeout = 2 * time.Second
+	t.Cleanup(cl.Close)
+	return
+}
+
+func TestService(t *testing.T) {
+	c := context.Background()
+	s, mock := newService(t, 50000)
+	merchant := connect(t, s, &Connection{Methods: []string{string(Methods.MakeInvoice),
+		string(Methods.LookupInvoice)}})
+	payer := connect(t, s, &Connection{Budget: 30000, BudgetPeriod: time.Hour})
+	caps, err := payer.Capabilities(c)
+	if err != nil || !caps.Supports(Methods.MultiPayKeysend) || caps.Encryption[0] != NIP44 {
+		t.Fatalf("capabilities %+v %v", caps, err)
+	}
+	tx, err := merchant.MakeInvoice(c, 21000, "coffee", 600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = merchant.GetBalance(c); !errors.Is(err, NewError(Errors.Restricted, "")) {
+		t.Fatalf("expected RESTRICTED, got %v", err)
+	}
+	if tx, err = merchant.LookupInvoice(c, tx.PaymentHash); err != nil || tx.Settled() {
+		t.Fatalf("invoice %+v %v", tx, err)
+	}
+	pm, err := payer.PayInvoice(c, PayInvoiceParams{Invoice: tx.Invoice})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx, err = merchant.LookupInvoice(c, tx.PaymentHash); err != nil || !tx.Settled() ||
+		tx.Preimage != pm.Preimage {
+		t.Fatalf("paid invoice %+v %v", tx, err)
+	}
+	// 21000 of the budget of 30000 is spent, so only one of these fits in it.
+	rr, err := payer.MultiPayKeysend(c, []PayKeysendParams{
+		{Id: "a", Amount: 5000, Pubkey: "02"}, {Id: "b", Amount: 5000, Pubkey: "02"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rr[0].Err != nil || !errors.Is(rr[1].Err, NewError(Errors.QuotaExceeded, "")) {
+		t.Fatalf("multi keysend results %+v", rr)
+	}
+	// a self payment leaves the balance as it was, less the keysend.
+	if balance, err := payer.GetBalance(c); err != nil || balance != 45000 {
+		t.Fatalf("balance %d %v", balance, err)
+	}
+	txs, err := payer.ListTransactions(c, ListTransactionsParams{Type: "outgoing"})
+	if err != nil || len(txs) != 2 || txs[0].Amount != 5000 {
+		t.Fatalf("transactions %+v %v", txs, err)
+	}
+	info, err := merchant.GetInfo(c)
+	if !errors.Is(err, NewError(Errors.Restricted, "")) {
+		t.Fatalf("expected RESTRICTED, got %+v %v", info, err)
+	}
+	if _, err = payer.GetInfo(c); err != nil {
+		t.Fatal(err)
+	}
+	s.RemoveConnection(payer.sign.Pub())
+	if _, err = payer.GetBalance(c); !errors.Is(err, NewError(Errors.Unauthorized, "")) {
+		t.Fatalf("expected UNAUTHORIZED, got %v", err)
+	}
+	if _, err = mock.LookupInvoice(c, LookupInvoiceParams{PaymentHash: "00"}); !errors.Is(err,
+		NewError(Errors.NotFound, "")) {
+		t.Fatalf("expected NOT_FOUND, got %v", err)
+	}
+}
+
+func TestServicePayInvoiceAmount(t *testing.T) {
+	c := context.Background()
+	s, _ := newService(t, 50000)
+	payer := connect(t, s, &Connection{Budget: 10000, BudgetPeriod: time.Hour})
+	tx, err := payer.MakeInvoice(c, 20000, "", 600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// an amount within the budget cannot be charged for an invoice of a larger one.
+	if _, err = payer.PayInvoice(c, PayInvoiceParams{Invoice: tx.Invoice,
+		Amount: 1000}); !errors.Is(err, NewError(Errors.Other, "")) {
+		t.Fatalf("expected OTHER, got %v", err)
+	}
+	if _, err = payer.PayInvoice(c, PayInvoiceParams{Invoice: tx.Invoice,
+		Amount: 20000}); !errors.Is(err, NewError(Errors.QuotaExceeded, "")) {
+		t.Fatalf("expected QUOTA_EXCEEDED, got %v", err)
+	}
+	if tx, err = payer.MakeInvoice(c, 10000, "", 600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = payer.PayInvoice(c, PayInvoiceParams{Invoice: tx.Invoice,
+		Amount: 10000}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,59 @@
+package nwc
+
+import (
+	"net/url"
+
+	"realy.lol/errorf"
+	"realy.lol/hex"
+	"realy.lol/keys"
+)
+
+// Scheme is the URI scheme of a wallet connection.
+const Scheme = "nostr+walletconnect"
+
+// URI is a parsed nostr+walletconnect:// wallet connection.
+type URI struct {
+	// Wallet is the pubkey of the wallet service.
+	Wallet []byte
+	// Relays are the relays the wallet service listens for requests on.
+	Relays []string
+	// Secret is the secret key the client signs its requests with.
+	Secret []byte
+	// Lud16 is the lightning address of the wallet, if it has one.
+	Lud16 string
+}
+
+// ParseURI parses a nostr+walletconnect:// wallet connection URI.
+func ParseURI(s string) (u *URI, err error) {
+	var p *url.URL
+	if p, err = url.Parse(s); err != nil {
+		err = errorf.E("invalid wallet connection URI: %v", err)
+		return
+	}
+	if p.Scheme != Scheme {
+		err = errorf.E("wrong scheme '%s', must be %s://", p.Scheme, Scheme)
+		return
+	}
+	// the pubkey is the host, but some wallets write it as nostr+walletconnect:<pubkey>.
+	pk := p.Host
+	if pk == "" {
+		pk = p.Opaque
+	}
+	if !keys.IsValidPublicKey(pk) {
+		err = errorf.E("'%s' is not a valid wallet public key", pk)
+		return
+	}
+	u = &URI{Relays: p.Query()["relay"], Lud16: p.Query().Get("lud16")}
+	if u.Wallet, err = hex.Dec(pk); err != nil {
+		return
+	}
+	if len(u.Relays) == 0 {
+		err = errorf.E("wallet connection URI has no relay")
+		return
+	}
+	if u.Secret, err = hex.Dec(p.Query().Get("secret")); err != nil || len(u.Secret) != 32 {
+		err = errorf.E("wallet connection URI has no valid secret")
+		return
+	}
+	return
+}
@@ -0,0 +1,92 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/danielgtaylor/huma/v2"
+
+	"realy.lol/cdc"
+	"realy.lol/context"
+	"realy.lol/log"
+	"realy.lol/realy/helpers"
+)
+
+// ChangesInput is the parameters for the HTTP API Changes method.
+type ChangesInput struct {
+	Auth        string `header:"Authorization" doc:"nostr nip-98 (and expiring variant)" required:"true"`
+	LastEventId string `header:"Last-Event-ID" doc:"serial of the last change received, to resume after a disconnection" required:"false"`
+	From        uint64 `query:"from" doc:"serial of the first change to send, ignored if Last-Event-ID is given" required:"false"`
+}
+
+// RegisterChanges implements the Changes HTTP API method, a stream of every save and deletion
+// in the event store in serial order.
+//
+// Each change is a server sent event of type "change" with its serial as the event id, so a
+// client that is disconnected resumes after the last change it received by sending it back
+// in the Last-Event-ID header, as standard event source libraries do.
+func (x *Operations) RegisterChanges(api huma.API) {
+	name := "Changes"
+	description := "Stream every saved and deleted event in the order of the database serials, from a serial or resuming after the Last-Event-ID, as Server Sent Events (only works with NIP-98/JWT capable client, will not work with UI)"
+	path := x.path + "/changes"
+	scopes := []string{"admin", "read"}
+	method := http.MethodGet
+	huma.Register(api, huma.Operation{
+		OperationID: name,
+		Summary:     name,
+		Path:        path,
+		Method:      method,
+		Tags:        []string{"admin"},
+		Description: helpers.GenerateDescription(description, scopes),
+		Security:    []map[string][]string{{"auth": scopes}},
+	}, func(ctx context.T, input *ChangesInput) (resp *huma.StreamResponse, err error) {
+		r := ctx.Value("http-request").(*http.Request)
+		remote := helpers.GetRemoteFromReq(r)
+		authed, pubkey := x.AdminAuth(r, remote)
+		if !authed {
+			err = huma.Error401Unauthorized("Not Authorized")
+			return
+		}
+		src, ok := x.Storage().(cdc.Source)
+		if !ok {
+			err = huma.Error501NotImplemented("event store does not record changes")
+			return
+		}
+		from := input.From
+		if input.LastEventId != "" {
+			var last uint64
+			if last, err = strconv.ParseUint(input.LastEventId, 10, 64); err != nil {
+				err = huma.Error400BadRequest("invalid Last-Event-ID")
+				return
+			}
+			from = last + 1
+		}
+		log.I.F("%s streaming changes from %d to %0x", remote, from, pubkey)
+		resp = &huma.StreamResponse{
+			Body: func(hctx huma.Context) {
+				hctx.SetHeader("Content-Type", "text/event-stream")
+				hctx.SetHeader("Cache-Control", "no-cache")
+				w := hctx.BodyWriter()
+				flusher, _ := w.(http.Flusher)
+				err := cdc.Follow(r.Context(), src, from, func(ch cdc.Change) (err error) {
+					var b []byte
+					if b, err = json.Marshal(ch); err != nil {
+						return
+					}
+					if _, err = fmt.Fprintf(w, "id: %d\nevent: change\ndata: %s\n\n",
+						ch.Serial, b); err != nil {
+						return
+					}
+					if flusher != nil {
+						flusher.Flush()
+					}
+					return
+				})
+				log.D.F("%s changes stream ended: %v", remote, err)
+			},
+		}
+		return
+	})
+}
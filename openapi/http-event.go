@@ -17,6 +17,7 @@ import (
 	"realy.lol/ints"
 	"realy.lol/kind"
 	"realy.lol/log"
+	"realy.lol/policy"
 	"realy.lol/realy/helpers"
 	"realy.lol/sha256"
 	"realy.lol/tag"
@@ -78,14 +79,8 @@ func (x *Operations) RegisterEvent(api huma.API) {
 				return
 			}
 		}
-		// if there was auth, or no auth, check the relay policy allows accepting the
-		// event (no auth with auth required or auth not valid for action can apply
-		// here).
-		accept, notice, after := x.AcceptEvent(ctx, ev, r, pubkey, remote)
-		if !accept {
-			err = huma.Error401Unauthorized(notice)
-			return
-		}
+		// the event must be valid before the relay policy is applied to it, so that the
+		// outcome cannot be learned for events that were not signed by the sender.
 		if !bytes.Equal(ev.GetIDBytes(), ev.Id) {
 			err = huma.Error400BadRequest("event id is computed incorrectly")
 			return
@@ -97,6 +92,19 @@ func (x *Operations) RegisterEvent(api huma.API) {
 			err = huma.Error400BadRequest("signature is invalid")
 			return
 		}
+		// if there was auth, or no auth, check the relay policy allows accepting the
+		// event (no auth with auth required or auth not valid for action can apply
+		// here).
+		accept, notice, after := x.AcceptEvent(ctx, ev, r, pubkey, remote)
+		if !accept && notice == policy.Shadowed {
+			// the event is reported as saved but is neither stored nor delivered.
+			output = &EventOutput{"event accepted"}
+			return
+		}
+		if !accept {
+			err = huma.Error401Unauthorized(notice)
+			return
+		}
 		if ev.Kind.K == kind.Deletion.K {
 			log.I.F("delete event\n%s", ev.Serialize())
 			for _, t := range ev.Tags.ToSliceOfTags() {
@@ -206,7 +214,7 @@ func (x *Operations) RegisterEvent(api huma.API) {
 		} else {
 			err = huma.Error500InternalServerError(string(reason))
 		}
-		if after != nil {
+		if ok && after != nil {
 			// do this in the background and let the http response close
 			go after()
 		}
@@ -0,0 +1,246 @@
+package openapi
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+
+	"realy.lol/chk"
+	"realy.lol/context"
+	"realy.lol/dns"
+	"realy.lol/hex"
+	"realy.lol/httpauth"
+	"realy.lol/log"
+	"realy.lol/realy/helpers"
+	"realy.lol/realy/interfaces"
+	"realy.lol/store"
+)
+
+// NamesListInput is the parameters for the HTTP API NamesList method.
+type NamesListInput struct {
+	Auth string `header:"Authorization" doc:"nostr nip-98 (and expiring variant)" required:"true"`
+}
+
+// NamesListOutput is the result of the HTTP API NamesList method.
+type NamesListOutput struct {
+	Body []*dns.Name `doc:"the registered names"`
+}
+
+// NameSetInput is the parameters for the HTTP API NameSet method.
+type NameSetInput struct {
+	Auth string `header:"Authorization" doc:"nostr nip-98 (and expiring variant)" required:"true"`
+	Body struct {
+		Name   string `json:"name" doc:"the local part of the NIP-05 identifier, _ for the domain itself"`
+		Pubkey string `json:"pubkey" doc:"hex encoded public key the name resolves to"`
+	}
+}
+
+// NameDeleteInput is the parameters for the HTTP API NameDelete method.
+type NameDeleteInput struct {
+	Auth string `header:"Authorization" doc:"nostr nip-98 (and expiring variant)" required:"true"`
+	Name string `path:"name" doc:"the name to remove"`
+}
+
+// NameRegisterInput is the parameters for the HTTP API NameRegister method.
+type NameRegisterInput struct {
+	Auth string `header:"Authorization" doc:"nostr nip-98 (and expiring variant)" required:"true"`
+	Name string `path:"name" doc:"the name to register to the authorized pubkey"`
+}
+
+// NameOutput is the result of changing the registry of names.
+type NameOutput struct {
+	Body *dns.Name `doc:"the registered name"`
+}
+
+// names returns the registry of names, or an error if the relay does not serve one.
+func (x *Operations) names() (reg store.Namer, err error) {
+	var ok bool
+	if r, isRegistrar := x.Server.(interfaces.Registrar); isRegistrar {
+		reg, ok = r.Names()
+	}
+	if !ok {
+		err = huma.Error501NotImplemented("relay does not serve a registry of names")
+	}
+	return
+}
+
+// RegisterNamesList implements the HTTP API for listing the registered NIP-05 names.
+func (x *Operations) RegisterNamesList(api huma.API) {
+	name := "NamesList"
+	description := "List the names registered in the NIP-05 registry"
+	path := x.path + "/names"
+	scopes := []string{"admin", "read"}
+	method := http.MethodGet
+	huma.Register(api, huma.Operation{
+		OperationID: name,
+		Summary:     name,
+		Path:        path,
+		Method:      method,
+		Tags:        []string{"admin"},
+		Description: helpers.GenerateDescription(description, scopes),
+		Security:    []map[string][]string{{"auth": scopes}},
+	}, func(ctx context.T, input *NamesListInput) (output *NamesListOutput, err error) {
+		r := ctx.Value("http-request").(*http.Request)
+		remote := helpers.GetRemoteFromReq(r)
+		if authed, _ := x.AdminAuth(r, remote); !authed {
+			err = huma.Error401Unauthorized("authorization required")
+			return
+		}
+		var reg store.Namer
+		if reg, err = x.names(); err != nil {
+			return
+		}
+		var nn []*dns.Name
+		if nn, err = reg.Names(); chk.E(err) {
+			err = huma.Error500InternalServerError(err.Error())
+			return
+		}
+		slices.SortFunc(nn, func(a, b *dns.Name) int { return strings.Compare(a.Name, b.Name) })
+		output = &NamesListOutput{Body: append([]*dns.Name{}, nn...)}
+		return
+	})
+}
+
+// RegisterNameSet implements the HTTP API for an admin to register a NIP-05 name to any
+// pubkey, replacing any previous entry of the name.
+func (x *Operations) RegisterNameSet(api huma.API) {
+	name := "NameSet"
+	description := "Register a name in the NIP-05 registry to a pubkey"
+	path := x.path + "/names/set"
+	scopes := []string{"admin", "write"}
+	method := http.MethodPost
+	huma.Register(api, huma.Operation{
+		OperationID: name,
+		Summary:     name,
+		Path:        path,
+		Method:      method,
+		Tags:        []string{"admin"},
+		Description: helpers.GenerateDescription(description, scopes),
+		Security:    []map[string][]string{{"auth": scopes}},
+	}, func(ctx context.T, input *NameSetInput) (output *NameOutput, err error) {
+		r := ctx.Value("http-request").(*http.Request)
+		remote := helpers.GetRemoteFromReq(r)
+		authed, pubkey := x.AdminAuth(r, remote)
+		if !authed {
+			err = huma.Error401Unauthorized("authorization required")
+			return
+		}
+		var reg store.Namer
+		if reg, err = x.names(); err != nil {
+			return
+		}
+		n := &dns.Name{Name: input.Body.Name, Pubkey: input.Body.Pubkey,
+			Registered: time.Now().Unix()}
+		if err = n.Validate(); err != nil {
+			err = huma.Error400BadRequest(err.Error())
+			return
+		}
+		if err = reg.SetName(n); chk.E(err) {
+			err = huma.Error500InternalServerError(err.Error())
+			return
+		}
+		log.I.F("%s %0x registered name %s to %s", remote, pubkey, n.Name, n.Pubkey)
+		output = &NameOutput{Body: n}
+		return
+	})
+}
+
+// RegisterNameDelete implements the HTTP API for an admin to remove a NIP-05 name.
+func (x *Operations) RegisterNameDelete(api huma.API) {
+	name := "NameDelete"
+	description := "Remove a name from the NIP-05 registry"
+	path := x.path + "/names/delete/{name}"
+	scopes := []string{"admin", "write"}
+	method := http.MethodPost
+	huma.Register(api, huma.Operation{
+		OperationID: name,
+		Summary:     name,
+		Path:        path,
+		Method:      method,
+		Tags:        []string{"admin"},
+		Description: helpers.GenerateDescription(description, scopes),
+		Security:    []map[string][]string{{"auth": scopes}},
+	}, func(ctx context.T, input *NameDeleteInput) (wgh *struct{}, err error) {
+		r := ctx.Value("http-request").(*http.Request)
+		remote := helpers.GetRemoteFromReq(r)
+		authed, pubkey := x.AdminAuth(r, remote)
+		if !authed {
+			err = huma.Error401Unauthorized("authorization required")
+			return
+		}
+		var reg store.Namer
+		if reg, err = x.names(); err != nil {
+			return
+		}
+		var n string
+		if n, err = dns.NormalizeName(input.Name); err != nil {
+			err = huma.Error400BadRequest(err.Error())
+			return
+		}
+		if err = reg.DeleteName(n); chk.E(err) {
+			err = huma.Error500InternalServerError(err.Error())
+			return
+		}
+		log.I.F("%s %0x removed name %s", remote, pubkey, n)
+		return
+	})
+}
+
+// RegisterNameRegister implements the HTTP API for a user to register a NIP-05 name for
+// themselves, if the relay permits it. A pubkey holds one name, so registering another one
+// releases the previous. The reserved names, such as _ for the domain itself, may only be
+// registered by an admin.
+func (x *Operations) RegisterNameRegister(api huma.API) {
+	name := "NameRegister"
+	description := "Register a name in the NIP-05 registry to the authorized pubkey, replacing the name it had; reserved names such as _ may only be registered by an admin"
+	path := x.path + "/names/register/{name}"
+	scopes := []string{"user", "write"}
+	method := http.MethodPost
+	huma.Register(api, huma.Operation{
+		OperationID: name,
+		Summary:     name,
+		Path:        path,
+		Method:      method,
+		Tags:        []string{"names"},
+		Description: helpers.GenerateDescription(description, scopes),
+		Security:    []map[string][]string{{"auth": scopes}},
+	}, func(ctx context.T, input *NameRegisterInput) (output *NameOutput, err error) {
+		r := ctx.Value("http-request").(*http.Request)
+		remote := helpers.GetRemoteFromReq(r)
+		var reg store.Namer
+		if reg, err = x.names(); err != nil {
+			return
+		}
+		var valid bool
+		var pubkey []byte
+		if valid, pubkey, err = httpauth.CheckAuth(r); err != nil || !valid ||
+			len(pubkey) == 0 {
+			err = huma.Error401Unauthorized("authorization required")
+			return
+		}
+		if !x.Server.(interfaces.Registrar).MayRegisterName(pubkey) {
+			err = huma.Error403Forbidden("pubkey may not register a name on this relay")
+			return
+		}
+		var n *dns.Name
+		if n, err = dns.Claim(reg, input.Name, hex.Enc(pubkey)); err != nil {
+			if errors.Is(err, dns.ErrNameTaken) {
+				err = huma.Error409Conflict(err.Error())
+				return
+			}
+			if errors.Is(err, dns.ErrNameReserved) {
+				err = huma.Error403Forbidden(err.Error())
+				return
+			}
+			err = huma.Error400BadRequest(err.Error())
+			return
+		}
+		log.I.F("%s %0x registered name %s", remote, pubkey, n.Name)
+		output = &NameOutput{Body: n}
+		return
+	})
+}
@@ -12,6 +12,7 @@ import (
 	"realy.lol/event"
 	"realy.lol/httpauth"
 	"realy.lol/log"
+	"realy.lol/policy"
 	"realy.lol/publish"
 	"realy.lol/realy/helpers"
 )
@@ -59,19 +60,13 @@ func (x *Operations) RegisterRelay(api huma.API) {
 			return
 		}
 		var ok bool
-		// if there was auth, or no auth, check the relay policy allows accepting the
-		// event (no auth with auth required or auth not valid for action can apply
-		// here).
 		ev := &event.T{}
 		if _, err = ev.Unmarshal(input.RawBody); chk.E(err) {
 			err = huma.Error406NotAcceptable(err.Error())
 			return
 		}
-		accept, notice, _ := x.AcceptEvent(ctx, ev, r, pubkey, remote)
-		if !accept {
-			err = huma.Error401Unauthorized(notice)
-			return
-		}
+		// the event must be valid before the relay policy is applied to it, so that the
+		// outcome cannot be learned for events that were not signed by the sender.
 		if !bytes.Equal(ev.GetIDBytes(), ev.Id) {
 			err = huma.Error400BadRequest("event id is computed incorrectly")
 			return
@@ -83,6 +78,17 @@ func (x *Operations) RegisterRelay(api huma.API) {
 			err = huma.Error400BadRequest("signature is invalid")
 			return
 		}
+		// if there was auth, or no auth, check the relay policy allows accepting the
+		// event (no auth with auth required or auth not valid for action can apply
+		// here).
+		accept, notice, _ := x.AcceptEvent(ctx, ev, r, pubkey, remote)
+		if !accept && notice == policy.Shadowed {
+			return
+		}
+		if !accept {
+			err = huma.Error401Unauthorized(notice)
+			return
+		}
 		var authRequired bool
 
 		authRequired = x.Server.AuthRequired()
@@ -1,7 +1,6 @@
 package openapi
 
 import (
-	"bytes"
 	"sync"
 
 	"realy.lol/context"
@@ -9,7 +8,6 @@ import (
 	"realy.lol/filter"
 	"realy.lol/publish"
 	"realy.lol/publish/publisher"
-	"realy.lol/tag"
 	"realy.lol/typer"
 )
 
@@ -87,15 +85,8 @@ func (p *S) Deliver(authRequired, publicReadable bool, ev *event.T) {
 			continue
 		}
 		// if the filter is privileged and the user doesn't have matching auth, skip
-		if ev.Kind.IsPrivileged() {
-			ab := sub.Pubkey
-			var containsPubkey bool
-			if ev.Tags != nil {
-				containsPubkey = ev.Tags.ContainsAny([]byte{'p'}, tag.New(ab))
-			}
-			if !bytes.Equal(ev.Pubkey, ab) || containsPubkey {
-				continue
-			}
+		if !ev.ReadableBy(sub.Pubkey) {
+			continue
 		}
 		// send the event to the subscriber
 		sub.Receiver <- ev
@@ -0,0 +1,54 @@
+//go:build cgo
+
+package p256k
+
+import (
+	"runtime"
+	"sync"
+
+	"realy.lol/ec/schnorr"
+)
+
+// BatchItem is a signature to check with BatchVerify.
+type BatchItem = schnorr.BatchItem
+
+// BatchVerify checks the signatures of many messages at once, returning the indexes of the
+// invalid ones in ascending order, or nil if they are all valid.
+//
+// libsecp256k1 has no batch verification, and checks a signature faster than the pure Go
+// batch, so the signatures are checked one at a time spread over all the CPUs.
+func BatchVerify(items []BatchItem) (invalid []int) {
+	bad := make([]bool, len(items))
+	workers := min(runtime.GOMAXPROCS(0), len(items))
+	var wg sync.WaitGroup
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := w; i < len(items); i += workers {
+				bad[i] = !verifyItem(&items[i])
+			}
+		}()
+	}
+	wg.Wait()
+	for i := range bad {
+		if bad[i] {
+			invalid = append(invalid, i)
+		}
+	}
+	return
+}
+
+// verifyItem checks a signature without logging why it is invalid, as invalid signatures are
+// expected in a batch.
+func verifyItem(item *BatchItem) (valid bool) {
+	if len(item.Hash) != 32 || len(item.Sig) != schnorr.SignatureSize ||
+		len(item.PubKey) != schnorr.PubKeyBytesLen {
+		return
+	}
+	pub, err := PubFromBytes(item.PubKey)
+	if err != nil {
+		return
+	}
+	return Verify(ToUchar(item.Hash), ToUchar(item.Sig), pub.Pub())
+}
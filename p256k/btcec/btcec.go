@@ -39,6 +39,7 @@ func (s *Signer) InitSec(sec []byte) (err error) {
 		return
 	}
 	s.SecretKey = secp256k1.SecKeyFromBytes(sec)
+	s.skb = s.SecretKey.Serialize()
 	s.PublicKey = s.SecretKey.PubKey()
 	s.pkb = schnorr.SerializePubKey(s.PublicKey)
 	s.BTCECSec, _ = ec.PrivKeyFromBytes(s.skb)
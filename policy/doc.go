@@ -0,0 +1,25 @@
+// Package policy runs an external write policy plugin, a long-running local subprocess that
+// receives each incoming event and subscription request as a line of JSON on its standard
+// input, and answers on its standard output with one line of JSON per request, saying
+// whether to accept, reject or shadow-reject it.
+//
+// A request looks like:
+//
+//	{"seq":1,"type":"event","event":{...},"received_at":1700000000,
+//	 "remote":"203.0.113.7","authed":"<hex pubkey>"}
+//
+// or for a subscription:
+//
+//	{"seq":2,"type":"req","subscription":"sub1","filters":[{...}],
+//	 "received_at":1700000000,"remote":"203.0.113.7"}
+//
+// and the plugin replies, in any order, with:
+//
+//	{"seq":1,"action":"accept"}
+//	{"seq":2,"action":"reject","msg":"blocked: no scraping"}
+//
+// A shadow-rejected event is reported to the client as saved but is not stored, and a
+// shadow-rejected subscription returns no events. The plugin is restarted if it exits, and
+// requests that are not answered in time are accepted or rejected according to the fail-open
+// setting.
+package policy
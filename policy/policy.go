@@ -0,0 +1,264 @@
+package policy
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"realy.lol/chk"
+	"realy.lol/context"
+	"realy.lol/errorf"
+	"realy.lol/event"
+	"realy.lol/filters"
+	"realy.lol/hex"
+	"realy.lol/log"
+	"realy.lol/reason"
+)
+
+// Action is the decision of the plugin about a request.
+type Action string
+
+const (
+	Accept       Action = "accept"
+	Reject       Action = "reject"
+	ShadowReject Action = "shadowReject"
+)
+
+// The types of request sent to the plugin.
+const (
+	TypeEvent = "event"
+	TypeReq   = "req"
+)
+
+// Shadowed is the notice of an event that was shadow rejected, which is reported to the client
+// as saved, although it is neither stored nor delivered.
+const Shadowed = "shadow rejected"
+
+// DefaultTimeout is the time the plugin has to answer a request if no timeout is configured.
+const DefaultTimeout = 2 * time.Second
+
+// RestartDelay is the time to wait before restarting the plugin after it exits.
+var RestartDelay = time.Second
+
+// Request is a line of JSON sent to the plugin.
+type Request struct {
+	Seq          uint64          `json:"seq"`
+	Type         string          `json:"type"`
+	Event        json.RawMessage `json:"event,omitempty"`
+	Subscription string          `json:"subscription,omitempty"`
+	Filters      json.RawMessage `json:"filters,omitempty"`
+	ReceivedAt   int64           `json:"received_at"`
+	Remote       string          `json:"remote"`
+	Authed       string          `json:"authed,omitempty"`
+}
+
+// Response is a line of JSON received from the plugin.
+type Response struct {
+	Seq    uint64 `json:"seq"`
+	Action Action `json:"action"`
+	Msg    string `json:"msg,omitempty"`
+}
+
+// P is a running policy plugin.
+type P struct {
+	command  []string
+	timeout  time.Duration
+	failOpen bool
+
+	mx sync.Mutex
+	// requests are the lines to write to the standard input of the running plugin, which is
+	// done by a goroutine so a plugin that does not read them cannot block a request with the
+	// mutex held.
+	requests chan []byte
+	seq      uint64
+	pending  map[uint64]chan Response
+}
+
+// New starts a policy plugin from a command line, and keeps it running until the context is
+// canceled. If failOpen is true, requests are accepted when the plugin does not answer within
+// the timeout or is not running, otherwise they are rejected.
+func New(c context.T, command string, timeout time.Duration, failOpen bool) (p *P) {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	p = &P{
+		command:  strings.Fields(command),
+		timeout:  timeout,
+		failOpen: failOpen,
+		pending:  make(map[uint64]chan Response),
+	}
+	go p.run(c)
+	return
+}
+
+// run starts the plugin and restarts it whenever it exits, until the context is canceled.
+func (p *P) run(c context.T) {
+	for {
+		if err := p.start(c); err != nil {
+			log.E.F("policy plugin %s: %v", p.command[0], err)
+		}
+		select {
+		case <-c.Done():
+			return
+		case <-time.After(RestartDelay):
+			log.W.F("restarting policy plugin %s", p.command[0])
+		}
+	}
+}
+
+// start runs the plugin and dispatches its responses until it exits.
+func (p *P) start(c context.T) (err error) {
+	if len(p.command) == 0 {
+		err = errorf.E("no policy plugin command")
+		return
+	}
+	cmd := exec.CommandContext(c, p.command[0], p.command[1:]...)
+	cmd.Stderr = os.Stderr
+	var stdin io.WriteCloser
+	if stdin, err = cmd.StdinPipe(); chk.E(err) {
+		return
+	}
+	var stdout io.ReadCloser
+	if stdout, err = cmd.StdoutPipe(); chk.E(err) {
+		return
+	}
+	if err = cmd.Start(); chk.E(err) {
+		return
+	}
+	log.I.F("started policy plugin %s pid %d", p.command[0], cmd.Process.Pid)
+	requests, exited := make(chan []byte, 64), make(chan struct{})
+	defer close(exited)
+	go write(stdin, requests, exited)
+	p.mx.Lock()
+	p.requests = requests
+	p.mx.Unlock()
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 4096), 1<<20)
+	for scanner.Scan() {
+		var res Response
+		if err = json.Unmarshal(scanner.Bytes(), &res); err != nil {
+			log.E.F("policy plugin sent invalid response '%s': %v", scanner.Bytes(), err)
+			continue
+		}
+		p.mx.Lock()
+		if ch, ok := p.pending[res.Seq]; ok {
+			ch <- res
+			delete(p.pending, res.Seq)
+		}
+		p.mx.Unlock()
+	}
+	// the plugin has exited, fail everything still waiting for it.
+	p.mx.Lock()
+	p.requests = nil
+	for seq, ch := range p.pending {
+		close(ch)
+		delete(p.pending, seq)
+	}
+	p.mx.Unlock()
+	err = cmd.Wait()
+	return
+}
+
+// write writes the requests to the standard input of the plugin until it has exited.
+func write(stdin io.WriteCloser, requests chan []byte, exited chan struct{}) {
+	for {
+		select {
+		case b := <-requests:
+			if _, err := stdin.Write(b); chk.E(err) {
+				return
+			}
+		case <-exited:
+			return
+		}
+	}
+}
+
+// ask sends a request to the plugin and waits for the response, for at most the timeout in
+// all.
+func (p *P) ask(req *Request) (res Response, err error) {
+	ch := make(chan Response, 1)
+	p.mx.Lock()
+	requests := p.requests
+	if requests == nil {
+		p.mx.Unlock()
+		err = errorf.E("policy plugin is not running")
+		return
+	}
+	p.seq++
+	req.Seq = p.seq
+	p.pending[req.Seq] = ch
+	p.mx.Unlock()
+	defer func() {
+		p.mx.Lock()
+		delete(p.pending, req.Seq)
+		p.mx.Unlock()
+	}()
+	var b []byte
+	if b, err = json.Marshal(req); chk.E(err) {
+		return
+	}
+	timer := time.NewTimer(p.timeout)
+	defer timer.Stop()
+	select {
+	case requests <- append(b, '\n'):
+	case <-timer.C:
+		err = errorf.E("policy plugin did not read the request within %v", p.timeout)
+		return
+	}
+	select {
+	case r, ok := <-ch:
+		if !ok {
+			err = errorf.E("policy plugin exited")
+			return
+		}
+		res = r
+	case <-timer.C:
+		err = errorf.E("policy plugin did not answer within %v", p.timeout)
+	}
+	return
+}
+
+// decide sends a request to the plugin and interprets the response, falling back to the
+// fail-open setting if there is no valid response.
+func (p *P) decide(req *Request) (action Action, msg string) {
+	req.ReceivedAt = time.Now().Unix()
+	res, err := p.ask(req)
+	if err == nil {
+		switch res.Action {
+		case Accept, Reject, ShadowReject:
+			return res.Action, res.Msg
+		}
+		err = errorf.E("policy plugin sent unknown action '%s'", res.Action)
+	}
+	log.E.F("%v", err)
+	if p.failOpen {
+		return Accept, ""
+	}
+	return Reject, string(reason.Error.F("policy plugin unavailable"))
+}
+
+// Event asks the plugin whether to accept an event from a client.
+func (p *P) Event(ev *event.T, remote string, authedPubkey []byte) (action Action, msg string) {
+	req := &Request{Type: TypeEvent, Event: ev.Serialize(), Remote: remote}
+	if len(authedPubkey) > 0 {
+		req.Authed = hex.Enc(authedPubkey)
+	}
+	return p.decide(req)
+}
+
+// Req asks the plugin whether to accept a subscription request from a client.
+func (p *P) Req(id []byte, ff *filters.T, remote string, authedPubkey []byte) (action Action,
+	msg string) {
+
+	req := &Request{Type: TypeReq, Subscription: string(id), Filters: ff.Marshal(nil),
+		Remote: remote}
+	if len(authedPubkey) > 0 {
+		req.Authed = hex.Enc(authedPubkey)
+	}
+	return p.decide(req)
+}
@@ -0,0 +1,152 @@
+package policy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"realy.lol/context"
+	"realy.lol/event"
+	"realy.lol/filter"
+	"realy.lol/filters"
+	"realy.lol/kind"
+	"realy.lol/kinds"
+	"realy.lol/timestamp"
+)
+
+// TestMain runs the test binary as a policy plugin when it is started by the tests.
+func TestMain(m *testing.M) {
+	if os.Getenv("REALY_POLICY_TEST_PLUGIN") == "1" {
+		plugin()
+		return
+	}
+	os.Exit(m.Run())
+}
+
+// plugin rejects events containing "spam", shadow-rejects events containing "shadow", exits
+// on events containing "crash", never answers events containing "slow", stops reading for a
+// second and exits on events containing "stall", and rejects all subscription requests.
+func plugin() {
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var req Request
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			os.Exit(1)
+		}
+		res := Response{Seq: req.Seq, Action: Accept}
+		switch {
+		case req.Type == TypeReq:
+			res.Action, res.Msg = Reject, "blocked: no subscriptions"
+		case bytes.Contains(req.Event, []byte("crash")):
+			os.Exit(1)
+		case bytes.Contains(req.Event, []byte("slow")):
+			continue
+		case bytes.Contains(req.Event, []byte("stall")):
+			time.Sleep(time.Second)
+			os.Exit(1)
+		case bytes.Contains(req.Event, []byte("spam")):
+			res.Action, res.Msg = Reject, "blocked: spam"
+		case bytes.Contains(req.Event, []byte("shadow")):
+			res.Action = ShadowReject
+		}
+		b, _ := json.Marshal(res)
+		fmt.Println(string(b))
+	}
+}
+
+func TestPlugin(t *testing.T) {
+	t.Setenv("REALY_POLICY_TEST_PLUGIN", "1")
+	RestartDelay = 10 * time.Millisecond
+	c, cancel := context.Cancel(context.Bg())
+	defer cancel()
+	p := New(c, os.Args[0], 200*time.Millisecond, false)
+	note := func(content string) *event.T {
+		return &event.T{Pubkey: make([]byte, 32), CreatedAt: timestamp.Now(),
+			Kind: kind.TextNote, Content: []byte(content)}
+	}
+	// wait for the plugin to start
+	for i := 0; ; i++ {
+		if a, _ := p.Event(note("hello"), "127.0.0.1", nil); a == Accept {
+			break
+		}
+		if i > 100 {
+			t.Fatal("plugin did not start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for _, tc := range []struct {
+		content string
+		action  Action
+	}{
+		{"hello", Accept},
+		{"spam", Reject},
+		{"shadow", ShadowReject},
+		{"slow", Reject},
+		{"crash", Reject},
+	} {
+		if a, msg := p.Event(note(tc.content), "127.0.0.1", make([]byte, 32)); a != tc.action {
+			t.Fatalf("%s: expected %s, got %s %s", tc.content, tc.action, a, msg)
+		}
+	}
+	// the plugin is restarted after it exits
+	for i := 0; ; i++ {
+		if a, _ := p.Event(note("hello"), "127.0.0.1", nil); a == Accept {
+			break
+		}
+		if i > 100 {
+			t.Fatal("plugin was not restarted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	ff := &filters.T{F: []*filter.T{{Kinds: kinds.New(kind.TextNote)}}}
+	if a, msg := p.Req([]byte("sub"), ff, "127.0.0.1", nil); a != Reject ||
+		msg != "blocked: no subscriptions" {
+		t.Fatalf("expected subscription to be rejected, got %s %s", a, msg)
+	}
+}
+
+func TestPluginStalled(t *testing.T) {
+	t.Setenv("REALY_POLICY_TEST_PLUGIN", "1")
+	c, cancel := context.Cancel(context.Bg())
+	defer cancel()
+	timeout := 200 * time.Millisecond
+	p := New(c, os.Args[0], timeout, false)
+	note := func(content string) *event.T {
+		return &event.T{Pubkey: make([]byte, 32), CreatedAt: timestamp.Now(),
+			Kind: kind.TextNote, Content: []byte(content)}
+	}
+	for i := 0; ; i++ {
+		if a, _ := p.Event(note("hello"), "127.0.0.1", nil); a == Accept {
+			break
+		}
+		if i > 100 {
+			t.Fatal("plugin did not start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	p.Event(note("stall"), "127.0.0.1", nil)
+	// enough requests to fill the pipe to the plugin, which must all time out rather than
+	// wait for the plugin to read them.
+	start := time.Now()
+	var wg sync.WaitGroup
+	big := strings.Repeat("x", 8192)
+	for range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if a, msg := p.Event(note(big), "127.0.0.1", nil); a != Reject {
+				t.Errorf("expected stalled plugin to reject, got %s %s", a, msg)
+			}
+		}()
+	}
+	wg.Wait()
+	if elapsed := time.Since(start); elapsed > 4*timeout {
+		t.Fatalf("requests to a stalled plugin took %v", elapsed)
+	}
+}
@@ -0,0 +1,3 @@
+// Package pow implements NIP-13 proof of work for events, measured as the number of leading
+// zero bits of the event id, with a committed target difficulty in the `nonce` tag.
+package pow
@@ -0,0 +1,81 @@
+package pow
+
+import (
+	"math/bits"
+	"strconv"
+
+	"realy.lol/errorf"
+	"realy.lol/event"
+	"realy.lol/reason"
+	"realy.lol/tag"
+	"realy.lol/tags"
+)
+
+// Difficulty returns the number of leading zero bits of an event id.
+func Difficulty(id []byte) (d int) {
+	for _, b := range id {
+		if b == 0 {
+			d += 8
+			continue
+		}
+		d += bits.LeadingZeros8(b)
+		break
+	}
+	return
+}
+
+// Target returns the target difficulty committed to in the third field of the `nonce` tag of
+// an event, or zero if there is none.
+func Target(ev *event.T) (target int) {
+	if ev.Tags == nil {
+		return
+	}
+	for _, t := range ev.Tags.ToSliceOfTags() {
+		if t.Len() < 3 || string(t.Key()) != "nonce" {
+			continue
+		}
+		var err error
+		if target, err = strconv.Atoi(string(t.B(2))); err != nil {
+			return 0
+		}
+		return
+	}
+	return
+}
+
+// Check returns an error if the event does not have at least the minimum difficulty, or does
+// not commit to a target of at least the minimum, which prevents events that were mined for a
+// lower target but got lucky from being accepted.
+func Check(ev *event.T, minimum int) (err error) {
+	if minimum <= 0 {
+		return
+	}
+	if d := Difficulty(ev.Id); d < minimum {
+		err = errorf.D(string(reason.PoW.F("difficulty %d is less than %d", d, minimum)))
+		return
+	}
+	if t := Target(ev); t < minimum {
+		err = errorf.D(string(reason.PoW.F("committed target %d is less than %d", t,
+			minimum)))
+	}
+	return
+}
+
+// Generate adds a `nonce` tag committing to the target difficulty to an unsigned event and
+// increments the nonce until the event id has at least that difficulty. The event must be
+// signed afterwards.
+func Generate(ev *event.T, target int) {
+	if ev.Tags == nil {
+		ev.Tags = tags.New()
+	}
+	nonce := tag.NewWithCap(3)
+	ev.Tags.AppendTags(nonce)
+	key, t := []byte("nonce"), []byte(strconv.Itoa(target))
+	for n := uint64(0); ; n++ {
+		nonce.Clear()
+		nonce.Append(key, strconv.AppendUint(nil, n, 10), t)
+		if ev.Id = ev.GetIDBytes(); Difficulty(ev.Id) >= target {
+			return
+		}
+	}
+}
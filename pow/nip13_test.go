@@ -0,0 +1,54 @@
+package pow
+
+import (
+	"testing"
+
+	"realy.lol/event"
+	"realy.lol/kind"
+	"realy.lol/p256k"
+	"realy.lol/timestamp"
+)
+
+func TestDifficulty(t *testing.T) {
+	for _, c := range []struct {
+		id []byte
+		d  int
+	}{
+		{[]byte{0xff}, 0},
+		{[]byte{0x00, 0x00, 0x0f}, 20},
+		{[]byte{0x00, 0x01}, 15},
+		{[]byte{0x00, 0x00, 0x00}, 24},
+	} {
+		if d := Difficulty(c.id); d != c.d {
+			t.Fatalf("difficulty of %0x is %d, expected %d", c.id, d, c.d)
+		}
+	}
+}
+
+func TestGenerateCheck(t *testing.T) {
+	sign := &p256k.Signer{}
+	if err := sign.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	ev := &event.T{Pubkey: sign.Pub(), CreatedAt: timestamp.Now(), Kind: kind.TextNote,
+		Content: []byte("mined")}
+	Generate(ev, 12)
+	if err := ev.Sign(sign); err != nil {
+		t.Fatal(err)
+	}
+	if Target(ev) != 12 {
+		t.Fatalf("expected committed target 12, got %d", Target(ev))
+	}
+	if err := Check(ev, 12); err != nil {
+		t.Fatal(err)
+	}
+	// a lucky event with a lower committed target is rejected
+	if Difficulty(ev.Id) < 13 {
+		if err := Check(ev, 13); err == nil {
+			t.Fatal("event with insufficient difficulty accepted")
+		}
+	}
+	if err := Check(ev, Difficulty(ev.Id)); Difficulty(ev.Id) > 12 && err == nil {
+		t.Fatal("event with a committed target lower than required accepted")
+	}
+}
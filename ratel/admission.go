@@ -0,0 +1,113 @@
+package ratel
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/dgraph-io/badger/v4"
+
+	"realy.lol/admission"
+	"realy.lol/chk"
+	"realy.lol/hex"
+	"realy.lol/ratel/keys/arb"
+	"realy.lol/ratel/prefixes"
+	"realy.lol/store"
+)
+
+var _ store.Admitter = (*T)(nil)
+
+// GetMember returns the paid write access of a pubkey, or nil if it has none.
+func (r *T) GetMember(pubkey []byte) (m *admission.Member, err error) {
+	err = r.View(func(txn *badger.Txn) (err error) {
+		var it *badger.Item
+		if it, err = txn.Get(prefixes.Member.Key(arb.New(pubkey))); err != nil {
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				err = nil
+			}
+			return
+		}
+		var b []byte
+		if b, err = it.ValueCopy(nil); chk.E(err) {
+			return
+		}
+		m = &admission.Member{}
+		if err = json.Unmarshal(b, m); chk.E(err) {
+			return
+		}
+		return
+	})
+	return
+}
+
+// SetMember stores the paid write access of a pubkey.
+func (r *T) SetMember(m *admission.Member) (err error) {
+	var pk, b []byte
+	if pk, err = hex.Dec(m.Pubkey); chk.E(err) {
+		return
+	}
+	if b, err = json.Marshal(m); chk.E(err) {
+		return
+	}
+	err = r.Update(func(txn *badger.Txn) (err error) {
+		if err = txn.Set(prefixes.Member.Key(arb.New(pk)), b); chk.E(err) {
+			return
+		}
+		return
+	})
+	return
+}
+
+// Invoices returns the unpaid invoices for write access.
+func (r *T) Invoices() (ii []*admission.Invoice, err error) {
+	err = r.View(func(txn *badger.Txn) (err error) {
+		prf := prefixes.AdmissionInvoice.Key()
+		it := txn.NewIterator(badger.IteratorOptions{Prefix: prf})
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			var b []byte
+			if b, err = it.Item().ValueCopy(nil); chk.E(err) {
+				return
+			}
+			i := &admission.Invoice{}
+			if err = json.Unmarshal(b, i); chk.E(err) {
+				return
+			}
+			ii = append(ii, i)
+		}
+		return
+	})
+	return
+}
+
+// SetInvoice stores an unpaid invoice for write access.
+func (r *T) SetInvoice(i *admission.Invoice) (err error) {
+	var h, b []byte
+	if h, err = hex.Dec(i.PaymentHash); chk.E(err) {
+		return
+	}
+	if b, err = json.Marshal(i); chk.E(err) {
+		return
+	}
+	err = r.Update(func(txn *badger.Txn) (err error) {
+		if err = txn.Set(prefixes.AdmissionInvoice.Key(arb.New(h)), b); chk.E(err) {
+			return
+		}
+		return
+	})
+	return
+}
+
+// DeleteInvoice removes an invoice for write access that has been paid or has expired.
+func (r *T) DeleteInvoice(paymentHash string) (err error) {
+	var h []byte
+	if h, err = hex.Dec(paymentHash); chk.E(err) {
+		return
+	}
+	err = r.Update(func(txn *badger.Txn) (err error) {
+		if err = txn.Delete(prefixes.AdmissionInvoice.Key(arb.New(h))); chk.E(err) {
+			return
+		}
+		return
+	})
+	return
+}
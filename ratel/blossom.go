@@ -0,0 +1,137 @@
+package ratel
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/dgraph-io/badger/v4"
+
+	"realy.lol/blossom"
+	"realy.lol/chk"
+	"realy.lol/hex"
+	"realy.lol/ratel/keys/arb"
+	"realy.lol/ratel/prefixes"
+	"realy.lol/store"
+)
+
+var _ store.Blobber = (*T)(nil)
+
+// getBlob reads the record of a blob in a transaction.
+func getBlob(txn *badger.Txn, sha []byte) (b *blossom.Blob, err error) {
+	var it *badger.Item
+	if it, err = txn.Get(prefixes.Blob.Key(arb.New(sha))); err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			err = nil
+		}
+		return
+	}
+	var v []byte
+	if v, err = it.ValueCopy(nil); chk.E(err) {
+		return
+	}
+	b = &blossom.Blob{}
+	if err = json.Unmarshal(v, b); chk.E(err) {
+		return
+	}
+	return
+}
+
+// ownerKeys returns the owner index keys of a blob.
+func ownerKeys(b *blossom.Blob) (kk [][]byte) {
+	sha, err := hex.Dec(b.Sha256)
+	if chk.E(err) {
+		return
+	}
+	for _, o := range b.Owners {
+		var pk []byte
+		if pk, err = hex.Dec(o); chk.E(err) {
+			continue
+		}
+		kk = append(kk, prefixes.BlobOwner.Key(arb.New(append(pk, sha...))))
+	}
+	return
+}
+
+// GetBlob returns the record of a Blossom blob, or nil if it is not stored.
+func (r *T) GetBlob(sha []byte) (b *blossom.Blob, err error) {
+	err = r.View(func(txn *badger.Txn) (err error) {
+		b, err = getBlob(txn, sha)
+		return
+	})
+	return
+}
+
+// PutBlob stores the record of a Blossom blob and indexes its owners.
+func (r *T) PutBlob(b *blossom.Blob) (err error) {
+	var sha, v []byte
+	if sha, err = hex.Dec(b.Sha256); chk.E(err) {
+		return
+	}
+	if v, err = json.Marshal(b); chk.E(err) {
+		return
+	}
+	err = r.Update(func(txn *badger.Txn) (err error) {
+		var prev *blossom.Blob
+		if prev, err = getBlob(txn, sha); err != nil {
+			return
+		}
+		if prev != nil {
+			for _, k := range ownerKeys(prev) {
+				if err = txn.Delete(k); chk.E(err) {
+					return
+				}
+			}
+		}
+		for _, k := range ownerKeys(b) {
+			if err = txn.Set(k, nil); chk.E(err) {
+				return
+			}
+		}
+		if err = txn.Set(prefixes.Blob.Key(arb.New(sha)), v); chk.E(err) {
+			return
+		}
+		return
+	})
+	return
+}
+
+// DeleteBlob removes the record of a Blossom blob and its owner index.
+func (r *T) DeleteBlob(sha []byte) (err error) {
+	err = r.Update(func(txn *badger.Txn) (err error) {
+		var b *blossom.Blob
+		if b, err = getBlob(txn, sha); err != nil || b == nil {
+			return
+		}
+		for _, k := range ownerKeys(b) {
+			if err = txn.Delete(k); chk.E(err) {
+				return
+			}
+		}
+		if err = txn.Delete(prefixes.Blob.Key(arb.New(sha))); chk.E(err) {
+			return
+		}
+		return
+	})
+	return
+}
+
+// BlobsOf returns the records of the Blossom blobs a pubkey has uploaded.
+func (r *T) BlobsOf(pubkey []byte) (bb []*blossom.Blob, err error) {
+	err = r.View(func(txn *badger.Txn) (err error) {
+		prf := prefixes.BlobOwner.Key(arb.New(pubkey))
+		it := txn.NewIterator(badger.IteratorOptions{Prefix: prf})
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			k := it.Item().Key()
+			var b *blossom.Blob
+			if b, err = getBlob(txn, k[len(prf):]); err != nil {
+				return
+			}
+			if b != nil {
+				bb = append(bb, b)
+			}
+		}
+		return
+	})
+	return
+}
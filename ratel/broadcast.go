@@ -0,0 +1,84 @@
+package ratel
+
+import (
+	"encoding/json"
+
+	"github.com/dgraph-io/badger/v4"
+
+	"realy.lol/broadcast"
+	"realy.lol/chk"
+	"realy.lol/hex"
+	"realy.lol/ratel/keys/arb"
+	"realy.lol/ratel/prefixes"
+	"realy.lol/store"
+)
+
+var _ store.Broadcaster = (*T)(nil)
+
+// broadcastKey returns the key of a delivery.
+func broadcastKey(d *broadcast.Delivery) (k []byte, err error) {
+	var id []byte
+	if id, err = hex.Dec(d.Id); chk.E(err) {
+		return
+	}
+	k = prefixes.Broadcast.Key(arb.New(id), arb.New(d.Relay))
+	return
+}
+
+// PutDelivery adds or updates the state of sending an event to a relay.
+func (r *T) PutDelivery(d *broadcast.Delivery) (err error) {
+	var k, b []byte
+	if k, err = broadcastKey(d); err != nil {
+		return
+	}
+	if b, err = json.Marshal(d); chk.E(err) {
+		return
+	}
+	err = r.Update(func(txn *badger.Txn) (err error) {
+		if err = txn.Set(k, b); chk.E(err) {
+			return
+		}
+		return
+	})
+	return
+}
+
+// DeleteDelivery removes the state of sending an event to a relay.
+func (r *T) DeleteDelivery(d *broadcast.Delivery) (err error) {
+	var k []byte
+	if k, err = broadcastKey(d); err != nil {
+		return
+	}
+	err = r.Update(func(txn *badger.Txn) (err error) {
+		if err = txn.Delete(k); chk.E(err) {
+			return
+		}
+		return
+	})
+	return
+}
+
+// Deliveries returns the state of sending an event to relays, or of all events if id is nil.
+func (r *T) Deliveries(id []byte) (dd []*broadcast.Delivery, err error) {
+	prf := prefixes.Broadcast.Key()
+	if id != nil {
+		prf = prefixes.Broadcast.Key(arb.New(id))
+	}
+	err = r.View(func(txn *badger.Txn) (err error) {
+		it := txn.NewIterator(badger.IteratorOptions{Prefix: prf})
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			var b []byte
+			if b, err = it.Item().ValueCopy(nil); chk.E(err) {
+				return
+			}
+			d := &broadcast.Delivery{}
+			if err = json.Unmarshal(b, d); chk.E(err) {
+				return
+			}
+			dd = append(dd, d)
+		}
+		return
+	})
+	return
+}
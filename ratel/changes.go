@@ -0,0 +1,79 @@
+package ratel
+
+import (
+	"github.com/dgraph-io/badger/v4"
+
+	"realy.lol/cdc"
+	"realy.lol/hex"
+	"realy.lol/ratel/keys"
+	"realy.lol/ratel/keys/fullid"
+	"realy.lol/ratel/keys/index"
+	"realy.lol/ratel/keys/serial"
+	"realy.lol/ratel/prefixes"
+	"realy.lol/store"
+)
+
+var _ store.Changer = (*T)(nil)
+
+// Deletions returns the deletions recorded from a serial, in ascending order.
+func (r *T) Deletions(start uint64, count int) (dd []cdc.Change, err error) {
+	err = r.View(func(txn *badger.Txn) (err error) {
+		it := txn.NewIterator(badger.IteratorOptions{Prefix: prefixes.Deletion.Key()})
+		defer it.Close()
+		for it.Seek(prefixes.Deletion.Key(serial.New(serial.Make(start)))); it.Valid() &&
+			len(dd) < count; it.Next() {
+			ser, id := serial.New(nil), fullid.New()
+			keys.Read(it.Item().KeyCopy(nil), index.New(0), ser, id)
+			var v []byte
+			if v, err = it.Item().ValueCopy(nil); err != nil {
+				return
+			}
+			dd = append(dd, cdc.Change{Serial: ser.Uint64(), Op: cdc.Delete,
+				Id: hex.Enc(id.Val), Tombstone: len(v) > 0 && v[0] == 1})
+		}
+		return
+	})
+	return
+}
+
+// Changed returns a channel that is closed when an event is next saved or deleted.
+func (r *T) Changed() <-chan struct{} {
+	r.changedMx.Lock()
+	defer r.changedMx.Unlock()
+	if r.changed == nil {
+		r.changed = make(chan struct{})
+	}
+	return r.changed
+}
+
+// notifyChanged wakes the readers waiting for a change.
+func (r *T) notifyChanged() {
+	r.changedMx.Lock()
+	defer r.changedMx.Unlock()
+	if r.changed != nil {
+		close(r.changed)
+		r.changed = nil
+	}
+}
+
+// Committed returns the serial below which all saves and deletions are committed, which is the
+// lowest serial that is allocated but not yet committed, or the serial after the last one
+// allocated.
+func (r *T) Committed() uint64 {
+	r.serialMx.Lock()
+	defer r.serialMx.Unlock()
+	w := r.lastSerial + 1
+	for ser := range r.uncommitted {
+		w = min(w, ser)
+	}
+	return w
+}
+
+// committed marks the write of a serial as committed or failed, which may raise the watermark
+// of committed changes, so the readers waiting for a change are woken.
+func (r *T) committed(ser uint64) {
+	r.serialMx.Lock()
+	delete(r.uncommitted, ser)
+	r.serialMx.Unlock()
+	r.notifyChanged()
+}
@@ -0,0 +1,49 @@
+package ratel
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/dgraph-io/badger/v4"
+
+	"realy.lol/chk"
+	"realy.lol/ratel/keys/arb"
+	"realy.lol/ratel/prefixes"
+	"realy.lol/store"
+)
+
+var _ store.PeerCursorer = (*T)(nil)
+
+// GetPeerSerial returns the serial of the next entry to read from the log of a cluster peer.
+func (r *T) GetPeerSerial(url string) (serial uint64, err error) {
+	err = r.View(func(txn *badger.Txn) (err error) {
+		var it *badger.Item
+		if it, err = txn.Get(prefixes.PeerSerial.Key(arb.New(url))); err != nil {
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				err = nil
+			}
+			return
+		}
+		var b []byte
+		if b, err = it.ValueCopy(nil); chk.E(err) {
+			return
+		}
+		if len(b) == 8 {
+			serial = binary.BigEndian.Uint64(b)
+		}
+		return
+	})
+	return
+}
+
+// SetPeerSerial stores the serial of the next entry to read from the log of a cluster peer.
+func (r *T) SetPeerSerial(url string, serial uint64) (err error) {
+	err = r.Update(func(txn *badger.Txn) (err error) {
+		if err = txn.Set(prefixes.PeerSerial.Key(arb.New(url)),
+			binary.BigEndian.AppendUint64(nil, serial)); chk.E(err) {
+			return
+		}
+		return
+	})
+	return
+}
@@ -10,11 +10,13 @@ import (
 	"realy.lol/log"
 	"realy.lol/ratel/keys"
 	"realy.lol/ratel/keys/createdat"
+	"realy.lol/ratel/keys/fullid"
 	"realy.lol/ratel/keys/id"
 	"realy.lol/ratel/keys/index"
 	"realy.lol/ratel/keys/serial"
 	"realy.lol/ratel/keys/tombstone"
 	"realy.lol/ratel/prefixes"
+	"realy.lol/sha256"
 	"realy.lol/timestamp"
 )
 
@@ -81,8 +83,27 @@ func (r *T) DeleteEvent(c context.T, eid *eventid.T, noTombstone ...bool) (err e
 		return
 	}
 	_, _ = w, l
-
+	// record the deletion in the change sequence.
+	var delKey, delVal []byte
+	if len(ev.Id) == sha256.Size {
+		var delSerial uint64
+		if delSerial, err = r.Serial(); chk.E(err) {
+			return
+		}
+		defer r.committed(delSerial)
+		delKey = prefixes.Deletion.Key(serial.New(serial.Make(delSerial)),
+			fullid.New(ev.EventId()))
+		if len(tombstoneKey) > 0 {
+			delVal = []byte{1}
+		}
+	}
+	defer r.notifyChanged()
 	err = r.Update(func(txn *badger.Txn) (err error) {
+		if len(delKey) > 0 {
+			if err = txn.Set(delKey, delVal); chk.E(err) {
+				return
+			}
+		}
 		if err = txn.Delete(evKey); chk.E(err) {
 		}
 		for _, key := range indexKeys {
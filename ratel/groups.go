@@ -0,0 +1,87 @@
+package ratel
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/dgraph-io/badger/v4"
+
+	"realy.lol/chk"
+	"realy.lol/groups"
+	"realy.lol/ratel/keys/arb"
+	"realy.lol/ratel/prefixes"
+	"realy.lol/store"
+)
+
+var _ store.Grouper = (*T)(nil)
+
+// GetGroup returns the stored state of a NIP-29 group, or nil if it does not exist.
+func (r *T) GetGroup(id string) (g *groups.Group, err error) {
+	err = r.View(func(txn *badger.Txn) (err error) {
+		var it *badger.Item
+		if it, err = txn.Get(prefixes.Group.Key(arb.New(id))); err != nil {
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				err = nil
+			}
+			return
+		}
+		var b []byte
+		if b, err = it.ValueCopy(nil); chk.E(err) {
+			return
+		}
+		g = &groups.Group{}
+		if err = json.Unmarshal(b, g); chk.E(err) {
+			return
+		}
+		return
+	})
+	return
+}
+
+// SetGroup stores the state of a NIP-29 group.
+func (r *T) SetGroup(g *groups.Group) (err error) {
+	var b []byte
+	if b, err = json.Marshal(g); chk.E(err) {
+		return
+	}
+	err = r.Update(func(txn *badger.Txn) (err error) {
+		if err = txn.Set(prefixes.Group.Key(arb.New(g.Id)), b); chk.E(err) {
+			return
+		}
+		return
+	})
+	return
+}
+
+// DeleteGroup removes the stored state of a NIP-29 group.
+func (r *T) DeleteGroup(id string) (err error) {
+	err = r.Update(func(txn *badger.Txn) (err error) {
+		if err = txn.Delete(prefixes.Group.Key(arb.New(id))); chk.E(err) {
+			return
+		}
+		return
+	})
+	return
+}
+
+// Groups returns the stored state of all NIP-29 groups.
+func (r *T) Groups() (gg []*groups.Group, err error) {
+	err = r.View(func(txn *badger.Txn) (err error) {
+		prf := prefixes.Group.Key()
+		it := txn.NewIterator(badger.IteratorOptions{Prefix: prf})
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			var b []byte
+			if b, err = it.Item().ValueCopy(nil); chk.E(err) {
+				return
+			}
+			g := &groups.Group{}
+			if err = json.Unmarshal(b, g); chk.E(err) {
+				return
+			}
+			gg = append(gg, g)
+		}
+		return
+	})
+	return
+}
@@ -13,7 +13,12 @@ import (
 
 const maxLen = 500000000
 
-// Import a collection of events in line structured minified JSON format (JSONL).
+// ImportBatchSize is the number of events whose signatures are checked together while
+// importing.
+var ImportBatchSize = 256
+
+// Import a collection of events in line structured minified JSON format (JSONL). Events with
+// an invalid Id or signature are skipped.
 func (r *T) Import(rr io.Reader) (done chan struct{}) {
 	done = make(chan struct{})
 	r.Flatten = true
@@ -40,6 +45,27 @@ func (r *T) Import(rr io.Reader) (done chan struct{}) {
 		buf := make([]byte, maxLen)
 		scan.Buffer(buf, maxLen)
 		var count, total int
+		batch := make([]*event.T, 0, ImportBatchSize)
+		// save the events of a batch that have valid signatures.
+		save := func() {
+			invalid := event.VerifyBatch(batch)
+			for i, ev := range batch {
+				if len(invalid) > 0 && invalid[0] == i {
+					invalid = invalid[1:]
+					log.D.F("not importing event %0x with an invalid signature", ev.Id)
+					continue
+				}
+				if err = r.SaveEvent(r.Ctx, ev); err != nil {
+					continue
+				}
+				count++
+				if count%100 == 0 {
+					log.I.F("received %d events", count)
+					debug.FreeOSMemory()
+				}
+			}
+			batch = batch[:0]
+		}
 		for scan.Scan() {
 			select {
 			case <-r.Ctx.Done():
@@ -52,21 +78,17 @@ func (r *T) Import(rr io.Reader) (done chan struct{}) {
 			if len(b) < 1 {
 				continue
 			}
+			// the decoded event refers to the line, which the scanner reuses, so it is copied
+			// as the event is kept until the batch is saved.
 			ev := &event.T{}
-			if _, err = ev.Unmarshal(b); err != nil {
-				continue
-			}
-			if err = r.SaveEvent(r.Ctx, ev); err != nil {
+			if _, err = ev.Unmarshal(append([]byte{}, b...)); err != nil {
 				continue
 			}
-			b = nil
-			ev = nil
-			count++
-			if count%100 == 0 {
-				log.I.F("received %d events", count)
-				debug.FreeOSMemory()
+			if batch = append(batch, ev); len(batch) == ImportBatchSize {
+				save()
 			}
 		}
+		save()
 		log.I.F("read %d bytes and saved %d events", total, count)
 		err = scan.Err()
 		if chk.E(err) {
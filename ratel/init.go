@@ -31,6 +31,13 @@ func (r *T) Init(path string) (err error) {
 	if r.seq, err = r.DB.GetSequence([]byte("events"), 1000); chk.E(err) {
 		return err
 	}
+	// the watermark of committed changes starts above everything written before, which
+	// needs a serial to be allocated to find out.
+	var ser uint64
+	if ser, err = r.Serial(); chk.E(err) {
+		return err
+	}
+	r.committed(ser)
 	log.T.Ln("running migrations", r.dataDir)
 	if err = r.runMigrations(); chk.E(err) {
 		return log.E.Err("error running migrations: %w; %s", err, r.dataDir)
@@ -45,6 +45,14 @@ type T struct {
 	// Binary sets whether to use a fast streaming binary codec for events, to change to this,
 	// events must be exported, the database nuked and the events re-imported.
 	Binary bool
+	// changed is closed and replaced when an event is saved or deleted.
+	changedMx sync.Mutex
+	changed   chan struct{}
+	// serialMx guards the last serial allocated and the serials whose writes are not yet
+	// committed, which hold back the watermark of committed changes.
+	serialMx    sync.Mutex
+	lastSerial  uint64
+	uncommitted map[uint64]struct{}
 }
 
 func (r *T) SetLogLevel(level string) {
@@ -110,9 +118,20 @@ func (r *T) SerialKey() (idx []byte, ser *serial.T) {
 }
 
 // Serial returns the next monotonic conflict free unique serial on the database.
+//
+// The serial is uncommitted until Committed is called with it, which must be done once the
+// write it is for is committed or has failed.
 func (r *T) Serial() (ser uint64, err error) {
+	r.serialMx.Lock()
+	defer r.serialMx.Unlock()
 	if ser, err = r.seq.Next(); chk.E(err) {
+		return
+	}
+	if r.uncommitted == nil {
+		r.uncommitted = make(map[uint64]struct{})
 	}
+	r.uncommitted[ser] = struct{}{}
+	r.lastSerial = max(r.lastSerial, ser)
 	// log.T.ToSliceOfBytes("serial %x", ser)
 	return
 }
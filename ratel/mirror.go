@@ -0,0 +1,54 @@
+package ratel
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/dgraph-io/badger/v4"
+
+	"realy.lol/chk"
+	"realy.lol/mirror"
+	"realy.lol/ratel/keys/arb"
+	"realy.lol/ratel/prefixes"
+	"realy.lol/store"
+)
+
+var _ store.Cursorer = (*T)(nil)
+
+// GetCursor returns the progress of mirroring an upstream relay, or nil if there is none.
+func (r *T) GetCursor(url string) (c *mirror.Cursor, err error) {
+	err = r.View(func(txn *badger.Txn) (err error) {
+		var it *badger.Item
+		if it, err = txn.Get(prefixes.MirrorCursor.Key(arb.New(url))); err != nil {
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				err = nil
+			}
+			return
+		}
+		var b []byte
+		if b, err = it.ValueCopy(nil); chk.E(err) {
+			return
+		}
+		c = &mirror.Cursor{}
+		if err = json.Unmarshal(b, c); chk.E(err) {
+			return
+		}
+		return
+	})
+	return
+}
+
+// SetCursor stores the progress of mirroring an upstream relay.
+func (r *T) SetCursor(url string, c *mirror.Cursor) (err error) {
+	var b []byte
+	if b, err = json.Marshal(c); chk.E(err) {
+		return
+	}
+	err = r.Update(func(txn *badger.Txn) (err error) {
+		if err = txn.Set(prefixes.MirrorCursor.Key(arb.New(url)), b); chk.E(err) {
+			return
+		}
+		return
+	})
+	return
+}
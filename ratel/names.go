@@ -0,0 +1,87 @@
+package ratel
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/dgraph-io/badger/v4"
+
+	"realy.lol/chk"
+	"realy.lol/dns"
+	"realy.lol/ratel/keys/arb"
+	"realy.lol/ratel/prefixes"
+	"realy.lol/store"
+)
+
+var _ store.Namer = (*T)(nil)
+
+// GetName returns the entry of a NIP-05 name, or nil if it is not registered.
+func (r *T) GetName(name string) (n *dns.Name, err error) {
+	err = r.View(func(txn *badger.Txn) (err error) {
+		var it *badger.Item
+		if it, err = txn.Get(prefixes.Name.Key(arb.New(name))); err != nil {
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				err = nil
+			}
+			return
+		}
+		var b []byte
+		if b, err = it.ValueCopy(nil); chk.E(err) {
+			return
+		}
+		n = &dns.Name{}
+		if err = json.Unmarshal(b, n); chk.E(err) {
+			return
+		}
+		return
+	})
+	return
+}
+
+// SetName stores the entry of a NIP-05 name.
+func (r *T) SetName(n *dns.Name) (err error) {
+	var b []byte
+	if b, err = json.Marshal(n); chk.E(err) {
+		return
+	}
+	err = r.Update(func(txn *badger.Txn) (err error) {
+		if err = txn.Set(prefixes.Name.Key(arb.New(n.Name)), b); chk.E(err) {
+			return
+		}
+		return
+	})
+	return
+}
+
+// DeleteName removes the entry of a NIP-05 name.
+func (r *T) DeleteName(name string) (err error) {
+	err = r.Update(func(txn *badger.Txn) (err error) {
+		if err = txn.Delete(prefixes.Name.Key(arb.New(name))); chk.E(err) {
+			return
+		}
+		return
+	})
+	return
+}
+
+// Names returns the entries of all registered NIP-05 names.
+func (r *T) Names() (nn []*dns.Name, err error) {
+	err = r.View(func(txn *badger.Txn) (err error) {
+		prf := prefixes.Name.Key()
+		it := txn.NewIterator(badger.IteratorOptions{Prefix: prf})
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			var b []byte
+			if b, err = it.Item().ValueCopy(nil); chk.E(err) {
+				return
+			}
+			n := &dns.Name{}
+			if err = json.Unmarshal(b, n); chk.E(err) {
+				return
+			}
+			nn = append(nn, n)
+		}
+		return
+	})
+	return
+}
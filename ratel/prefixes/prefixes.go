@@ -140,6 +140,69 @@ const (
 	//
 	// [ 17 ][ 8 bytes eventid.T prefix ][ 8 bytes Serial ]
 	TagEventId
+
+	// Group stores the state of a NIP-29 relay based group as minified JSON, keyed by the
+	// group identifier. It can be regenerated from the stored moderation events.
+	//
+	// [ 18 ][ group id ]
+	Group
+
+	// MirrorCursor stores the progress of mirroring an upstream relay as minified JSON, keyed
+	// by the relay URL.
+	//
+	// [ 19 ][ relay url ]
+	MirrorCursor
+
+	// Broadcast stores the state of sending an event to another relay as minified JSON, keyed
+	// by the event id and the relay URL.
+	//
+	// [ 20 ][ 32 bytes event id ][ relay url ]
+	Broadcast
+
+	// PeerSerial stores the serial of the next entry to read from the log of a cluster peer
+	// as an 8 byte big endian value, keyed by the URL of the log.
+	//
+	// [ 21 ][ log url ]
+	PeerSerial
+
+	// Deletion records the deletion of an event under a serial of the event sequence, so the
+	// changes to the store can be read in order. The value is 1 if a tombstone was written.
+	//
+	// [ 22 ][ 8 bytes Serial ][ 32 bytes event id ]
+	Deletion
+
+	// Blob stores the record of a Blossom blob as minified JSON, keyed by its hash.
+	//
+	// [ 23 ][ 32 bytes sha256 ]
+	Blob
+
+	// BlobOwner indexes the Blossom blobs uploaded by a pubkey.
+	//
+	// [ 24 ][ 32 bytes pubkey ][ 32 bytes sha256 ]
+	BlobOwner
+
+	// Name stores an entry of the registry of NIP-05 names as minified JSON, keyed by the
+	// name.
+	//
+	// [ 25 ][ name ]
+	Name
+
+	// Verification stores the result of verifying the NIP-05 identifier of a pubkey as
+	// minified JSON.
+	//
+	// [ 26 ][ 32 bytes pubkey ]
+	Verification
+
+	// Member stores the end of the paid write access of a pubkey as minified JSON.
+	//
+	// [ 27 ][ 32 bytes pubkey ]
+	Member
+
+	// AdmissionInvoice stores an unpaid invoice for write access as minified JSON, keyed by
+	// its payment hash.
+	//
+	// [ 28 ][ 32 bytes payment hash ]
+	AdmissionInvoice
 )
 
 // FilterPrefixes is a slice of the prefixes used by filter index to enable a loop
@@ -173,6 +236,17 @@ var AllPrefixes = [][]byte{
 	{Configuration.B()},
 	{FulltextIndex.B()},
 	{LangIndex.B()},
+	{Group.B()},
+	{MirrorCursor.B()},
+	{Broadcast.B()},
+	{PeerSerial.B()},
+	{Deletion.B()},
+	{Blob.B()},
+	{BlobOwner.B()},
+	{Name.B()},
+	{Verification.B()},
+	{Member.B()},
+	{AdmissionInvoice.B()},
 }
 
 // KeySizes are the byte size of keys of each type of key prefix. int(P) or call the P.I() method
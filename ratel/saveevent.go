@@ -99,7 +99,7 @@ func (r *T) SaveEvent(c context.T, ev *event.T) (err error) {
 	// otherwise, save new event record.
 	var idx []byte
 	var ser *serial.T
-	if err = r.Update(func(txn *badger.Txn) (err error) {
+	err = r.Update(func(txn *badger.Txn) (err error) {
 		idx, ser = r.SerialKey()
 		// encode to binary
 		// raw event store
@@ -121,7 +121,11 @@ func (r *T) SaveEvent(c context.T, ev *event.T) (err error) {
 		}
 		// log.D.ToSliceOfBytes("saved event to ratel %s:\n%s", r.dataDir, ev.Serialize())
 		return
-	}); chk.E(err) {
+	})
+	if ser != nil {
+		r.committed(ser.Uint64())
+	}
+	if chk.E(err) {
 		return
 	}
 	if err = r.GenerateFulltextIndex(ev, ser); chk.E(err) {
@@ -130,6 +134,7 @@ func (r *T) SaveEvent(c context.T, ev *event.T) (err error) {
 	if err = r.GenerateLanguageIndex(ev, ser); chk.E(err) {
 		return
 	}
+	r.notifyChanged()
 	return
 }
 
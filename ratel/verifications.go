@@ -0,0 +1,81 @@
+package ratel
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/dgraph-io/badger/v4"
+
+	"realy.lol/chk"
+	"realy.lol/dns"
+	"realy.lol/hex"
+	"realy.lol/ratel/keys/arb"
+	"realy.lol/ratel/prefixes"
+	"realy.lol/store"
+)
+
+var _ store.Verifier = (*T)(nil)
+
+// GetVerification returns the last result of verifying the NIP-05 identifier of a pubkey, or
+// nil if there is none.
+func (r *T) GetVerification(pubkey []byte) (v *dns.Verification, err error) {
+	err = r.View(func(txn *badger.Txn) (err error) {
+		var it *badger.Item
+		if it, err = txn.Get(prefixes.Verification.Key(arb.New(pubkey))); err != nil {
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				err = nil
+			}
+			return
+		}
+		var b []byte
+		if b, err = it.ValueCopy(nil); chk.E(err) {
+			return
+		}
+		v = &dns.Verification{}
+		if err = json.Unmarshal(b, v); chk.E(err) {
+			return
+		}
+		return
+	})
+	return
+}
+
+// SetVerification stores the result of verifying the NIP-05 identifier of a pubkey.
+func (r *T) SetVerification(v *dns.Verification) (err error) {
+	var pk, b []byte
+	if pk, err = hex.Dec(v.Pubkey); chk.E(err) {
+		return
+	}
+	if b, err = json.Marshal(v); chk.E(err) {
+		return
+	}
+	err = r.Update(func(txn *badger.Txn) (err error) {
+		if err = txn.Set(prefixes.Verification.Key(arb.New(pk)), b); chk.E(err) {
+			return
+		}
+		return
+	})
+	return
+}
+
+// Verifications returns all the stored results of verifying NIP-05 identifiers.
+func (r *T) Verifications() (vv []*dns.Verification, err error) {
+	err = r.View(func(txn *badger.Txn) (err error) {
+		prf := prefixes.Verification.Key()
+		it := txn.NewIterator(badger.IteratorOptions{Prefix: prf})
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			var b []byte
+			if b, err = it.Item().ValueCopy(nil); chk.E(err) {
+				return
+			}
+			v := &dns.Verification{}
+			if err = json.Unmarshal(b, v); chk.E(err) {
+				return
+			}
+			vv = append(vv, v)
+		}
+		return
+	})
+	return
+}
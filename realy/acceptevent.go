@@ -2,6 +2,8 @@ package realy
 
 import (
 	"bytes"
+	"net/http"
+	"time"
 
 	"realy.lol/chk"
 	"realy.lol/context"
@@ -9,15 +11,61 @@ import (
 	"realy.lol/event"
 	"realy.lol/kind"
 	"realy.lol/log"
+	"realy.lol/sanity"
 	"realy.lol/tag"
 	"realy.lol/tag/atag"
 )
 
-func (s *Server) acceptEvent(c context.T, evt *event.T, authedPubkey []byte,
+func (s *Server) acceptEvent(c context.T, evt *event.T, hr *http.Request, authedPubkey []byte,
 	remote string) (accept bool, notice string, afterSave func()) {
 	authRequired := s.AuthRequired()
+	cfg := s.Configuration()
+	// reject events that are oversized, badly timestamped or malformed for their kind.
+	if err := sanity.Check(evt, limits(cfg), time.Now().Unix()); err != nil {
+		notice = err.Error()
+		return
+	}
+	// zap receipts may be required to match the invoice and zap request they carry.
+	if notice = s.checkZap(c, cfg, evt); notice != "" {
+		return
+	}
+	// publishers may be required to have a verified NIP-05 identifier on an allowed domain.
+	if notice = s.checkNIP05(c, cfg, evt); notice != "" {
+		return
+	}
+	// the write policy plugin is asked before anything is done with the event, such as
+	// applying a deletion.
+	if notice = s.checkPolicy(evt, authedPubkey, remote); notice != "" {
+		return
+	}
 	s.Lock()
 	defer s.Unlock()
+	// untrusted clients may be required to do proof of work to publish.
+	if notice = s.checkPow(cfg, evt, authedPubkey); notice != "" {
+		return
+	}
+	// if write access is sold, untrusted authors must have paid for it.
+	if notice = s.checkAdmission(c, cfg, evt); notice != "" {
+		return
+	}
+	// banned events and kinds that are not allowed are rejected whoever the author is.
+	if notice = checkManagedEvent(cfg, evt); notice != "" {
+		return
+	}
+	// gift wraps addressed to users of the relay are accepted from anyone, as their author is a
+	// random key that cannot be authenticated.
+	if s.acceptInbox(c, evt, s.relayURL(hr)) {
+		return true, "", nil
+	}
+	// the pubkey lists maintained through the NIP-86 management api apply to all other events.
+	if notice = checkManagedPubkey(cfg, evt); notice != "" {
+		return
+	}
+	// events posted to NIP-29 groups are governed by the group membership.
+	var handled bool
+	if accept, notice, afterSave, handled = s.acceptGroupEvent(c, evt); handled {
+		return
+	}
 	// if the authenticator is enabled we require auth to accept events
 	if authRequired && len(s.owners) == 0 {
 		log.W.F("%s auth not required and no ACL enabled, accepting event %0x", remote, evt.Id)
@@ -8,6 +8,7 @@ import (
 	"realy.lol/ec/schnorr"
 	"realy.lol/filters"
 	"realy.lol/log"
+	"realy.lol/policy"
 )
 
 func (s *Server) AcceptReq(c context.T, hr *http.Request, id []byte,
@@ -15,6 +16,18 @@ func (s *Server) AcceptReq(c context.T, hr *http.Request, id []byte,
 	modified bool) {
 
 	log.T.F("%s AcceptReq pubkey %0x", remote, authedPubkey)
+	if s.Policy != nil {
+		switch action, msg := s.Policy.Req(id, ff, remote, authedPubkey); action {
+		case policy.Reject:
+			log.I.F("%s req rejected by policy: %s", remote, msg)
+			return
+		case policy.ShadowReject:
+			// an empty set of filters finds nothing, so the client only gets an EOSE.
+			log.I.F("%s req shadow rejected by policy: %s", remote, msg)
+			allowed, ok = filters.New(), true
+			return
+		}
+	}
 	authRequired := s.AuthRequired()
 	if s.PublicReadable() && !authRequired {
 		log.W.F("%s accept req because public readable and not auth required", remote)
@@ -0,0 +1,92 @@
+package realy
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"realy.lol/admission"
+	"realy.lol/chk"
+	"realy.lol/context"
+	"realy.lol/event"
+	"realy.lol/log"
+	"realy.lol/realy/config"
+	"realy.lol/relayinfo"
+	"realy.lol/store"
+)
+
+// AdmissionInterval is how often the unpaid invoices for write access are looked up.
+const AdmissionInterval = 15 * time.Second
+
+// StartAdmission adds the admission endpoint to the server and starts looking up the invoices
+// for write access, if there is a wallet and the store can keep the members. Whether
+// publishers must pay is decided by the configured fees, so it can be changed without a
+// restart.
+func (s *Server) StartAdmission() {
+	fees := len(s.Configuration().AdmissionFees) > 0
+	members, ok := s.Store.(store.Admitter)
+	if !ok {
+		if fees {
+			log.E.F("event store cannot keep paid members, rejecting all events from " +
+				"unpaid publishers")
+		}
+		return
+	}
+	if s.Wallet == nil {
+		if fees {
+			log.E.F("no wallet connection configured, rejecting all events from unpaid " +
+				"publishers")
+		}
+		return
+	}
+	s.admission = &admission.A{
+		Wallet:      s.Wallet,
+		Store:       members,
+		Fees:        func() []config.Fee { return s.Configuration().AdmissionFees },
+		Description: s.Name + " write access",
+	}
+	s.admission.Register(s.Mux.ServeMux)
+	go s.admission.Run(s.Ctx, AdmissionInterval)
+	log.I.F("serving admission at %s", admission.Path)
+}
+
+// checkAdmission returns a notice if admission fees are configured and the author of an event
+// has not paid for write access that is still current. The superuser, admins, owners and the
+// users on the owners' follow lists never need to pay.
+//
+// This must be called with the Server mutex locked.
+func (s *Server) checkAdmission(c context.T, cfg config.C, evt *event.T) (notice string) {
+	if len(cfg.AdmissionFees) == 0 || isLocalOnly(c) || evt.Kind.IsGiftWrap() ||
+		s.powTier(evt.Pubkey) == "" {
+		return
+	}
+	restricted := "restricted: publishing requires paid admission"
+	if s.admission == nil {
+		return restricted
+	}
+	_, ok, err := s.admission.Admitted(evt.Pubkey)
+	if chk.E(err) {
+		return "error: failed to check admission"
+	}
+	if !ok {
+		return restricted + ", see " + admission.Path
+	}
+	return
+}
+
+// admissionFees returns the fees of write access for the relay information document, and the
+// URL they are paid at, if admission is being sold.
+func (s *Server) admissionFees(r *http.Request, cfg config.C) (fees *relayinfo.Fees,
+	url string) {
+	if s.admission == nil || len(cfg.AdmissionFees) == 0 {
+		return
+	}
+	fees = &relayinfo.Fees{}
+	for _, f := range cfg.AdmissionFees {
+		fees.Subscription = append(fees.Subscription, relayinfo.Subscription{
+			Amount: int(f.Amount), Unit: admission.Unit, Period: int(f.Period)})
+	}
+	// wss:// becomes https:// and ws:// becomes http://.
+	url = strings.Replace(RelayURL(r), "ws", "http", 1) + admission.Path
+	return
+}
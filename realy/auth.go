@@ -49,6 +49,11 @@ func (s *Server) ServiceURL(req *http.Request) (st string) {
 		log.T.F("auth not required")
 		return
 	}
+	return RelayURL(req)
+}
+
+// RelayURL returns the websocket address of the relay as seen by the client of a request.
+func RelayURL(req *http.Request) (st string) {
 	host := req.Header.Get("X-Forwarded-Host")
 	if host == "" {
 		host = req.Host
@@ -0,0 +1,89 @@
+package realy
+
+import (
+	"bytes"
+
+	"realy.lol/blossom"
+	"realy.lol/filestorage"
+	"realy.lol/hex"
+	"realy.lol/log"
+	"realy.lol/relaymanagement"
+	"realy.lol/store"
+)
+
+// StartBlossom adds the Blossom blob server endpoints to the server if it is enabled.
+func (s *Server) StartBlossom() {
+	if !s.Configuration().Blossom {
+		return
+	}
+	b := s.blobStore()
+	if b == nil {
+		log.E.F("not serving blossom")
+		return
+	}
+	b.Register(s.Mux.ServeMux)
+	log.I.F("serving blossom blobs from %s", s.BlobDir)
+}
+
+// StartFileStorage adds the NIP-96 file storage endpoints to the server if they are enabled.
+// The files are the same blobs the Blossom server keeps, and count towards the same quota.
+func (s *Server) StartFileStorage() {
+	if !s.Configuration().NIP96 {
+		return
+	}
+	b := s.blobStore()
+	if b == nil {
+		log.E.F("not serving nip-96 file storage")
+		return
+	}
+	(&filestorage.S{Store: b}).Register(s.Mux.ServeMux)
+	log.I.F("serving nip-96 files from %s", s.BlobDir)
+}
+
+// blobStore returns the blob store shared by the Blossom and NIP-96 servers, or nil if the
+// event store cannot keep blob records or there is no blob directory.
+func (s *Server) blobStore() (b *blossom.S) {
+	index, ok := s.Store.(store.Blobber)
+	if !ok {
+		log.E.F("event store cannot keep blob records")
+		return
+	}
+	if s.BlobDir == "" {
+		log.E.F("no blob directory configured")
+		return
+	}
+	return &blossom.S{
+		Index:     index,
+		Dir:       s.BlobDir,
+		MaxSize:   func() int64 { return s.Configuration().BlossomMaxSize },
+		Quota:     func([]byte) int64 { return s.Configuration().BlossomQuota },
+		MayUpload: s.mayUpload,
+	}
+}
+
+// mayUpload returns true if a pubkey may store blobs, which is the same set of users that may
+// publish events: anyone not banned if there are no owners, otherwise the superuser, the
+// admins and the users on the owners' follow lists.
+func (s *Server) mayUpload(pk []byte) bool {
+	cfg := s.Configuration()
+	h := hex.Enc(pk)
+	if relaymanagement.Contains(cfg.BannedPubkeys, h) ||
+		(len(cfg.AllowedPubkeys) > 0 && !relaymanagement.Contains(cfg.AllowedPubkeys, h)) {
+		return false
+	}
+	s.Lock()
+	defer s.Unlock()
+	if len(s.owners) == 0 {
+		return true
+	}
+	if s.Superuser != nil && bytes.Equal(pk, s.Superuser.Pub()) {
+		return true
+	}
+	for _, a := range s.admins {
+		if bytes.Equal(pk, a.Pub()) {
+			return true
+		}
+	}
+	_, ok := s.followed[string(pk)]
+	return ok
+}
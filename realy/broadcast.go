@@ -0,0 +1,117 @@
+package realy
+
+import (
+	"bytes"
+	"net/url"
+
+	"realy.lol/broadcast"
+	"realy.lol/chk"
+	"realy.lol/context"
+	"realy.lol/event"
+	"realy.lol/filter"
+	"realy.lol/groups"
+	"realy.lol/kind"
+	"realy.lol/kinds"
+	"realy.lol/log"
+	"realy.lol/netaddr"
+	"realy.lol/store"
+	"realy.lol/tag"
+)
+
+type localOnlyKey struct{}
+
+// LocalOnly marks a context so that events published with it are stored but not broadcast to
+// other relays, for events that were pulled from other relays or are generated by this relay.
+func LocalOnly(c context.T) context.T { return context.Value(c, localOnlyKey{}, true) }
+
+// isLocalOnly returns true if the context was marked with LocalOnly.
+func isLocalOnly(c context.T) bool {
+	l, _ := c.Value(localOnlyKey{}).(bool)
+	return l
+}
+
+// StartBroadcast starts sending the events published to the relay on to other relays, which
+// continues until the server context is canceled.
+func (s *Server) StartBroadcast() {
+	q, ok := s.Store.(store.Broadcaster)
+	if !ok {
+		log.W.F("event store cannot keep a broadcast queue, not broadcasting")
+		return
+	}
+	s.broadcaster = broadcast.New(s.newPool(), q, func(id []byte) (ev *event.T, err error) {
+		var evs event.Ts
+		if evs, err = s.Store.QueryEvents(s.Ctx, &filter.T{IDs: tag.New(id)}); err != nil {
+			return
+		}
+		if len(evs) > 0 {
+			ev = evs[0]
+		}
+		return
+	})
+	go s.broadcaster.Run(s.Ctx)
+}
+
+// broadcast queues a newly stored event to be sent to the configured relays, and to the
+// write relays of its author if outbox broadcasting is enabled.
+func (s *Server) broadcast(c context.T, ev *event.T) {
+	if s.broadcaster == nil || isLocalOnly(c) || !s.broadcastable(ev) {
+		return
+	}
+	cfg := s.Configuration()
+	relays := append([]string{}, cfg.BroadcastRelays...)
+	if cfg.BroadcastOutbox {
+		relays = append(relays, s.writeRelays(c, ev.Pubkey)...)
+	}
+	if len(relays) == 0 {
+		return
+	}
+	chk.E(s.broadcaster.Broadcast(ev, relays))
+}
+
+// broadcastable returns true if an event may be sent on to other relays, which it may not be
+// if it is NIP-70 protected, is addressed only to the users in its tags, was posted to a
+// NIP-29 group of this relay, or is signed by the relay itself.
+func (s *Server) broadcastable(ev *event.T) bool {
+	switch {
+	case ev.Tags.ContainsProtectedMarker():
+	case ev.Kind.IsPrivileged(), ev.Kind.IsGiftWrap():
+	case groups.Id(ev) != "":
+	case ev.Kind.OneOf(kind.GroupMetadata, kind.GroupAdmins, kind.GroupMembers,
+		kind.GroupRoles):
+	case s.Superuser != nil && bytes.Equal(ev.Pubkey, s.Superuser.Pub()):
+	default:
+		return true
+	}
+	return false
+}
+
+// writeRelays returns the relays a user publishes to according to their stored NIP-65 relay
+// list, which are the r tags with no marker or the write marker.
+//
+// As the list is given by the user, only secure websocket URLs of hosts with public addresses
+// are returned, so it cannot be used to make the relay connect to its own network.
+func (s *Server) writeRelays(c context.T, pubkey []byte) (relays []string) {
+	evs, err := s.Store.QueryEvents(c, &filter.T{Authors: tag.New(pubkey),
+		Kinds: kinds.New(kind.RelayListMetadata)})
+	if chk.E(err) || len(evs) == 0 {
+		return
+	}
+	for _, t := range evs[0].Tags.ToSliceOfTags() {
+		if t.S(0) != "r" || t.S(1) == "" {
+			continue
+		}
+		if m := t.S(2); m != "" && m != "write" {
+			continue
+		}
+		u, err := url.Parse(t.S(1))
+		if err != nil || u.Scheme != "wss" || u.Hostname() == "" {
+			continue
+		}
+		if err = netaddr.CheckHost(c, u.Hostname()); err != nil {
+			log.D.F("not broadcasting to write relay of %0x: %v", pubkey, err)
+			continue
+		}
+		relays = append(relays, t.S(1))
+	}
+	return
+}
@@ -0,0 +1,41 @@
+package realy
+
+import (
+	"testing"
+
+	"realy.lol/event"
+	"realy.lol/kind"
+	"realy.lol/p256k"
+	"realy.lol/tag"
+	"realy.lol/tags"
+)
+
+func TestBroadcastable(t *testing.T) {
+	relay, user := &p256k.Signer{}, &p256k.Signer{}
+	for _, sign := range []*p256k.Signer{relay, user} {
+		if err := sign.Generate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := &Server{Superuser: relay}
+	mk := func(k *kind.T, author *p256k.Signer, tt ...*tag.T) *event.T {
+		return &event.T{Pubkey: author.Pub(), Kind: k, Tags: tags.New(tt...)}
+	}
+	for _, c := range []struct {
+		name string
+		ev   *event.T
+		ok   bool
+	}{
+		{"note", mk(kind.TextNote, user), true},
+		{"protected", mk(kind.TextNote, user, tag.New("-")), false},
+		{"direct message", mk(kind.EncryptedDirectMessage, user), false},
+		{"gift wrap", mk(kind.GiftWrap, user), false},
+		{"group post", mk(kind.TextNote, user, tag.New("h", "group")), false},
+		{"group metadata", mk(kind.GroupMetadata, user, tag.New("d", "group")), false},
+		{"relay signed", mk(kind.TextNote, relay), false},
+	} {
+		if ok := s.broadcastable(c.ev); ok != c.ok {
+			t.Errorf("%s: expected broadcastable %v, got %v", c.name, c.ok, ok)
+		}
+	}
+}
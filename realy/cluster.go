@@ -0,0 +1,101 @@
+package realy
+
+import (
+	"errors"
+	"strings"
+
+	"realy.lol/bech32encoding"
+	"realy.lol/cdc"
+	"realy.lol/chk"
+	"realy.lol/cluster"
+	"realy.lol/errorf"
+	"realy.lol/event"
+	"realy.lol/eventid"
+	"realy.lol/hex"
+	"realy.lol/log"
+	"realy.lol/publish"
+	"realy.lol/store"
+)
+
+// StartCluster serves the log of stored events to the configured cluster peers and starts
+// replicating their logs into this relay, which continues until the server context is
+// canceled.
+func (s *Server) StartCluster() {
+	peers := s.Configuration().ClusterPeers
+	if len(peers) == 0 {
+		return
+	}
+	if s.Superuser == nil || len(s.Superuser.Sec()) == 0 {
+		log.E.F("cluster peers require the superuser nsec to authenticate, not replicating")
+		return
+	}
+	cursors, ok := s.Store.(store.PeerCursorer)
+	if !ok {
+		log.E.F("event store cannot keep cluster cursors, not replicating")
+		return
+	}
+	var src cdc.Source
+	if src, ok = s.Store.(cdc.Source); !ok {
+		log.E.F("event store does not record its changes, not replicating")
+		return
+	}
+	var pubkeys [][]byte
+	for _, p := range peers {
+		pk, err := decodePubkey(p.Pubkey)
+		if err != nil {
+			log.E.F("invalid pubkey for cluster peer %s: %v", p.URL, err)
+			continue
+		}
+		pubkeys = append(pubkeys, pk)
+	}
+	s.Mux.Handle(cluster.Path, cluster.NewLog(src, func() [][]byte { return pubkeys }))
+	r := cluster.New(s.Superuser, cursors, s.addReplicated, s.deleteReplicated)
+	for _, p := range peers {
+		go r.Replicate(s.Ctx, p.URL)
+	}
+}
+
+// addReplicated stores an event received from a cluster peer and delivers it to the
+// subscriptions on this relay. The peer has already accepted it, and broadcast it if needed,
+// but this relay's own lists and limits still apply.
+//
+// An error is only returned if the event could not be stored, so that it is read again.
+func (s *Server) addReplicated(ev *event.T) (err error) {
+	if err = s.checkPulled(ev); err != nil {
+		log.D.F("not storing replicated event %0x: %v", ev.Id, err)
+		err = nil
+		return
+	}
+	if err = s.Publish(LocalOnly(s.Ctx), ev); err != nil {
+		if errors.Is(err, store.ErrDupEvent) {
+			err = nil
+			return
+		}
+		// a newer replacement may already be stored, or the event was deleted here.
+		if msg := err.Error(); NIP20prefixmatcher.MatchString(msg) ||
+			strings.Contains(msg, "tombstone") {
+			log.D.F("not storing replicated event %0x: %v", ev.Id, err)
+			err = nil
+		}
+		return
+	}
+	publish.P.Deliver(s.AuthRequired(), s.PublicReadable(), ev)
+	return
+}
+
+// deleteReplicated deletes an event that a cluster peer deleted, which also covers the events
+// that replaceable events replaced, group moderators removed and the NIP-86 API banned.
+func (s *Server) deleteReplicated(id []byte, tombstone bool) (err error) {
+	return s.Store.DeleteEvent(LocalOnly(s.Ctx), eventid.NewWith(id), !tombstone)
+}
+
+// decodePubkey decodes a pubkey in hex or npub format.
+func decodePubkey(s string) (pk []byte, err error) {
+	if pk, err = hex.Dec(s); err == nil && len(pk) == 32 {
+		return
+	}
+	if pk, err = bech32encoding.NpubToBytes([]byte(s)); chk.E(err) {
+		err = errorf.E("invalid pubkey %s", s)
+	}
+	return
+}
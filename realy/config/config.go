@@ -1,14 +1,104 @@
 package config
 
+import "slices"
+
 type C struct {
-	AppName        string   `json:"app_name" doc:"application name" default:"realy"`
-	AllowList      []string `json:"allow_list" doc:"List of allowed IP addresses"`
-	BlockList      []string `json:"block_list" doc:"list of IP addresses that will be ignored"`
-	Admins         []string `json:"admins" doc:"list of npubs that have admin access"`
-	Owners         []string `json:"owners" doc:"list of owner npubs whose follow lists set the whitelisted users and enables auth implicitly for all writes"`
-	AuthRequired   bool     `json:"auth_required" doc:"authentication is required for read and write" default:"false"`
-	PublicReadable bool     `json:"public_readable" doc:"authentication is relaxed for read except privileged events" default:"false"`
-	LogLevel       string   `json:"log_level" doc:"Log level" doc:"info"`
-	DBLogLevel     string   `json:"db_log_level" default:"info" doc:"database log level"`
-	LogTimestamp   bool     `json:"log_timestamp" default:"false" doc:"print log timestamp"`
+	AppName             string    `json:"app_name" doc:"application name" default:"realy"`
+	AllowList           []string  `json:"allow_list" doc:"List of allowed IP addresses"`
+	BlockList           []string  `json:"block_list" doc:"list of IP addresses that will be ignored"`
+	Admins              []string  `json:"admins" doc:"list of npubs that have admin access"`
+	Owners              []string  `json:"owners" doc:"list of owner npubs whose follow lists set the whitelisted users and enables auth implicitly for all writes"`
+	AuthRequired        bool      `json:"auth_required" doc:"authentication is required for read and write" default:"false"`
+	PublicReadable      bool      `json:"public_readable" doc:"authentication is relaxed for read except privileged events" default:"false"`
+	LogLevel            string    `json:"log_level" doc:"Log level" doc:"info"`
+	DBLogLevel          string    `json:"db_log_level" default:"info" doc:"database log level"`
+	LogTimestamp        bool      `json:"log_timestamp" default:"false" doc:"print log timestamp"`
+	Groups              bool      `json:"groups" default:"false" doc:"host NIP-29 relay based groups, requires the superuser nsec to sign group state"`
+	RelayName           string    `json:"relay_name,omitempty" doc:"relay name advertised in the relay information document, instead of the application name"`
+	RelayDescription    string    `json:"relay_description,omitempty" doc:"relay description advertised in the relay information document"`
+	RelayIcon           string    `json:"relay_icon,omitempty" doc:"URL of the relay icon advertised in the relay information document"`
+	RelayURL            string    `json:"relay_url,omitempty" doc:"public websocket URL of the relay, which a user's DM relay list must name for gift wraps to them to be accepted, the URL clients connect to if not set"`
+	BannedPubkeys       []Entry   `json:"banned_pubkeys,omitempty" doc:"hex pubkeys that may not publish events to the relay"`
+	AllowedPubkeys      []Entry   `json:"allowed_pubkeys,omitempty" doc:"if not empty, only these hex pubkeys may publish events to the relay"`
+	BannedEvents        []Entry   `json:"banned_events,omitempty" doc:"hex ids of events that have been removed and may not be published again"`
+	AllowedKinds        []int     `json:"allowed_kinds,omitempty" doc:"if not empty, only events of these kinds are accepted"`
+	MaxMessageLength    int       `json:"max_message_length,omitempty" doc:"maximum size in bytes of a websocket message, the default is 1Mb"`
+	MaxContentLength    int       `json:"max_content_length,omitempty" doc:"maximum number of characters in the content of an event, 0 is unlimited"`
+	MaxEventTags        int       `json:"max_event_tags,omitempty" doc:"maximum number of tags in an event, 0 is unlimited"`
+	CreatedAtLowerLimit int64     `json:"created_at_lower_limit,omitempty" doc:"how many seconds in the past the created_at of a new event may be, 0 is unlimited"`
+	CreatedAtUpperLimit int64     `json:"created_at_upper_limit,omitempty" doc:"how many seconds in the future the created_at of a new event may be, 0 is unlimited"`
+	ValidateZaps        bool      `json:"validate_zaps,omitempty" doc:"reject NIP-57 zap receipts whose invoice is not validly signed or does not match the amount and zap request they embed, or that are not signed by the LNURL server in the stored profile of the recipient"`
+	Mirrors             []Mirror  `json:"mirrors,omitempty" doc:"upstream relays to pull events from into this relay, changes take effect on restart"`
+	PowRules            []PowRule `json:"pow_rules,omitempty" doc:"minimum NIP-13 proof of work difficulty required of events by access tier and kind"`
+	BroadcastRelays     []string  `json:"broadcast_relays,omitempty" doc:"websocket URLs of relays that events published to this relay are also sent to"`
+	BroadcastOutbox     bool      `json:"broadcast_outbox,omitempty" doc:"also send events published to this relay to the write relays in the author's NIP-65 relay list that are wss:// URLs of public hosts"`
+	Blossom             bool      `json:"blossom,omitempty" doc:"serve a Blossom blob server for the users that may publish to the relay, changes take effect on restart"`
+	NIP96               bool      `json:"nip96,omitempty" doc:"serve NIP-96 file storage for the users that may publish to the relay, sharing the Blossom blobs and quota, changes take effect on restart"`
+	BlossomMaxSize      int64     `json:"blossom_max_size,omitempty" doc:"largest blob in bytes that may be uploaded to the Blossom or NIP-96 server, 0 is unlimited"`
+	BlossomQuota        int64     `json:"blossom_quota,omitempty" doc:"total size in bytes of the blobs each pubkey may store on the Blossom and NIP-96 servers together, 0 is unlimited"`
+	NIP05               bool      `json:"nip05,omitempty" doc:"serve /.well-known/nostr.json from the registry of names managed through the HTTP API, changes take effect on restart"`
+	NIP05SelfRegister   bool      `json:"nip05_self_register,omitempty" doc:"let the users that may publish to the relay register a name for themselves"`
+	NIP05Domains        []string  `json:"nip05_domains,omitempty" doc:"if not empty, only pubkeys whose profile has a NIP-05 identifier that verifies on one of these domains may publish events"`
+	NIP05MaxAge         int64     `json:"nip05_max_age,omitempty" doc:"how many seconds a NIP-05 verification is trusted before it is checked again, the default is one day, changes take effect on restart"`
+	AdmissionFees       []Fee     `json:"admission_fees,omitempty" doc:"prices of time limited write access paid to the NWC wallet of the relay, if not empty only paying users may publish events"`
+	ClusterPeers        []Peer    `json:"cluster_peers,omitempty" doc:"other nodes of a cluster that this relay replicates events with, requires the superuser nsec, changes take effect on restart"`
+}
+
+// Entry is a pubkey or event id on a list managed through the NIP-86 relay
+// management API, with the reason given for putting it there.
+type Entry struct {
+	Value  string `json:"value"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Mirror is an upstream relay and the events to pull from it.
+type Mirror struct {
+	URL      string   `json:"url" doc:"websocket URL of the upstream relay"`
+	Followed bool     `json:"followed,omitempty" doc:"pull the events of the owners and the users on their follow lists"`
+	Filters  []string `json:"filters,omitempty" doc:"nostr filters as JSON objects selecting further events to pull"`
+}
+
+// Peer is another node of a cluster of relays that replicate their events to each other.
+type Peer struct {
+	URL    string `json:"url" doc:"HTTP base URL of the peer, such as https://relay.example.com"`
+	Pubkey string `json:"pubkey" doc:"npub or hex pubkey of the peer relay key, which it authenticates with"`
+}
+
+// Fee is the price of write access to the relay for a period of time.
+type Fee struct {
+	Amount int64 `json:"amount" doc:"price in millisatoshis"`
+	Period int64 `json:"period" doc:"seconds of write access the payment buys"`
+}
+
+// The access tiers that proof of work rules apply to. The superuser, admins, owners and the
+// users on the owners' follow lists are trusted and never need to do proof of work.
+const (
+	// PowTierAnonymous is a client that has not authenticated.
+	PowTierAnonymous = "anonymous"
+	// PowTierGuest is an authenticated user followed by the users the owners follow.
+	PowTierGuest = "guest"
+	// PowTierAuthed is any other authenticated user.
+	PowTierAuthed = "authed"
+)
+
+// PowRule is a minimum proof of work difficulty for events from clients in an access tier.
+type PowRule struct {
+	Tier       string `json:"tier" enum:"anonymous,guest,authed" doc:"access tier the rule applies to"`
+	Kinds      []int  `json:"kinds,omitempty" doc:"kinds the rule applies to, all kinds if empty"`
+	Difficulty int    `json:"difficulty" doc:"minimum number of leading zero bits of the event id"`
+}
+
+// PowDifficulty returns the highest difficulty of the rules that apply to an event of a kind
+// from a client in an access tier.
+func (c C) PowDifficulty(tier string, k uint16) (difficulty int) {
+	for _, r := range c.PowRules {
+		if r.Tier != tier || r.Difficulty <= difficulty {
+			continue
+		}
+		if len(r.Kinds) > 0 && !slices.Contains(r.Kinds, int(k)) {
+			continue
+		}
+		difficulty = r.Difficulty
+	}
+	return
 }
@@ -0,0 +1,262 @@
+package realy
+
+import (
+	"bytes"
+	"sort"
+
+	"realy.lol/chk"
+	"realy.lol/context"
+	"realy.lol/event"
+	"realy.lol/eventid"
+	"realy.lol/filter"
+	"realy.lol/groups"
+	"realy.lol/kind"
+	"realy.lol/kinds"
+	"realy.lol/log"
+	"realy.lol/publish"
+	"realy.lol/reason"
+	"realy.lol/store"
+	"realy.lol/tag"
+	"realy.lol/timestamp"
+)
+
+// groupKinds are the NIP-29 events that change the state of a group.
+var groupKinds = kinds.New(
+	kind.GroupPutUser,
+	kind.GroupRemoveUser,
+	kind.GroupEditMetadata,
+	kind.GroupDeleteEvent,
+	kind.GroupCreateGroup,
+	kind.GroupDeleteGroup,
+	kind.GroupCreateInvite,
+	kind.GroupJoinRequest,
+	kind.GroupLeaveRequest,
+)
+
+// Groups returns the group state store if the relay hosts NIP-29 groups. This requires the
+// configuration to enable it, a store that can keep the group state, and the superuser
+// secret key to sign the group state events.
+func (s *Server) Groups() (gs store.Grouper, ok bool) {
+	if !s.Configuration().Groups || s.Superuser == nil || len(s.Superuser.Sec()) == 0 {
+		return
+	}
+	gs, ok = s.Store.(store.Grouper)
+	return
+}
+
+// isGroupEvent returns true if the event is posted to a group or is a group moderation event.
+func isGroupEvent(ev *event.T) bool {
+	return groups.Id(ev) != "" || ev.Kind.IsGroupModeration() ||
+		ev.Kind.OneOf(kind.GroupJoinRequest, kind.GroupLeaveRequest)
+}
+
+// mayCreateGroup returns true if the pubkey is permitted to create new groups: the superuser,
+// the admins, and if owners are configured, the users on their follow lists.
+//
+// This must be called with the Server mutex locked.
+func (s *Server) mayCreateGroup(pk []byte) bool {
+	if bytes.Equal(pk, s.Superuser.Pub()) {
+		return true
+	}
+	for _, a := range s.admins {
+		if bytes.Equal(pk, a.Pub()) {
+			return true
+		}
+	}
+	if len(s.owners) == 0 {
+		return true
+	}
+	_, ok := s.followed[string(pk)]
+	return ok
+}
+
+// acceptGroupEvent decides whether to accept an event posted to a NIP-29 group. Group events
+// are governed by the group membership instead of the relay access control lists. Handled is
+// false if groups are not enabled or the event is not a group event.
+//
+// This must be called with the Server mutex locked.
+func (s *Server) acceptGroupEvent(c context.T, evt *event.T) (accept bool, notice string,
+	afterSave func(), handled bool) {
+
+	var gs store.Grouper
+	var ok bool
+	if gs, ok = s.Groups(); !ok || !isGroupEvent(evt) {
+		return
+	}
+	handled = true
+	if evt.Kind.Equal(kind.GroupCreateGroup) && !s.mayCreateGroup(evt.Pubkey) {
+		notice = string(reason.Restricted.F("%0x may not create groups on this relay",
+			evt.Pubkey))
+		return
+	}
+	var err error
+	var g *groups.Group
+	if g, err = gs.GetGroup(groups.Id(evt)); chk.E(err) {
+		notice = string(reason.Error.F(err.Error()))
+		return
+	}
+	if err = groups.Check(g, evt, s.Superuser.Pub()); err != nil {
+		notice = err.Error()
+		return
+	}
+	accept = true
+	if evt.Kind.OneOf(groupKinds.K...) {
+		afterSave = func() { s.applyGroupEvent(context.Bg(), gs, evt) }
+	}
+	return
+}
+
+// applyGroupEvent changes the state of a group according to a stored moderation event,
+// stores the new state and publishes the relay signed group state events.
+func (s *Server) applyGroupEvent(c context.T, gs store.Grouper, evt *event.T) {
+	s.groupsMx.Lock()
+	defer s.groupsMx.Unlock()
+	var err error
+	var g *groups.Group
+	id := groups.Id(evt)
+	if g, err = gs.GetGroup(id); chk.E(err) {
+		return
+	}
+	// the group may have changed since the event was accepted
+	if err = groups.Check(g, evt, s.Superuser.Pub()); err != nil {
+		log.D.F("not applying %0x to group %s: %v", evt.Id, id, err)
+		return
+	}
+	var ids [][]byte
+	if g, ids, err = groups.Apply(g, evt); chk.E(err) {
+		return
+	}
+	if len(ids) > 0 {
+		// a group admin may only delete the events posted to their group.
+		var evs event.Ts
+		if evs, err = s.Store.QueryEvents(c, &filter.T{IDs: tag.New(ids...)}); chk.E(err) {
+			return
+		}
+		for _, ev := range groups.InGroup(id, evs) {
+			if err = s.Store.DeleteEvent(c, eventid.NewWith(ev.Id)); chk.E(err) {
+				continue
+			}
+		}
+	}
+	if g == nil {
+		log.I.F("deleting group %s", id)
+		chk.E(gs.DeleteGroup(id))
+		return
+	}
+	if err = gs.SetGroup(g); chk.E(err) {
+		return
+	}
+	s.publishGroup(c, g)
+}
+
+// publishGroup signs, stores and delivers the group state events of a group.
+func (s *Server) publishGroup(c context.T, g *groups.Group) {
+	var err error
+	var evs event.Ts
+	if evs, err = g.Events(s.Superuser); chk.E(err) {
+		return
+	}
+	for _, ev := range evs {
+		// the group state is particular to this relay and is not broadcast.
+		if err = s.Publish(LocalOnly(c), ev); chk.E(err) {
+			continue
+		}
+		publish.P.Deliver(s.AuthRequired(), s.PublicReadable(), ev)
+	}
+}
+
+// CheckGroups regenerates the state of the NIP-29 groups from the stored moderation events if
+// there is no stored group state.
+func (s *Server) CheckGroups(c context.T) {
+	var gs store.Grouper
+	var ok bool
+	if gs, ok = s.Groups(); !ok {
+		return
+	}
+	var err error
+	var gg []*groups.Group
+	if gg, err = gs.Groups(); chk.E(err) || len(gg) > 0 {
+		return
+	}
+	if err = s.RebuildGroups(c); chk.E(err) {
+		return
+	}
+}
+
+// RebuildGroups replays all stored group moderation events in chronological order to
+// regenerate the state of the NIP-29 groups, and republishes the group state events.
+func (s *Server) RebuildGroups(c context.T) (err error) {
+	var gs store.Grouper
+	var ok bool
+	if gs, ok = s.Groups(); !ok {
+		return
+	}
+	// fetch the moderation events, paging backwards through time.
+	var all event.Ts
+	until := timestamp.Now()
+	for {
+		var evs event.Ts
+		if evs, err = s.Store.QueryEvents(c, &filter.T{Kinds: groupKinds,
+			Until: until}); chk.E(err) {
+			return
+		}
+		if len(evs) == 0 {
+			break
+		}
+		all = append(all, evs...)
+		sort.Sort(evs)
+		until = timestamp.FromUnix(evs[len(evs)-1].CreatedAt.I64() - 1)
+	}
+	sort.Sort(event.Ascending(all))
+	log.I.F("rebuilding groups from %d moderation events", len(all))
+	state := make(map[string]*groups.Group)
+	seen := make(map[string]struct{})
+	for _, ev := range all {
+		if _, ok = seen[string(ev.Id)]; ok {
+			continue
+		}
+		seen[string(ev.Id)] = struct{}{}
+		id := groups.Id(ev)
+		g := state[id]
+		if err = groups.Check(g, ev, s.Superuser.Pub()); err != nil {
+			err = nil
+			continue
+		}
+		if g, _, err = groups.Apply(g, ev); chk.E(err) {
+			err = nil
+			continue
+		}
+		state[id] = g
+	}
+	s.groupsMx.Lock()
+	defer s.groupsMx.Unlock()
+	for id, g := range state {
+		if g == nil {
+			chk.E(gs.DeleteGroup(id))
+			continue
+		}
+		if err = gs.SetGroup(g); chk.E(err) {
+			return
+		}
+		s.publishGroup(c, g)
+	}
+	return
+}
+
+// ReadableBy returns true if an event may be sent to a client authed to the given pubkey,
+// which keeps the events posted to private NIP-29 groups to the members of the group.
+func (s *Server) ReadableBy(ev *event.T, authedPubkey []byte) (ok bool) {
+	var gs store.Grouper
+	if gs, ok = s.Groups(); !ok {
+		return true
+	}
+	id := groups.Id(ev)
+	if id == "" {
+		return true
+	}
+	g, err := gs.GetGroup(id)
+	if chk.E(err) || g == nil {
+		return true
+	}
+	return !g.Private || g.IsMember(authedPubkey)
+}
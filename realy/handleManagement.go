@@ -0,0 +1,128 @@
+package realy
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+
+	"realy.lol/chk"
+	"realy.lol/errorf"
+	"realy.lol/event"
+	"realy.lol/eventid"
+	"realy.lol/hex"
+	"realy.lol/httpauth"
+	"realy.lol/log"
+	"realy.lol/realy/config"
+	"realy.lol/realy/helpers"
+	"realy.lol/reason"
+	"realy.lol/relaymanagement"
+	"realy.lol/store"
+)
+
+// HandleManagement serves the NIP-86 relay management API. Requests must be authorized with
+// NIP-98 by the superuser or one of the admins, with the hash of the request body in the
+// `payload` tag.
+func (s *Server) HandleManagement(w http.ResponseWriter, r *http.Request) {
+	remote := helpers.GetRemoteFromReq(r)
+	w.Header().Set("Content-Type", relaymanagement.ContentType)
+	reply := func(status int, res relaymanagement.Response) {
+		w.WriteHeader(status)
+		chk.E(json.NewEncoder(w).Encode(res))
+	}
+	authed, pubkey := s.AdminAuth(r, remote)
+	if !authed {
+		reply(http.StatusUnauthorized, relaymanagement.Response{Error: "unauthorized"})
+		return
+	}
+	var err error
+	var body []byte
+	if body, err = io.ReadAll(io.LimitReader(r.Body, 1<<16)); chk.E(err) {
+		reply(http.StatusBadRequest, relaymanagement.Response{Error: err.Error()})
+		return
+	}
+	// the authorization must be for this request body, or it could be replayed with another
+	// method call within its time window.
+	if err = httpauth.CheckPayload(r, body); err != nil {
+		log.I.F("%s management request from %0x: %v", remote, pubkey, err)
+		reply(http.StatusUnauthorized, relaymanagement.Response{Error: err.Error()})
+		return
+	}
+	var req *relaymanagement.Request
+	if req, err = relaymanagement.Unmarshal(body); chk.E(err) {
+		reply(http.StatusBadRequest, relaymanagement.Response{Error: err.Error()})
+		return
+	}
+	log.I.F("%s management request %s from %0x", remote, req.Method, pubkey)
+	var res any
+	if res, err = s.manage(req); err != nil {
+		reply(http.StatusOK, relaymanagement.Response{Error: err.Error()})
+		return
+	}
+	reply(http.StatusOK, relaymanagement.Response{Result: res})
+}
+
+// manage performs a NIP-86 method call, storing the configuration if it was changed, and
+// deleting banned events from the event store.
+func (s *Server) manage(req *relaymanagement.Request) (res any, err error) {
+	s.managementMx.Lock()
+	defer s.managementMx.Unlock()
+	cfg := s.Configuration()
+	var changed bool
+	if res, changed, err = relaymanagement.Do(&cfg, req); err != nil {
+		return
+	}
+	if !changed {
+		return
+	}
+	c, ok := s.Store.(store.Configurationer)
+	if !ok {
+		err = errorf.E("event store cannot save the configuration")
+		return
+	}
+	if err = c.SetConfiguration(cfg); chk.E(err) {
+		return
+	}
+	if err = s.UpdateConfiguration(); chk.E(err) {
+		return
+	}
+	if req.Method == relaymanagement.BanEvent {
+		var id []byte
+		if id, err = hex.Dec(req.Params[0].(string)); chk.E(err) {
+			return
+		}
+		if err = s.Store.DeleteEvent(s.Ctx, eventid.NewWith(id)); err != nil {
+			// the event may not be stored, it is banned regardless.
+			log.D.F("deleting banned event %0x: %v", id, err)
+			err = nil
+		}
+	}
+	return
+}
+
+// checkManagedPubkey returns a notice if the author of an event is rejected by the banned or
+// allowed pubkeys maintained through the NIP-86 relay management API.
+func checkManagedPubkey(cfg config.C, ev *event.T) (notice string) {
+	pk := hex.Enc(ev.Pubkey)
+	switch {
+	case relaymanagement.Contains(cfg.BannedPubkeys, pk):
+		notice = string(reason.Blocked.F("pubkey %s has been banned from this relay", pk))
+	case len(cfg.AllowedPubkeys) > 0 && !relaymanagement.Contains(cfg.AllowedPubkeys, pk):
+		notice = string(reason.Restricted.F("pubkey %s is not allowed to publish to this relay",
+			pk))
+	}
+	return
+}
+
+// checkManagedEvent returns a notice if an event is rejected by the NIP-86 banned events or
+// allowed kinds, which apply regardless of who the author is, including to gift wraps whose
+// author is a random key.
+func checkManagedEvent(cfg config.C, ev *event.T) (notice string) {
+	switch {
+	case relaymanagement.Contains(cfg.BannedEvents, hex.Enc(ev.Id)):
+		notice = string(reason.Blocked.F("event has been banned from this relay"))
+	case len(cfg.AllowedKinds) > 0 && !slices.Contains(cfg.AllowedKinds, int(ev.Kind.K)):
+		notice = string(reason.Restricted.F("kind %d is not accepted by this relay", ev.Kind.K))
+	}
+	return
+}
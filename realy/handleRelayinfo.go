@@ -8,7 +8,10 @@ import (
 	"realy.lol"
 	"realy.lol/chk"
 	"realy.lol/log"
+	"realy.lol/realy/config"
 	"realy.lol/relayinfo"
+	"realy.lol/socketapi"
+	"realy.lol/timestamp"
 )
 
 func (s *Server) HandleRelayInfo(w http.ResponseWriter, r *http.Request) {
@@ -28,21 +31,66 @@ func (s *Server) HandleRelayInfo(w http.ResponseWriter, r *http.Request) {
 		relayinfo.ExpirationTimestamp,
 		relayinfo.ProtectedEvents,
 		relayinfo.RelayListMetadata,
+		relayinfo.PrivateDirectMessages,
+		relayinfo.GiftWrap,
+		relayinfo.RelayManagementAPI,
 	)
 	if s.ServiceURL(r) != "" {
 		supportedNIPs = append(supportedNIPs, relayinfo.Authentication.N())
 	}
+	if _, ok := s.Groups(); ok {
+		supportedNIPs = append(supportedNIPs, relayinfo.RelayBasedGroups.N())
+	}
+	cfg := s.Configuration()
+	// the difficulty required of anonymous clients for all kinds applies to every new event.
+	var minPow int
+	for _, rule := range cfg.PowRules {
+		if rule.Tier == config.PowTierAnonymous && len(rule.Kinds) == 0 && rule.Difficulty > minPow {
+			minPow = rule.Difficulty
+		}
+	}
+	if len(cfg.PowRules) > 0 {
+		supportedNIPs = append(supportedNIPs, relayinfo.ProofOfWork.N())
+	}
 	sort.Sort(supportedNIPs)
 	log.T.Ln("supported NIPs", supportedNIPs)
-	info = &relayinfo.T{Name: s.Name,
-		Description: realy_lol.Description,
+	name, description, icon := s.Name, realy_lol.Description,
+		"https://cdn.satellite.earth/ac9778868fbf23b63c47c769a74e163377e6ea94d3f0f31711931663d035c4f6.png"
+	if cfg.RelayName != "" {
+		name = cfg.RelayName
+	}
+	if cfg.RelayDescription != "" {
+		description = cfg.RelayDescription
+	}
+	if cfg.RelayIcon != "" {
+		icon = cfg.RelayIcon
+	}
+	maxMessageLength := cfg.MaxMessageLength
+	if maxMessageLength == 0 {
+		maxMessageLength = socketapi.DefaultMaxMessageSize
+	}
+	info = &relayinfo.T{Name: name,
+		Description: description,
 		Nips:        supportedNIPs, Software: realy_lol.URL, Version: realy_lol.Version,
 		Limitation: relayinfo.Limits{
 			MaxLimit:         s.MaxLimit,
+			MinPowDifficulty: minPow,
+			MaxMessageLength: maxMessageLength,
+			MaxContentLength: cfg.MaxContentLength,
+			MaxEventTags:     cfg.MaxEventTags,
 			AuthRequired:     s.AuthRequired(),
-			RestrictedWrites: !s.PublicReadable() || s.AuthRequired() || len(s.owners) > 0,
+			RestrictedWrites: !s.PublicReadable() || s.AuthRequired() || len(s.owners) > 0 ||
+				len(cfg.AllowedPubkeys) > 0 || len(cfg.AllowedKinds) > 0 ||
+				len(cfg.AdmissionFees) > 0,
 		},
-		Icon: "https://cdn.satellite.earth/ac9778868fbf23b63c47c769a74e163377e6ea94d3f0f31711931663d035c4f6.png"}
+		Icon: icon}
+	info.Fees, info.PaymentsURL = s.admissionFees(r, cfg)
+	if cfg.CreatedAtLowerLimit > 0 {
+		info.Limitation.Oldest = timestamp.FromUnix(cfg.CreatedAtLowerLimit)
+	}
+	if cfg.CreatedAtUpperLimit > 0 {
+		info.Limitation.Newest = timestamp.FromUnix(cfg.CreatedAtUpperLimit)
+	}
 	if err := json.NewEncoder(w).Encode(info); chk.E(err) {
 	}
 }
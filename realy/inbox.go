@@ -0,0 +1,75 @@
+package realy
+
+import (
+	"bytes"
+	"net/http"
+
+	"realy.lol/chk"
+	"realy.lol/context"
+	"realy.lol/event"
+	"realy.lol/filter"
+	"realy.lol/kind"
+	"realy.lol/kinds"
+	"realy.lol/log"
+	"realy.lol/normalize"
+	"realy.lol/tag"
+)
+
+// acceptInbox returns true if the event is a gift wrap addressed to a user of the relay.
+//
+// The author of a gift wrap is a random single use key, so it cannot be matched against the
+// access control lists, and for the relay to act as a NIP-17 inbox it must accept wraps for
+// its users from anyone. A recipient is a user of the relay if they are on the owners' follow
+// lists, or if their kind 10050 DM relay list stored on this relay names this relay, whose
+// URL is relayURL.
+//
+// This must be called with the Server mutex locked.
+func (s *Server) acceptInbox(c context.T, evt *event.T, relayURL string) (accept bool) {
+	if !evt.Kind.IsGiftWrap() {
+		return
+	}
+	recipients := evt.Recipients()
+	if len(recipients) == 0 {
+		return
+	}
+	for _, pk := range recipients {
+		if _, ok := s.followed[string(pk)]; ok {
+			log.T.F("accepting gift wrap %0x for followed user %0x", evt.Id, pk)
+			return true
+		}
+	}
+	var err error
+	var evs event.Ts
+	if evs, err = s.Store.QueryEvents(c, &filter.T{Authors: tag.New(recipients...),
+		Kinds: kinds.New(kind.DMRelaysList)}); chk.E(err) {
+		return
+	}
+	// the lists of mirrored users are stored too, so the list must name this relay.
+	self := normalize.URL(relayURL)
+	if len(self) == 0 {
+		return
+	}
+	for _, ev := range evs {
+		for _, t := range ev.Tags.ToSliceOfTags() {
+			if t.Len() >= 2 && bytes.Equal(t.Key(), []byte("relay")) &&
+				bytes.Equal(normalize.URL(t.Value()), self) {
+				log.T.F("accepting gift wrap %0x for user %0x with DM relay list", evt.Id,
+					ev.Pubkey)
+				return true
+			}
+		}
+	}
+	return
+}
+
+// relayURL returns the websocket URL of the relay, the configured RelayURL if it is set, or
+// else the URL the client of a request connected to, if there is one.
+func (s *Server) relayURL(hr *http.Request) string {
+	if u := s.Configuration().RelayURL; u != "" {
+		return u
+	}
+	if hr == nil {
+		return ""
+	}
+	return RelayURL(hr)
+}
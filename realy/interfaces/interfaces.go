@@ -20,6 +20,7 @@ type Server interface {
 	CheckOwnerLists(c context.T)
 	Configuration() config.C
 	Context() context.T
+	HandleManagement(w http.ResponseWriter, r *http.Request)
 	HandleRelayInfo(w http.ResponseWriter, r *http.Request)
 	Lock()
 	Owners() [][]byte
@@ -33,3 +34,17 @@ type Server interface {
 	UpdateConfiguration() (err error)
 	ZeroLists()
 }
+
+// EventReader is implemented by a Server that restricts which stored events may be read by an
+// authenticated pubkey beyond the rules for privileged kinds.
+type EventReader interface {
+	ReadableBy(ev *event.T, authedPubkey []byte) (ok bool)
+}
+
+// Registrar is implemented by a Server that serves a registry of NIP-05 names.
+type Registrar interface {
+	// Names returns the registry of NIP-05 names if the relay serves them.
+	Names() (reg store.Namer, ok bool)
+	// MayRegisterName returns true if a pubkey may register a name for itself.
+	MayRegisterName(pubkey []byte) bool
+}
@@ -0,0 +1,110 @@
+package realy
+
+import (
+	"bytes"
+	"slices"
+
+	"realy.lol/chk"
+	"realy.lol/errorf"
+	"realy.lol/event"
+	"realy.lol/filter"
+	"realy.lol/filters"
+	"realy.lol/log"
+	"realy.lol/mirror"
+	"realy.lol/realy/config"
+	"realy.lol/signer"
+	"realy.lol/store"
+	"realy.lol/tag"
+	"realy.lol/ws"
+)
+
+// StartMirrors starts pulling events from the upstream relays in the configuration, which
+// continues until the server context is canceled.
+func (s *Server) StartMirrors() {
+	mirrors := s.Configuration().Mirrors
+	if len(mirrors) == 0 {
+		return
+	}
+	cursors, ok := s.Store.(store.Cursorer)
+	if !ok {
+		log.E.F("event store cannot keep mirror cursors, not mirroring")
+		return
+	}
+	// the mirror checks the signatures of the events it receives, in batches when backfilling,
+	// so the pool does not.
+	pool := s.newPool()
+	pool.SignatureChecker = func(*event.T) bool { return true }
+	m := &mirror.M{
+		Pool:    pool,
+		Cursors: cursors,
+		Auth:    s.relayAuth(),
+		Add: func(ev *event.T) (err error) {
+			if err = s.checkPulled(ev); err != nil {
+				return
+			}
+			if ok, msg := s.AddEvent(LocalOnly(s.Ctx), ev, nil, nil, "mirror"); !ok {
+				err = errorf.D("%s", msg)
+			}
+			return
+		},
+	}
+	for _, mi := range mirrors {
+		ff := s.mirrorFilters(mi)
+		if !mi.Followed && ff().Len() == 0 {
+			log.W.F("no filters to mirror from %s", mi.URL)
+			continue
+		}
+		go m.Mirror(s.Ctx, mi.URL, ff)
+	}
+}
+
+// newPool creates a pool of connections to other relays, which authenticates as the relay
+// itself to relays that require it when the superuser secret key is available.
+func (s *Server) newPool() *ws.Pool {
+	var opts []ws.PoolOption
+	if auth := s.relayAuth(); auth != nil {
+		opts = append(opts, ws.WithAuthHandler(auth))
+	}
+	return ws.NewPool(s.Ctx, opts...)
+}
+
+// relayAuth returns the key to authenticate to other relays with, which is the superuser if
+// its secret key is available, or nil if it is not.
+func (s *Server) relayAuth() func() signer.I {
+	if s.Superuser == nil || len(s.Superuser.Sec()) == 0 {
+		return nil
+	}
+	return func() signer.I { return s.Superuser }
+}
+
+// mirrorFilters returns a function that returns the filters selecting the events to pull from
+// an upstream relay, with the currently followed users if they are mirrored.
+func (s *Server) mirrorFilters(mi config.Mirror) func() *filters.T {
+	var configured []*filter.T
+	for _, js := range mi.Filters {
+		f := filter.New()
+		if _, err := f.Unmarshal([]byte(js)); chk.E(err) {
+			log.E.F("invalid mirror filter for %s: %s", mi.URL, js)
+			continue
+		}
+		configured = append(configured, f)
+	}
+	return func() (ff *filters.T) {
+		ff = filters.New()
+		if mi.Followed {
+			var authors [][]byte
+			s.Lock()
+			for pk := range s.followed {
+				authors = append(authors, []byte(pk))
+			}
+			s.Unlock()
+			// the authors are sorted so that the filter only changes when the users do.
+			slices.SortFunc(authors, bytes.Compare)
+			if len(authors) > 0 {
+				ff.F = append(ff.F, &filter.T{Authors: tag.New(authors...)})
+			}
+		}
+		ff.F = append(ff.F, configured...)
+		return
+	}
+}
@@ -0,0 +1,42 @@
+package realy
+
+import (
+	"net/http"
+
+	"realy.lol/dns"
+	"realy.lol/log"
+	"realy.lol/store"
+)
+
+// Names returns the registry of NIP-05 names if the relay serves them, which requires the
+// configuration to enable it and a store that can keep the registry.
+func (s *Server) Names() (reg store.Namer, ok bool) {
+	if !s.Configuration().NIP05 {
+		return
+	}
+	reg, ok = s.Store.(store.Namer)
+	return
+}
+
+// MayRegisterName returns true if a pubkey may register a NIP-05 name for itself, which is
+// so if self registration is enabled and the pubkey may publish to the relay.
+func (s *Server) MayRegisterName(pubkey []byte) bool {
+	return s.Configuration().NIP05SelfRegister && s.mayUpload(pubkey)
+}
+
+// StartNames adds the NIP-05 document of the registered names to the server if it is
+// enabled. Each name is given the relay itself as its relay hint.
+func (s *Server) StartNames() {
+	reg, ok := s.Names()
+	if !ok {
+		if s.Configuration().NIP05 {
+			log.E.F("event store cannot keep a names registry, not serving nip-05")
+		}
+		return
+	}
+	s.Mux.Handle(dns.WellKnownPath, &dns.WellKnown{
+		Registry: reg,
+		Relays:   func(r *http.Request) []string { return []string{RelayURL(r)} },
+	})
+	log.I.F("serving nip-05 names at %s", dns.WellKnownPath)
+}
@@ -0,0 +1,130 @@
+package realy
+
+import (
+	"bytes"
+	"strings"
+	"time"
+
+	"realy.lol/chk"
+	"realy.lol/context"
+	"realy.lol/dns"
+	"realy.lol/event"
+	"realy.lol/filter"
+	"realy.lol/kind"
+	"realy.lol/kinds"
+	"realy.lol/log"
+	"realy.lol/realy/config"
+	"realy.lol/store"
+	"realy.lol/tag"
+)
+
+// DefaultNIP05MaxAge is how long a NIP-05 verification is trusted if the configuration does
+// not say.
+const DefaultNIP05MaxAge = 24 * time.Hour
+
+// StartNIP05Verification creates the verifier of the NIP-05 identifiers of publishers, if the
+// store can keep its results, and starts verifying the stored results again as they expire.
+// Whether publishers must be verified is decided by the configured domains, so it can be
+// changed without a restart.
+func (s *Server) StartNIP05Verification() {
+	results, ok := s.Store.(store.Verifier)
+	if !ok {
+		if len(s.Configuration().NIP05Domains) > 0 {
+			log.E.F("event store cannot keep nip-05 verifications, rejecting all events " +
+				"from unverified publishers")
+		}
+		return
+	}
+	maxAge := time.Duration(s.Configuration().NIP05MaxAge) * time.Second
+	if maxAge <= 0 {
+		maxAge = DefaultNIP05MaxAge
+	}
+	s.verifier = &dns.Verifier{
+		Results:    results,
+		Domains:    func() []string { return s.Configuration().NIP05Domains },
+		MaxAge:     maxAge,
+		Identifier: s.profileIdentifier,
+		Base:       s.NIP05Base,
+	}
+	go s.verifier.Run(s.Ctx, maxAge/4)
+}
+
+// profileIdentifier returns the NIP-05 identifier in the latest stored profile of a pubkey.
+func (s *Server) profileIdentifier(c context.T, pubkey []byte) (identifier string,
+	err error) {
+	var content []byte
+	if content, err = s.profileContent(c, pubkey); err != nil || content == nil {
+		return
+	}
+	return dns.ProfileIdentifier(content), nil
+}
+
+// profileContent returns the content of the latest stored profile of a pubkey, or nil if it
+// has none.
+func (s *Server) profileContent(c context.T, pubkey []byte) (content []byte, err error) {
+	var evs event.Ts
+	if evs, err = s.Store.QueryEvents(c, &filter.T{Authors: tag.New(pubkey),
+		Kinds: kinds.New(kind.ProfileMetadata)}); chk.E(err) {
+		return
+	}
+	if len(evs) == 0 {
+		return
+	}
+	return evs[0].Content, nil
+}
+
+// nip05Exempt returns true if a pubkey may publish without a verified NIP-05 identifier,
+// which is so for the superuser, the admins and the owners.
+func (s *Server) nip05Exempt(pubkey []byte) bool {
+	s.Lock()
+	defer s.Unlock()
+	if s.Superuser != nil && bytes.Equal(pubkey, s.Superuser.Pub()) {
+		return true
+	}
+	for _, a := range s.admins {
+		if bytes.Equal(pubkey, a.Pub()) {
+			return true
+		}
+	}
+	for _, o := range s.owners {
+		if bytes.Equal(pubkey, o) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkNIP05 returns a notice if NIP-05 domains are configured and the author of an event does
+// not have a verified identifier on one of them. A profile is checked against the identifier
+// it carries, so that a user can publish the profile that verifies them.
+//
+// The Id and signature of the event must have been checked, so that a forged profile cannot
+// be used to look up identifiers for another pubkey.
+//
+// This makes requests to the domains of unverified identifiers, so it must not be called with
+// the Server mutex locked.
+func (s *Server) checkNIP05(c context.T, cfg config.C, evt *event.T) (notice string) {
+	if len(cfg.NIP05Domains) == 0 || isLocalOnly(c) || evt.Kind.IsGiftWrap() ||
+		s.nip05Exempt(evt.Pubkey) {
+		return
+	}
+	restricted := "restricted: publishing requires a verified nip-05 identifier on " +
+		strings.Join(cfg.NIP05Domains, ", ")
+	if s.verifier == nil {
+		return restricted
+	}
+	var identifier string
+	if evt.Kind.Equal(kind.ProfileMetadata) {
+		if identifier = dns.ProfileIdentifier(evt.Content); identifier == "" {
+			return restricted
+		}
+	}
+	ok, err := s.verifier.Verified(c, evt.Pubkey, identifier)
+	if chk.E(err) {
+		return "error: failed to verify nip-05 identifier"
+	}
+	if !ok {
+		return restricted
+	}
+	return
+}
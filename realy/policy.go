@@ -0,0 +1,29 @@
+package realy
+
+import (
+	"realy.lol/event"
+	"realy.lol/log"
+	"realy.lol/policy"
+	"realy.lol/reason"
+)
+
+// checkPolicy returns a notice if the write policy plugin rejects an event, which is
+// policy.Shadowed if it is shadow rejected, and must be reported to the client as saved
+// without storing it.
+func (s *Server) checkPolicy(evt *event.T, authedPubkey []byte, remote string) (notice string) {
+	if s.Policy == nil {
+		return
+	}
+	switch action, msg := s.Policy.Event(evt, remote, authedPubkey); action {
+	case policy.Reject:
+		log.I.F("%s event %0x rejected by policy: %s", remote, evt.Id, msg)
+		if !NIP20prefixmatcher.MatchString(msg) {
+			return string(reason.Blocked.F("%s", msg))
+		}
+		return msg
+	case policy.ShadowReject:
+		log.I.F("%s event %0x shadow rejected by policy: %s", remote, evt.Id, msg)
+		return policy.Shadowed
+	}
+	return
+}
@@ -0,0 +1,58 @@
+package realy
+
+import (
+	"bytes"
+
+	"realy.lol/event"
+	"realy.lol/pow"
+	"realy.lol/realy/config"
+)
+
+// powTier returns the access tier of an authenticated pubkey for the proof of work rules, or
+// an empty string if it is trusted.
+//
+// This must be called with the Server mutex locked.
+func (s *Server) powTier(authedPubkey []byte) (tier string) {
+	if len(authedPubkey) == 0 {
+		return config.PowTierAnonymous
+	}
+	if s.Superuser != nil && bytes.Equal(authedPubkey, s.Superuser.Pub()) {
+		return
+	}
+	for _, a := range s.admins {
+		if bytes.Equal(authedPubkey, a.Pub()) {
+			return
+		}
+	}
+	for _, o := range s.owners {
+		if bytes.Equal(authedPubkey, o) {
+			return
+		}
+	}
+	// the users the owners follow are also on the followed list, with the users they follow.
+	if _, ok := s.ownersFollowed[string(authedPubkey)]; ok {
+		return
+	}
+	if _, ok := s.followed[string(authedPubkey)]; ok {
+		return config.PowTierGuest
+	}
+	return config.PowTierAuthed
+}
+
+// checkPow returns a notice if an event does not carry the NIP-13 proof of work required of
+// the access tier of the client that submitted it.
+//
+// This must be called with the Server mutex locked.
+func (s *Server) checkPow(cfg config.C, evt *event.T, authedPubkey []byte) (notice string) {
+	if len(cfg.PowRules) == 0 {
+		return
+	}
+	tier := s.powTier(authedPubkey)
+	if tier == "" {
+		return
+	}
+	if err := pow.Check(evt, cfg.PowDifficulty(tier, evt.Kind.K)); err != nil {
+		notice = err.Error()
+	}
+	return
+}
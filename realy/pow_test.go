@@ -0,0 +1,40 @@
+package realy
+
+import (
+	"testing"
+
+	"realy.lol/list"
+	"realy.lol/p256k"
+	"realy.lol/realy/config"
+)
+
+func TestPowTier(t *testing.T) {
+	keys := make([][]byte, 4)
+	for i := range keys {
+		sign := &p256k.Signer{}
+		if err := sign.Generate(); err != nil {
+			t.Fatal(err)
+		}
+		keys[i] = sign.Pub()
+	}
+	owner, follow, guest, stranger := keys[0], keys[1], keys[2], keys[3]
+	// the followed list holds the owners, their follows and the follows of their follows.
+	s := &Server{owners: [][]byte{owner},
+		followed:       list.L{string(owner): {}, string(follow): {}, string(guest): {}},
+		ownersFollowed: list.L{string(follow): {}}}
+	for _, c := range []struct {
+		name   string
+		pubkey []byte
+		tier   string
+	}{
+		{"anonymous", nil, config.PowTierAnonymous},
+		{"owner", owner, ""},
+		{"owner follow", follow, ""},
+		{"follow of follow", guest, config.PowTierGuest},
+		{"stranger", stranger, config.PowTierAuthed},
+	} {
+		if tier := s.powTier(c.pubkey); tier != c.tier {
+			t.Errorf("%s: expected tier %q, got %q", c.name, c.tier, tier)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package realy
+
+import (
+	"time"
+
+	"realy.lol/errorf"
+	"realy.lol/event"
+	"realy.lol/realy/config"
+	"realy.lol/sanity"
+)
+
+// limits returns the event sanity limits set in the configuration.
+func limits(cfg config.C) sanity.Limits {
+	return sanity.Limits{
+		MaxContentLength:    cfg.MaxContentLength,
+		MaxEventTags:        cfg.MaxEventTags,
+		CreatedAtLowerLimit: cfg.CreatedAtLowerLimit,
+		CreatedAtUpperLimit: cfg.CreatedAtUpperLimit,
+	}
+}
+
+// checkPulled returns an error if an event pulled from another relay, by a mirror or from a
+// cluster peer, is malformed or rejected by the lists maintained through the NIP-86 relay
+// management API.
+//
+// The created_at window is not applied, as pulled events may be old, and were checked against
+// it when the relay they came from received them.
+func (s *Server) checkPulled(ev *event.T) (err error) {
+	cfg := s.Configuration()
+	l := limits(cfg)
+	l.CreatedAtLowerLimit, l.CreatedAtUpperLimit = 0, 0
+	if err = sanity.Check(ev, l, time.Now().Unix()); err != nil {
+		return
+	}
+	notice := checkManagedEvent(cfg, ev)
+	if notice == "" {
+		notice = checkManagedPubkey(cfg, ev)
+	}
+	if notice != "" {
+		err = errorf.D("%s", notice)
+	}
+	return
+}
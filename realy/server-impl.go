@@ -51,7 +51,7 @@ func (s *Server) AddEvent(
 func (s *Server) AcceptEvent(
 	c context.T, ev *event.T, hr *http.Request, authedPubkey []byte,
 	remote string) (accept bool, notice string, afterSave func()) {
-	return s.acceptEvent(c, ev, authedPubkey, remote)
+	return s.acceptEvent(c, ev, hr, authedPubkey, remote)
 }
 
 func (s *Server) PublicReadable() bool {
@@ -126,5 +126,8 @@ func (s *Server) Publish(c context.T, evt *event.T) (err error) {
 	if err = sto.SaveEvent(c, evt); chk.E(err) && !errors.Is(err, store.ErrDupEvent) {
 		return errorf.E("failed to save: %w", err)
 	}
+	if err == nil {
+		s.broadcast(c, evt)
+	}
 	return
 }
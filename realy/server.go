@@ -11,15 +11,20 @@ import (
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/rs/cors"
 
+	"realy.lol/admission"
+	"realy.lol/broadcast"
 	"realy.lol/chk"
 	"realy.lol/context"
+	"realy.lol/dns"
 	"realy.lol/list"
 	"realy.lol/log"
+	"realy.lol/policy"
 	"realy.lol/realy/config"
 	"realy.lol/realy/helpers"
 	"realy.lol/servemux"
 	"realy.lol/signer"
 	"realy.lol/store"
+	"realy.lol/zap"
 )
 
 type Server struct {
@@ -33,6 +38,23 @@ type Server struct {
 	huma.API
 	Store    store.I
 	MaxLimit int
+	// Policy is the write policy plugin, if one is configured.
+	Policy *policy.P
+	// BlobDir is the directory the Blossom and NIP-96 servers store blobs in.
+	BlobDir string
+	// NIP05Base returns the URL the NIP-05 document of a domain is fetched from when verifying
+	// publishers, if it is not nil, so that tests can use a local server in place of the domain.
+	NIP05Base func(domain string) string
+	// Wallet makes the invoices for write access, if admission fees are configured.
+	Wallet admission.Wallet
+	// admission sells write access, if there is a wallet and the store can keep the members.
+	admission *admission.A
+	// verifier checks the NIP-05 identifiers of publishers, if the store can keep its results.
+	verifier *dns.Verifier
+	// zapProviders finds the LNURL servers that must sign the zap receipts of recipients.
+	zapProviders *zap.Providers
+	// broadcaster sends published events to other relays, if the store can keep its queue.
+	broadcaster *broadcast.B
 
 	configurationMx sync.Mutex
 	configuration   config.C
@@ -56,10 +78,24 @@ type Server struct {
 	// ownersMuteLists are the event IDs of owners mute lists, which must not be
 	// deleted, only replaced.
 	ownersMuteLists [][]byte
+	// groupsMx serializes the changes to the state of NIP-29 groups.
+	groupsMx sync.Mutex
+	// managementMx serializes the configuration changes made by NIP-86 management requests.
+	managementMx sync.Mutex
 }
 
 func (s *Server) Start() (err error) {
 	s.Init()
+	s.CheckGroups(s.Ctx)
+	s.StartMirrors()
+	s.StartBroadcast()
+	s.StartCluster()
+	s.StartBlossom()
+	s.StartFileStorage()
+	s.StartNames()
+	s.StartNIP05Verification()
+	s.StartAdmission()
+	s.zapProviders = &zap.Providers{Profile: s.profileContent}
 	var listener net.Listener
 	if listener, err = net.Listen("tcp", s.Address); chk.E(err) {
 		return
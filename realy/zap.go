@@ -0,0 +1,34 @@
+package realy
+
+import (
+	"time"
+
+	"realy.lol/context"
+	"realy.lol/event"
+	"realy.lol/kind"
+	"realy.lol/realy/config"
+)
+
+// ZapTimeout is how long the LNURL server of the recipient of a zap receipt is waited for.
+const ZapTimeout = 10 * time.Second
+
+// checkZap returns a notice if zap receipts are validated and an event is a zap receipt whose
+// invoice or embedded zap request is forged, or that is not signed by the LNURL server in the
+// stored profile of its recipient.
+//
+// This may make a request to the LNURL server of the recipient, so it must not be called with
+// the Server mutex locked.
+func (s *Server) checkZap(c context.T, cfg config.C, evt *event.T) (notice string) {
+	if !cfg.ValidateZaps || !evt.Kind.Equal(kind.Zap) {
+		return
+	}
+	if s.zapProviders == nil {
+		return "error: zap receipts cannot be validated"
+	}
+	c, cancel := context.Timeout(c, ZapTimeout)
+	defer cancel()
+	if _, err := s.zapProviders.Validate(c, evt); err != nil {
+		notice = err.Error()
+	}
+	return
+}
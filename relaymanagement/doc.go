@@ -0,0 +1,5 @@
+// Package relaymanagement implements the NIP-86 relay management API, a JSON-RPC like protocol
+// spoken over HTTP POST requests to the relay URL with NIP-98 authorization, that lets relay
+// admin clients ban and allow pubkeys, events, kinds and IP addresses and change the relay
+// information document.
+package relaymanagement
@@ -0,0 +1,282 @@
+package relaymanagement
+
+import (
+	"encoding/json"
+	"slices"
+
+	"realy.lol/errorf"
+	"realy.lol/hex"
+	"realy.lol/realy/config"
+	"realy.lol/sha256"
+)
+
+// ContentType is the Content-Type of NIP-86 requests and responses.
+const ContentType = "application/nostr+json+rpc"
+
+// The methods of the NIP-86 relay management API.
+const (
+	SupportedMethods            = "supportedmethods"
+	BanPubkey                   = "banpubkey"
+	ListBannedPubkeys           = "listbannedpubkeys"
+	AllowPubkey                 = "allowpubkey"
+	ListAllowedPubkeys          = "listallowedpubkeys"
+	ListEventsNeedingModeration = "listeventsneedingmoderation"
+	AllowEvent                  = "allowevent"
+	BanEvent                    = "banevent"
+	ListBannedEvents            = "listbannedevents"
+	ChangeRelayName             = "changerelayname"
+	ChangeRelayDescription      = "changerelaydescription"
+	ChangeRelayIcon             = "changerelayicon"
+	AllowKind                   = "allowkind"
+	DisallowKind                = "disallowkind"
+	ListAllowedKinds            = "listallowedkinds"
+	BlockIP                     = "blockip"
+	UnblockIP                   = "unblockip"
+	ListBlockedIPs              = "listblockedips"
+)
+
+// Methods are the methods supported by Do.
+var Methods = []string{
+	SupportedMethods,
+	BanPubkey, ListBannedPubkeys, AllowPubkey, ListAllowedPubkeys,
+	ListEventsNeedingModeration, AllowEvent, BanEvent, ListBannedEvents,
+	ChangeRelayName, ChangeRelayDescription, ChangeRelayIcon,
+	AllowKind, DisallowKind, ListAllowedKinds,
+	BlockIP, UnblockIP, ListBlockedIPs,
+}
+
+// Request is a NIP-86 method call.
+type Request struct {
+	Method string `json:"method"`
+	Params []any  `json:"params"`
+}
+
+// Response is the reply to a Request, with either a Result or an Error.
+type Response struct {
+	Result any    `json:"result"`
+	Error  string `json:"error,omitempty"`
+}
+
+// PubkeyReason is an entry in the result of the pubkey list methods.
+type PubkeyReason struct {
+	Pubkey string `json:"pubkey"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// IdReason is an entry in the result of the event list methods.
+type IdReason struct {
+	Id     string `json:"id"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// IPReason is an entry in the result of the IP address list methods.
+type IPReason struct {
+	IP     string `json:"ip"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Unmarshal decodes a Request from the body of an HTTP request.
+func Unmarshal(b []byte) (req *Request, err error) {
+	req = &Request{}
+	if err = json.Unmarshal(b, req); err != nil {
+		err = errorf.E("invalid request: %v", err)
+		return
+	}
+	if req.Method == "" {
+		err = errorf.E("invalid request: no method")
+	}
+	return
+}
+
+// Do performs a method call on a copy of the relay configuration. If changed is true the
+// configuration has been modified and must be stored for the call to take effect.
+//
+// Banning an event only records it in the configuration, the caller is responsible for also
+// deleting it from the event store.
+func Do(cfg *config.C, req *Request) (result any, changed bool, err error) {
+	switch req.Method {
+	case SupportedMethods:
+		result = Methods
+	case BanPubkey:
+		var e config.Entry
+		if e, err = entry(req); err != nil {
+			return
+		}
+		cfg.AllowedPubkeys = remove(cfg.AllowedPubkeys, e.Value)
+		cfg.BannedPubkeys = add(cfg.BannedPubkeys, e)
+		result, changed = true, true
+	case ListBannedPubkeys:
+		result = pubkeys(cfg.BannedPubkeys)
+	case AllowPubkey:
+		var e config.Entry
+		if e, err = entry(req); err != nil {
+			return
+		}
+		cfg.BannedPubkeys = remove(cfg.BannedPubkeys, e.Value)
+		cfg.AllowedPubkeys = add(cfg.AllowedPubkeys, e)
+		result, changed = true, true
+	case ListAllowedPubkeys:
+		result = pubkeys(cfg.AllowedPubkeys)
+	case ListEventsNeedingModeration:
+		// events are accepted or rejected when they are published, there is no queue.
+		result = []IdReason{}
+	case AllowEvent:
+		var e config.Entry
+		if e, err = entry(req); err != nil {
+			return
+		}
+		cfg.BannedEvents = remove(cfg.BannedEvents, e.Value)
+		result, changed = true, true
+	case BanEvent:
+		var e config.Entry
+		if e, err = entry(req); err != nil {
+			return
+		}
+		cfg.BannedEvents = add(cfg.BannedEvents, e)
+		result, changed = true, true
+	case ListBannedEvents:
+		ids := []IdReason{}
+		for _, e := range cfg.BannedEvents {
+			ids = append(ids, IdReason{Id: e.Value, Reason: e.Reason})
+		}
+		result = ids
+	case ChangeRelayName:
+		if cfg.RelayName, err = param(req, 0); err != nil {
+			return
+		}
+		result, changed = true, true
+	case ChangeRelayDescription:
+		if cfg.RelayDescription, err = param(req, 0); err != nil {
+			return
+		}
+		result, changed = true, true
+	case ChangeRelayIcon:
+		if cfg.RelayIcon, err = param(req, 0); err != nil {
+			return
+		}
+		result, changed = true, true
+	case AllowKind:
+		var k int
+		if k, err = kindParam(req); err != nil {
+			return
+		}
+		if !slices.Contains(cfg.AllowedKinds, k) {
+			cfg.AllowedKinds = append(cfg.AllowedKinds, k)
+			slices.Sort(cfg.AllowedKinds)
+		}
+		result, changed = true, true
+	case DisallowKind:
+		var k int
+		if k, err = kindParam(req); err != nil {
+			return
+		}
+		cfg.AllowedKinds = slices.DeleteFunc(cfg.AllowedKinds,
+			func(a int) bool { return a == k })
+		result, changed = true, true
+	case ListAllowedKinds:
+		result = append([]int{}, cfg.AllowedKinds...)
+	case BlockIP:
+		var ip string
+		if ip, err = param(req, 0); err != nil {
+			return
+		}
+		if !slices.Contains(cfg.BlockList, ip) {
+			cfg.BlockList = append(cfg.BlockList, ip)
+		}
+		result, changed = true, true
+	case UnblockIP:
+		var ip string
+		if ip, err = param(req, 0); err != nil {
+			return
+		}
+		cfg.BlockList = slices.DeleteFunc(cfg.BlockList,
+			func(a string) bool { return a == ip })
+		result, changed = true, true
+	case ListBlockedIPs:
+		ips := []IPReason{}
+		for _, ip := range cfg.BlockList {
+			ips = append(ips, IPReason{IP: ip})
+		}
+		result = ips
+	default:
+		err = errorf.E("unsupported method: %s", req.Method)
+	}
+	return
+}
+
+// param returns the string parameter at index i of a request.
+func param(req *Request, i int) (s string, err error) {
+	if len(req.Params) <= i {
+		err = errorf.E("%s: missing parameter %d", req.Method, i)
+		return
+	}
+	var ok bool
+	if s, ok = req.Params[i].(string); !ok {
+		err = errorf.E("%s: parameter %d is not a string", req.Method, i)
+	}
+	return
+}
+
+// kindParam returns the kind number that is the first parameter of a request.
+func kindParam(req *Request) (k int, err error) {
+	if len(req.Params) < 1 {
+		err = errorf.E("%s: missing kind parameter", req.Method)
+		return
+	}
+	f, ok := req.Params[0].(float64)
+	if !ok || f < 0 || f > 65535 || f != float64(int(f)) {
+		err = errorf.E("%s: invalid kind %v", req.Method, req.Params[0])
+		return
+	}
+	k = int(f)
+	return
+}
+
+// entry returns the hex pubkey or event id in the first parameter of a request and the
+// optional reason in the second.
+func entry(req *Request) (e config.Entry, err error) {
+	if e.Value, err = param(req, 0); err != nil {
+		return
+	}
+	var b []byte
+	if b, err = hex.Dec(e.Value); err != nil || len(b) != sha256.Size {
+		err = errorf.E("%s: invalid hex value %s", req.Method, e.Value)
+		return
+	}
+	// normalise to lower case
+	e.Value = hex.Enc(b)
+	if len(req.Params) > 1 {
+		e.Reason, _ = req.Params[1].(string)
+	}
+	return
+}
+
+// add puts an entry on a list, replacing the reason if it is already there.
+func add(list []config.Entry, e config.Entry) []config.Entry {
+	for i := range list {
+		if list[i].Value == e.Value {
+			list[i].Reason = e.Reason
+			return list
+		}
+	}
+	return append(list, e)
+}
+
+// remove takes an entry off a list.
+func remove(list []config.Entry, value string) []config.Entry {
+	return slices.DeleteFunc(list, func(e config.Entry) bool { return e.Value == value })
+}
+
+// pubkeys converts a list of entries into the result of the pubkey list methods.
+func pubkeys(list []config.Entry) (pks []PubkeyReason) {
+	pks = []PubkeyReason{}
+	for _, e := range list {
+		pks = append(pks, PubkeyReason{Pubkey: e.Value, Reason: e.Reason})
+	}
+	return
+}
+
+// Contains returns true if the value is on a list of entries.
+func Contains(list []config.Entry, value string) bool {
+	return slices.ContainsFunc(list, func(e config.Entry) bool { return e.Value == value })
+}
@@ -0,0 +1,69 @@
+package relaymanagement
+
+import (
+	"strings"
+	"testing"
+
+	"lukechampine.com/frand"
+
+	"realy.lol/hex"
+	"realy.lol/realy/config"
+)
+
+func TestDo(t *testing.T) {
+	var cfg config.C
+	pk := hex.Enc(frand.Bytes(32))
+	do := func(js string) (res any) {
+		req, err := Unmarshal([]byte(js))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res, _, err = Do(&cfg, req); err != nil {
+			t.Fatalf("%s: %v", js, err)
+		}
+		return
+	}
+	do(`{"method":"banpubkey","params":["` + strings.ToUpper(pk) + `","spam"]}`)
+	if !Contains(cfg.BannedPubkeys, pk) || cfg.BannedPubkeys[0].Reason != "spam" {
+		t.Fatalf("pubkey was not banned: %v", cfg.BannedPubkeys)
+	}
+	if l := do(`{"method":"listbannedpubkeys","params":[]}`).([]PubkeyReason); len(l) != 1 {
+		t.Fatalf("expected 1 banned pubkey, got %d", len(l))
+	}
+	do(`{"method":"allowpubkey","params":["` + pk + `"]}`)
+	if Contains(cfg.BannedPubkeys, pk) || !Contains(cfg.AllowedPubkeys, pk) {
+		t.Fatal("allowing a pubkey did not lift the ban")
+	}
+	do(`{"method":"allowkind","params":[30023]}`)
+	do(`{"method":"allowkind","params":[1]}`)
+	do(`{"method":"allowkind","params":[1]}`)
+	if k := do(`{"method":"listallowedkinds","params":[]}`).([]int); len(k) != 2 || k[0] != 1 {
+		t.Fatalf("unexpected allowed kinds %v", k)
+	}
+	do(`{"method":"disallowkind","params":[1]}`)
+	if len(cfg.AllowedKinds) != 1 {
+		t.Fatalf("kind was not disallowed %v", cfg.AllowedKinds)
+	}
+	do(`{"method":"changerelayname","params":["test relay"]}`)
+	if cfg.RelayName != "test relay" {
+		t.Fatal("relay name not changed")
+	}
+	do(`{"method":"blockip","params":["10.0.0.1","abuse"]}`)
+	do(`{"method":"unblockip","params":["10.0.0.1"]}`)
+	if len(cfg.BlockList) != 0 {
+		t.Fatal("ip was not unblocked")
+	}
+	for _, js := range []string{
+		`{"method":"banevent","params":["not hex"]}`,
+		`{"method":"allowkind","params":["1"]}`,
+		`{"method":"nosuchmethod","params":[]}`,
+	} {
+		req, err := Unmarshal([]byte(js))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err = Do(&cfg, req); err == nil {
+			t.Fatalf("%s did not fail", js)
+		}
+	}
+}
@@ -0,0 +1,3 @@
+// Package sanity checks events against configurable limits on their size, number of tags and
+// created_at timestamp, and validates the structure required of some well known kinds.
+package sanity
@@ -0,0 +1,91 @@
+package sanity
+
+import (
+	"encoding/json"
+	"unicode/utf8"
+
+	"realy.lol/errorf"
+	"realy.lol/event"
+	"realy.lol/hex"
+	"realy.lol/kind"
+	"realy.lol/reason"
+	"realy.lol/sha256"
+)
+
+// Limits are the bounds an event must be within to be accepted. Zero values are no limit.
+type Limits struct {
+	// MaxContentLength is the maximum number of unicode characters in the content field.
+	MaxContentLength int
+	// MaxEventTags is the maximum number of tags.
+	MaxEventTags int
+	// CreatedAtLowerLimit is how many seconds in the past created_at may be.
+	CreatedAtLowerLimit int64
+	// CreatedAtUpperLimit is how many seconds in the future created_at may be.
+	CreatedAtUpperLimit int64
+}
+
+// Check returns an error if an event exceeds the limits, given the current unix time, or does
+// not have the structure required for its kind.
+func Check(ev *event.T, l Limits, now int64) (err error) {
+	if l.MaxContentLength > 0 {
+		if n := utf8.RuneCount(ev.Content); n > l.MaxContentLength {
+			err = invalid("content is %d characters, more than %d", n, l.MaxContentLength)
+			return
+		}
+	}
+	if l.MaxEventTags > 0 && ev.Tags.Len() > l.MaxEventTags {
+		err = invalid("event has %d tags, more than %d", ev.Tags.Len(), l.MaxEventTags)
+		return
+	}
+	ts := ev.CreatedAt.I64()
+	if l.CreatedAtLowerLimit > 0 && ts < now-l.CreatedAtLowerLimit {
+		err = invalid("created_at %d is more than %d seconds in the past", ts,
+			l.CreatedAtLowerLimit)
+		return
+	}
+	if l.CreatedAtUpperLimit > 0 && ts > now+l.CreatedAtUpperLimit {
+		err = invalid("created_at %d is more than %d seconds in the future", ts,
+			l.CreatedAtUpperLimit)
+		return
+	}
+	return CheckKind(ev)
+}
+
+// CheckKind returns an error if an event does not have the structure required for its kind:
+//
+//   - kind 0 profile metadata content must be a JSON object.
+//   - kind 3 follow list p tags must contain hex public keys.
+//   - addressable kinds must have a d tag.
+func CheckKind(ev *event.T) (err error) {
+	switch {
+	case ev.Kind.Equal(kind.ProfileMetadata):
+		var m map[string]any
+		if err = json.Unmarshal(ev.Content, &m); err != nil {
+			err = invalid("profile metadata content is not a JSON object")
+			return
+		}
+	case ev.Kind.Equal(kind.FollowList):
+		for _, t := range ev.Tags.ToSliceOfTags() {
+			if string(t.Key()) != "p" {
+				continue
+			}
+			var pk []byte
+			if pk, err = hex.Dec(string(t.Value())); err != nil || len(pk) != sha256.Size {
+				err = invalid("follow list p tag has invalid pubkey '%s'", t.Value())
+				return
+			}
+		}
+	case ev.Kind.IsParameterizedReplaceable():
+		for _, t := range ev.Tags.ToSliceOfTags() {
+			if string(t.Key()) == "d" {
+				return
+			}
+		}
+		err = invalid("addressable event kind %d has no d tag", ev.Kind.K)
+	}
+	return
+}
+
+func invalid(format string, args ...any) error {
+	return errorf.D(string(reason.Invalid.F(format, args...)))
+}
@@ -0,0 +1,45 @@
+package sanity
+
+import (
+	"strings"
+	"testing"
+
+	"realy.lol/event"
+	"realy.lol/kind"
+	"realy.lol/tag"
+	"realy.lol/tags"
+	"realy.lol/timestamp"
+)
+
+func TestCheck(t *testing.T) {
+	now := timestamp.Now().I64()
+	l := Limits{MaxContentLength: 16, MaxEventTags: 2, CreatedAtLowerLimit: 3600,
+		CreatedAtUpperLimit: 60}
+	mk := func(k *kind.T, ts int64, content string, tt ...*tag.T) *event.T {
+		return &event.T{Kind: k, CreatedAt: timestamp.FromUnix(ts), Content: []byte(content),
+			Tags: tags.New(tt...)}
+	}
+	pk := strings.Repeat("ab", 32)
+	for i, c := range []struct {
+		ev *event.T
+		ok bool
+	}{
+		{mk(kind.TextNote, now, "hello"), true},
+		{mk(kind.TextNote, now, "ünïcödé ✓"), true},
+		{mk(kind.TextNote, now, "hello world, hello"), false},
+		{mk(kind.TextNote, now, "", tag.New("t", "a"), tag.New("t", "b"), tag.New("t", "c")),
+			false},
+		{mk(kind.TextNote, now-7200, ""), false},
+		{mk(kind.TextNote, now+120, ""), false},
+		{mk(kind.ProfileMetadata, now, `{"name":"x"}`), true},
+		{mk(kind.ProfileMetadata, now, `"x"`), false},
+		{mk(kind.FollowList, now, "", tag.New("p", pk)), true},
+		{mk(kind.FollowList, now, "", tag.New("p", "npub")), false},
+		{mk(kind.New(30023), now, "", tag.New("d", "")), true},
+		{mk(kind.New(30023), now, ""), false},
+	} {
+		if err := Check(c.ev, l, now); (err == nil) != c.ok {
+			t.Fatalf("case %d: expected ok %v, got %v", i, c.ok, err)
+		}
+	}
+}
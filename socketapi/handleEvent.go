@@ -2,6 +2,7 @@ package socketapi
 
 import (
 	"bytes"
+	"errors"
 	"strings"
 
 	"realy.lol/chk"
@@ -15,6 +16,7 @@ import (
 	"realy.lol/ints"
 	"realy.lol/kind"
 	"realy.lol/log"
+	"realy.lol/policy"
 	"realy.lol/realy/interfaces"
 	"realy.lol/sha256"
 	"realy.lol/store"
@@ -40,16 +42,25 @@ func (a *A) HandleEvent(c context.T, req []byte, srv interfaces.Server,
 		log.T.F("%s extra '%s'", remote, rem)
 	}
 	log.I.F("authed pubkey: %0x", a.Listener.AuthedBytes())
+	// the event is checked to be signed by its author before anything is decided from its
+	// content.
+	if ok, err = a.VerifyEvent(c, env); chk.E(err) || !ok {
+		return
+	}
 	accept, notice, after := a.Server.AcceptEvent(c, env.T, a.Listener.Req(),
 		a.Listener.AuthedBytes(), remote)
 	log.T.F("%s accepted %v", remote, accept)
-	if !accept {
-		if err = a.HandleRejectEvent(env, notice); chk.E(err) {
+	if !accept && notice == policy.Shadowed {
+		// the client is told the event was saved, but it is neither stored nor delivered.
+		if err = okenvelope.NewFrom(env.Id(), true, nil).Write(a.Listener); chk.E(err) {
 			return
 		}
 		return
 	}
-	if err = a.VerifyEvent(env); chk.E(err) {
+	if !accept {
+		if err = a.HandleRejectEvent(env, notice); chk.E(err) {
+			return
+		}
 		return
 	}
 	if env.T.Kind.K == kind.Deletion.K {
@@ -63,30 +74,31 @@ func (a *A) HandleEvent(c context.T, req []byte, srv interfaces.Server,
 	if err = okenvelope.NewFrom(env.Id(), ok, reason).Write(a.Listener); chk.E(err) {
 		return
 	}
-	if after != nil {
+	if ok && after != nil {
 		after()
 	}
 	return
 }
 
-func (a *A) VerifyEvent(env *eventenvelope.Submission) (err error) {
-	if !bytes.Equal(env.GetIDBytes(), env.Id()) {
-		if err = Ok.Invalid(a, env, "event id is computed incorrectly"); chk.E(err) {
+// VerifyEvent checks the Id and signature of a submitted event with the EventVerifier, and
+// sends the client an OK message saying why if it is invalid.
+func (a *A) VerifyEvent(c context.T, env *eventenvelope.Submission) (valid bool, err error) {
+	verr := EventVerifier.Verify(c, env.T)
+	switch {
+	case verr == nil:
+		return true, nil
+	case errors.Is(verr, ErrIdIncorrect):
+		if err = Ok.Invalid(a, env, "%s", verr.Error()); chk.E(err) {
 			return
 		}
-		return
-	}
-	var ok bool
-	if ok, err = env.Verify(); chk.T(err) {
-		if err = Ok.Error(a, env, "failed to verify signature", err); chk.T(err) {
+	case errors.Is(verr, ErrInvalidSignature):
+		if err = Ok.Error(a, env, "%s", verr.Error()); chk.T(err) {
 			return
 		}
-		return
-	} else if !ok {
-		if err = Ok.Error(a, env, "signature is invalid", err); chk.T(err) {
+	default:
+		if err = Ok.Error(a, env, "failed to verify signature: %s", verr.Error()); chk.T(err) {
 			return
 		}
-		return
 	}
 	return
 }
@@ -96,6 +108,12 @@ func (a *A) HandleRejectEvent(env *eventenvelope.Submission, notice string) (err
 		if err = Ok.Blocked(a, env, notice); chk.E(err) {
 			return
 		}
+	} else if NIP20prefixmatcher.MatchString(notice) {
+		// the notice already carries a machine-readable reason, so auth will not help.
+		if err = okenvelope.NewFrom(env.Id(), false, []byte(notice)).Write(a.Listener); chk.E(err) {
+			return
+		}
+		return
 	} else {
 		if !a.Listener.AuthRequested() {
 			a.Listener.RequestAuth()
@@ -1,7 +1,6 @@
 package socketapi
 
 import (
-	"bytes"
 	"errors"
 
 	"github.com/dgraph-io/badger/v4"
@@ -20,7 +19,6 @@ import (
 	"realy.lol/realy/interfaces"
 	"realy.lol/realy/pointers"
 	"realy.lol/reason"
-	"realy.lol/store"
 	"realy.lol/tag"
 )
 
@@ -91,11 +89,6 @@ func (a *A) HandleReq(c context.T, req []byte, srv interfaces.Server, aut []byte
 			continue
 		}
 		aut := a.Listener.AuthedBytes()
-		// // remove events from muted authors if we have the authed user's mute list.
-		// if a.Listener.IsAuthed() {
-		// 	a.FilterPrivileged(c, sto, aut, events)
-		// }
-		// remove privileged events as they come through in scrape queries
 		if events, notice, err = a.CheckPrivilege(events, f, env, srv, aut, remote); chk.E(err) {
 			return
 		}
@@ -160,68 +153,42 @@ func (a *A) HandleAuthPrivilege(env *reqenvelope.T, f *filter.T, aut []byte, rem
 	return
 }
 
-func (a *A) FilterPrivileged(c context.T, sto store.I, aut []byte, events event.Ts) (evs event.Ts) {
-	// var mutes event.Ts
-	// var err error
-	// if mutes, err = sto.QueryEvents(c, &filter.T{Authors: tag.New(aut),
-	// 	Kinds: kinds.New(kind.MuteList)}); !chk.E(err) {
-	// 	var mutePubs [][]byte
-	// 	for _, ev := range mutes {
-	// 		for _, t := range ev.Tags.ToSliceOfTags() {
-	// 			if bytes.Equal(t.Key(), []byte("p")) {
-	// 				var p []byte
-	// 				if p, err = hex.Dec(string(t.Value())); chk.E(err) {
-	// 					continue
-	// 				}
-	// 				mutePubs = append(mutePubs, p)
-	// 			}
-	// 		}
-	// 	}
-	// 	var tmp event.Ts
-	// 	for _, ev := range events {
-	// 		for _, pk := range mutePubs {
-	// 			if bytes.Equal(ev.Pubkey, pk) {
-	// 				continue
-	// 			}
-	// 			tmp = append(tmp, ev)
-	// 		}
-	// 	}
-	// 	// remove privileged events
-	// 	evs = tmp
-	// }
+// FilterPrivileged removes privileged events that the authenticated pubkey is not a party to.
+//
+// DMs and application specific data are only returned to their author and the pubkeys in
+// their p tags, and gift wraps, which are signed by a random key, only to the recipient.
+func (a *A) FilterPrivileged(aut []byte, events event.Ts) (evs event.Ts) {
+	for _, ev := range events {
+		if !ev.ReadableBy(aut) {
+			log.T.F("filtering privileged event %0x not addressed to %0x", ev.Id, aut)
+			continue
+		}
+		evs = append(evs, ev)
+	}
 	return
 }
 
 func (a *A) CheckPrivilege(events event.Ts, f *filter.T, env *reqenvelope.T,
 	srv interfaces.Server, aut []byte, remote string) (evs event.Ts, notice []byte, err error) {
 
-	authRequired := srv.AuthRequired()
-	isPrivileged := f.Kinds.IsPrivileged()
-	for _, ev := range events {
-		// if auth is required, kind is privileged and there is no authed pubkey, skip
-		if authRequired && isPrivileged && len(aut) == 0 {
-			log.I.F("privileged and not authed")
-			if notice, err = a.AuthRequiredResponse(env, remote, aut, reason.Restricted); chk.E(err) {
-				return
-			}
+	// if auth is required, kind is privileged and there is no authed pubkey, request auth
+	if srv.AuthRequired() && f.Kinds.IsPrivileged() && len(aut) == 0 {
+		log.I.F("privileged and not authed")
+		if notice, err = a.AuthRequiredResponse(env, remote, aut, reason.Restricted); chk.E(err) {
 			return
 		}
-		// if the authed pubkey is not present in the pubkey or p tags, skip
-		receivers := f.Tags.GetAll(tag.New("#p"))
-		if receivers == nil {
-			continue
-		}
-		if isPrivileged && !(bytes.Equal(ev.Pubkey, aut) ||
-			!receivers.ContainsAny([]byte("#p"), tag.New(a.Listener.AuthedBytes()))) {
-
-			log.I.F("%v && (%v || %v)", isPrivileged, !bytes.Equal(ev.Pubkey, aut),
-				!receivers.ContainsAny([]byte("#p"), tag.New(a.Listener.AuthedBytes())))
-			if notice, err = a.AuthRequiredResponse(env, remote, aut, reason.Restricted); chk.E(err) {
-				return
+		return
+	}
+	// remove privileged events as they come through in scrape queries
+	evs = a.FilterPrivileged(aut, events)
+	if r, ok := srv.(interfaces.EventReader); ok {
+		var readable event.Ts
+		for _, ev := range evs {
+			if r.ReadableBy(ev, aut) {
+				readable = append(readable, ev)
 			}
-			return
 		}
-		evs = append(evs, ev)
+		evs = readable
 	}
 	return
 }
@@ -15,6 +15,7 @@ import (
 	"realy.lol/publish"
 	"realy.lol/realy/helpers"
 	"realy.lol/realy/interfaces"
+	"realy.lol/relaymanagement"
 	"realy.lol/servemux"
 	"realy.lol/units"
 	"realy.lol/ws"
@@ -36,6 +37,9 @@ type A struct {
 
 func New(s interfaces.Server, path string, sm *servemux.S) {
 	a := &A{Server: s}
+	if r, ok := s.(interfaces.EventReader); ok {
+		Publisher.Reader = r
+	}
 	sm.Handle(path, a)
 	return
 }
@@ -47,6 +51,12 @@ func (a *A) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		a.Server.HandleRelayInfo(w, r)
 		return
 	}
+	if r.Method == http.MethodPost &&
+		strings.HasPrefix(r.Header.Get("Content-Type"), relaymanagement.ContentType) {
+		log.T.F("serving relay management request %s", remote)
+		a.Server.HandleManagement(w, r)
+		return
+	}
 	if r.Header.Get("Upgrade") != "websocket" {
 		// todo: we can put a website here
 		http.Error(w, http.StatusText(http.StatusUpgradeRequired), http.StatusUpgradeRequired)
@@ -74,7 +84,11 @@ func (a *A) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		})
 		chk.E(a.Listener.Conn.Close())
 	}()
-	conn.SetReadLimit(DefaultMaxMessageSize)
+	maxMessageSize := int64(DefaultMaxMessageSize)
+	if l := a.Server.Configuration().MaxMessageLength; l > 0 {
+		maxMessageSize = int64(l)
+	}
+	conn.SetReadLimit(maxMessageSize)
 	chk.E(conn.SetReadDeadline(time.Now().Add(DefaultPongWait)))
 	conn.SetPongHandler(func(string) error {
 		chk.E(conn.SetReadDeadline(time.Now().Add(DefaultPongWait)))
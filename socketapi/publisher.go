@@ -1,7 +1,6 @@
 package socketapi
 
 import (
-	"bytes"
 	"regexp"
 	"sync"
 
@@ -11,7 +10,7 @@ import (
 	"realy.lol/filters"
 	"realy.lol/publish"
 	"realy.lol/publish/publisher"
-	"realy.lol/tag"
+	"realy.lol/realy/interfaces"
 	"realy.lol/typer"
 	"realy.lol/ws"
 )
@@ -48,12 +47,17 @@ type S struct {
 	Mx sync.Mutex
 	// Map is the map of subscribers and subscriptions from the websocket api.
 	Map
+	// Reader, if set, further restricts which events may be delivered to an authed pubkey.
+	Reader interfaces.EventReader
 }
 
 var _ publisher.I = &S{}
 
+// Publisher is the websocket api publisher registered with publish.P.
+var Publisher = NewPublisher()
+
 func init() {
-	publish.Register(NewPublisher())
+	publish.Register(Publisher)
 }
 
 func NewPublisher() *S { return &S{Map: make(Map)} }
@@ -95,18 +99,13 @@ func (p *S) Deliver(authRequired, publicReadable bool, ev *event.T) {
 			if !subscriber.Match(ev) {
 				continue
 			}
-			if ev.Kind.IsPrivileged() {
-				ab := w.AuthedBytes()
-				var containsPubkey bool
-				if ev.Tags != nil {
-					containsPubkey = ev.Tags.ContainsAny([]byte{'p'}, tag.New(ab))
-				}
-				if !bytes.Equal(ev.Pubkey, ab) || containsPubkey {
-					if ab == nil {
-						continue
-					}
-					continue
-				}
+			// privileged events only go to the author and the tagged recipients, and gift
+			// wraps only to the recipient.
+			if !ev.ReadableBy(w.AuthedBytes()) {
+				continue
+			}
+			if p.Reader != nil && !p.Reader.ReadableBy(ev, w.AuthedBytes()) {
+				continue
 			}
 			var res *eventenvelope.Result
 			if res, err = eventenvelope.NewResultWith(id, ev); chk.E(err) {
@@ -0,0 +1,185 @@
+package socketapi
+
+import (
+	"bytes"
+	"container/list"
+	"errors"
+	"runtime"
+	"sync"
+
+	"realy.lol/context"
+	"realy.lol/event"
+	"realy.lol/p256k"
+)
+
+const (
+	// VerifyBatchSize is the most events a verification worker checks together.
+	VerifyBatchSize = 64
+	// VerifyQueueSize is how many events can wait to be verified before the connections
+	// submitting more are made to wait.
+	VerifyQueueSize = 256
+	// VerifiedCacheSize is how many verified event id and signature pairs are remembered.
+	VerifiedCacheSize = 1 << 16
+)
+
+var (
+	// ErrIdIncorrect is returned by Verifier.Verify for an event whose Id is not the hash of
+	// its content.
+	ErrIdIncorrect = errors.New("event id is computed incorrectly")
+	// ErrInvalidSignature is returned by Verifier.Verify for an event whose signature is
+	// invalid.
+	ErrInvalidSignature = errors.New("signature is invalid")
+)
+
+// EventVerifier is the Verifier of the events submitted on all the websocket connections.
+var EventVerifier = NewVerifier(runtime.GOMAXPROCS(0), VerifyQueueSize, VerifiedCacheSize)
+
+// Verifier checks the Id and signature of events on a fixed number of workers, shared by all
+// the connections, so that a connection flooding events cannot use all the CPUs, and events
+// that are waiting together are checked as a batch.
+//
+// The queue of events to check is bounded, and a connection waits for its event to be checked
+// before reading its next message, so clients sending faster than the events can be checked
+// are slowed down rather than buffered.
+//
+// The id and signature pairs of valid events are kept in an LRU cache, so an event that is
+// sent again, as relays and clients often rebroadcast events, is only hashed and not checked
+// again, and goes on to be found to be a duplicate by the event store.
+type Verifier struct {
+	jobs  chan *verification
+	cache *verifiedCache
+}
+
+// verification is an event waiting to be checked, and where the result is sent.
+type verification struct {
+	ev     *event.T
+	result chan error
+}
+
+// NewVerifier starts a Verifier with a number of workers, a queue of events waiting to be
+// checked of a size, and a cache of a number of verified events.
+func NewVerifier(workers, queue, cached int) (v *Verifier) {
+	v = &Verifier{jobs: make(chan *verification, queue), cache: newVerifiedCache(cached)}
+	for range max(workers, 1) {
+		go v.work()
+	}
+	return
+}
+
+// Verify checks the Id and signature of an event, waiting for a worker to be free. It returns
+// ErrIdIncorrect or ErrInvalidSignature if the event is invalid, or the error of the context
+// if it is canceled first.
+func (v *Verifier) Verify(c context.T, ev *event.T) (err error) {
+	if err = c.Err(); err != nil {
+		return
+	}
+	j := &verification{ev: ev, result: make(chan error, 1)}
+	select {
+	case v.jobs <- j:
+	case <-c.Done():
+		return c.Err()
+	}
+	select {
+	case err = <-j.result:
+		return
+	case <-c.Done():
+		return c.Err()
+	}
+}
+
+// work checks the events in the queue, taking as many as are waiting up to the
+// VerifyBatchSize to check together.
+func (v *Verifier) work() {
+	batch := make([]*verification, 0, VerifyBatchSize)
+	for j := range v.jobs {
+		batch = append(batch[:0], j)
+	fill:
+		for len(batch) < VerifyBatchSize {
+			select {
+			case j = <-v.jobs:
+				batch = append(batch, j)
+			default:
+				break fill
+			}
+		}
+		v.verify(batch)
+	}
+}
+
+// verify checks a batch of events and sends each its result.
+func (v *Verifier) verify(batch []*verification) {
+	var items []p256k.BatchItem
+	var pending []*verification
+	var keys []string
+	for _, j := range batch {
+		id := j.ev.GetIDBytes()
+		if !bytes.Equal(id, j.ev.Id) {
+			j.result <- ErrIdIncorrect
+			continue
+		}
+		// the id commits to the content and pubkey, so a known valid id and signature pair
+		// is a valid event.
+		key := string(id) + string(j.ev.Sig)
+		if v.cache.contains(key) {
+			j.result <- nil
+			continue
+		}
+		items = append(items, p256k.BatchItem{Hash: id, Sig: j.ev.Sig, PubKey: j.ev.Pubkey})
+		pending, keys = append(pending, j), append(keys, key)
+	}
+	if len(items) == 0 {
+		return
+	}
+	invalid := p256k.BatchVerify(items)
+	for i, j := range pending {
+		if len(invalid) > 0 && invalid[0] == i {
+			invalid = invalid[1:]
+			j.result <- ErrInvalidSignature
+			continue
+		}
+		v.cache.add(keys[i])
+		j.result <- nil
+	}
+}
+
+// verifiedCache is an LRU set of the id and signature pairs of valid events.
+type verifiedCache struct {
+	sync.Mutex
+	size  int
+	order *list.List
+	keys  map[string]*list.Element
+}
+
+func newVerifiedCache(size int) *verifiedCache {
+	return &verifiedCache{size: size, order: list.New(), keys: make(map[string]*list.Element)}
+}
+
+// contains returns true if the key is in the cache, making it the most recently used.
+func (vc *verifiedCache) contains(key string) bool {
+	vc.Lock()
+	defer vc.Unlock()
+	el, ok := vc.keys[key]
+	if ok {
+		vc.order.MoveToFront(el)
+	}
+	return ok
+}
+
+// add puts a key in the cache, removing the least recently used if it is full.
+func (vc *verifiedCache) add(key string) {
+	vc.Lock()
+	defer vc.Unlock()
+	if el, ok := vc.keys[key]; ok {
+		vc.order.MoveToFront(el)
+		return
+	}
+	if vc.size <= 0 {
+		return
+	}
+	if vc.order.Len() >= vc.size {
+		oldest := vc.order.Back()
+		vc.order.Remove(oldest)
+		delete(vc.keys, oldest.Value.(string))
+	}
+	vc.keys[key] = vc.order.PushFront(key)
+}
@@ -0,0 +1,93 @@
+package socketapi
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"realy.lol/context"
+	"realy.lol/event"
+	"realy.lol/p256k"
+)
+
+func TestVerifier(t *testing.T) {
+	signer := &p256k.Signer{}
+	if err := signer.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	var evs []*event.T
+	for range 200 {
+		ev, err := event.GenerateRandomTextNoteEvent(signer, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		evs = append(evs, ev)
+	}
+	evs[5].Content = append(evs[5].Content, '!')
+	evs[50].Sig[10] ^= 1
+	v := NewVerifier(2, 4, 100)
+	c := context.Bg()
+	// submit them all at once, so they queue up and are checked in batches.
+	errs := make([]error, len(evs))
+	var wg sync.WaitGroup
+	for i, ev := range evs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = v.Verify(c, ev)
+		}()
+	}
+	wg.Wait()
+	for i, err := range errs {
+		switch i {
+		case 5:
+			if !errors.Is(err, ErrIdIncorrect) {
+				t.Fatalf("event with changed content: %v", err)
+			}
+		case 50:
+			if !errors.Is(err, ErrInvalidSignature) {
+				t.Fatalf("event with changed signature: %v", err)
+			}
+		default:
+			if err != nil {
+				t.Fatalf("valid event %d: %v", i, err)
+			}
+		}
+	}
+	// only the most recent valid events are remembered.
+	if v.cache.order.Len() != 100 {
+		t.Fatalf("cache has %d events, expected 100", v.cache.order.Len())
+	}
+	last := evs[len(evs)-1]
+	if err := v.Verify(c, last); err != nil {
+		t.Fatal(err)
+	}
+	if !v.cache.contains(string(last.Id) + string(last.Sig)) {
+		t.Fatal("valid event was not cached")
+	}
+	// a cached event with another content has a different id.
+	last.Content = append(last.Content, '!')
+	if err := v.Verify(c, last); !errors.Is(err, ErrIdIncorrect) {
+		t.Fatalf("changed cached event: %v", err)
+	}
+	// a canceled context stops waiting.
+	cc, cancel := context.Cancel(c)
+	cancel()
+	if err := v.Verify(cc, evs[0]); err == nil {
+		t.Fatal("verified with a canceled context")
+	}
+}
+
+func TestVerifiedCache(t *testing.T) {
+	vc := newVerifiedCache(2)
+	vc.add("a")
+	vc.add("b")
+	// using a makes b the least recently used.
+	if !vc.contains("a") {
+		t.Fatal("a not cached")
+	}
+	vc.add("c")
+	if vc.contains("b") || !vc.contains("a") || !vc.contains("c") {
+		t.Fatal("least recently used was not evicted")
+	}
+}
@@ -6,11 +6,19 @@ package store
 import (
 	"io"
 
+	"realy.lol/admission"
+	"realy.lol/blossom"
+	"realy.lol/broadcast"
+	"realy.lol/cdc"
+	"realy.lol/cluster"
 	"realy.lol/context"
+	"realy.lol/dns"
 	"realy.lol/event"
 	"realy.lol/eventid"
 	"realy.lol/eventidserial"
 	"realy.lol/filter"
+	"realy.lol/groups"
+	"realy.lol/mirror"
 	"realy.lol/realy/config"
 	"realy.lol/tag"
 )
@@ -118,6 +126,18 @@ type Configurationer interface {
 	SetConfiguration(c config.C) (err error)
 }
 
+// Grouper stores the state of NIP-29 relay based groups.
+type Grouper interface {
+	// GetGroup returns the state of a group, or nil if it does not exist.
+	GetGroup(id string) (g *groups.Group, err error)
+	// SetGroup stores the state of a group.
+	SetGroup(g *groups.Group) (err error)
+	// DeleteGroup removes the state of a group.
+	DeleteGroup(id string) (err error)
+	// Groups returns the state of all groups.
+	Groups() (gg []*groups.Group, err error)
+}
+
 type LogLeveler interface {
 	SetLogLevel(level string)
 }
@@ -126,3 +146,46 @@ type EventIdSerialer interface {
 	EventIdsBySerial(start uint64, count int) (evs []eventidserial.E,
 		err error)
 }
+
+// Cursorer stores the progress of mirroring upstream relays.
+type Cursorer interface {
+	mirror.Cursors
+}
+
+// Broadcaster stores the queue of events to be sent to other relays.
+type Broadcaster interface {
+	broadcast.Queue
+}
+
+// PeerCursorer stores the progress of replicating the logs of cluster peers.
+type PeerCursorer interface {
+	cluster.Cursors
+}
+
+// Changer records the deletions of events and signals changes, so that the saves and
+// deletions can be streamed in serial order.
+type Changer interface {
+	Deletions(start uint64, count int) (dd []cdc.Change, err error)
+	Changed() <-chan struct{}
+	Committed() uint64
+}
+
+// Blobber stores the records of Blossom blobs.
+type Blobber interface {
+	blossom.Index
+}
+
+// Namer stores the registry of NIP-05 names served by the relay.
+type Namer interface {
+	dns.Registry
+}
+
+// Verifier stores the results of verifying the NIP-05 identifiers of pubkeys.
+type Verifier interface {
+	dns.Verifications
+}
+
+// Admitter stores the members that have paid for write access and their unpaid invoices.
+type Admitter interface {
+	admission.Store
+}
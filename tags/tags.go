@@ -11,6 +11,7 @@ import (
 
 	"realy.lol/chk"
 	"realy.lol/errorf"
+	"realy.lol/hex"
 	"realy.lol/log"
 	"realy.lol/lol"
 	"realy.lol/tag"
@@ -299,6 +300,9 @@ func (t *T) Scan(src any) (err error) {
 // Intersects returns true if a filter tags.T has a match. This means the second character of
 // the filter tag key matches, (ignoring the stupid # prefix in the filter) and one of the
 // following values in the tag matches the first tag of this tag.
+//
+// Filters hold the values of e and p tags as the binary 32 bytes while events hold them as
+// hex, so these are compared in both forms.
 func (t *T) Intersects(f *T) (has bool) {
 	if t == nil || f == nil {
 		log.I.F("caller provided nil tag %v", lol.GetNLoc(4))
@@ -312,8 +316,11 @@ func (t *T) Intersects(f *T) (has bool) {
 			if bytes.Equal(v.FilterKey(), w.Key()) {
 				// we have a matching tag key, and both have a first field, check if tag has any
 				// of the subsequent values in the filter tag.
+				key := w.Key()
+				binary := len(key) == 1 && (key[0] == 'e' || key[0] == 'p')
 				for _, val := range v.ToSliceOfBytes()[1:] {
-					if bytes.Equal(val, w.Value()) {
+					if bytes.Equal(val, w.Value()) ||
+						(binary && len(val) == 32 && hex.Enc(val) == string(w.Value())) {
 						matches--
 					}
 				}
@@ -191,7 +191,8 @@ func ReadLoop(conn *Connection, r *Client) {
 			r.Close()
 			break
 		}
-		message := buf.Bytes()
+		// the parsed envelopes refer to the message, so it must not share the reused buffer.
+		message := bytes.Clone(buf.Bytes())
 		log.D.F("{%s} %v\n", r.URL, message)
 
 		var t string
@@ -354,7 +355,7 @@ func (r *Client) publish(ctx context.T, ev *event.T) (err error) {
 	r.okCallbacks.Store(id, func(ok bool, reason string) {
 		gotOk = true
 		if !ok {
-			err = errorf.E("msg: %s", reason)
+			err = &RejectedError{Reason: reason}
 		}
 		cancel()
 	})
@@ -384,11 +385,20 @@ func (r *Client) publish(ctx context.T, ev *event.T) (err error) {
 			return ctx.Err()
 		case <-r.connectionContext.Done():
 			// this is caused when we lose connectivity
+			if !gotOk {
+				return errorf.E("connection to %s closed before OK", r.URL)
+			}
 			return err
 		}
 	}
 }
 
+// RejectedError is returned by Publish when the relay answers with an OK false, and carries
+// the reason it gave.
+type RejectedError struct{ Reason string }
+
+func (e *RejectedError) Error() string { return "msg: " + e.Reason }
+
 // Subscribe sends a "REQ" command to the relay r as in NIP-01.
 // Events are returned through the channel sub.Events.
 // The subscription is closed when context ctx is cancelled ("CLOSE" in NIP-01).
@@ -0,0 +1,112 @@
+// Package wstest provides a relay for testing the clients of relays.
+package wstest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/net/websocket"
+
+	"realy.lol/envelopes"
+	"realy.lol/envelopes/eoseenvelope"
+	"realy.lol/envelopes/eventenvelope"
+	"realy.lol/envelopes/okenvelope"
+	"realy.lol/envelopes/reqenvelope"
+	"realy.lol/event"
+	"realy.lol/filters"
+)
+
+// Relay is a relay that keeps nothing but its Stored events. It sends the events published to
+// it, or the responses to them, to the subscriptions of all of its connections that match
+// them.
+type Relay struct {
+	// Stored are the events sent to the new subscriptions that match them.
+	Stored []*event.T
+	// Respond returns the events that are sent to the subscriptions in place of an event
+	// published to the relay, if it is not nil.
+	Respond func(ev *event.T) []*event.T
+	t       testing.TB
+	sync.Mutex
+	subs map[*websocket.Conn]map[string]*filters.T
+}
+
+// Start serves a Relay that sends the events published to it to the subscriptions that match
+// them, and returns its websocket URL.
+func Start(t testing.TB) string { return (&Relay{}).Start(t) }
+
+// Start serves the relay until the end of a test and returns its websocket URL.
+func (r *Relay) Start(t testing.TB) string {
+	r.t, r.subs = t, make(map[*websocket.Conn]map[string]*filters.T)
+	srv := httptest.NewServer(&websocket.Server{
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler:   r.serve,
+	})
+	t.Cleanup(srv.Close)
+	return strings.Replace(srv.URL, "http", "ws", 1)
+}
+
+func (r *Relay) serve(conn *websocket.Conn) {
+	r.Lock()
+	r.subs[conn] = make(map[string]*filters.T)
+	r.Unlock()
+	defer func() {
+		r.Lock()
+		delete(r.subs, conn)
+		r.Unlock()
+	}()
+	for {
+		var msg []byte
+		if err := websocket.Message.Receive(conn, &msg); err != nil {
+			return
+		}
+		label, rem := envelopes.Identify(msg)
+		switch label {
+		case reqenvelope.L:
+			req := reqenvelope.New()
+			if _, err := req.Unmarshal(rem); err != nil {
+				r.t.Error(err)
+				return
+			}
+			r.Lock()
+			r.subs[conn][req.Subscription.String()] = req.Filters
+			for _, ev := range r.Stored {
+				if req.Filters.Match(ev) {
+					send(conn, req.Subscription.String(), ev)
+				}
+			}
+			r.Unlock()
+			websocket.Message.Send(conn, eoseenvelope.NewFrom(req.Subscription).Marshal(nil))
+		case eventenvelope.L:
+			env := eventenvelope.NewSubmission()
+			if _, err := env.Unmarshal(rem); err != nil {
+				r.t.Error(err)
+				return
+			}
+			websocket.Message.Send(conn, okenvelope.NewFrom(env.T.Id, true).Marshal(nil))
+			evs := []*event.T{env.T}
+			if r.Respond != nil {
+				evs = r.Respond(env.T)
+			}
+			r.Lock()
+			for _, ev := range evs {
+				for c, subs := range r.subs {
+					for id, ff := range subs {
+						if ff.Match(ev) {
+							send(c, id, ev)
+						}
+					}
+				}
+			}
+			r.Unlock()
+		}
+	}
+}
+
+// send sends an event to a subscription of a connection.
+func send(conn *websocket.Conn, id string, ev *event.T) {
+	res, _ := eventenvelope.NewResultWith(id, ev)
+	websocket.Message.Send(conn, res.Marshal(nil))
+}
@@ -0,0 +1,7 @@
+// Package zap validates NIP-57 lightning zaps: kind 9734 zap requests, and kind 9735 zap
+// receipts against the zap request and BOLT11 invoice embedded in them.
+//
+// A receipt is only shown not to be forged if it is signed by the nostrPubkey of the LNURL
+// server of its recipient, which Providers finds from the lightning address in the recipient's
+// profile.
+package zap
@@ -0,0 +1,215 @@
+package zap
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"realy.lol/context"
+	"realy.lol/ec/bech32"
+	"realy.lol/errorf"
+	"realy.lol/event"
+	"realy.lol/hex"
+	"realy.lol/netaddr"
+)
+
+const (
+	// ProviderMaxAge is how long the nostrPubkey of the LNURL server of a recipient is used
+	// before it is fetched again.
+	ProviderMaxAge = time.Hour
+	// ProviderRetryAge is how long a failure to find the nostrPubkey of the LNURL server of a
+	// recipient is remembered before it is tried again.
+	ProviderRetryAge = 5 * time.Minute
+	// MaxProviders is how many recipients the nostrPubkeys of the LNURL servers of are
+	// remembered.
+	MaxProviders = 10000
+)
+
+// Client is the HTTP client the LNURL servers of recipients are queried with, which refuses to
+// connect to addresses that are not public.
+var Client = &http.Client{Timeout: 10 * time.Second, Transport: &http.Transport{
+	DialContext: (&net.Dialer{Timeout: 5 * time.Second,
+		Control: netaddr.Control}).DialContext,
+}}
+
+// LNURL returns the URL of the LNURL pay endpoint in the content of a kind 0 profile, from its
+// lud16 lightning address or its lud06 bech32 encoded LNURL.
+func LNURL(content []byte) (u string, err error) {
+	var p struct {
+		LUD06 string `json:"lud06"`
+		LUD16 string `json:"lud16"`
+	}
+	if err = json.Unmarshal(content, &p); err != nil {
+		err = errorf.E("invalid profile: %v", err)
+		return
+	}
+	if address := strings.TrimSpace(p.LUD16); address != "" {
+		name, domain, ok := strings.Cut(address, "@")
+		if !ok || name == "" || domain == "" || strings.ContainsAny(domain, "/?#@") {
+			err = errorf.E("invalid lightning address '%s'", address)
+			return
+		}
+		return "https://" + domain + "/.well-known/lnurlp/" + name, nil
+	}
+	if lnurl := strings.TrimSpace(p.LUD06); lnurl != "" {
+		var hrp, data []byte
+		if hrp, data, err = bech32.DecodeNoLimit([]byte(lnurl)); err != nil {
+			err = errorf.E("invalid lnurl: %v", err)
+			return
+		}
+		if !bytes.Equal(hrp, []byte("lnurl")) {
+			err = errorf.E("invalid lnurl: prefix is '%s'", hrp)
+			return
+		}
+		if data, err = bech32.ConvertBits(data, 5, 8, false); err != nil {
+			err = errorf.E("invalid lnurl: %v", err)
+			return
+		}
+		return string(data), nil
+	}
+	err = errorf.E("profile has no lightning address")
+	return
+}
+
+// FetchProvider returns the nostrPubkey of an LNURL pay endpoint, which is the key that signs
+// the zap receipts of the payments made to it.
+func FetchProvider(c context.T, client *http.Client, u string) (pubkey []byte, err error) {
+	var req *http.Request
+	if req, err = http.NewRequestWithContext(c, http.MethodGet, u, nil); err != nil {
+		return
+	}
+	var res *http.Response
+	if res, err = client.Do(req); err != nil {
+		return
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.StatusCode != http.StatusOK {
+		err = errorf.E("lnurl request failed: %s", res.Status)
+		return
+	}
+	var p struct {
+		AllowsNostr bool   `json:"allowsNostr"`
+		NostrPubkey string `json:"nostrPubkey"`
+	}
+	if err = json.NewDecoder(io.LimitReader(res.Body, 1<<16)).Decode(&p); err != nil {
+		err = errorf.E("invalid lnurl response: %v", err)
+		return
+	}
+	if !p.AllowsNostr {
+		err = errorf.E("lnurl server does not allow zaps")
+		return
+	}
+	if pubkey, err = hex.Dec(p.NostrPubkey); err != nil || len(pubkey) != 32 {
+		err = errorf.E("lnurl server has an invalid nostrPubkey '%s'", p.NostrPubkey)
+	}
+	return
+}
+
+// Providers finds the nostrPubkeys of the LNURL servers of the recipients of zaps, from the
+// lightning addresses in their profiles, and remembers them for the ProviderMaxAge.
+type Providers struct {
+	// Profile returns the content of the latest kind 0 profile of a pubkey, or nil if it has
+	// none.
+	Profile func(c context.T, pubkey []byte) (content []byte, err error)
+	// Client is the HTTP client used to query the LNURL servers, the default is Client.
+	Client *http.Client
+	mx     sync.Mutex
+	cache  map[string]*provider
+}
+
+// provider is the remembered result of finding the nostrPubkey of the LNURL server of a
+// recipient.
+type provider struct {
+	pubkey []byte
+	err    error
+	at     time.Time
+}
+
+// fresh returns true if a result may still be used, which is for the ProviderMaxAge if it
+// was found and the ProviderRetryAge if it was not.
+func (pr *provider) fresh() bool {
+	if pr.err != nil {
+		return time.Since(pr.at) < ProviderRetryAge
+	}
+	return time.Since(pr.at) < ProviderMaxAge
+}
+
+// Get returns the nostrPubkey of the LNURL server of a recipient.
+func (p *Providers) Get(c context.T, recipient []byte) (pubkey []byte, err error) {
+	key := hex.Enc(recipient)
+	p.mx.Lock()
+	pr, ok := p.cache[key]
+	p.mx.Unlock()
+	if ok && pr.fresh() {
+		return pr.pubkey, pr.err
+	}
+	pr = &provider{at: time.Now()}
+	pr.pubkey, pr.err = p.fetch(c, recipient)
+	if c.Err() != nil {
+		// a request that was canceled says nothing about the recipient.
+		return pr.pubkey, pr.err
+	}
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	if p.cache == nil {
+		p.cache = make(map[string]*provider)
+	}
+	if len(p.cache) >= MaxProviders {
+		for k, old := range p.cache {
+			if !old.fresh() || len(p.cache) >= MaxProviders {
+				delete(p.cache, k)
+			}
+		}
+	}
+	p.cache[key] = pr
+	return pr.pubkey, pr.err
+}
+
+// fetch finds the nostrPubkey of the LNURL server in the profile of a recipient.
+func (p *Providers) fetch(c context.T, recipient []byte) (pubkey []byte, err error) {
+	var content []byte
+	if content, err = p.Profile(c, recipient); err != nil {
+		return
+	}
+	if content == nil {
+		err = errorf.E("recipient has no profile")
+		return
+	}
+	var u string
+	if u, err = LNURL(content); err != nil {
+		return
+	}
+	client := p.Client
+	if client == nil {
+		client = Client
+	}
+	return FetchProvider(c, client, u)
+}
+
+// Validate checks a zap receipt with ValidateReceipt, and that it is signed by the
+// nostrPubkey of the LNURL server of its recipient, so that only the server that was paid can
+// make a receipt.
+func (p *Providers) Validate(c context.T, ev *event.T) (r *Receipt, err error) {
+	if r, err = ValidateReceipt(ev, nil); err != nil {
+		return
+	}
+	var recipient, provider []byte
+	if recipient, err = hex.Dec(r.Recipient()); err != nil {
+		err = invalid("zap receipt has an invalid recipient")
+		return
+	}
+	var e error
+	if provider, e = p.Get(c, recipient); e != nil {
+		err = invalid("cannot find the LNURL provider of the zap recipient: %v", e)
+		return
+	}
+	if !bytes.Equal(ev.Pubkey, provider) {
+		err = invalid("zap receipt is not signed by the LNURL provider of the recipient")
+	}
+	return
+}
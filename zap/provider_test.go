@@ -0,0 +1,96 @@
+package zap
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"realy.lol/ec"
+	"realy.lol/ec/bech32"
+	"realy.lol/event"
+	"realy.lol/hex"
+	"realy.lol/kind"
+	"realy.lol/p256k"
+	"realy.lol/tag"
+	"realy.lol/tags"
+	"realy.lol/timestamp"
+)
+
+func TestLNURL(t *testing.T) {
+	u, err := LNURL([]byte(`{"lud16":"alice@example.com"}`))
+	if err != nil || u != "https://example.com/.well-known/lnurlp/alice" {
+		t.Fatalf("lud16 resolved to %s: %v", u, err)
+	}
+	data, _ := bech32.ConvertBits([]byte("https://example.com/lnurlp/bob"), 8, 5, true)
+	lnurl, _ := bech32.Encode([]byte("lnurl"), data)
+	if u, err = LNURL([]byte(`{"lud06":"` + string(lnurl) + `"}`)); err != nil ||
+		u != "https://example.com/lnurlp/bob" {
+		t.Fatalf("lud06 resolved to %s: %v", u, err)
+	}
+	for _, content := range []string{`{}`, `{"lud16":"example.com"}`,
+		`{"lud16":"alice@example.com/x"}`, `{"lud06":"npub1xyz"}`} {
+		if _, err = LNURL([]byte(content)); err == nil {
+			t.Fatalf("invalid profile %s accepted", content)
+		}
+	}
+}
+
+func TestProviders(t *testing.T) {
+	sender, provider, forger, recipient := &p256k.Signer{}, &p256k.Signer{},
+		&p256k.Signer{}, &p256k.Signer{}
+	for _, s := range []*p256k.Signer{sender, provider, forger, recipient} {
+		if err := s.Generate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	node, err := btcec.NewSecretKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fetched int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetched++
+		_ = json.NewEncoder(w).Encode(map[string]any{"allowsNostr": true,
+			"nostrPubkey": hex.Enc(provider.Pub())})
+	}))
+	defer srv.Close()
+	data, _ := bech32.ConvertBits([]byte(srv.URL), 8, 5, true)
+	lnurl, _ := bech32.Encode([]byte("lnurl"), data)
+	p := &Providers{Client: srv.Client(),
+		Profile: func(c context.Context, pubkey []byte) ([]byte, error) {
+			if string(pubkey) != string(recipient.Pub()) {
+				return nil, nil
+			}
+			return []byte(`{"lud06":"` + string(lnurl) + `"}`), nil
+		}}
+	zapRequest := func(to []byte) *event.T {
+		req := &event.T{CreatedAt: timestamp.Now(), Kind: kind.ZapRequest, Tags: tags.New(
+			tag.New("relays", "wss://relay.example.com"), tag.New("p", hex.Enc(to)))}
+		if err = req.Sign(sender); err != nil {
+			t.Fatal(err)
+		}
+		return req
+	}
+	req := zapRequest(recipient.Pub())
+	c := context.Background()
+	if _, err = p.Validate(c, receipt(t, provider, node, req, 1000,
+		req.Serialize())); err != nil {
+		t.Fatal(err)
+	}
+	// a receipt signed by anyone else is forged, however valid its invoice.
+	if _, err = p.Validate(c, receipt(t, forger, node, req, 1000,
+		req.Serialize())); err == nil {
+		t.Fatal("forged receipt accepted")
+	}
+	if fetched != 1 {
+		t.Fatalf("provider fetched %d times", fetched)
+	}
+	// a recipient without a lightning address in a stored profile cannot be zapped.
+	other := zapRequest(sender.Pub())
+	if _, err = p.Validate(c, receipt(t, provider, node, other, 1000,
+		other.Serialize())); err == nil {
+		t.Fatal("receipt for a recipient without a profile accepted")
+	}
+}
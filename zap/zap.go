@@ -0,0 +1,189 @@
+package zap
+
+import (
+	"bytes"
+	"strconv"
+
+	"realy.lol/bolt11"
+	"realy.lol/errorf"
+	"realy.lol/event"
+	"realy.lol/hex"
+	"realy.lol/kind"
+	"realy.lol/reason"
+	"realy.lol/sha256"
+	"realy.lol/tag"
+	"realy.lol/tag/atag"
+)
+
+// Receipt is a zap receipt that has been checked against its zap request and invoice.
+type Receipt struct {
+	// Event is the kind 9735 zap receipt.
+	Event *event.T
+	// Request is the kind 9734 zap request embedded in the description tag of the receipt.
+	Request *event.T
+	// Invoice is the decoded invoice in the bolt11 tag of the receipt.
+	Invoice *bolt11.Invoice
+}
+
+// Amount returns the amount zapped in millisatoshis.
+func (r *Receipt) Amount() uint64 { return r.Invoice.Amount }
+
+// Sender returns the pubkey of the zap request, which is a random key for anonymous zaps.
+func (r *Receipt) Sender() []byte { return r.Request.Pubkey }
+
+// Recipient returns the hex pubkey of the user that was zapped.
+func (r *Receipt) Recipient() string { return string(values(r.Request, "p")[0]) }
+
+// ValidateRequest returns an error if an event is not a valid zap request: it must be a
+// signed kind 9734 event with one hex p tag, at most one e and P tag, a well formed a tag if
+// it has one, and a whole millisatoshi amount if it has one.
+func ValidateRequest(req *event.T) (err error) {
+	if !req.Kind.Equal(kind.ZapRequest) {
+		err = invalid("zap request has kind %d", req.Kind.K)
+		return
+	}
+	if !bytes.Equal(req.Id, req.GetIDBytes()) {
+		err = invalid("zap request has an incorrect id")
+		return
+	}
+	var valid bool
+	if valid, err = req.Verify(); err != nil || !valid {
+		err = invalid("zap request has an invalid signature")
+		return
+	}
+	p := values(req, "p")
+	if len(p) != 1 {
+		err = invalid("zap request has %d p tags, it must have one", len(p))
+		return
+	}
+	if pk, e := hex.Dec(string(p[0])); e != nil || len(pk) != sha256.Size {
+		err = invalid("zap request p tag has invalid pubkey '%s'", p[0])
+		return
+	}
+	if e := values(req, "e"); len(e) > 1 {
+		err = invalid("zap request has %d e tags, it may have one", len(e))
+		return
+	}
+	if pp := values(req, "P"); len(pp) > 1 {
+		err = invalid("zap request has %d P tags, it may have one", len(pp))
+		return
+	}
+	if a := values(req, "a"); len(a) > 0 {
+		if _, e := (&atag.T{}).Unmarshal(a[0]); e != nil {
+			err = invalid("zap request has an invalid a tag")
+			return
+		}
+	}
+	if a := values(req, "amount"); len(a) > 0 {
+		if _, e := strconv.ParseUint(string(a[0]), 10, 64); e != nil {
+			err = invalid("zap request has an invalid amount '%s'", a[0])
+			return
+		}
+	}
+	return
+}
+
+// ValidateReceipt checks a kind 9735 zap receipt against the zap request in its description
+// tag and the invoice in its bolt11 tag. The invoice must have been signed by its payee and
+// commit to the hash of the description, the amount must be the one requested, the p, e and a
+// tags must be those of the request, and if the receipt has a P tag or a preimage they must be
+// the pubkey of the request and the preimage of the payment hash.
+//
+// If provider is not empty it is the nostrPubkey of the LNURL server of the recipient, which
+// the receipt must be signed by. Checking the signature of the receipt itself is left to the
+// caller.
+func ValidateReceipt(ev *event.T, provider []byte) (r *Receipt, err error) {
+	if !ev.Kind.Equal(kind.Zap) {
+		err = invalid("zap receipt has kind %d", ev.Kind.K)
+		return
+	}
+	if len(provider) > 0 && !bytes.Equal(ev.Pubkey, provider) {
+		err = invalid("zap receipt is not signed by the LNURL provider of the recipient")
+		return
+	}
+	r = &Receipt{Event: ev, Request: &event.T{}}
+	invoice, description := values(ev, "bolt11"), values(ev, "description")
+	if len(invoice) != 1 || len(description) != 1 {
+		err = invalid("zap receipt must have one bolt11 and one description tag")
+		return
+	}
+	var e error
+	if r.Invoice, e = bolt11.Decode(invoice[0]); e != nil {
+		err = invalid("zap receipt has an invalid invoice: %v", e)
+		return
+	}
+	// the description hash commits the payee to the zap request.
+	h := sha256.Sum256(description[0])
+	if !bytes.Equal(r.Invoice.DescriptionHash, h[:]) {
+		err = invalid("zap receipt invoice description hash does not match the zap request")
+		return
+	}
+	// the event decoder may unescape in place, so it gets a copy of the tag.
+	if _, e = r.Request.Unmarshal(append([]byte{}, description[0]...)); e != nil {
+		err = invalid("zap receipt description is not an event: %v", e)
+		return
+	}
+	if err = ValidateRequest(r.Request); err != nil {
+		return
+	}
+	if a := values(r.Request, "amount"); len(a) > 0 && string(a[0]) !=
+		strconv.FormatUint(r.Invoice.Amount, 10) {
+		err = invalid("zap receipt invoice amount %d is not the requested %s msat",
+			r.Invoice.Amount, a[0])
+		return
+	}
+	for _, key := range []string{"p", "e", "a"} {
+		if !equalValues(values(ev, key), values(r.Request, key)) {
+			err = invalid("zap receipt %s tag does not match the zap request", key)
+			return
+		}
+	}
+	if pp := values(ev, "P"); len(pp) > 0 &&
+		(len(pp) > 1 || string(pp[0]) != hex.Enc(r.Request.Pubkey)) {
+		err = invalid("zap receipt P tag is not the pubkey of the zap request")
+		return
+	}
+	if pre := values(ev, "preimage"); len(pre) > 0 {
+		var preimage []byte
+		if preimage, e = hex.Dec(string(pre[0])); e != nil {
+			err = invalid("zap receipt has an invalid preimage")
+			return
+		}
+		if ph := sha256.Sum256(preimage); !bytes.Equal(ph[:], r.Invoice.PaymentHash) {
+			err = invalid("zap receipt preimage does not match the invoice payment hash")
+			return
+		}
+	}
+	return
+}
+
+// values returns the values of the tags of an event with a key. Unlike tags.T.GetAll the key
+// must match exactly, so that the p tags do not include the preimage.
+func values(ev *event.T, key string) (v [][]byte) {
+	if ev.Tags == nil {
+		return
+	}
+	for _, t := range ev.Tags.ToSliceOfTags() {
+		if t.Len() >= 2 && string(t.Key()) == key {
+			v = append(v, t.B(tag.Value))
+		}
+	}
+	return
+}
+
+// equalValues returns true if two lists of tag values are the same.
+func equalValues(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func invalid(format string, args ...any) error {
+	return errorf.D(string(reason.Invalid.F(format, args...)))
+}
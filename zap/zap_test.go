@@ -0,0 +1,108 @@
+package zap
+
+import (
+	"testing"
+
+	"realy.lol/bolt11"
+	"realy.lol/ec"
+	"realy.lol/event"
+	"realy.lol/hex"
+	"realy.lol/kind"
+	"realy.lol/p256k"
+	"realy.lol/sha256"
+	"realy.lol/tag"
+	"realy.lol/tags"
+	"realy.lol/timestamp"
+)
+
+// receipt makes a zap receipt for a zap request, with an invoice for an amount that commits
+// to a description, and extra tags.
+func receipt(t *testing.T, provider *p256k.Signer, node *btcec.SecretKey, req *event.T,
+	amount uint64, description []byte, extra ...*tag.T) *event.T {
+	preimage := []byte("preimage of the payment hash....")
+	ph, dh := sha256.Sum256(preimage), sha256.Sum256(description)
+	invoice, err := bolt11.Encode(&bolt11.Invoice{Amount: amount, Timestamp: 1700000000,
+		PaymentHash: ph[:], DescriptionHash: dh[:]}, node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev := &event.T{CreatedAt: timestamp.Now(), Kind: kind.Zap, Tags: tags.New(
+		tag.New("p", string(values(req, "p")[0])),
+		tag.New("bolt11", string(invoice)),
+		tag.New("description", string(description)),
+		tag.New("preimage", hex.Enc(preimage)),
+	)}
+	ev.Tags.AppendTags(extra...)
+	if err = ev.Sign(provider); err != nil {
+		t.Fatal(err)
+	}
+	return ev
+}
+
+func TestValidateReceipt(t *testing.T) {
+	sender, provider, recipient := &p256k.Signer{}, &p256k.Signer{}, &p256k.Signer{}
+	for _, s := range []*p256k.Signer{sender, provider, recipient} {
+		if err := s.Generate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	node, err := btcec.NewSecretKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := &event.T{CreatedAt: timestamp.Now(), Kind: kind.ZapRequest,
+		Content: []byte("great post"), Tags: tags.New(
+			tag.New("relays", "wss://relay.example.com"),
+			tag.New("amount", "21000"),
+			tag.New("p", hex.Enc(recipient.Pub())),
+		)}
+	if err = req.Sign(sender); err != nil {
+		t.Fatal(err)
+	}
+	if err = ValidateRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	description := req.Serialize()
+	ev := receipt(t, provider, node, req, 21000, description,
+		tag.New("P", hex.Enc(sender.Pub())))
+	r, err := ValidateReceipt(ev, provider.Pub())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Amount() != 21000 || string(r.Sender()) != string(sender.Pub()) ||
+		r.Recipient() != hex.Enc(recipient.Pub()) {
+		t.Fatalf("receipt %d %0x %s", r.Amount(), r.Sender(), r.Recipient())
+	}
+	if _, err = ValidateReceipt(ev, recipient.Pub()); err == nil {
+		t.Fatal("receipt from another provider accepted")
+	}
+	// a zap receipt for less than was requested
+	if _, err = ValidateReceipt(receipt(t, provider, node, req, 1000, description),
+		nil); err == nil {
+		t.Fatal("receipt with the wrong amount accepted")
+	}
+	// an invoice that does not commit to the zap request
+	forged := receipt(t, provider, node, req, 21000, description)
+	forged.Tags = tags.New(forged.Tags.ToSliceOfTags()[0], forged.Tags.ToSliceOfTags()[1],
+		tag.New("description", string(description[:len(description)-1])+" }"))
+	if _, err = ValidateReceipt(forged, nil); err == nil {
+		t.Fatal("receipt with a description not committed to accepted")
+	}
+	// a P tag that is not the sender
+	if _, err = ValidateReceipt(receipt(t, provider, node, req, 21000, description,
+		tag.New("P", hex.Enc(provider.Pub()))), nil); err == nil {
+		t.Fatal("receipt with the wrong sender accepted")
+	}
+	// an e tag that the request did not have
+	if _, err = ValidateReceipt(receipt(t, provider, node, req, 21000, description,
+		tag.New("e", hex.Enc(req.Id))), nil); err == nil {
+		t.Fatal("receipt for another event accepted")
+	}
+	// a zap request that has been tampered with
+	req.Content = []byte("tampered")
+	tampered := req.Serialize()
+	if _, err = ValidateReceipt(receipt(t, provider, node, req, 21000, tampered),
+		nil); err == nil {
+		t.Fatal("receipt with a tampered zap request accepted")
+	}
+}